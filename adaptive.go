@@ -0,0 +1,73 @@
+package main
+
+import "time"
+
+// adaptiveWindow 一个路径的自适应去抖动窗口
+type adaptiveWindow struct {
+	window time.Duration
+	last   time.Time // 最近一次事件的时间
+}
+
+// adaptiveDebounce 自适应去抖动的配置和各路径的当前窗口，受 Debouncer.mu 保护
+type adaptiveDebounce struct {
+	min, max time.Duration
+	paths    map[string]*adaptiveWindow
+}
+
+// WithAdaptiveDebounce 启用自适应去抖动：每个路径的窗口从 min 开始，窗口内又有新事件（事件频繁）时翻倍，
+// 最大到 max；之后每安静一个窗口的时间就减半，回落到 min。持续写入的大文件、频繁刷新的日志
+// 得到较长的窗口，偶尔变化的文件仍按 min 及时分发，不需要为所有路径调一个全局时长
+func WithAdaptiveDebounce(min, max time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.debouncer = NewDebouncer(min)
+		fw.debouncer.adaptive = newAdaptiveDebounce(min, max)
+	}
+}
+
+// newAdaptiveDebounce 创建自适应窗口配置
+func newAdaptiveDebounce(min, max time.Duration) *adaptiveDebounce {
+	return &adaptiveDebounce{min: min, max: max, paths: make(map[string]*adaptiveWindow)}
+}
+
+// SetAdaptive 启用自适应窗口（见 WithAdaptiveDebounce），min 不为正或 max 不大于 min 时关闭
+func (d *Debouncer) SetAdaptive(min, max time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if min <= 0 || max <= min {
+		d.adaptive = nil
+		return
+	}
+	d.duration = min
+	d.adaptive = newAdaptiveDebounce(min, max)
+}
+
+// window 根据事件间隔更新并返回路径的窗口，调用方持有 d.mu
+func (a *adaptiveDebounce) window(path string, now time.Time) time.Duration {
+	w := a.paths[path]
+	if w == nil {
+		w = &adaptiveWindow{window: a.min}
+		a.paths[path] = w
+	} else if gap := now.Sub(w.last); gap < w.window {
+		w.window = min(w.window*2, a.max)
+	} else {
+		// 每安静一个窗口减半一次
+		for gap >= w.window && w.window > a.min {
+			gap -= w.window
+			w.window = max(w.window/2, a.min)
+		}
+	}
+	w.last = now
+	return w.window
+}
+
+// prune 清理已回落到 min 或安静超过 2*max（下次事件时必然回落）的路径，pending 为仍挂起定时器的路径，调用方持有 d.mu
+func (a *adaptiveDebounce) prune(pending map[string]*debounceEntry, now time.Time) {
+	for path, w := range a.paths {
+		if _, ok := pending[path]; ok {
+			continue
+		}
+		if w.window == a.min || now.Sub(w.last) >= 2*a.max {
+			delete(a.paths, path)
+		}
+	}
+}
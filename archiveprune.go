@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveCompactGrace 小时结束后多久才合并该小时的分区，确保写入方已刷新并切换到新文件
+const archiveCompactGrace = time.Hour
+
+// ArchiveRetention 事件归档的保留策略，零值表示不清理
+type ArchiveRetention struct {
+	MaxAge  time.Duration // 删除结束时间早于该时长的小时分区
+	MaxSize int64         // 归档总大小超出时从最早的小时分区开始删除（不删除当前小时）
+}
+
+// PruneResult 一次清理和合并的结果
+type PruneResult struct {
+	Removed      int   // 删除的文件数
+	RemovedBytes int64 // 删除的字节数
+	Compacted    int   // 合并的小时分区数
+	Files        int   // 清理后剩余的文件数
+	Bytes        int64 // 清理后的总大小
+}
+
+// archivePartition 一个小时分区
+type archivePartition struct {
+	dir   string
+	hour  time.Time
+	files []string
+	size  int64
+}
+
+// PruneArchive 按保留策略删除事件归档中过期的小时分区，并把已结束的小时内的多个文件（如多次重启产生的）合并为一个；
+// now 为当前时间，当前小时和刚结束不到一小时的分区不会被合并
+func PruneArchive(dir string, r ArchiveRetention, compact bool, now time.Time) (PruneResult, error) {
+	var result PruneResult
+	parts, err := archivePartitions(dir)
+	if err != nil {
+		return result, err
+	}
+	current := now.UTC().Truncate(time.Hour)
+	var total int64
+	for _, p := range parts {
+		total += p.size
+	}
+	remove := func(p *archivePartition) {
+		for _, file := range p.files {
+			if err := os.Remove(file); err == nil {
+				result.Removed++
+			}
+		}
+		result.RemovedBytes += p.size
+		total -= p.size
+		p.files, p.size = nil, 0
+		os.Remove(p.dir)
+		os.Remove(filepath.Dir(p.dir)) // 日期目录，非空时删除失败
+	}
+	// 分区按时间排序，从最早的开始删除
+	for _, p := range parts {
+		expired := r.MaxAge > 0 && now.Sub(p.hour.Add(time.Hour)) > r.MaxAge
+		oversize := r.MaxSize > 0 && total > r.MaxSize && p.hour.Before(current)
+		if expired || oversize {
+			remove(p)
+		}
+	}
+
+	var errs []error
+	for _, p := range parts {
+		if compact && len(p.files) > 1 && now.Sub(p.hour.Add(time.Hour)) >= archiveCompactGrace {
+			if err := compactPartition(p); err != nil {
+				errs = append(errs, err)
+			} else {
+				result.Compacted++
+			}
+		}
+		result.Files += len(p.files)
+		result.Bytes += p.size
+	}
+	return result, errors.Join(errs...)
+}
+
+// archivePartitions 列出 date=YYYY-MM-DD/hour=HH 分区及其中的 Avro 文件，按时间排序
+func archivePartitions(dir string) ([]*archivePartition, error) {
+	dates, err := filepath.Glob(filepath.Join(dir, "date=*", "hour=*"))
+	if err != nil {
+		return nil, err
+	}
+	var parts []*archivePartition
+	for _, d := range dates {
+		date := strings.TrimPrefix(filepath.Base(filepath.Dir(d)), "date=")
+		hour, err := time.Parse("2006-01-02 15", date+" "+strings.TrimPrefix(filepath.Base(d), "hour="))
+		if err != nil {
+			continue // 不是归档写入的目录
+		}
+		p := &archivePartition{dir: d, hour: hour}
+		files, _ := filepath.Glob(filepath.Join(d, "events-*.avro"))
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				p.files = append(p.files, file)
+				p.size += info.Size()
+			}
+		}
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].hour.Before(parts[j].hour) })
+	return parts, nil
+}
+
+// compactPartition 把分区中的文件合并为一个：数据块原样复制（换成新文件的同步标记），写完后删除原文件
+func compactPartition(p *archivePartition) error {
+	var sync [16]byte
+	if _, err := rand.Read(sync[:]); err != nil {
+		return err
+	}
+	name := filepath.Join(p.dir, fmt.Sprintf("events-%s-compacted.avro", p.hour.Format("20060102T15")))
+	tmp, err := os.CreateTemp(p.dir, ".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	var header bytes.Buffer
+	header.WriteString("Obj\x01")
+	avroLong(&header, 2)
+	avroString(&header, "avro.schema")
+	avroString(&header, archiveSchema)
+	avroString(&header, "avro.codec")
+	avroString(&header, "deflate")
+	avroLong(&header, 0)
+	header.Write(sync[:])
+	w.Write(header.Bytes())
+	for _, file := range p.files {
+		if err := copyAvroBlocks(w, file, sync); err != nil {
+			tmp.Close()
+			return fmt.Errorf("compact %s: %w", file, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	var size int64
+	for _, file := range p.files {
+		if file != name {
+			os.Remove(file)
+		}
+	}
+	if info, err := os.Stat(name); err == nil {
+		size = info.Size()
+	}
+	p.files, p.size = []string{name}, size
+	return nil
+}
+
+// copyAvroBlocks 把归档文件的数据块以新的同步标记写入 w；只接受本程序写入的 schema 和编码，
+// 文件末尾不完整的数据块（写入时进程崩溃）被丢弃，文件头不完整的文件视为没有事件
+func copyAvroBlocks(w io.Writer, file string, sync [16]byte) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(data)
+	meta, fileSync, err := readAvroHeader(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if meta["avro.schema"] != archiveSchema || meta["avro.codec"] != "deflate" {
+		return fmt.Errorf("unexpected schema or codec")
+	}
+	for r.Len() > 0 {
+		count, err := readAvroLong(r)
+		if err != nil {
+			return nil
+		}
+		size, err := readAvroLong(r)
+		if err != nil || size < 0 || size+16 > int64(r.Len()) {
+			return nil
+		}
+		block := make([]byte, size)
+		io.ReadFull(r, block)
+		var marker [16]byte
+		io.ReadFull(r, marker[:])
+		if marker != fileSync {
+			return fmt.Errorf("corrupt block")
+		}
+		var out bytes.Buffer
+		avroLong(&out, count)
+		avroLong(&out, size)
+		out.Write(block)
+		out.Write(sync[:])
+		if _, err := w.Write(out.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAvroHeader 读取 Avro 容器文件头，返回元数据和同步标记
+func readAvroHeader(r *bytes.Reader) (map[string]string, [16]byte, error) {
+	var sync [16]byte
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, sync, err
+	}
+	if string(magic) != "Obj\x01" {
+		return nil, sync, fmt.Errorf("not an Avro container file")
+	}
+	meta := make(map[string]string)
+	for {
+		n, err := readAvroLong(r)
+		if err != nil {
+			return nil, sync, err
+		}
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			n = -n
+			if _, err := readAvroLong(r); err != nil { // 块的字节数
+				return nil, sync, err
+			}
+		}
+		for ; n > 0; n-- {
+			k, err := readAvroBytes(r)
+			if err != nil {
+				return nil, sync, err
+			}
+			v, err := readAvroBytes(r)
+			if err != nil {
+				return nil, sync, err
+			}
+			meta[string(k)] = string(v)
+		}
+	}
+	_, err := io.ReadFull(r, sync[:])
+	return meta, sync, err
+}
+
+// readAvroLong 读取 Avro 二进制编码的 long
+func readAvroLong(r io.ByteReader) (int64, error) {
+	var u uint64
+	for shift := 0; shift < 64; shift += 7 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		u |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid varint")
+}
+
+// readAvroBytes 读取 Avro 二进制编码的 bytes/string
+func readAvroBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readAvroLong(r)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	if n > int64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// logPrune 记录清理结果
+func logPrune(dir string, res PruneResult) {
+	if res.Removed > 0 || res.Compacted > 0 {
+		logf("Event archive %s: removed %d file(s) (%s), compacted %d hour(s); %d file(s), %s left",
+			dir, res.Removed, formatBytes(res.RemovedBytes), res.Compacted, res.Files, formatBytes(res.Bytes))
+	}
+}
+
+// runPrune prune 子命令：按保留策略清理事件归档并合并已结束小时内的文件
+func runPrune(args []string) int {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	maxAge := fs.String("max-age", "", "remove hours older than this, e.g. 90d")
+	maxSize := fs.String("max-size", "", "remove the oldest hours until the archive fits in this size, e.g. 10G")
+	noCompact := fs.Bool("no-compact", false, "do not merge the files of each finished hour into one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s prune [flags] archive-dir\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitConfig
+	}
+
+	var r ArchiveRetention
+	var err error
+	if *maxAge != "" {
+		if r.MaxAge, err = ParseDuration(*maxAge); err != nil || r.MaxAge <= 0 {
+			return exitWith(ExitConfig, "invalid --max-age %q", *maxAge)
+		}
+	}
+	if *maxSize != "" {
+		if r.MaxSize, err = ParseSize(*maxSize); err != nil || r.MaxSize <= 0 {
+			return exitWith(ExitConfig, "invalid --max-size %q", *maxSize)
+		}
+	}
+	dir := fs.Arg(0)
+	if _, err := os.Stat(dir); err != nil {
+		return exitErr(classifyWatchError(dir, err), "%v", err)
+	}
+	res, err := PruneArchive(dir, r, !*noCompact, time.Now())
+	fmt.Printf("removed %d file(s) (%s), compacted %d hour(s); %d file(s), %s left\n",
+		res.Removed, formatBytes(res.RemovedBytes), res.Compacted, res.Files, formatBytes(res.Bytes))
+	if err != nil {
+		return exitWith(ExitFailure, "prune %s: %v", dir, err)
+	}
+	return ExitOK
+}
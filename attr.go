@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileAttrs 文件属性快照
+type fileAttrs struct {
+	mode    os.FileMode
+	uid     int // 不支持的平台为 -1
+	gid     int
+	modTime time.Time
+}
+
+// AttrChange CHMOD 事件中实际发生变化的属性（新旧值）
+type AttrChange struct {
+	OldMode, NewMode       os.FileMode
+	OldUID, NewUID         int // 不支持所有者信息的平台为 -1
+	OldGID, NewGID         int
+	OldModTime, NewModTime time.Time
+}
+
+// specialBits setuid/setgid/sticky 等特殊权限位
+const specialBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// PermissionsChanged 权限位（含特殊权限位）是否变化
+func (c AttrChange) PermissionsChanged() bool {
+	return c.OldMode.Perm() != c.NewMode.Perm() || c.OldMode&specialBits != c.NewMode&specialBits
+}
+
+// OwnerChanged 所有者或属组是否变化
+func (c AttrChange) OwnerChanged() bool {
+	return c.OldUID != c.NewUID || c.OldGID != c.NewGID
+}
+
+// TimesChanged 修改时间是否变化（如 touch）
+func (c AttrChange) TimesChanged() bool {
+	return !c.OldModTime.Equal(c.NewModTime)
+}
+
+// String 返回变化内容的简短描述
+func (c AttrChange) String() string {
+	var parts []string
+	if c.PermissionsChanged() {
+		parts = append(parts, fmt.Sprintf("mode %s -> %s", c.OldMode, c.NewMode))
+	}
+	if c.OwnerChanged() {
+		parts = append(parts, fmt.Sprintf("owner %d:%d -> %d:%d", c.OldUID, c.OldGID, c.NewUID, c.NewGID))
+	}
+	if c.TimesChanged() {
+		parts = append(parts, fmt.Sprintf("mtime %s -> %s", c.OldModTime.Format(time.RFC3339), c.NewModTime.Format(time.RFC3339)))
+	}
+	if len(parts) == 0 {
+		return "no visible change"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ChmodDetailHandler 可选接口：需要知道 CHMOD 具体改了什么的处理器实现此接口
+// 启用属性跟踪且已知变化前状态时，调用 OnChmodDetail 而不是 OnChmod
+type ChmodDetailHandler interface {
+	OnChmodDetail(path string, change AttrChange) error
+}
+
+// attrTracker 缓存文件属性，在 CHMOD 事件时对比前后差异
+type attrTracker struct {
+	mu      sync.Mutex
+	attrs   map[string]fileAttrs
+	pending map[string]*AttrChange // 已检测到、尚未分发的变化
+}
+
+// WithChmodDetail 跟踪文件权限、所有者和修改时间，CHMOD 事件携带具体变化
+// 以便安全类处理器区分 chown 和 touch
+func WithChmodDetail() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.attrs = &attrTracker{
+			attrs:   make(map[string]fileAttrs),
+			pending: make(map[string]*AttrChange),
+		}
+	}
+}
+
+// snapshot 从 FileInfo 提取属性快照
+func snapshot(info os.FileInfo) fileAttrs {
+	uid, gid := fileOwner(info)
+	return fileAttrs{mode: info.Mode(), uid: uid, gid: gid, modTime: info.ModTime()}
+}
+
+// record 记录属性快照（初始遍历时调用）
+func (t *attrTracker) record(path string, info os.FileInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attrs[filepath.Clean(path)] = snapshot(info)
+}
+
+// observe 在原始事件到达时更新快照，CHMOD 时记录变化（去抖动期间多次变化会合并）
+func (t *attrTracker) observe(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		delete(t.attrs, path)
+		delete(t.pending, path)
+		return
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	cur := snapshot(info)
+	prev, known := t.attrs[path]
+	t.attrs[path] = cur
+
+	if !event.Has(fsnotify.Chmod) || !known {
+		return
+	}
+	if change, ok := t.pending[path]; ok {
+		change.NewMode, change.NewUID, change.NewGID, change.NewModTime = cur.mode, cur.uid, cur.gid, cur.modTime
+		return
+	}
+	t.pending[path] = &AttrChange{
+		OldMode: prev.mode, NewMode: cur.mode,
+		OldUID: prev.uid, NewUID: cur.uid,
+		OldGID: prev.gid, NewGID: cur.gid,
+		OldModTime: prev.modTime, NewModTime: cur.modTime,
+	}
+}
+
+// take 返回并清除路径待分发的属性变化
+func (t *attrTracker) take(path string) *AttrChange {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	change := t.pending[path]
+	delete(t.pending, path)
+	return change
+}
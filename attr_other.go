@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileOwner 当前平台不提供 uid/gid，返回 -1
+func fileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultCommitMessage 自动提交的默认提交信息模板
+const defaultCommitMessage = "auto: {{.Count}} files changed under {{.Dir}}/"
+
+// CommitInfo 提交信息模板可用的字段
+type CommitInfo struct {
+	Count int       // 变更文件数
+	Dir   string    // 监控目录（相对仓库根目录）
+	Files []string  // 变更文件（相对仓库根目录，已排序）
+	Time  time.Time // 提交时间
+}
+
+// AutoCommit 自动提交处理器：目录中的文件变化稳定 settle 时间后，
+// 暂存并提交该目录下的全部改动，适合需要持续版本化的 wiki 类目录
+// 事件同时转发给下一个处理器
+type AutoCommit struct {
+	next      EventHandler
+	dir       string // 要提交的目录（绝对路径）
+	repo      string // 仓库根目录
+	message   *template.Template
+	debouncer *Debouncer
+	dryRun    bool // 演练模式，只记录本应提交的内容
+
+	mu      sync.Mutex
+	changed map[string]bool
+	gitMu   sync.Mutex // 串行执行 git add/commit，避免两次提交争用 .git/index.lock
+}
+
+// NewAutoCommit 创建自动提交处理器，message 为 text/template 模板（字段见 CommitInfo），为空时使用默认模板
+func NewAutoCommit(next EventHandler, dir string, settle time.Duration, message string) (*AutoCommit, error) {
+	if message == "" {
+		message = defaultCommitMessage
+	}
+	tmpl, err := template.New("commit").Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("parse commit message template: %w", err)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "-C", abs, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository: %w", dir, err)
+	}
+
+	return &AutoCommit{
+		next:      next,
+		dir:       abs,
+		repo:      strings.TrimSpace(string(out)),
+		message:   tmpl,
+		debouncer: NewDebouncer(settle),
+		changed:   make(map[string]bool),
+	}, nil
+}
+
+func (h *AutoCommit) OnCreate(path string) error {
+	h.record(path)
+	return h.next.OnCreate(path)
+}
+
+func (h *AutoCommit) OnWrite(path string) error {
+	h.record(path)
+	return h.next.OnWrite(path)
+}
+
+func (h *AutoCommit) OnRemove(path string) error {
+	h.record(path)
+	return h.next.OnRemove(path)
+}
+
+func (h *AutoCommit) OnRename(path string) error {
+	h.record(path)
+	return h.next.OnRename(path)
+}
+
+func (h *AutoCommit) OnChmod(path string) error {
+	return h.next.OnChmod(path)
+}
+
+// Init 转发给下一个处理器的 Initializer
+func (h *AutoCommit) Init(ctx context.Context) error {
+	h.dryRun = IsDryRun(ctx)
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 取消挂起的定时提交，等待正在进行的提交结束后提交剩余改动，再转发给下一个处理器的 Closer
+func (h *AutoCommit) Close() error {
+	h.debouncer.Cancel()
+	h.commit()
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// record 记录变更路径并重新计时
+func (h *AutoCommit) record(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil || !isUnder(h.dir, abs) {
+		return
+	}
+	rel, err := filepath.Rel(h.repo, abs)
+	if err != nil || rel == ".git" || strings.HasPrefix(rel, ".git"+string(filepath.Separator)) {
+		return
+	}
+
+	h.mu.Lock()
+	h.changed[filepath.ToSlash(rel)] = true
+	h.mu.Unlock()
+
+	h.debouncer.Debounce(h.dir, h.commit)
+}
+
+// commit 暂存并提交目录下的全部改动
+func (h *AutoCommit) commit() {
+	h.gitMu.Lock()
+	defer h.gitMu.Unlock()
+
+	h.mu.Lock()
+	files := make([]string, 0, len(h.changed))
+	for f := range h.changed {
+		files = append(files, f)
+	}
+	h.changed = make(map[string]bool)
+	h.mu.Unlock()
+
+	if len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	dirRel, _ := filepath.Rel(h.repo, h.dir)
+	var msg bytes.Buffer
+	info := CommitInfo{Count: len(files), Dir: filepath.ToSlash(dirRel), Files: files, Time: time.Now()}
+	if err := h.message.Execute(&msg, info); err != nil {
+		logf("auto-commit: render message: %v", err)
+		return
+	}
+
+	if h.dryRun {
+		wouldDo("commit %d file(s) in %s: %s", len(files), h.dir, msg.String())
+		return
+	}
+
+	if out, err := exec.Command("git", "-C", h.repo, "add", "-A", "--", h.dir).CombinedOutput(); err != nil {
+		logf("auto-commit: git add: %v: %s", err, out)
+		return
+	}
+	// 没有实际改动（如文件改了又改回去）时跳过提交
+	if err := exec.Command("git", "-C", h.repo, "diff", "--cached", "--quiet", "--", h.dir).Run(); err == nil {
+		return
+	}
+	if out, err := exec.Command("git", "-C", h.repo, "commit", "-q", "-m", msg.String(), "--", h.dir).CombinedOutput(); err != nil {
+		logf("auto-commit: git commit: %v: %s", err, out)
+		return
+	}
+	logf("auto-commit: %s", msg.String())
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// BackfillFilter 回填时选择文件的条件，零值字段不限制；大小、扩展名等条件使用监控器的过滤器（WithSizeRange、WithExtensions）
+type BackfillFilter struct {
+	Patterns []string  // 文件模式（语法同 WithPriority，相对根路径，以 / 开头按绝对路径匹配），匹配任一即可
+	Before   time.Time // 只选修改时间早于此刻的文件
+	After    time.Time // 只选修改时间不早于此刻的文件
+}
+
+// validate 校验文件模式
+func (f BackfillFilter) validate() error {
+	var problems []string
+	for _, pattern := range f.Patterns {
+		if pattern == "" || !validGlob(pattern) {
+			problems = append(problems, fmt.Sprintf("invalid backfill pattern %q", pattern))
+		}
+	}
+	if !f.Before.IsZero() && !f.After.IsZero() && !f.After.Before(f.Before) {
+		problems = append(problems, fmt.Sprintf("backfill time range is empty: newer than %s and older than %s",
+			f.After.Format(time.RFC3339), f.Before.Format(time.RFC3339)))
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// match 判断根路径 root 下的文件是否符合条件
+func (f BackfillFilter) match(fw *FileWatcher, root, path string, info fs.FileInfo) bool {
+	if !f.Before.IsZero() && !info.ModTime().Before(f.Before) {
+		return false
+	}
+	if !f.After.IsZero() && info.ModTime().Before(f.After) {
+		return false
+	}
+	if len(f.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range f.Patterns {
+		target := path
+		if !filepath.IsAbs(pattern) {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				target = rel
+			}
+		}
+		if matchGlob(fw.fold(pattern), fw.fold(target)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backfill 遍历 root 下的现有文件，为符合 filter 的文件生成合成的 CREATE 事件并分发，用于让已有文件的子集重新经过处理器；
+// 事件与实时事件一样经过过滤器、事件补全和处理器（含重试），递归、排除模式、隐藏文件和深度限制与 Watch(root, opts...) 相同。
+// 无法读取的目录以 WatchError 上报后跳过；ctx 取消时停止遍历。返回通过过滤器、交给处理器的文件数
+func (fw *FileWatcher) Backfill(ctx context.Context, root string, filter BackfillFilter, opts ...WatchOption) (int, error) {
+	if err := filter.validate(); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return 0, classifyWatchError(root, err)
+	}
+	r := fw.newRoot(root, opts)
+	if err := validateRoot(r); err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		r.path, r.recursive = filepath.Dir(r.path), false
+	}
+
+	count := 0
+	err = filepath.WalkDir(r.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == r.path {
+				return err
+			}
+			fw.reportError(&WatchError{Path: path, Err: err})
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == r.path {
+			return nil
+		}
+		if d.IsDir() {
+			if !r.recursive || fw.ignored(r, path) || fw.tooDeep(r, path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && path != fw.rootPath(root) {
+			return nil // 单文件根路径只回填该文件
+		}
+		if fw.ignored(r, path) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil || !fi.Mode().IsRegular() || !filter.match(fw, r.path, path, fi) {
+			return nil
+		}
+		if fw.special != nil && !fw.allowSpecial(path, fi) {
+			return nil
+		}
+		now := time.Now()
+		if fw.dispatchEvent(Event{Path: path, Op: fsnotify.Create, FirstSeen: now, LastSeen: now}) {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// parseSince 解析日期（YYYY-MM-DD，本地时间）或距今的时长（如 7d），返回对应的时刻
+func parseSince(s string) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true
+	}
+	if d, err := ParseDuration(s); err == nil {
+		return time.Now().Add(-d), true
+	}
+	return time.Time{}, false
+}
+
+// runBackfill backfill 子命令：把已有文件中符合条件的子集作为 CREATE 事件交给配置的处理器
+func runBackfill(args []string) int {
+	var cfg watchConfig
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	cfg.register(fs)
+	match := fs.String("match", "", "only backfill files matching these comma-separated patterns, relative to each path (e.g. '**/*.csv')")
+	olderThan := fs.String("older-than", "", "only backfill files modified before this date (YYYY-MM-DD) or more than this long ago (e.g. 7d)")
+	newerThan := fs.String("newer-than", "", "only backfill files modified on or after this date (YYYY-MM-DD) or within this long (e.g. 24h)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s backfill [flags] [path...]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	if err := cfg.parse(fs, args); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	if err := SetLanguage(cfg.Lang); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+
+	var filter BackfillFilter
+	if *match != "" {
+		filter.Patterns = strings.Split(*match, ",")
+	}
+	for _, bound := range []struct {
+		name  string
+		value string
+		t     *time.Time
+	}{{"--older-than", *olderThan, &filter.Before}, {"--newer-than", *newerThan, &filter.After}} {
+		if bound.value == "" {
+			continue
+		}
+		t, ok := parseSince(bound.value)
+		if !ok {
+			return exitWith(ExitConfig, "invalid %s %q: want YYYY-MM-DD or a duration like 7d", bound.name, bound.value)
+		}
+		*bound.t = t
+	}
+
+	handler, _, err := cfg.handler()
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	opts, err := cfg.options()
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	watcher, err := NewFileWatcher(handler, opts...)
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+
+	// Ctrl+C 中止回填
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitForSignal()
+		cancel()
+	}()
+
+	paths := cfg.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	for _, path := range paths {
+		n, err := watcher.Backfill(ctx, path, filter)
+		if errors.Is(err, context.Canceled) {
+			logf("backfill %s stopped: %v", path, err)
+			return ExitOK
+		}
+		if err != nil {
+			return exitErr(err, "backfill %s stopped: %v", path, err)
+		}
+		logf("Backfilled %d file(s) from %s", n, path)
+	}
+	return ExitOK
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// nopHandler 不做任何事的处理器，基准测试只衡量监控器自身的开销
+type nopHandler struct{}
+
+func (nopHandler) OnCreate(string) error { return nil }
+func (nopHandler) OnWrite(string) error  { return nil }
+func (nopHandler) OnRemove(string) error { return nil }
+func (nopHandler) OnRename(string) error { return nil }
+func (nopHandler) OnChmod(string) error  { return nil }
+
+// benchPaths 轮流使用的路径，模拟多个文件同时变化
+func benchPaths(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/bench/dir%d/file%d.txt", i%16, i)
+	}
+	return paths
+}
+
+// BenchmarkDebouncer 衡量每个事件重置去抖动定时器的开销（窗口足够长，回调不会触发）
+func BenchmarkDebouncer(b *testing.B) {
+	for _, n := range []int{1, 1024} {
+		b.Run(fmt.Sprintf("paths=%d", n), func(b *testing.B) {
+			d := NewDebouncer(time.Hour)
+			defer d.Cancel()
+			paths := benchPaths(n)
+			callback := func() {}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d.Debounce(paths[i%n], callback)
+			}
+		})
+	}
+}
+
+// BenchmarkDispatch 衡量一个已去抖动的事件经过过滤和路由到达处理器的开销
+func BenchmarkDispatch(b *testing.B) {
+	fw, err := NewFileWatcher(nopHandler{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fw.Stop()
+	paths := benchPaths(1024)
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fw.dispatchEvent(Event{Path: paths[i%len(paths)], Op: fsnotify.Write, FirstSeen: now, LastSeen: now})
+	}
+}
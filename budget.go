@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// watchBudget 后端 watch 数量的上限
+type watchBudget struct {
+	max      int
+	interval time.Duration // 超出预算的目录的轮询间隔
+}
+
+// WithWatchBudget 限制后端 watch 的总数：递归注册的目录树超出预算时按层分配，
+// 从根开始尽可能多的层使用原生 watch，更深的层改为每 pollInterval 轮询一次（对比大小、修改时间和权限，合成 CREATE/WRITE/REMOVE/CHMOD 事件），
+// 并在日志中报告选择的策略；运行中新建的目录超出预算时同样改为轮询。
+// 避免超大目录树耗尽内核的 watch 限制或注册失败，代价是深层变化有最多 pollInterval 的延迟
+func WithWatchBudget(max int, pollInterval time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.budget = &watchBudget{max: max, interval: pollInterval}
+		fw.usePoller(pollInterval)
+	}
+}
+
+// planBudget 统计根路径下各层的目录数，按剩余预算决定从哪一层开始轮询，设置 root.pollDepth 并报告策略
+func (fw *FileWatcher) planBudget(root *watchRoot) {
+	fw.poller.drop(root)
+	root.pollDepth = -1
+	remaining := fw.budget.max - len(fw.backend().WatchList())
+
+	var counts []int
+	filepath.WalkDir(root.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root.path && fw.ignored(root, path) || fw.tooDeep(root, path) {
+			return filepath.SkipDir
+		}
+		n := depth(root.path, path)
+		for len(counts) <= n {
+			counts = append(counts, 0)
+		}
+		counts[n]++
+		return nil
+	})
+	total, native := 0, 0
+	for n, count := range counts {
+		if total+count > remaining {
+			root.pollDepth = n
+			break
+		}
+		total += count
+		native = n + 1
+	}
+	if root.pollDepth < 0 {
+		return
+	}
+	polled := 0
+	for _, count := range counts[root.pollDepth:] {
+		polled += count
+	}
+	logf("Watch budget: %s has %d directories, watching %d level(s) natively (%d watches) and polling %d deeper director(ies) every %s", root.path, total+polled, native, total, polled, fw.budget.interval)
+}
+
+// divert 判断运行中新建的目录是否不添加原生 watch：位于已轮询的目录树内（由轮询覆盖），
+// 或深度达到轮询层、watch 预算已用完（新开一个轮询目录）时返回 true
+func (fw *FileWatcher) divert(root *watchRoot, dir string) bool {
+	if fw.poller == nil {
+		return false
+	}
+	if fw.poller.covered(root, dir) {
+		return true
+	}
+	if fw.budget == nil {
+		return false
+	}
+	if root.pollDepth >= 0 && depth(root.path, dir) >= root.pollDepth || len(fw.backend().WatchList()) >= fw.budget.max {
+		logf("Polling new directory %s (watch budget)", dir)
+		fw.poller.poll(fw, root, dir, fw.budget.interval, false)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// BulkSummary 批量操作期间被汇总的事件
+type BulkSummary struct {
+	Reason  string         `json:"reason"`
+	Started time.Time      `json:"started"`
+	Ended   time.Time      `json:"ended"`
+	Events  int            `json:"events"` // 汇总的原始事件数
+	Ops     map[string]int `json:"ops"`    // 按事件类型计数，如 CREATE: 1200
+	Root    string         `json:"root"`   // 所有变化路径的最近公共祖先，没有事件时为空
+}
+
+// BulkHandler 可选接口：批量操作结束时接收一次汇总，代替逐文件回调
+// 未实现时，分发器对 Root 分发一个 WRITE 事件，提示处理器重新扫描该目录
+type BulkHandler interface {
+	OnBulk(summary BulkSummary) error
+}
+
+// bulkMode 批量模式状态
+type bulkMode struct {
+	mu      sync.Mutex
+	active  *BulkSummary
+	timer   *time.Timer // 超时自动结束
+	started uint64      // 用于识别超时定时器对应的批次
+}
+
+// BeginBulk 进入批量模式：此后的事件不再逐个分发，只做汇总（新目录仍会被加入监控），
+// 直到 EndBulk 或 timeout 到期（0 表示不自动结束）；已处于批量模式时返回 ErrBulkActive
+func (fw *FileWatcher) BeginBulk(reason string, timeout time.Duration) error {
+	if fw.stopped() {
+		return ErrStopped
+	}
+	b := &fw.bulk
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active != nil {
+		return ErrBulkActive
+	}
+	b.active = &BulkSummary{Reason: reason, Started: time.Now(), Ops: make(map[string]int)}
+	b.started++
+	if timeout > 0 {
+		batch := b.started
+		b.timer = time.AfterFunc(timeout, func() {
+			b.mu.Lock()
+			current := b.active != nil && b.started == batch
+			b.mu.Unlock()
+			if current {
+				logf("Bulk mode %q timed out after %v, resuming", reason, timeout)
+				fw.EndBulk()
+			}
+		})
+	}
+	logf("Bulk mode started: %s", reason)
+	return nil
+}
+
+// EndBulk 退出批量模式并分发一次汇总事件；不在批量模式时返回 ErrNotBulk
+func (fw *FileWatcher) EndBulk() (BulkSummary, error) {
+	b := &fw.bulk
+	b.mu.Lock()
+	summary := b.active
+	b.active = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if summary == nil {
+		return BulkSummary{}, ErrNotBulk
+	}
+
+	summary.Ended = time.Now()
+	logf("Bulk mode ended: %s, %d event(s) under %s in %v",
+		summary.Reason, summary.Events, summary.Root, summary.Ended.Sub(summary.Started).Round(time.Millisecond))
+	if summary.Events > 0 && !fw.stopped() {
+		fw.reconcile(*summary)
+	}
+	return *summary, nil
+}
+
+// BulkStatus 返回当前批量模式的汇总，未处于批量模式时返回 false
+func (fw *FileWatcher) BulkStatus() (BulkSummary, bool) {
+	b := &fw.bulk
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active == nil {
+		return BulkSummary{}, false
+	}
+	s := *b.active
+	s.Ops = make(map[string]int, len(b.active.Ops))
+	for k, v := range b.active.Ops {
+		s.Ops[k] = v
+	}
+	return s, true
+}
+
+// absorb 批量模式下汇总事件，返回 false 表示不在批量模式、事件应照常处理
+func (b *bulkMode) absorb(event fsnotify.Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.active
+	if s == nil {
+		return false
+	}
+	s.Events++
+	for _, op := range knownOps {
+		if event.Has(op) {
+			s.Ops[op.String()]++
+		}
+	}
+	if s.Root == "" {
+		s.Root = event.Name
+	} else {
+		s.Root = commonAncestor(s.Root, event.Name)
+	}
+	return true
+}
+
+// reconcile 分发汇总事件：实现 BulkHandler 的处理器接收汇总，
+// 其余处理器（及订阅者）收到对公共祖先的一个 WRITE，提示其重新扫描
+func (fw *FileWatcher) reconcile(summary BulkSummary) {
+	var fallback []*route
+	for _, r := range fw.routes {
+		h, ok := r.handler.(BulkHandler)
+		if !ok {
+			fallback = append(fallback, r)
+			continue
+		}
+		fw.inflight.begin()
+		fw.invoke("BULK", summary.Root, func(string) error {
+			return h.OnBulk(summary)
+		})
+		fw.inflight.end()
+	}
+	if len(fallback) == 0 {
+		return
+	}
+
+	ev := Event{Path: summary.Root, Op: fsnotify.Write, FirstSeen: summary.Started, LastSeen: summary.Ended}
+	fw.inflight.begin()
+	defer fw.inflight.end()
+	defer fw.publish(ev)
+	defer fw.callBindings(ev)
+	for _, r := range fallback {
+		if e, ok := r.accept(fw, ev); ok {
+			fw.dispatchTo(r.handler, e)
+		}
+	}
+}
+
+// commonAncestor 返回两个路径的最近公共祖先
+func commonAncestor(a, b string) string {
+	sep := string(os.PathSeparator)
+	pa := strings.Split(filepath.Clean(a), sep)
+	pb := strings.Split(filepath.Clean(b), sep)
+	n := 0
+	for n < len(pa) && n < len(pb) && pa[n] == pb[n] {
+		n++
+	}
+	if n == len(pa) && n == len(pb) {
+		return a
+	}
+	common := strings.Join(pa[:n], sep)
+	if common == "" && strings.HasPrefix(a, sep) {
+		return sep
+	}
+	return common
+}
+
+// registerBulk 注册批量模式控制端点：
+// POST /bulk/begin?reason=deploy&timeout=10m 进入批量模式，POST /bulk/end 结束并返回汇总，GET /bulk 查看状态
+func registerBulk(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/bulk", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := fw.BulkStatus()
+		writeJSON(w, map[string]any{"active": ok, "summary": s})
+	})
+	mux.HandleFunc("/bulk/begin", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		var timeout time.Duration
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				http.Error(w, "invalid timeout: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			timeout = d
+		}
+		if err := fw.BeginBulk(r.URL.Query().Get("reason"), timeout); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/bulk/end", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		s, err := fw.EndBulk()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, s)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// byteTopN Stats 中返回的目录和文件数
+const byteTopN = 10
+
+// DirBytes 一个目录（不含子目录）或文件写入的字节数
+type DirBytes struct {
+	Path     string `json:"path"`
+	Total    uint64 `json:"total"`     // 运行以来写入的字节数
+	LastHour uint64 `json:"last_hour"` // 最近一小时写入的字节数
+}
+
+// byteCounter 一个路径的字节计数：总数加最近一小时按分钟分桶的环形缓冲区
+type byteCounter struct {
+	total   uint64
+	buckets [60]uint64
+	minutes [60]int64 // 每个桶对应的 Unix 分钟，过期的桶视为 0
+}
+
+// add 记录写入的字节数
+func (c *byteCounter) add(n uint64, now time.Time) {
+	m := now.Unix() / 60
+	i := int(m % 60)
+	if c.minutes[i] != m {
+		c.minutes[i], c.buckets[i] = m, 0
+	}
+	c.buckets[i] += n
+	c.total += n
+}
+
+// lastHour 返回最近一小时的字节数
+func (c *byteCounter) lastHour(now time.Time) uint64 {
+	oldest := now.Unix()/60 - 60
+	var sum uint64
+	for i, n := range c.buckets {
+		if c.minutes[i] > oldest {
+			sum += n
+		}
+	}
+	return sum
+}
+
+// WithByteAccounting 统计写入的字节数：根据 WRITE/CREATE 时文件大小的变化累计（变大记增量，
+// 截断或重写记新的大小），分发时再核对一次大小补上合并掉的写入；结果记在 Event.Bytes，
+// 并按文件和所在目录汇总到 Stats 和 /metrics，回答"这一小时有多少数据落进了接收目录"
+func WithByteAccounting() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.sizeTracker().accounting = true
+	}
+}
+
+// account 按大小变化记一次写入，返回新增的字节数，调用方持有 t.mu
+func (t *sizeTracker) account(path string, prev int64, known bool, size int64) int64 {
+	if !t.accounting {
+		return 0
+	}
+	delta := size
+	if known && size >= prev {
+		delta = size - prev
+	}
+	if delta > 0 {
+		t.written[path] += delta
+	}
+	return delta
+}
+
+// takeBytes 分发前核对文件大小，返回自上次分发以来写入的字节数以及核对时补记的字节数
+func (t *sizeTracker) takeBytes(path string) (written, reconciled int64) {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		prev, known := t.sizes[path]
+		if !known || info.Size() != prev {
+			t.sizes[path] = info.Size()
+			reconciled = t.account(path, prev, known, info.Size())
+		}
+	}
+	written = t.written[path]
+	delete(t.written, path)
+	return written, reconciled
+}
+
+// written 统计写入的字节数
+func (s *statsCollector) written(path string, n int64) {
+	if n <= 0 {
+		return
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += uint64(n)
+	addBytes(s.fileBytes, path, uint64(n), now)
+	addBytes(s.dirBytes, filepath.Dir(path), uint64(n), now)
+}
+
+// addBytes 计入一个路径的字节数，超出计数上限的新路径只计入总数
+func addBytes(m map[string]*byteCounter, key string, n uint64, now time.Time) {
+	c := m[key]
+	if c == nil {
+		if len(m) >= maxStatsPaths {
+			return
+		}
+		c = &byteCounter{}
+		m[key] = c
+	}
+	c.add(n, now)
+}
+
+// topBytes 按最近一小时、再按总数从高到低返回前 n 个路径
+func topBytes(m map[string]*byteCounter, n int, now time.Time) []DirBytes {
+	top := make([]DirBytes, 0, len(m))
+	for path, c := range m {
+		top = append(top, DirBytes{Path: path, Total: c.total, LastHour: c.lastHour(now)})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].LastHour != top[j].LastHour {
+			return top[i].LastHour > top[j].LastHour
+		}
+		if top[i].Total != top[j].Total {
+			return top[i].Total > top[j].Total
+		}
+		return top[i].Path < top[j].Path
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// canonConfig 路径规范化配置
+type canonConfig struct {
+	resolveSymlinks bool
+}
+
+// WithCanonicalPaths 在过滤和分发之前规范化路径：清理 . 和 ..、转换为绝对路径，
+// resolveSymlinks 为 true 时再解析符号链接（路径已不存在时解析其父目录）；
+// 根路径按同样的规则规范化，处理器收到的路径与 Watch 时的写法无关
+func WithCanonicalPaths(resolveSymlinks bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.canon = &canonConfig{resolveSymlinks: resolveSymlinks}
+	}
+}
+
+// WithCaseInsensitive 路径模式（排除、优先通道、处理器过滤、订阅）匹配时忽略大小写，
+// 使 "*.JPG" 与 "photo.jpg" 在 Windows/macOS 等大小写不敏感的文件系统上行为一致
+func WithCaseInsensitive(enabled bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.foldCase = enabled
+	}
+}
+
+// canonical 返回规范化后的路径，未启用 WithCanonicalPaths 时原样返回
+func (fw *FileWatcher) canonical(path string) string {
+	if fw.canon == nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if !fw.canon.resolveSymlinks {
+		return abs
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	// 已删除或移走的路径：解析父目录后拼接文件名
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// fold 启用 WithCaseInsensitive 时转换为小写，用于模式匹配
+func (fw *FileWatcher) fold(s string) string {
+	if !fw.foldCase {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// foldAll 对一组模式执行 fold
+func (fw *FileWatcher) foldAll(patterns []string) []string {
+	if !fw.foldCase || len(patterns) == 0 {
+		return patterns
+	}
+	folded := make([]string, len(patterns))
+	for i, p := range patterns {
+		folded[i] = strings.ToLower(p)
+	}
+	return folded
+}
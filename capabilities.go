@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+)
+
+// Capabilities 当前平台和后端支持的能力，库的使用者可据此调整逻辑，而不必依赖构建标签
+type Capabilities struct {
+	Backend         string `json:"backend"`          // 底层机制：inotify、kqueue、ReadDirectoryChangesW、FEN
+	NativeRecursion bool   `json:"native_recursion"` // 后端能否一次监控整棵目录树；不能时递归监控逐个目录添加 watch，受系统 watch 数量限制
+	CloseWrite      bool   `json:"close_write"`      // 能否报告"写入后关闭"，不能时用去抖动或上传完成检测判断写完
+	MoveCorrelation bool   `json:"move_correlation"` // 能否把重命名的新旧路径关联起来（见 WithInodeTracking）
+	Xattr           bool   `json:"xattr"`            // 能否读取扩展属性（见 WithXattrMonitoring）
+	Ownership       bool   `json:"ownership"`        // FileInfo 是否提供所有者，CHMOD 详情能否区分 chown
+	Inodes          bool   `json:"inodes"`           // FileInfo 是否提供 inode，能否识别硬链接
+	Sparse          bool   `json:"sparse"`           // 能否识别稀疏文件（见 WithSpecialFiles）
+	Attribution     bool   `json:"attribution"`      // 能否知道是哪个进程做的修改
+}
+
+// Capabilities 返回当前平台和后端支持的能力；MoveCorrelation 还取决于是否启用了 inode 跟踪
+func (fw *FileWatcher) Capabilities() Capabilities {
+	info, err := os.Stat(os.TempDir())
+	c := Capabilities{Backend: backendName()}
+	if err == nil {
+		_, _, c.Inodes = fileID(info)
+		uid, _ := fileOwner(info)
+		c.Ownership = uid >= 0
+	}
+	_, err = readXattrs(os.TempDir())
+	c.Xattr = !errors.Is(err, errXattrUnsupported)
+	c.Sparse = c.Inodes // 与 inode 一样来自 Unix 的 stat 结构
+	c.MoveCorrelation = c.Inodes && fw.inodes != nil
+	// fsnotify 不提供递归 watch、IN_CLOSE_WRITE 和修改者信息
+	return c
+}
+
+// backendName 返回 fsnotify 在当前平台使用的机制
+func backendName() string {
+	switch runtime.GOOS {
+	case "linux", "android":
+		return "inotify"
+	case "darwin", "ios", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return "kqueue"
+	case "windows":
+		return "ReadDirectoryChangesW"
+	case "solaris", "illumos":
+		return "FEN"
+	}
+	return "unsupported"
+}
+
+// registerCapabilities 注册 /capabilities 端点
+func registerCapabilities(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/capabilities", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, fw.Capabilities())
+	})
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"watchdogdemo/handlers"
+)
+
+// 检查点文件中的记录类型
+const (
+	checkpointBegin = "begin" // 开始处理一个事件
+	checkpointDone  = "done"  // 事件的某个动作执行成功
+	checkpointEnd   = "end"   // 事件处理结束，不再需要记录
+)
+
+// checkpointEntry 检查点文件中的一行（JSON Lines）
+type checkpointEntry struct {
+	Type   string    `json:"type"`
+	ID     string    `json:"id"`
+	Path   string    `json:"path,omitempty"`
+	Action string    `json:"action,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// checkpointEvent 一个未结束的事件
+type checkpointEvent struct {
+	path string
+	done map[string]bool // 已完成的动作
+}
+
+// Checkpoints 动作检查点：持久化记录哪些 (事件 ID, 动作) 已执行成功，崩溃重启后重试未完成的动作时跳过已完成的；
+// 命令成功与写入检查点之间崩溃时动作仍会重跑，因此动作本身最好是幂等的。
+// 文件以 JSON Lines 追加写入（仅所有者可读写），打开时丢弃已结束的事件并压缩
+type Checkpoints struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	sealer *handlers.Sealer
+	events map[string]*checkpointEvent // 事件 ID -> 事件
+	byPath map[string]string           // 路径 -> 未结束事件的 ID
+	seq    int
+}
+
+// OpenCheckpoints 打开（不存在时创建）检查点文件；sealer 非 nil 时每行加密存放，已有的明文记录在打开时重写为密文
+func OpenCheckpoints(path string, sealer *handlers.Sealer) (*Checkpoints, error) {
+	c := &Checkpoints{path: path, sealer: sealer, events: make(map[string]*checkpointEvent), byPath: make(map[string]string)}
+	lines, plaintext, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	if lines > c.size() || plaintext > 0 {
+		if err := c.compact(); err != nil {
+			return nil, err
+		}
+	}
+	c.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load 重放已有记录，返回行数和设置了密钥时读到的明文行数
+func (c *Checkpoints) load() (lines, plaintext int, err error) {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	plaintext, err = readSealedLines(f, c.sealer, func(line []byte) {
+		var e checkpointEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// 崩溃时可能留下不完整的最后一行
+			return
+		}
+		c.replay(e)
+		lines++
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("read checkpoints %s: %w", c.path, err)
+	}
+	return lines, plaintext, nil
+}
+
+// replay 把一条记录应用到内存状态
+func (c *Checkpoints) replay(e checkpointEntry) {
+	switch e.Type {
+	case checkpointBegin:
+		c.events[e.ID] = &checkpointEvent{path: e.Path, done: make(map[string]bool)}
+		c.byPath[e.Path] = e.ID
+	case checkpointDone:
+		if ev := c.events[e.ID]; ev != nil {
+			ev.done[e.Action] = true
+		}
+	case checkpointEnd:
+		if ev := c.events[e.ID]; ev != nil {
+			if c.byPath[ev.path] == e.ID {
+				delete(c.byPath, ev.path)
+			}
+			delete(c.events, e.ID)
+		}
+	}
+}
+
+// size 压缩后需要的记录数
+func (c *Checkpoints) size() int {
+	n := len(c.events)
+	for _, ev := range c.events {
+		n += len(ev.done)
+	}
+	return n
+}
+
+// compact 重写检查点文件，只保留未结束的事件
+func (c *Checkpoints) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(c.sealer.NewWriter(w))
+	now := time.Now()
+	for id, ev := range c.events {
+		enc.Encode(checkpointEntry{Type: checkpointBegin, ID: id, Path: ev.path, Time: now})
+		for action := range ev.done {
+			enc.Encode(checkpointEntry{Type: checkpointDone, ID: id, Action: action, Time: now})
+		}
+	}
+	if err := errors.Join(w.Flush(), tmp.Sync(), tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// write 追加一条记录并落盘，调用方持有锁
+func (c *Checkpoints) write(e checkpointEntry) error {
+	e.Time = time.Now()
+	if err := json.NewEncoder(c.sealer.NewWriter(c.f)).Encode(e); err != nil {
+		return err
+	}
+	return c.f.Sync()
+}
+
+// Begin 返回路径上未结束事件的 ID（崩溃前开始处理的事件沿用原 ID），没有时开始一个新事件
+func (c *Checkpoints) Begin(path string) (string, error) {
+	path = absPath(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.byPath[path]; ok {
+		return id, nil
+	}
+	c.seq++
+	e := checkpointEntry{Type: checkpointBegin, ID: fmt.Sprintf("%x-%d", time.Now().UnixNano(), c.seq), Path: path}
+	if err := c.write(e); err != nil {
+		return "", err
+	}
+	c.replay(e)
+	return e.ID, nil
+}
+
+// Completed 判断事件的动作是否已执行成功
+func (c *Checkpoints) Completed(id, action string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ev := c.events[id]
+	return ev != nil && ev.done[action]
+}
+
+// Do 执行事件的动作：已完成时跳过，fn 成功后记录检查点
+func (c *Checkpoints) Do(id, action string, fn func() error) error {
+	if c.Completed(id, action) {
+		logf("action %q for event %s already completed, skipping", action, id)
+		return nil
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := checkpointEntry{Type: checkpointDone, ID: id, Action: action}
+	if err := c.write(e); err != nil {
+		return fmt.Errorf("record checkpoint: %w", err)
+	}
+	c.replay(e)
+	return nil
+}
+
+// End 结束事件，此后同一路径上的新事件会重新执行所有动作
+func (c *Checkpoints) End(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events[id] == nil {
+		return nil
+	}
+	e := checkpointEntry{Type: checkpointEnd, ID: id}
+	c.replay(e)
+	return c.write(e)
+}
+
+// Close 关闭检查点文件
+func (c *Checkpoints) Close() error {
+	return c.f.Close()
+}
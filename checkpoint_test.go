@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"watchdogdemo/handlers"
+)
+
+func openTestCheckpoints(t *testing.T, path string, sealer *handlers.Sealer) *Checkpoints {
+	t.Helper()
+	c, err := OpenCheckpoints(path, sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCheckpointsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+	file := filepath.Join(t.TempDir(), "in.csv")
+
+	c := openTestCheckpoints(t, path, nil)
+	id, err := c.Begin(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(id, "upload", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(id, "notify", func() error { return errors.New("down") }); err == nil {
+		t.Fatal("action error not returned")
+	}
+	c.Close()
+
+	// 模拟崩溃后重启：同一路径沿用原事件，已完成的动作不再执行，失败的动作重试
+	c = openTestCheckpoints(t, path, nil)
+	again, err := c.Begin(filepath.Join(filepath.Dir(file), ".", "in.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Errorf("Begin after reopening = %q, want the unfinished event %q", again, id)
+	}
+	ran := map[string]bool{}
+	for _, action := range []string{"upload", "notify"} {
+		if err := c.Do(again, action, func() error { ran[action] = true; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ran["upload"] || !ran["notify"] {
+		t.Errorf("actions run after reopening = %v, want only notify", ran)
+	}
+
+	if err := c.End(again); err != nil {
+		t.Fatal(err)
+	}
+	next, err := c.Begin(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == id || c.Completed(next, "upload") {
+		t.Error("a new event after End reused the finished event")
+	}
+}
+
+func TestCheckpointsCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+	dir := t.TempDir()
+
+	c := openTestCheckpoints(t, path, nil)
+	open, _ := c.Begin(filepath.Join(dir, "open.csv"))
+	c.Do(open, "upload", func() error { return nil })
+	for i := 0; i < 3; i++ {
+		id, _ := c.Begin(filepath.Join(dir, "done.csv"))
+		c.Do(id, "upload", func() error { return nil })
+		c.End(id)
+	}
+	c.Close()
+	before := countLines(t, path)
+
+	// 末尾不完整的一行（写入时崩溃）被忽略
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"begin","id":"x`)
+	f.Close()
+
+	c = openTestCheckpoints(t, path, nil)
+	if n := countLines(t, path); n != 2 || n >= before {
+		t.Errorf("checkpoints have %d line(s) after compaction (%d before), want 2", n, before)
+	}
+	if !c.Completed(open, "upload") {
+		t.Error("unfinished event lost by compaction")
+	}
+}
+
+func TestCheckpointsEncryptPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+	file := filepath.Join(t.TempDir(), "secret-name.csv")
+	c := openTestCheckpoints(t, path, nil)
+	id, _ := c.Begin(file)
+	c.Do(id, "upload", func() error { return nil })
+	c.Close()
+
+	sealer, err := handlers.NewSealer(bytes.Repeat([]byte{7}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCheckpoints(path, sealer); !errors.Is(err, handlers.ErrPlaintext) {
+		t.Fatalf("plaintext checkpoints opened with a key: err = %v, want ErrPlaintext", err)
+	}
+
+	sealer.AcceptPlaintext = true
+	c = openTestCheckpoints(t, path, sealer)
+	if !c.Completed(id, "upload") {
+		t.Error("plaintext record lost while migrating")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-name")) {
+		t.Error("checkpoints still hold plaintext after migration")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sidecarSuffix 校验和旁路文件的扩展名
+const sidecarSuffix = ".sha256"
+
+// ChecksumHandler 校验和处理器：文件创建或修改后稳定 settle 时间，写入与 sha256sum 格式兼容的校验和——
+// 默认为每个文件旁边的 NAME.sha256，指定 manifest 时改为集中写入一个清单文件（每行 "哈希  相对路径"）；
+// 文件删除或移走时删除对应的旁路文件或清单条目。所有事件同时转发给下一个处理器
+type ChecksumHandler struct {
+	next     EventHandler
+	root     string // 清单中的路径相对于此目录
+	manifest string // 清单文件路径，为空时写旁路文件
+	settle   *Debouncer
+
+	ctx    context.Context
+	dryRun bool
+
+	mu      sync.Mutex
+	entries map[string]string // 清单模式下相对路径 -> 哈希
+}
+
+// NewChecksumHandler 创建校验和处理器，manifest 为空时为每个文件写旁路文件
+func NewChecksumHandler(next EventHandler, root, manifest string, settle time.Duration) (*ChecksumHandler, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	h := &ChecksumHandler{next: next, root: abs, settle: NewDebouncer(settle), ctx: context.Background()}
+	if manifest != "" {
+		if h.manifest, err = filepath.Abs(manifest); err != nil {
+			return nil, err
+		}
+		if h.entries, err = readManifest(h.manifest); err != nil {
+			return nil, fmt.Errorf("read checksum manifest: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// readManifest 读取已有的清单，文件不存在时返回空清单
+func readManifest(path string) (map[string]string, error) {
+	entries := make(map[string]string)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, rel, ok := strings.Cut(scanner.Text(), "  ")
+		if ok {
+			entries[rel] = sum
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (h *ChecksumHandler) OnCreate(path string) error {
+	h.schedule(path)
+	return h.next.OnCreate(path)
+}
+
+func (h *ChecksumHandler) OnWrite(path string) error {
+	h.schedule(path)
+	return h.next.OnWrite(path)
+}
+
+func (h *ChecksumHandler) OnRemove(path string) error {
+	h.schedule(path)
+	return h.next.OnRemove(path)
+}
+
+func (h *ChecksumHandler) OnRename(path string) error {
+	h.schedule(path)
+	return h.next.OnRename(path)
+}
+
+func (h *ChecksumHandler) OnChmod(path string) error {
+	return h.next.OnChmod(path)
+}
+
+// Init 记录上下文并转发给下一个处理器的 Initializer
+func (h *ChecksumHandler) Init(ctx context.Context) error {
+	h.ctx = ctx
+	h.dryRun = IsDryRun(ctx)
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 处理仍在等待稳定的文件，再转发给下一个处理器的 Closer
+func (h *ChecksumHandler) Close() error {
+	h.settle.Flush()
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// schedule 路径每次变化后重新计时，稳定后按文件的最终状态更新或删除校验和；忽略旁路文件和清单本身
+func (h *ChecksumHandler) schedule(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil || strings.HasSuffix(abs, sidecarSuffix) || strings.HasSuffix(abs, sidecarSuffix+".tmp") {
+		return
+	}
+	if h.manifest != "" && (abs == h.manifest || abs == h.manifest+".tmp") {
+		return
+	}
+	h.settle.Debounce(abs, func() { h.update(abs) })
+}
+
+// update 文件存在时写入校验和，已不存在时删除
+func (h *ChecksumHandler) update(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		h.forget(path)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+	sum, err := hashFile(path)
+	if err != nil {
+		logf("checksum: %s: %v", path, err)
+		return
+	}
+	if h.dryRun {
+		wouldDo("record sha256 %s for %s", sum, path)
+		return
+	}
+	if h.manifest == "" {
+		line := sum + "  " + filepath.Base(path) + "\n"
+		if err := h.writeFile(path+sidecarSuffix, []byte(line)); err != nil {
+			logf("checksum: %s: %v", path, err)
+		}
+		return
+	}
+	rel, err := filepath.Rel(h.root, path)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entries[filepath.ToSlash(rel)] == sum {
+		return
+	}
+	h.entries[filepath.ToSlash(rel)] = sum
+	h.saveManifest()
+}
+
+// forget 删除已消失文件的校验和
+func (h *ChecksumHandler) forget(path string) {
+	if h.manifest == "" {
+		sidecar := path + sidecarSuffix
+		if _, err := os.Lstat(sidecar); err != nil {
+			return
+		}
+		if h.dryRun {
+			wouldDo("remove %s", sidecar)
+			return
+		}
+		if err := SelfWriteContext(h.ctx, func() error { return os.Remove(sidecar) }, sidecar); err != nil {
+			logf("checksum: %s: %v", path, err)
+		}
+		return
+	}
+	rel, err := filepath.Rel(h.root, path)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.entries[filepath.ToSlash(rel)]; !ok {
+		return
+	}
+	if h.dryRun {
+		wouldDo("remove %s from %s", rel, h.manifest)
+		return
+	}
+	delete(h.entries, filepath.ToSlash(rel))
+	h.saveManifest()
+}
+
+// saveManifest 按路径排序重写清单，调用方持有 h.mu
+func (h *ChecksumHandler) saveManifest() {
+	rels := make([]string, 0, len(h.entries))
+	for rel := range h.entries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	var b strings.Builder
+	for _, rel := range rels {
+		fmt.Fprintf(&b, "%s  %s\n", h.entries[rel], rel)
+	}
+	if err := h.writeFile(h.manifest, []byte(b.String())); err != nil {
+		logf("checksum: %s: %v", h.manifest, err)
+	}
+}
+
+// writeFile 写入临时文件后改名，下游读到的始终是完整内容；标记为自身写入
+func (h *ChecksumHandler) writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	return SelfWriteContext(h.ctx, func() error {
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}, path, tmp)
+}
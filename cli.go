@@ -0,0 +1,870 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"watchdogdemo/handlers"
+)
+
+// watchConfig watch 命令的配置，对应命令行参数
+type watchConfig struct {
+	MIME              string
+	Ext               string
+	MinSize           string
+	MaxSize           string
+	SkipHidden        bool
+	MaxDepth          int
+	Exclude           string
+	Priority          string
+	Canonical         bool
+	ResolveSymlinks   bool
+	IgnoreCase        bool
+	DetectTruncate    bool
+	Bytes             bool
+	Inodes            bool
+	Special           string
+	SkipInaccessible  bool
+	Normalize         bool
+	AutoBackend       bool
+	NetworkPoll       time.Duration
+	Container         time.Duration
+	WalkProgress      time.Duration
+	WatchBudget       int
+	BudgetPoll        time.Duration
+	ChmodDetail       bool
+	Xattr             bool
+	Quota             string
+	Retain            string
+	RetainAge         string
+	RetainKeep        int
+	RetainArchive     string
+	Ops               string
+	Dedupe            time.Duration
+	DropTransient     bool
+	Diff              string
+	Git               bool
+	AutoCommit        time.Duration
+	AutoCommitMessage string
+	HotFolder         string
+	Exec              string
+	Record            string
+	EncryptionKeyFile string
+	AcceptPlaintext   bool
+	EventArchive      string
+	ArchiveMaxAge     string
+	ArchiveMaxSize    string
+	Pipe              bool
+	StdinCommands     bool
+	DebounceLimit     int
+	FlushOnStop       bool
+	ShutdownTimeout   time.Duration
+	LatencyBudget     time.Duration
+	DryRun            bool
+	Explain           bool
+	Stats             bool
+	StatsEvery        time.Duration
+	Control           string
+	ControlSocket     string
+	Pprof             bool
+	Lang              string
+	Config            string
+	Profile           string
+	Debounce          time.Duration
+	DebounceMax       time.Duration
+	DirWindow         time.Duration
+	DirThreshold      int
+	Sample            string
+	Progress          time.Duration
+	ProgressSettle    time.Duration
+	ProgressMinSize   string
+	UploadSettle      time.Duration
+	Extract           string
+	ScanClamd         string
+	Thumbnails        string
+	Checksums         bool
+	Duplicates        string
+	ChecksumManifest  string
+	ThumbnailSpecs    string
+	ThumbnailWorkers  int
+	ScanCmd           string
+	ScanClean         string
+	ScanInfected      string
+	ScanWorkers       int
+	ExtractMaxSize    string
+	ExtractMaxFiles   int
+	UploadTemp        string
+	Output            string
+	FilesFrom         string
+	Null              bool
+	GlobRescan        time.Duration
+	WritesTo          string
+	Ledger            string
+	Checkpoint        string
+	Observe           time.Duration
+	LoopHops          int
+
+	Paths    []string          // 要监控的路径，默认当前目录
+	listed   []string          // --files-from 列出的路径，逐个非递归监控
+	explicit map[string]string // 命令行显式指定的参数，覆盖配置文件和环境变量
+	file     *fileConfig       // 配置文件中生效的设置，监控组在此基础上叠加
+}
+
+// register 将配置项注册为命令行参数
+func (c *watchConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.MIME, "mime", "", "only dispatch created/written files whose detected MIME type matches these comma-separated patterns, e.g. image/*")
+	fs.StringVar(&c.Ext, "ext", "", "only dispatch events for these comma-separated file extensions, e.g. .csv,.json")
+	fs.StringVar(&c.MinSize, "min-size", "", "only dispatch events for files at least this large, e.g. 1K")
+	fs.StringVar(&c.MaxSize, "max-size", "", "only dispatch events for files at most this large, e.g. 10MB (0 = no upper limit)")
+	fs.BoolVar(&c.SkipHidden, "skip-hidden", false, "ignore dotfiles, dot-directories, OS junk files and editor temp files")
+	fs.IntVar(&c.WatchBudget, "watch-budget", 0, "maximum number of native watches; deeper levels of trees that would exceed it are polled instead (0 means unlimited)")
+	fs.DurationVar(&c.BudgetPoll, "budget-poll", 2*time.Second, "poll interval for directories beyond --watch-budget")
+	fs.DurationVar(&c.WalkProgress, "walk-progress", 0, "while registering directories, log progress at most once per this interval (0 disables)")
+	fs.DurationVar(&c.Container, "container", 0, "container mode: combine native watches with a full reconciliation scan of each root at this interval, catching overlayfs lower-layer and bind-mount changes (0 disables)")
+	fs.BoolVar(&c.AutoBackend, "auto-backend", false, "detect each root's filesystem and poll network/FUSE mounts (NFS, CIFS, sshfs...) instead of relying on native watches")
+	fs.DurationVar(&c.NetworkPoll, "network-poll", 5*time.Second, "poll interval for roots on network or FUSE filesystems with --auto-backend")
+	fs.BoolVar(&c.Normalize, "normalize", false, "normalize events to platform-independent semantics: drop CHMOD without a visible mode/owner change and WRITE on directories, report replaced paths as WRITE")
+	fs.BoolVar(&c.SkipInaccessible, "skip-inaccessible", false, "skip and report subdirectories that cannot be read or watched instead of failing the whole registration")
+	fs.StringVar(&c.Config, "config", "", "JSON file with default settings keyed by flag name, plus \"paths\"; flags and WATCHDOG_* environment variables take precedence")
+	fs.StringVar(&c.Profile, "profile", "", "use this named profile from the config file's \"profiles\" section, e.g. dev or ingest")
+	fs.DurationVar(&c.Debounce, "debounce", 100*time.Millisecond, "wait this long after the last event on a path before dispatching (0 = disabled)")
+	fs.StringVar(&c.Output, "output", "", "append logs and events to this file instead of stderr")
+	fs.StringVar(&c.FilesFrom, "files-from", "", "also watch the files and directories listed in this file, one per line (- = stdin); listed directories are watched non-recursively")
+	fs.BoolVar(&c.Null, "null", false, "--files-from entries are NUL-separated, as printed by find -print0 or fd -0")
+	fs.DurationVar(&c.GlobRescan, "glob-rescan", 0, "re-expand glob path arguments such as 'services/*/config' at this interval, watching new matches (0 = only at startup)")
+	fs.StringVar(&c.WritesTo, "writes-to", "", "comma-separated directories the handlers write into (e.g. a mirror destination); rejected if watched, to prevent event loops")
+	fs.IntVar(&c.LoopHops, "loop-hops", DefaultLoopHops, "drop and report events once handlers have re-triggered each other this many times in a row (0 = no loop detection)")
+	fs.IntVar(&c.MaxDepth, "max-depth", -1, "stop descending after this many directory levels below each root (-1 = unlimited)")
+	fs.StringVar(&c.Exclude, "exclude", "", "comma-separated glob patterns to exclude (pruned during the recursive walk), e.g. node_modules,.git,build/**")
+	fs.BoolVar(&c.Canonical, "canonical", false, "report clean absolute paths regardless of how the watched path was written")
+	fs.BoolVar(&c.ResolveSymlinks, "resolve-symlinks", false, "like --canonical, and also resolve symlinks in event paths")
+	fs.BoolVar(&c.IgnoreCase, "ignore-case", false, "match --exclude/--priority patterns case-insensitively, e.g. *.JPG also matches photo.jpg")
+	fs.StringVar(&c.Priority, "priority", "", "comma-separated glob patterns dispatched immediately, bypassing debounce and bulk mode, e.g. /etc/**,*.lock")
+	fs.BoolVar(&c.DetectTruncate, "detect-truncate", false, "report writes that shrink a file as TRUNCATE instead of WRITE")
+	fs.BoolVar(&c.Bytes, "bytes", false, "count bytes written per file and directory from size changes (summary, /stats, /metrics)")
+	fs.BoolVar(&c.Inodes, "inodes", false, "track device and inode numbers to recognise hard links and pair renames (Unix; shown with --explain and in --record)")
+	fs.StringVar(&c.Special, "special", "", "how to treat FIFOs, sockets, device nodes and sparse files: report, skip or error for all, or per type, e.g. fifo=skip,socket=skip,device=error,sparse=report")
+	fs.BoolVar(&c.ChmodDetail, "chmod-detail", false, "report which permissions, ownership or timestamps a CHMOD event changed")
+	fs.BoolVar(&c.Xattr, "xattr", false, "report extended attribute (xattr) changes as ATTRIB events")
+	fs.StringVar(&c.Quota, "quota", "", "comma-separated dir=size thresholds to alert on, e.g. /data=100G,/var/log=5G")
+	fs.StringVar(&c.Retain, "retain", "", "directory whose old files are cleaned up (see --retain-age, --retain-keep, --retain-archive)")
+	fs.StringVar(&c.RetainAge, "retain-age", "", "remove files in --retain older than this, e.g. 7d or 12h")
+	fs.IntVar(&c.RetainKeep, "retain-keep", 0, "keep only the newest N files in --retain")
+	fs.StringVar(&c.RetainArchive, "retain-archive", "", "move expired files here instead of deleting them")
+	fs.StringVar(&c.Ops, "ops", "", "only dispatch these comma-separated ops: create,write,remove,rename,chmod")
+	fs.DurationVar(&c.Dedupe, "dedupe", 0, "drop events identical (path, op, mtime, size) to one dispatched within this window, e.g. 5s")
+	fs.BoolVar(&c.DropTransient, "drop-transient", false, "report nothing for files created and removed again within the debounce window")
+	fs.StringVar(&c.Diff, "diff", "", "log unified diffs on write for text files matching these comma-separated patterns, e.g. *.yaml,*.conf")
+	fs.BoolVar(&c.Git, "git", false, "skip .git and paths ignored by .gitignore")
+	fs.DurationVar(&c.AutoCommit, "auto-commit", 0, "commit changes under the watched directory to git after they settle for this long, e.g. 30s")
+	fs.StringVar(&c.AutoCommitMessage, "auto-commit-message", "", "text/template for auto-commit messages (fields: .Count .Dir .Files .Time)")
+	fs.StringVar(&c.HotFolder, "hot-folder", "", "process new files dropped into this directory with --exec once their size has not changed for 2s, then move them to done/ or failed/; on Ctrl+C a running --exec gets --shutdown-timeout to finish, otherwise its file stays in processing/ and is resumed on the next start")
+	fs.StringVar(&c.Exec, "exec", "", "shell command run for each hot-folder file; the file path is passed as $1")
+	fs.DurationVar(&c.DebounceMax, "debounce-max", 0, "adaptive debounce: grow a busy path's window up to this long, starting from --debounce and shrinking back when it calms down (0 = fixed window)")
+	fs.DurationVar(&c.DirWindow, "dir-window", 0, "after debouncing, hold file events per directory tree for this long; a tree with at least --dir-threshold events is dispatched as one directory-level event (0 = disabled)")
+	fs.IntVar(&c.DirThreshold, "dir-threshold", 1000, "number of file events in one --dir-window that turns them into a single directory-level event")
+	fs.DurationVar(&c.Progress, "progress", 0, "report the progress of files being written at this interval until their size settles, e.g. 1s (0 = disabled)")
+	fs.DurationVar(&c.ProgressSettle, "progress-settle", 5*time.Second, "consider a file complete once its size has not changed for this long")
+	fs.StringVar(&c.ProgressMinSize, "progress-min-size", "10M", "only report progress for files at least this large")
+	fs.StringVar(&c.Extract, "extract", "", "extract .zip/.tar.gz archives that arrive into a subdirectory of this directory and report the extracted files")
+	fs.StringVar(&c.ExtractMaxSize, "extract-max-size", "1G", "give up on archives that extract to more than this many bytes")
+	fs.IntVar(&c.ExtractMaxFiles, "extract-max-files", 10000, "give up on archives that contain more than this many files (0 = unlimited)")
+	fs.StringVar(&c.Duplicates, "duplicates", "", "report files whose content duplicates an existing file under the watched path, then: report, hardlink (replace with a hard link; both paths then share one inode, so a later edit to either changes the other) or remove")
+	fs.BoolVar(&c.Checksums, "checksums", false, "write a NAME.sha256 sidecar next to every created or modified file after it settles, and remove it when the file is deleted")
+	fs.StringVar(&c.ChecksumManifest, "checksum-manifest", "", "with --checksums, keep all checksums in this sha256sum-style manifest instead of sidecars")
+	fs.StringVar(&c.Thumbnails, "thumbnails", "", "generate thumbnails of images under the watched path into this directory, mirroring the source tree (uses --ledger to skip unchanged images)")
+	fs.StringVar(&c.ThumbnailSpecs, "thumbnail-specs", "256,1024", "comma-separated outputs SIZE[:jpeg|png]; SIZE is the longest edge, 0 keeps the original size")
+	fs.IntVar(&c.ThumbnailWorkers, "thumbnail-workers", 4, "number of images processed at the same time")
+	fs.StringVar(&c.ScanClamd, "scan-clamd", "", "virus-scan settled files with clamd at this address, e.g. /var/run/clamav/clamd.ctl or 127.0.0.1:3310")
+	fs.StringVar(&c.ScanCmd, "scan-cmd", "", "virus-scan settled files with this shell command ($1 is the file; exit 0 = clean, 1 = infected), e.g. 'clamscan --no-summary \"$1\"'")
+	fs.StringVar(&c.ScanClean, "scan-clean", "", "directory that clean files are moved to after scanning")
+	fs.StringVar(&c.ScanInfected, "scan-infected", "", "directory that infected files are moved to after scanning")
+	fs.IntVar(&c.ScanWorkers, "scan-workers", 4, "maximum number of files scanned at the same time")
+	fs.DurationVar(&c.UploadSettle, "upload-settle", 0, "treat the watched paths as FTP/SFTP drop folders: ignore upload temp files and report each file once, after its size has not changed for this long, e.g. 2s (0 = disabled)")
+	fs.StringVar(&c.UploadTemp, "upload-temp", "", "comma-separated glob patterns of upload temp file names for --upload-settle (default *.filepart,*.part,*.partial,*.tmp,.pureftpd-upload.*,.in.*)")
+	fs.StringVar(&c.Sample, "sample", "", "sample hot paths: comma-separated pattern:1/N (keep 1 in N events per path) or pattern:M/s (keep at most M per second per path), e.g. '*.log:1/100'")
+	fs.IntVar(&c.DebounceLimit, "debounce-limit", DefaultDebounceLimit, "max pending debounce timers; beyond this the oldest paths are dispatched early (0 = unlimited)")
+	fs.BoolVar(&c.FlushOnStop, "flush-on-stop", true, "dispatch events still inside the debounce window when shutting down (false = discard them)")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for in-flight handlers on Ctrl+C/SIGTERM before forcing shutdown")
+	fs.DurationVar(&c.LatencyBudget, "latency-budget", 0, "log a warning when an event takes longer than this from receipt to handler completion (0 = disabled)")
+	fs.BoolVar(&c.DryRun, "dry-run", false, "run filters, debouncing and rules normally but only log the actions (exec, moves, deletes, commits) that would be taken")
+	fs.BoolVar(&c.Explain, "explain", false, "log every decision made for each raw event: matched excludes, debouncing, filters and receiving handlers")
+	fs.BoolVar(&c.Stats, "stats", true, "print an event summary (counts by op, most active paths, suppressed events, handler errors) on exit")
+	fs.DurationVar(&c.StatsEvery, "stats-every", 0, "also print the event summary at this interval, e.g. 10m (0 = only on exit)")
+	fs.StringVar(&c.Control, "control", "", "serve the HTTP control API on this address, e.g. 127.0.0.1:9090; read-only: the unauthenticated endpoints that change the watcher or list files (/status, /watch, /pause, /resume, /bulk, /mute, /tree, /inject) are only served on --control-socket")
+	fs.StringVar(&c.ControlSocket, "control-socket", "", "serve the control API on this Unix socket (mode 0600, only the same user can connect); manage it with the ctl subcommand")
+	fs.BoolVar(&c.Pprof, "pprof", false, "expose /debug/pprof/ and /debug/state on the control API (requires --control or --control-socket)")
+	fs.StringVar(&c.Lang, "lang", languageFromEnv(), "language of log messages: en or zh (default from LANG)")
+	fs.DurationVar(&c.Observe, "observe", 0, "observation-only mode: dispatch nothing, only count events per directory over this rolling window, e.g. 1m; query them at /rates on --control")
+	fs.StringVar(&c.Checkpoint, "checkpoint", "", "record completed --exec runs of the hot folder in this file, so files resumed from processing/ after a crash are not run again")
+	fs.StringVar(&c.Ledger, "ledger", "", "skip created/written files whose content this ledger file already records as processed, and record each file the handlers process successfully (files are checked once they have had no events for 2s)")
+	fs.StringVar(&c.Record, "record", "", "record dispatched events with timing to this file (JSON Lines) for later replay")
+	fs.StringVar(&c.EncryptionKeyFile, "encryption-key-file", "", "encrypt each line of --record, --ledger and --checkpoint (and decrypt recordings for replay) with AES-GCM using the key in this file (16, 24 or 32 bytes, hex or base64); defaults to the key in $WATCHDOG_ENCRYPTION_KEY; --event-archive is not encrypted and is refused while a key is set")
+	fs.BoolVar(&c.AcceptPlaintext, "encryption-accept-plaintext", false, "with an encryption key, accept unencrypted lines written before encryption was enabled (the ledger and checkpoints are rewritten encrypted); by default they are rejected")
+	fs.StringVar(&c.EventArchive, "event-archive", "", "append dispatched events to hourly Avro files under this directory, partitioned as date=YYYY-MM-DD/hour=HH (UTC), for querying long-term history with DuckDB or Athena")
+	fs.StringVar(&c.ArchiveMaxAge, "event-archive-max-age", "", "remove --event-archive hours older than this, e.g. 90d (checked hourly; see also the prune subcommand)")
+	fs.StringVar(&c.ArchiveMaxSize, "event-archive-max-size", "", "remove the oldest --event-archive hours while the archive exceeds this size, e.g. 10G")
+	fs.BoolVar(&c.StdinCommands, "stdin-commands", false, "read commands from stdin (ADD path, REMOVE path, PAUSE [duration], RESUME, QUIT) and answer each with one line on stdout; stops when stdin closes")
+	fs.BoolVar(&c.Pipe, "pipe", false, "write each dispatched event to stdout as one JSON line (same format as --record), flushed immediately; logs stay on stderr and the watcher exits cleanly when the reader closes the pipe")
+}
+
+// sealer 返回 --encryption-key-file 或 $WATCHDOG_ENCRYPTION_KEY 指定的 Sealer，未设置密钥时为 nil
+func (c *watchConfig) sealer() (*handlers.Sealer, error) {
+	return loadSealer(c.EncryptionKeyFile, c.AcceptPlaintext)
+}
+
+// root 返回主监控路径
+func (c *watchConfig) root() string {
+	if len(c.Paths) > 0 {
+		return c.Paths[0]
+	}
+	if len(c.listed) > 0 {
+		return c.listed[0]
+	}
+	return "."
+}
+
+// handler 根据配置组装事件处理器，启用热文件夹时同时返回它
+func (c *watchConfig) handler() (EventHandler, *HotFolder, error) {
+	if c.Observe > 0 {
+		// 观察模式不分发事件，需要初始化或处理文件的处理器没有意义
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{{"hot-folder", c.HotFolder != ""}, {"ledger", c.Ledger != ""}, {"diff", c.Diff != ""}, {"auto-commit", c.AutoCommit > 0}, {"extract", c.Extract != ""}, {"scan-clamd", c.ScanClamd != ""}, {"scan-cmd", c.ScanCmd != ""}, {"thumbnails", c.Thumbnails != ""}, {"checksums", c.Checksums}, {"duplicates", c.Duplicates != ""}, {"pipe", c.Pipe}} {
+			if f.set {
+				return nil, nil, fmt.Errorf("--observe dispatches no events and cannot be combined with --%s", f.name)
+			}
+		}
+	}
+	var handler EventHandler = &LoggingHandler{}
+	if c.Pipe {
+		if c.HotFolder != "" || c.Thumbnails != "" {
+			return nil, nil, fmt.Errorf("--pipe cannot be combined with --hot-folder or --thumbnails")
+		}
+		handler = pipeStdout()
+	}
+	var hot *HotFolder
+	if c.HotFolder != "" {
+		if c.Exec == "" {
+			return nil, nil, fmt.Errorf("--hot-folder requires --exec")
+		}
+		hot = NewHotFolder(c.HotFolder, Command{Line: c.Exec}.Run)
+		hot.CloseTimeout = c.ShutdownTimeout
+		if c.Checkpoint != "" {
+			sealer, err := c.sealer()
+			if err != nil {
+				return nil, nil, err
+			}
+			cp, err := OpenCheckpoints(c.Checkpoint, sealer)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open checkpoints: %w", err)
+			}
+			hot.Checkpoints, hot.Action = cp, c.Exec
+		}
+		handler = hot
+	} else if c.Checkpoint != "" {
+		return nil, nil, fmt.Errorf("--checkpoint requires --hot-folder")
+	}
+	if c.Thumbnails != "" {
+		if hot != nil {
+			return nil, nil, fmt.Errorf("--thumbnails cannot be combined with --hot-folder")
+		}
+		specs, err := ParseThumbnailSpecs(c.ThumbnailSpecs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --thumbnail-specs: %w", err)
+		}
+		// 账本放在 worker 池之后，生成成功才记账
+		var ledger *Ledger
+		if c.Ledger != "" {
+			sealer, err := c.sealer()
+			if err != nil {
+				return nil, nil, err
+			}
+			if ledger, err = OpenLedger(c.Ledger, sealer); err != nil {
+				return nil, nil, fmt.Errorf("failed to open ledger: %w", err)
+			}
+		}
+		if handler, err = NewImagePipeline(c.root(), c.Thumbnails, specs, 2*time.Second, c.ThumbnailWorkers, ledger); err != nil {
+			return nil, nil, fmt.Errorf("failed to set up thumbnails: %w", err)
+		}
+	} else if c.Ledger != "" {
+		if hot != nil {
+			return nil, nil, fmt.Errorf("--ledger cannot be combined with --hot-folder, which already moves processed files to done/")
+		}
+		sealer, err := c.sealer()
+		if err != nil {
+			return nil, nil, err
+		}
+		ledger, err := OpenLedger(c.Ledger, sealer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open ledger: %w", err)
+		}
+		// 文件稳定后才计算哈希查账，而不是对写入中的文件逐个 WRITE 计算
+		handler = NewSettleHandler(NewLedgerHandler(handler, ledger), ledgerSettle, "ledger")
+	}
+	if c.Diff != "" {
+		handler = NewDiffHandler(handler, nil, strings.Split(c.Diff, ",")...)
+	}
+	if c.Extract != "" {
+		limits := ArchiveLimits{MaxFiles: c.ExtractMaxFiles}
+		var err error
+		if limits.MaxBytes, err = ParseSize(c.ExtractMaxSize); err != nil {
+			return nil, nil, fmt.Errorf("invalid --extract-max-size: %w", err)
+		}
+		if handler, err = NewArchiveExtractor(handler, c.Extract, 2*time.Second, limits); err != nil {
+			return nil, nil, fmt.Errorf("failed to set up archive extraction: %w", err)
+		}
+	}
+	if c.Duplicates != "" {
+		action, err := ParseDuplicateAction(c.Duplicates)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --duplicates: %w", err)
+		}
+		handler = NewDuplicateHandler(handler, c.root(), 2*time.Second, action)
+	}
+	if c.Checksums {
+		var err error
+		if handler, err = NewChecksumHandler(handler, c.root(), c.ChecksumManifest, 2*time.Second); err != nil {
+			return nil, nil, fmt.Errorf("failed to set up checksums: %w", err)
+		}
+	} else if c.ChecksumManifest != "" {
+		return nil, nil, fmt.Errorf("--checksum-manifest requires --checksums")
+	}
+	if c.ScanClamd != "" || c.ScanCmd != "" {
+		var scanner Scanner
+		switch {
+		case c.ScanClamd != "" && c.ScanCmd != "":
+			return nil, nil, fmt.Errorf("--scan-clamd and --scan-cmd are mutually exclusive")
+		case c.ScanClamd != "":
+			clamd := ParseClamdAddress(c.ScanClamd)
+			clamd.Timeout = 5 * time.Minute
+			scanner = clamd
+		default:
+			scanner = CommandScanner{Command: Command{Line: c.ScanCmd}}
+		}
+		if c.ScanClean == "" || c.ScanInfected == "" {
+			return nil, nil, fmt.Errorf("virus scanning requires --scan-clean and --scan-infected")
+		}
+		scan, err := NewScanHandler(handler, scanner, c.ScanClean, c.ScanInfected, 2*time.Second, c.ScanWorkers)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set up virus scanning: %w", err)
+		}
+		scan.DrainTimeout = c.ShutdownTimeout
+		handler = scan
+	} else if c.ScanClean != "" || c.ScanInfected != "" {
+		return nil, nil, fmt.Errorf("--scan-clean and --scan-infected require --scan-clamd or --scan-cmd")
+	}
+	if c.AutoCommit > 0 {
+		ac, err := NewAutoCommit(handler, c.root(), c.AutoCommit, c.AutoCommitMessage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set up auto-commit: %w", err)
+		}
+		handler = ac
+	}
+	return handler, hot, nil
+}
+
+// options 根据配置生成监控器选项（默认启用递归监控、去抖动和失败重试）
+func (c *watchConfig) options() ([]WatcherOption, error) {
+	if c.Pprof && c.Control == "" && c.ControlSocket == "" {
+		return nil, fmt.Errorf("--pprof requires --control or --control-socket")
+	}
+	opts := []WatcherOption{
+		WithRecursive(true),
+		WithRetry(DefaultRetryPolicy()),
+		WithDebounceLimit(c.DebounceLimit),
+		WithFlushOnStop(c.FlushOnStop),
+		WithLatencyBudget(c.LatencyBudget),
+		WithDryRun(c.DryRun),
+		WithExplain(c.Explain),
+	}
+	switch {
+	case c.DebounceMax > 0:
+		if c.Debounce <= 0 {
+			return nil, fmt.Errorf("--debounce-max requires a positive --debounce")
+		}
+		opts = append(opts, WithAdaptiveDebounce(c.Debounce, c.DebounceMax))
+	case c.Debounce > 0:
+		opts = append(opts, WithDebounce(c.Debounce))
+	}
+	if c.DirWindow > 0 {
+		if c.Debounce <= 0 {
+			return nil, fmt.Errorf("--dir-window requires a positive --debounce")
+		}
+		opts = append(opts, WithDirectoryCoalescing(c.DirWindow, c.DirThreshold))
+	}
+	if c.Observe > 0 {
+		if c.Control == "" && c.ControlSocket == "" {
+			return nil, fmt.Errorf("--observe requires --control or --control-socket")
+		}
+		opts = append(opts, WithObserveOnly(c.Observe))
+	}
+	if c.Sample != "" {
+		policies, err := ParseSamplePolicies(c.Sample)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --sample: %w", err)
+		}
+		opts = append(opts, WithSampling(policies...))
+	}
+	if c.Progress > 0 {
+		minSize, err := ParseSize(c.ProgressMinSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --progress-min-size: %w", err)
+		}
+		opts = append(opts, WithArrivalProgress(c.Progress, c.ProgressSettle, minSize))
+	}
+	if c.UploadSettle > 0 {
+		var patterns []string
+		if c.UploadTemp != "" {
+			patterns = strings.Split(c.UploadTemp, ",")
+		}
+		opts = append(opts, WithUploadDetection(c.UploadSettle, patterns...))
+	} else if c.UploadTemp != "" {
+		return nil, fmt.Errorf("--upload-temp requires --upload-settle")
+	}
+	if c.WritesTo != "" {
+		opts = append(opts, WithOutputDirs(strings.Split(c.WritesTo, ",")...))
+	}
+	if c.MaxDepth >= 0 {
+		opts = append(opts, WithMaxDepth(c.MaxDepth))
+	}
+	if c.Exclude != "" {
+		opts = append(opts, WithExclude(strings.Split(c.Exclude, ",")...))
+	}
+	if c.Priority != "" {
+		opts = append(opts, WithPriority(strings.Split(c.Priority, ",")...))
+	}
+	if c.Canonical || c.ResolveSymlinks {
+		opts = append(opts, WithCanonicalPaths(c.ResolveSymlinks))
+	}
+	if c.IgnoreCase {
+		opts = append(opts, WithCaseInsensitive(true))
+	}
+	if c.DetectTruncate {
+		opts = append(opts, WithTruncateDetection())
+	}
+	if c.Special != "" {
+		policy, err := ParseSpecialFilePolicy(c.Special)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --special: %w", err)
+		}
+		opts = append(opts, WithSpecialFiles(policy))
+	}
+	if c.Inodes {
+		opts = append(opts, WithInodeTracking())
+	}
+	if c.Bytes {
+		opts = append(opts, WithByteAccounting())
+	}
+	if c.ChmodDetail {
+		opts = append(opts, WithChmodDetail())
+	}
+	if c.Xattr {
+		opts = append(opts, WithXattrMonitoring(0))
+	}
+	if c.Quota != "" {
+		var rules []QuotaRule
+		for _, spec := range strings.Split(c.Quota, ",") {
+			dir, size, ok := strings.Cut(spec, "=")
+			limit, err := ParseSize(size)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid --quota entry %q: want dir=size", spec)
+			}
+			rules = append(rules, QuotaRule{Dir: dir, Limit: limit, OnExceed: logQuotaExceeded, OnRecover: logQuotaRecovered})
+		}
+		opts = append(opts, WithQuota(5*time.Minute, rules...))
+	}
+	if c.Retain != "" {
+		rule := RetentionRule{Dir: c.Retain, KeepNewest: c.RetainKeep, ArchiveDir: c.RetainArchive}
+		if c.RetainAge != "" {
+			age, err := ParseDuration(c.RetainAge)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --retain-age: %w", err)
+			}
+			rule.MaxAge = age
+		}
+		opts = append(opts, WithRetention(time.Hour, rule))
+	}
+	if c.Dedupe > 0 {
+		opts = append(opts, WithDedupe(c.Dedupe))
+	}
+	if c.DropTransient {
+		opts = append(opts, WithTransientCancel(true))
+	}
+	if c.Git {
+		opts = append(opts, WithGitAware(false))
+	}
+	if c.Container > 0 {
+		opts = append(opts, WithContainerMode(c.Container))
+	}
+	if c.AutoBackend {
+		opts = append(opts, WithAutoBackend(c.NetworkPoll))
+	}
+	if c.Normalize {
+		opts = append(opts, WithNormalize())
+	}
+	if c.SkipInaccessible {
+		opts = append(opts, WithSkipInaccessible())
+	}
+	if c.WatchBudget > 0 {
+		opts = append(opts, WithWatchBudget(c.WatchBudget, c.BudgetPoll))
+	}
+	if c.WalkProgress > 0 {
+		var last time.Time
+		opts = append(opts, WithWalkProgress(func(dirs, files int, current string) {
+			if current != "" && time.Since(last) >= c.WalkProgress {
+				last = time.Now()
+				logf("Registering: %d dir(s), %d file(s) so far, at %s", dirs, files, current)
+			}
+		}))
+	}
+	if c.SkipHidden {
+		opts = append(opts, WithSkipHidden())
+	}
+	if c.EventArchive != "" {
+		// Avro 归档不加密，设置了密钥时拒绝把路径以明文写入归档
+		if sealer, err := c.sealer(); err != nil {
+			return nil, err
+		} else if sealer != nil {
+			return nil, fmt.Errorf("--event-archive is not encrypted and cannot be used while an encryption key is set")
+		}
+		archive, err := NewEventArchive(c.EventArchive, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --event-archive: %w", err)
+		}
+		if c.ArchiveMaxAge != "" {
+			if archive.Retention.MaxAge, err = ParseDuration(c.ArchiveMaxAge); err != nil {
+				return nil, fmt.Errorf("invalid --event-archive-max-age: %w", err)
+			}
+		}
+		if c.ArchiveMaxSize != "" {
+			if archive.Retention.MaxSize, err = ParseSize(c.ArchiveMaxSize); err != nil {
+				return nil, fmt.Errorf("invalid --event-archive-max-size: %w", err)
+			}
+		}
+		opts = append(opts, WithHandler(archive))
+	} else if c.ArchiveMaxAge != "" || c.ArchiveMaxSize != "" {
+		return nil, fmt.Errorf("--event-archive-max-age and --event-archive-max-size require --event-archive")
+	}
+	if c.Ops != "" {
+		ops, err := ParseOps(c.Ops)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ops: %w", err)
+		}
+		opts = append(opts, WithOps(ops))
+	}
+	if c.Ext != "" {
+		opts = append(opts, WithExtensions(strings.Split(c.Ext, ",")...))
+	}
+	if c.MinSize != "" || c.MaxSize != "" {
+		var minSize, maxSize int64
+		var err error
+		if c.MinSize != "" {
+			if minSize, err = ParseSize(c.MinSize); err != nil {
+				return nil, fmt.Errorf("invalid --min-size: %w", err)
+			}
+		}
+		if c.MaxSize != "" {
+			if maxSize, err = ParseSize(c.MaxSize); err != nil {
+				return nil, fmt.Errorf("invalid --max-size: %w", err)
+			}
+		}
+		opts = append(opts, WithSizeRange(minSize, maxSize))
+	}
+	if c.MIME != "" {
+		opts = append(opts, WithMIMEDetection(strings.Split(c.MIME, ",")...))
+	}
+	return opts, nil
+}
+
+// runWatch 默认命令：监控路径并记录事件；配置文件定义了 groups 时在同一进程中运行所有监控组
+func runWatch(args []string) int {
+	var cfg watchConfig
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	cfg.register(fs)
+	if err := cfg.parse(fs, args); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	if err := SetLanguage(cfg.Lang); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	if cfg.StdinCommands && cfg.FilesFrom == "-" {
+		return exitWith(ExitConfig, "--stdin-commands cannot be combined with --files-from -")
+	}
+	if err := cfg.readFilesFrom(os.Stdin); err != nil {
+		return exitErr(err, "%v", err)
+	}
+	if cfg.Output != "" {
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return exitErr(err, "failed to open output: %v", err)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	}
+
+	groups := []*watchGroup{{cfg: &cfg}}
+	if len(cfg.file.groups) > 0 {
+		var err error
+		if groups, err = cfg.groupConfigs(); err != nil {
+			return exitWith(ExitConfig, "%v", err)
+		}
+	}
+	// 多个组共享一个 fsnotify 后端，只占用一个 inotify 实例
+	if len(groups) > 1 {
+		shared, err := NewSharedBackend()
+		if err != nil {
+			return exitErr(err, "failed to create watcher: %v", err)
+		}
+		defer shared.Close()
+		for _, g := range groups {
+			g.shared = shared
+		}
+	}
+	// 所有组共享一个循环检测器，才能发现跨组的循环
+	if cfg.LoopHops > 0 {
+		loops := NewLoopDetector(cfg.LoopHops)
+		for _, g := range groups {
+			g.loops = loops
+		}
+	}
+	watchers := make([]*FileWatcher, len(groups))
+	for i, g := range groups {
+		cleanup, code := g.setup()
+		if code != ExitOK {
+			return code
+		}
+		defer cleanup()
+		watchers[i] = g.watcher
+	}
+
+	// 管理端点（增删路径、暂停、目录树、注入事件）没有认证，只在权限为 0600 的 Unix 套接字上提供
+	newControl := func(admin bool) *ControlServer {
+		if groups[0].name == "" {
+			return NewControlServer(groups[0].watcher, cfg.Pprof, admin)
+		}
+		named := make(map[string]*FileWatcher, len(groups))
+		for _, g := range groups {
+			named[g.name] = g.watcher
+		}
+		return NewGroupControlServer(named, cfg.Pprof, admin)
+	}
+	serveControl := func(l net.Listener, admin bool) *ControlServer {
+		control := newControl(admin)
+		go func() {
+			if err := control.Serve(l); err != nil {
+				logf("control API: %v", err)
+			}
+		}()
+		logf("Control API listening on %s", l.Addr())
+		return control
+	}
+	if cfg.Control != "" {
+		l, err := net.Listen("tcp", cfg.Control)
+		if err != nil {
+			return exitErr(err, "failed to start control API: %v", err)
+		}
+		defer serveControl(l, false).Close()
+	}
+	if cfg.ControlSocket != "" {
+		l, err := ListenUnix(cfg.ControlSocket)
+		if err != nil {
+			return exitErr(err, "failed to start control API: %v", err)
+		}
+		defer serveControl(l, true).Close()
+	}
+
+	// 下游关闭管道或 stdin 命令要求退出时正常关闭
+	stop := make(chan struct{})
+	quit := sync.OnceFunc(func() { close(stop) })
+	if cfg.Pipe {
+		// 写入返回 EPIPE 而不是被 SIGPIPE 直接终止
+		signal.Ignore(syscall.SIGPIPE)
+		go func() {
+			<-pipeStdout().Closed()
+			quit()
+		}()
+	}
+	if cfg.StdinCommands {
+		reply := func(r commandReply) { fmt.Println(r) }
+		if cfg.Pipe {
+			reply = func(r commandReply) { pipeStdout().WriteJSON(r) }
+		}
+		go func() {
+			serveCommands(os.Stdin, reply, watchers)
+			quit()
+		}()
+	}
+
+	logf("Press Ctrl+C to stop...")
+
+	// 启动监控
+	for _, watcher := range watchers {
+		if err := watcher.Start(); err != nil {
+			return exitErr(err, "failed to start watcher: %v", err)
+		}
+	}
+
+	if cfg.StatsEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.StatsEvery)
+			defer ticker.Stop()
+			for range ticker.C {
+				writeGroupStats(groups)
+			}
+		}()
+	}
+
+	// 收到信号正常退出；任一后端失效时同样先关闭，再以 ExitBackend 退出
+	failure := waitForExit(stop, watchers...)
+	if failure != nil {
+		logf("watcher error: %v", failure)
+	}
+	logf("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, watcher := range watchers {
+		if err := watcher.Shutdown(ctx); err != nil {
+			logf("shutdown: %v", err)
+		}
+	}
+	if cfg.Stats {
+		writeGroupStats(groups)
+	}
+	return exitCode(failure)
+}
+
+// writeGroupStats 输出各监控组的事件汇总
+func writeGroupStats(groups []*watchGroup) {
+	for _, g := range groups {
+		if g.name != "" {
+			logf("Watch group %s:", g.name)
+		}
+		g.watcher.Stats().WriteSummary(log.Writer())
+	}
+}
+
+// waitForSignal 阻塞直到收到 SIGINT 或 SIGTERM
+func waitForSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	signal.Stop(sigChan)
+}
+
+// waitForExit 阻塞直到收到 SIGINT/SIGTERM 或 stop 关闭（返回 nil），或任一监控器后端失效（返回 ErrBackendFailed）
+func waitForExit(stop <-chan struct{}, watchers ...*FileWatcher) error {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	failed := make(chan struct{}, len(watchers))
+	for _, fw := range watchers {
+		go func() {
+			select {
+			case <-fw.Failed():
+				failed <- struct{}{}
+			case <-fw.done:
+			}
+		}()
+	}
+	select {
+	case <-sigChan:
+		return nil
+	case <-stop:
+		return nil
+	case <-failed:
+		return ErrBackendFailed
+	}
+}
+
+// subcommands 子命令表，第一个参数不是子命令时执行 runWatch；返回值为进程退出码
+var subcommands = map[string]func(args []string) int{
+	"watch":      runWatch,
+	"backfill":   runBackfill,
+	"ctl":        runCtl,
+	"dev":        runDev,
+	"diff":       runDiff,
+	"livereload": runLiveReload,
+	"pipeline":   runPipeline,
+	"prune":      runPrune,
+	"replay":     runReplay,
+	"report":     runReport,
+	"snapshot":   runSnapshot,
+	"stress":     runStress,
+	"wait":       runWait,
+}
+
+// runReplay replay 子命令：将 --record 录制的事件回放给处理器
+func runReplay(args []string) int {
+	var cfg watchConfig
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	cfg.register(fs)
+	speed := fs.Float64("speed", 1, "replay speed multiplier (0 = as fast as possible)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags] recording.jsonl\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	if err := cfg.parse(fs, args); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitConfig
+	}
+	if err := SetLanguage(cfg.Lang); err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return exitErr(err, "failed to open recording: %v", err)
+	}
+	defer f.Close()
+	sealer, err := cfg.sealer()
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+
+	handler, _, err := cfg.handler()
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	watcher, err := NewFileWatcher(handler, WithRetry(DefaultRetryPolicy()))
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+
+	// Ctrl+C 中止回放
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitForSignal()
+		cancel()
+	}()
+
+	logf("Replaying %s at %gx", fs.Arg(0), *speed)
+	if err := watcher.Replay(ctx, sealer.NewReader(f), *speed); err != nil {
+		if errors.Is(err, context.Canceled) {
+			logf("replay stopped: %v", err)
+			return ExitOK
+		}
+		return exitErr(err, "replay stopped: %v", err)
+	}
+	logf("Replay finished")
+	return ExitOK
+}
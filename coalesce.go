@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// coalescer 合并去抖动窗口内同一路径的多个事件
+type coalescer struct {
+	mu              sync.Mutex
+	pending         map[string]*pendingOp
+	cancelTransient bool // 窗口内创建又删除/移走的临时文件不产生任何事件
+}
+
+// newCoalescer 创建事件合并器
+func newCoalescer() *coalescer {
+	return &coalescer{pending: make(map[string]*pendingOp)}
+}
+
+// size 返回等待合并的路径数量
+func (c *coalescer) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// WithTransientCancel 设置是否抵消去抖动窗口内"创建后又删除（或移走）"的事件（默认关闭）
+// 开启时短暂存在的临时文件不会产生任何下游事件；关闭时照常分发 CREATE 和 REMOVE。
+// 只抵消窗口内第一个事件就是 CREATE（窗口开始时路径不存在）的情况，已有文件被删除、重建又删除时仍报告 REMOVE
+func WithTransientCancel(enabled bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.coalescer.cancelTransient = enabled
+	}
+}
+
+// pendingOp 窗口内累加的事件类型和收到时间
+type pendingOp struct {
+	op          fsnotify.Op
+	firstOp     fsnotify.Op // 窗口内第一个事件的类型，用于判断窗口开始时路径是否存在
+	first, last time.Time
+}
+
+// add 累加窗口内的事件类型，seen 为收到原始事件的时间；返回 true 表示并入了已挂起的事件
+func (c *coalescer) add(event fsnotify.Event, seen time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, merged := c.pending[event.Name]
+	if !merged {
+		p = &pendingOp{firstOp: event.Op, first: seen}
+		c.pending[event.Name] = p
+	}
+	p.op |= event.Op
+	p.last = seen
+	return merged
+}
+
+// take 取出窗口内合并后的事件，按文件最终状态修正事件类型
+// 返回 false 表示事件相互抵消，无需分发
+func (c *coalescer) take(name string) (Event, bool) {
+	c.mu.Lock()
+	p := c.pending[name]
+	delete(c.pending, name)
+	c.mu.Unlock()
+
+	if p == nil || p.op == 0 {
+		return Event{}, false
+	}
+	op := p.op
+
+	_, err := os.Lstat(name)
+	exists := err == nil
+	gone := fsnotify.Remove | fsnotify.Rename
+
+	switch {
+	case exists && op.Has(fsnotify.Create):
+		// 删除后又重新创建（如原子替换），最终文件存在，不再报告删除
+		op &^= gone
+	case !exists && op&gone != 0 && p.firstOp.Has(fsnotify.Create):
+		// 窗口内新建又删除/移走的临时文件
+		if c.cancelTransient {
+			return Event{}, false
+		}
+	case !exists && op&gone != 0:
+		// 文件最终已不存在（包括已有文件删除、重建又删除），之前的创建、写入和属性变化没有意义
+		op &= gone
+	}
+	return Event{Path: name, Op: op, FirstSeen: p.first, LastSeen: p.last}, true
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix 配置环境变量前缀：参数名转为大写、- 替换为 _，如 --skip-hidden 对应 WATCHDOG_SKIP_HIDDEN
+const envPrefix = "WATCHDOG_"
+
+// envName 返回参数对应的环境变量名
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// setting 来自配置文件或环境变量的参数值
+type setting struct {
+	value  string
+	source string // 用于错误信息，如 "WATCHDOG_DEBOUNCE" 或 "config file watchdog.json"
+}
+
+// parse 解析命令行参数，再叠加配置文件（--config 或 WATCHDOG_CONFIG）和 WATCHDOG_* 环境变量，
+// 优先级：命令行 > 环境变量 > 配置文件中选中的 profile > 配置文件顶层 > 默认值；
+// 没有位置参数时，监控路径取自 WATCHDOG_PATHS（按系统路径列表分隔符分隔）或配置文件的 paths
+func (c *watchConfig) parse(fs *flag.FlagSet, args []string) error {
+	fs.Parse(args)
+	c.Paths = fs.Args()
+	c.explicit = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { c.explicit[f.Name] = f.Value.String() })
+
+	if c.Config == "" {
+		c.Config = os.Getenv(envName("config"))
+	}
+	if c.Profile == "" {
+		c.Profile = os.Getenv(envName("profile"))
+	}
+	if c.Config == "" && c.Profile != "" {
+		return fmt.Errorf("--profile %s requires a config file (--config or %s)", c.Profile, envName("config"))
+	}
+	c.file = &fileConfig{}
+	if c.Config != "" {
+		file, err := loadConfigFile(c.Config, c.Profile)
+		if err != nil {
+			return err
+		}
+		c.file = file
+	}
+	if err := c.apply(fs, c.file.settings(c.Config)); err != nil {
+		return err
+	}
+	paths := c.file.paths
+	if value := os.Getenv(envName("paths")); value != "" {
+		paths = filepath.SplitList(value)
+	}
+	if len(c.Paths) == 0 {
+		c.Paths = paths
+	}
+	if len(c.file.groups) > 0 && len(c.Paths) > 0 {
+		return fmt.Errorf("config file %s defines groups: list paths in each group instead of on the command line, %s or the top level", c.Config, envName("paths"))
+	}
+	return nil
+}
+
+// apply 将配置文件中的设置、WATCHDOG_* 环境变量和命令行参数依次设置到 fs
+func (c *watchConfig) apply(fs *flag.FlagSet, settings map[string]setting) error {
+	for name, s := range settings {
+		if name == "config" || name == "profile" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", s.source, name)
+		}
+	}
+	// 环境变量覆盖配置文件；未知的 WATCHDOG_* 变量（如 systemd 的 WATCHDOG_USEC）忽略
+	fs.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(envName(f.Name)); ok && f.Name != "config" && f.Name != "profile" {
+			settings[f.Name] = setting{value, envName(f.Name)}
+		}
+	})
+	for name, value := range c.explicit {
+		settings[name] = setting{value, "command line"}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := settings[name]
+		if err := fs.Set(name, s.value); err != nil {
+			return fmt.Errorf("%s: invalid value %q for -%s: %w", s.source, s.value, name, err)
+		}
+	}
+	return nil
+}
+
+// fileConfig 配置文件中生效的设置：顶层设置叠加选中的 profile
+type fileConfig struct {
+	values map[string]string
+	paths  []string
+	groups map[string]map[string]any // 监控组名 -> 组内设置，见 groupConfigs
+}
+
+// settings 返回文件中的参数值，来源标注为配置文件路径
+func (f *fileConfig) settings(path string) map[string]setting {
+	settings := make(map[string]setting, len(f.values))
+	for name, value := range f.values {
+		settings[name] = setting{value, "config file " + path}
+	}
+	return settings
+}
+
+// loadConfigFile 读取 JSON 配置文件：键为参数名，值为字符串、数字、布尔值或字符串数组（按逗号拼接），
+// paths 为要监控的路径列表，如 {"paths": ["/data"], "debounce": "200ms", "exclude": ["node_modules", ".git"]}；
+// profiles 定义命名配置，选中的 profile（参数 profile 或文件顶层的 "profile"）中的设置覆盖顶层设置，如
+// {"exclude": ".git", "profiles": {"dev": {"paths": ["./src"]}, "ingest": {"paths": ["/data/in"], "hot-folder": "/data/in", "exec": "ingest.sh"}}}；
+// groups 定义在同一进程中同时运行的监控组，可写在顶层或 profile 中，见 groupConfigs
+func loadConfigFile(path, profile string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	profiles, ok := raw["profiles"].(map[string]any)
+	if _, present := raw["profiles"]; present && !ok {
+		return nil, fmt.Errorf("config file %s: profiles must be an object keyed by profile name", path)
+	}
+	delete(raw, "profiles")
+	if profile == "" {
+		profile, _ = raw["profile"].(string)
+	}
+	delete(raw, "profile")
+
+	file := &fileConfig{values: make(map[string]string, len(raw))}
+	if file.groups, err = configGroups(raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if err := mergeSettings(file.values, &file.paths, raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if profile != "" {
+		settings, ok := profiles[profile].(map[string]any)
+		if !ok {
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("config file %s: unknown profile %q (defined: %s)", path, profile, strings.Join(names, ", "))
+		}
+		groups, err := configGroups(settings)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: profile %s: %w", path, profile, err)
+		}
+		if groups != nil {
+			file.groups = groups
+		}
+		if err := mergeSettings(file.values, &file.paths, settings); err != nil {
+			return nil, fmt.Errorf("config file %s: profile %s: %w", path, profile, err)
+		}
+	}
+	return file, nil
+}
+
+// configGroups 取出并删除 raw 中的 groups 设置
+func configGroups(raw map[string]any) (map[string]map[string]any, error) {
+	v, present := raw["groups"]
+	if !present {
+		return nil, nil
+	}
+	delete(raw, "groups")
+	list, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("groups must be an object keyed by group name")
+	}
+	groups := make(map[string]map[string]any, len(list))
+	for name, v := range list {
+		settings, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("group %q must be an object of settings", name)
+		}
+		if name == "" || strings.ContainsAny(name, "/ ") {
+			return nil, fmt.Errorf("invalid group name %q", name)
+		}
+		groups[name] = settings
+	}
+	return groups, nil
+}
+
+// mergeSettings 将一组 JSON 设置转换为参数值，覆盖 values 和 paths 中已有的设置
+func mergeSettings(values map[string]string, paths *[]string, raw map[string]any) error {
+	for name, v := range raw {
+		if name == "paths" {
+			list, ok := stringList(v)
+			if !ok {
+				return fmt.Errorf("paths must be a string or a list of strings")
+			}
+			*paths = list
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			values[name] = v
+		case bool:
+			values[name] = strconv.FormatBool(v)
+		case float64:
+			values[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			list, ok := stringList(v)
+			if !ok {
+				return fmt.Errorf("unsupported value for %q", name)
+			}
+			values[name] = strings.Join(list, ",")
+		}
+	}
+	return nil
+}
+
+// stringList 将 JSON 字符串或字符串数组转换为列表
+func stringList(v any) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list[i] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// WithContainerMode 容器模式：overlayfs 和绑定挂载上，容器外对下层（镜像层、宿主机目录）的修改不一定产生 inotify 事件，
+// 因此在原生 watch 之外每 reconcile 对每个根路径做一次完整的对账扫描，补发漏掉的事件（原生事件已报告过的变化不会重复报告）；
+// 另外把 overlayfs 上层目录中表示删除的 whiteout 文件（0/0 字符设备）的 CREATE 报告为 REMOVE。
+// 启动时记录检测到的容器运行时，注册时提示位于 overlayfs 的根路径
+func WithContainerMode(reconcile time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.container = reconcile
+		fw.usePoller(reconcile)
+	}
+}
+
+// reconcileRoot 为根路径开启对账扫描
+func (fw *FileWatcher) reconcileRoot(root *watchRoot) {
+	if fsType, err := filesystemType(root.path); err == nil && fsType == "overlayfs" {
+		logf("%s is on overlayfs: changes to lower layers made outside the container are only picked up by reconciliation every %s", root.path, fw.container)
+	}
+	fw.poller.poll(fw, root, root.path, fw.container, true)
+}
+
+// containerRuntime 返回检测到的容器运行时，不在容器中时返回空字符串
+func containerRuntime() string {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		for _, name := range []string{"kubepods", "docker", "containerd", "lxc"} {
+			if strings.Contains(string(data), name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// logContainer 启动时记录容器模式的检测结果
+func logContainer(reconcile time.Duration) {
+	if runtime := containerRuntime(); runtime != "" {
+		logf("Container mode: running under %s, reconciling every %s", runtime, reconcile)
+	} else {
+		logf("Container mode: no container runtime detected, reconciling every %s anyway", reconcile)
+	}
+}
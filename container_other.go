@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// isWhiteout 当前平台没有 overlayfs
+func isWhiteout(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ControlServer HTTP 控制接口，供运维工具查询和控制运行中的监控器
+type ControlServer struct {
+	fw     *FileWatcher
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewControlServer 创建只读的控制接口（/healthz、/metrics、/stats、/capabilities，观察模式下还有 /rates），
+// debug 为 true 时额外提供 /debug/pprof/ 和 /debug/state；admin 为 true 时额外提供能修改监控器或列出目录的
+// /status、/watch、/pause、/resume、/bulk、/mute、/tree 和 /inject，这些端点没有认证，只应在 ListenUnix 的监听器上开启
+func NewControlServer(fw *FileWatcher, debug, admin bool) *ControlServer {
+	c := &ControlServer{fw: fw, mux: http.NewServeMux()}
+	c.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if fw.stopped() {
+			http.Error(w, ErrStopped.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	registerMetrics(c.mux, fw)
+	registerStats(c.mux, fw)
+	registerRates(c.mux, fw)
+	registerCapabilities(c.mux, fw)
+	if admin {
+		registerBulk(c.mux, fw)
+		registerMute(c.mux, fw)
+		registerTree(c.mux, fw)
+		registerInject(c.mux, fw)
+		registerAdmin(c.mux, fw)
+	}
+	if debug {
+		registerDebug(c.mux, fw)
+	}
+	c.server = &http.Server{Handler: c.mux, ReadHeaderTimeout: 10 * time.Second}
+	return c
+}
+
+// NewGroupControlServer 为多个监控组创建控制接口：各组的端点挂载在 /groups/<name>/ 下（如 /groups/ingest/stats），
+// /groups 列出组名，/healthz 在所有组都运行时返回 ok
+func NewGroupControlServer(groups map[string]*FileWatcher, debug, admin bool) *ControlServer {
+	c := &ControlServer{mux: http.NewServeMux()}
+	names := make([]string, 0, len(groups))
+	for name, fw := range groups {
+		names = append(names, name)
+		prefix := "/groups/" + name
+		c.mux.Handle(prefix+"/", http.StripPrefix(prefix, NewControlServer(fw, debug, admin).mux))
+	}
+	sort.Strings(names)
+	c.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range names {
+			if groups[name].stopped() {
+				http.Error(w, name+": "+ErrStopped.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("ok\n"))
+	})
+	c.mux.HandleFunc("/groups", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, names)
+	})
+	c.server = &http.Server{Handler: c.mux, ReadHeaderTimeout: 10 * time.Second}
+	return c
+}
+
+// Handle 注册额外的端点
+func (c *ControlServer) Handle(pattern string, handler http.Handler) {
+	c.mux.Handle(pattern, handler)
+}
+
+// Serve 在 l 上提供服务，直到 Close 被调用
+func (c *ControlServer) Serve(l net.Listener) error {
+	if err := c.server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ListenAndServe 监听 TCP 地址并提供服务，直到 Close 被调用
+func (c *ControlServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return c.Serve(l)
+}
+
+// Close 关闭控制接口
+func (c *ControlServer) Close() error {
+	return c.server.Close()
+}
+
+// writeJSON 以 JSON 格式返回响应
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// adminEndpoints 能修改监控器或列出目录的端点，只应在 admin 监听器上注册
+var adminEndpoints = []struct{ method, path string }{
+	{http.MethodGet, "/status"},
+	{http.MethodPost, "/pause"},
+	{http.MethodPost, "/resume"},
+	{http.MethodGet, "/bulk"},
+	{http.MethodPost, "/bulk/begin"},
+	{http.MethodPost, "/bulk/end"},
+	{http.MethodPost, "/mute?pattern=**&duration=1h"},
+	{http.MethodGet, "/tree"},
+	{http.MethodPost, "/inject"},
+}
+
+func newTestWatcher(t *testing.T) *FileWatcher {
+	t.Helper()
+	fw, err := NewFileWatcher(NopHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fw.Stop() })
+	return fw
+}
+
+func serve(h http.Handler, method, path string) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code
+}
+
+func TestControlServerAdminGating(t *testing.T) {
+	fw := newTestWatcher(t)
+	public := NewControlServer(fw, false, false).mux
+	admin := NewControlServer(fw, false, true).mux
+
+	for _, ep := range adminEndpoints {
+		if code := serve(public, ep.method, ep.path); code != http.StatusNotFound {
+			t.Errorf("%s %s on the public listener = %d, want 404", ep.method, ep.path, code)
+		}
+	}
+	if pattern := fw.muted("/anything"); pattern != "" {
+		t.Errorf("watcher muted by %q through the public listener", pattern)
+	}
+	if _, active := fw.BulkStatus(); active {
+		t.Error("watcher put into bulk mode through the public listener")
+	}
+	for _, path := range []string{"/healthz", "/stats", "/metrics", "/capabilities"} {
+		if code := serve(public, http.MethodGet, path); code != http.StatusOK {
+			t.Errorf("GET %s on the public listener = %d, want 200", path, code)
+		}
+	}
+	for _, ep := range adminEndpoints {
+		if code := serve(admin, ep.method, ep.path); code == http.StatusNotFound {
+			t.Errorf("%s %s on the admin listener = 404, want it served", ep.method, ep.path)
+		}
+	}
+}
+
+func TestGroupControlServerAdminGating(t *testing.T) {
+	groups := map[string]*FileWatcher{"ingest": newTestWatcher(t)}
+	public := NewGroupControlServer(groups, false, false).mux
+	admin := NewGroupControlServer(groups, false, true).mux
+
+	for _, ep := range adminEndpoints {
+		path := "/groups/ingest" + ep.path
+		if code := serve(public, ep.method, path); code != http.StatusNotFound {
+			t.Errorf("%s %s on the public listener = %d, want 404", ep.method, path, code)
+		}
+		if code := serve(admin, ep.method, path); code == http.StatusNotFound {
+			t.Errorf("%s %s on the admin listener = 404, want it served", ep.method, path)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ControlStatus /status 返回的运行状态
+type ControlStatus struct {
+	Roots       []RootStatus `json:"roots"`
+	Watches     int          `json:"watches"` // 后端实际注册的 watch 数量
+	Paused      bool         `json:"paused"`
+	Held        int          `json:"held,omitempty"`         // 暂停期间保留的事件
+	PausedUntil *time.Time   `json:"paused_until,omitempty"` // 自动恢复的时间
+	Bulk        bool         `json:"bulk"`                   // 是否处于批量模式
+}
+
+// RootStatus 一个已注册的根路径
+type RootStatus struct {
+	Path       string `json:"path"`
+	Recursive  bool   `json:"recursive"`
+	File       bool   `json:"file,omitempty"`
+	Polled     bool   `json:"polled,omitempty"`     // 整个根路径以轮询代替原生 watch
+	Filesystem string `json:"filesystem,omitempty"` // 启用 WithAutoBackend 时检测到的文件系统
+}
+
+// Status 返回已注册的根路径、watch 数量以及暂停和批量模式状态
+func (fw *FileWatcher) Status() ControlStatus {
+	var s ControlStatus
+	fw.mu.Lock()
+	for _, r := range fw.roots {
+		s.Roots = append(s.Roots, rootStatus(r))
+	}
+	s.Watches = len(fw.watcher.WatchList())
+	fw.mu.Unlock()
+	var until time.Time
+	s.Paused, s.Held, until = fw.Paused()
+	if !until.IsZero() {
+		s.PausedUntil = &until
+	}
+	_, s.Bulk = fw.BulkStatus()
+	return s
+}
+
+// rootStatus 返回根路径的状态
+func rootStatus(r *watchRoot) RootStatus {
+	return RootStatus{Path: r.path, Recursive: r.recursive, File: r.file, Polled: r.polled, Filesystem: r.fsType}
+}
+
+// Unwatch 按路径注销根路径，等同于该次 Watch 返回的 WatchHandle.Close；路径未注册时返回 ErrNotWatching
+func (fw *FileWatcher) Unwatch(path string) error {
+	path = fw.rootPath(path)
+	fw.mu.Lock()
+	var root *watchRoot
+	for _, r := range fw.roots {
+		if r.path == path {
+			root = r
+		}
+	}
+	fw.mu.Unlock()
+	if root == nil || !fw.removeRoot(root) {
+		return &WatchError{Path: path, Err: ErrNotWatching}
+	}
+	return nil
+}
+
+// ListenUnix 在 Unix 域套接字上监听控制接口，套接字文件权限为 0600，只有同一用户（和 root）能连接；
+// 需要开放给某个组时可在启动后 chmod/chown。残留的套接字文件（没有进程在监听）会被替换
+func ListenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// registerAdmin 注册管理端点：GET /status；POST /watch?path=&recursive= 添加根路径，DELETE /watch?path= 注销；
+// POST /pause?duration= 暂停分发（省略 duration 时直到 /resume），POST /resume 恢复
+func registerAdmin(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, fw.Status())
+	})
+	mux.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			var opts []WatchOption
+			if s := r.URL.Query().Get("recursive"); s != "" {
+				recursive, err := strconv.ParseBool(s)
+				if err != nil {
+					http.Error(w, "invalid recursive: "+s, http.StatusBadRequest)
+					return
+				}
+				opts = append(opts, Recursive(recursive))
+			}
+			h, err := fw.Watch(path, opts...)
+			if h == nil {
+				http.Error(w, err.Error(), watchErrorStatus(err))
+				return
+			}
+			logf("Watching: %s (recursive: %v)", h.Path(), h.root.recursive)
+			result := struct {
+				RootStatus
+				Warning string `json:"warning,omitempty"`
+			}{RootStatus: rootStatus(h.root)}
+			if err != nil {
+				result.Warning = err.Error()
+			}
+			writeJSON(w, result)
+		case http.MethodDelete:
+			if err := fw.Unwatch(path); err != nil {
+				http.Error(w, err.Error(), watchErrorStatus(err))
+				return
+			}
+			logf("Stopped watching %s", path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "POST or DELETE required", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/pause", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		var duration time.Duration
+		if s := r.URL.Query().Get("duration"); s != "" {
+			var err error
+			if duration, err = time.ParseDuration(s); err != nil || duration <= 0 {
+				http.Error(w, "invalid duration: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		fw.Pause(duration)
+		writeJSON(w, fw.Status())
+	})
+	mux.HandleFunc("/resume", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, map[string]int{"released": fw.Resume()})
+	})
+}
+
+// watchErrorStatus 返回 Watch/Unwatch 错误对应的 HTTP 状态码
+func watchErrorStatus(err error) int {
+	var ce *ConfigError
+	switch {
+	case errors.Is(err, ErrPathNotFound), errors.Is(err, ErrNotWatching):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyWatching):
+		return http.StatusConflict
+	case errors.As(err, &ce):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrStopped):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// runCtl ctl 子命令：通过 Unix 域套接字（或 TCP）管理运行中的 watch 进程
+func runCtl(args []string) int {
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	socket := fs.String("socket", os.Getenv("WATCHDOG_SOCKET"), "control socket of the running watcher (--control-socket); defaults to $WATCHDOG_SOCKET")
+	addr := fs.String("addr", "", "TCP address of the control API (--control) instead of a socket")
+	group := fs.String("group", "", "watch group to manage when the watcher runs several groups")
+	recursive := fs.Bool("recursive", true, "add: watch subdirectories too")
+	fs.Usage = func() {
+		name := filepath.Base(os.Args[0])
+		fmt.Fprintf(fs.Output(), "Usage: %s ctl [flags] status | add PATH... | remove PATH... | pause [DURATION] | resume\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *socket == "" && *addr == "" {
+		fs.Usage()
+		return ExitConfig
+	}
+	c := newCtlClient(*socket, *addr, *group)
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "status":
+		var s ControlStatus
+		if code := c.do(http.MethodGet, "/status", nil, &s); code != ExitOK {
+			return code
+		}
+		printStatus(os.Stdout, s)
+	case "add", "remove":
+		if len(rest) == 0 {
+			fs.Usage()
+			return ExitConfig
+		}
+		method := http.MethodPost
+		if cmd == "remove" {
+			method = http.MethodDelete
+		}
+		for _, path := range rest {
+			// 相对路径按 ctl 的工作目录解析，而不是 watch 进程的
+			q := url.Values{"path": {absPath(path)}}
+			if cmd == "add" {
+				q.Set("recursive", strconv.FormatBool(*recursive))
+			}
+			var result struct {
+				RootStatus
+				Warning string `json:"warning"`
+			}
+			if code := c.do(method, "/watch", q, &result); code != ExitOK {
+				return code
+			}
+			if cmd == "add" {
+				fmt.Printf("watching %s (recursive: %v)\n", result.Path, result.Recursive)
+				if result.Warning != "" {
+					fmt.Printf("warning: %s\n", result.Warning)
+				}
+			} else {
+				fmt.Printf("stopped watching %s\n", path)
+			}
+		}
+	case "pause":
+		q := url.Values{}
+		if len(rest) > 0 {
+			q.Set("duration", rest[0])
+		}
+		var s ControlStatus
+		if code := c.do(http.MethodPost, "/pause", q, &s); code != ExitOK {
+			return code
+		}
+		if s.PausedUntil != nil {
+			fmt.Printf("paused until %s\n", s.PausedUntil.Format(time.RFC3339))
+		} else {
+			fmt.Println("paused until resumed")
+		}
+	case "resume":
+		var result struct {
+			Released int `json:"released"`
+		}
+		if code := c.do(http.MethodPost, "/resume", nil, &result); code != ExitOK {
+			return code
+		}
+		fmt.Printf("resumed, %d held event(s) released\n", result.Released)
+	default:
+		fs.Usage()
+		return ExitConfig
+	}
+	return ExitOK
+}
+
+// ctlClient 控制接口的 HTTP 客户端
+type ctlClient struct {
+	http *http.Client
+	base string
+}
+
+// newCtlClient 创建连接 Unix 域套接字（socket 不为空时）或 TCP 地址的客户端
+func newCtlClient(socket, addr, group string) *ctlClient {
+	c := &ctlClient{http: &http.Client{Timeout: 30 * time.Second}, base: "http://" + addr}
+	if socket != "" {
+		c.base = "http://unix"
+		c.http.Transport = &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}}
+	}
+	if group != "" {
+		c.base += "/groups/" + url.PathEscape(group)
+	}
+	return c
+}
+
+// do 发送请求并把 JSON 响应解码到 out，返回退出码；失败时输出服务端的错误信息
+func (c *ctlClient) do(method, path string, q url.Values, out any) int {
+	u := c.base + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return exitWith(ExitFailure, "failed to reach the watcher: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return exitWith(ExitPathNotFound, "%s", strings.TrimSpace(string(body)))
+	case resp.StatusCode == http.StatusBadRequest:
+		return exitWith(ExitConfig, "%s", strings.TrimSpace(string(body)))
+	case resp.StatusCode >= 300:
+		return exitWith(ExitFailure, "%s", strings.TrimSpace(string(body)))
+	}
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return exitWith(ExitFailure, "unexpected response: %v", err)
+		}
+	}
+	return ExitOK
+}
+
+// printStatus 以文本形式输出运行状态
+func printStatus(w io.Writer, s ControlStatus) {
+	switch {
+	case s.Paused && s.PausedUntil != nil:
+		fmt.Fprintf(w, "paused:   until %s, %d event(s) held\n", s.PausedUntil.Format(time.RFC3339), s.Held)
+	case s.Paused:
+		fmt.Fprintf(w, "paused:   until resumed, %d event(s) held\n", s.Held)
+	default:
+		fmt.Fprintln(w, "paused:   no")
+	}
+	fmt.Fprintf(w, "bulk:     %v\n", s.Bulk)
+	fmt.Fprintf(w, "watches:  %d\n", s.Watches)
+	fmt.Fprintf(w, "roots:    %d\n", len(s.Roots))
+	for _, r := range s.Roots {
+		var notes []string
+		switch {
+		case r.File:
+			notes = append(notes, "file")
+		case r.Recursive:
+			notes = append(notes, "recursive")
+		}
+		if r.Polled {
+			notes = append(notes, "polled")
+		}
+		if r.Filesystem != "" {
+			notes = append(notes, r.Filesystem)
+		}
+		fmt.Fprintf(w, "  %s (%s)\n", r.Path, strings.Join(notes, ", "))
+	}
+}
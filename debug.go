@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// DebugState 运行时内部状态快照，用于诊断长时间运行时的内存增长
+type DebugState struct {
+	Goroutines  int    `json:"goroutines"`
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+	NumGC       uint32 `json:"num_gc"`
+
+	Roots         int    `json:"roots"`             // 通过 Watch 注册的根路径
+	Watches       int    `json:"watches"`           // 后端实际注册的 watch 数量
+	EventQueue    int    `json:"event_queue"`       // 后端事件通道中等待处理的事件
+	ErrorQueue    int    `json:"error_queue"`       // 后端错误通道中等待处理的错误
+	PendingTimers int    `json:"pending_timers"`    // 去抖动定时器
+	ForcedFlushes uint64 `json:"forced_flushes"`    // 因定时器数量超限被提前分发的次数
+	Coalescing    int    `json:"pending_coalesced"` // 等待合并的路径
+
+	TrackedSizes  int `json:"tracked_sizes,omitempty"`  // 截断检测和字节统计跟踪的文件
+	TrackedAttrs  int `json:"tracked_attrs,omitempty"`  // 属性跟踪的文件
+	TrackedInodes int `json:"tracked_inodes,omitempty"` // inode 跟踪的路径
+	DedupeEntries int `json:"dedupe_entries,omitempty"` // 去重窗口内的记录
+}
+
+// DebugState 返回当前内部状态
+func (fw *FileWatcher) DebugState() DebugState {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s := DebugState{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapObjects: mem.HeapObjects,
+		NumGC:       mem.NumGC,
+	}
+
+	fw.mu.Lock()
+	s.Roots = len(fw.roots)
+	w := fw.watcher
+	fw.mu.Unlock()
+	s.Watches = len(w.WatchList())
+	s.EventQueue = len(w.events())
+	s.ErrorQueue = len(w.errors())
+
+	if fw.debouncer != nil {
+		s.PendingTimers = fw.debouncer.Pending()
+		s.ForcedFlushes = fw.debouncer.Forced()
+	}
+	if fw.coalescer != nil {
+		s.Coalescing = fw.coalescer.size()
+	}
+	if t := fw.sizes; t != nil {
+		t.mu.Lock()
+		s.TrackedSizes = len(t.sizes)
+		t.mu.Unlock()
+	}
+	if t := fw.attrs; t != nil {
+		t.mu.Lock()
+		s.TrackedAttrs = len(t.attrs)
+		t.mu.Unlock()
+	}
+	if t := fw.inodes; t != nil {
+		t.mu.Lock()
+		s.TrackedInodes = len(t.paths)
+		t.mu.Unlock()
+	}
+	if d := fw.dedupe; d != nil {
+		d.mu.Lock()
+		s.DedupeEntries = len(d.last)
+		d.mu.Unlock()
+	}
+	return s
+}
+
+// registerDebug 注册 pprof 和内部状态端点
+func registerDebug(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, fw.DebugState())
+	})
+}
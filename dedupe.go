@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dedupeKey 判断两次事件"完全相同"的依据
+type dedupeKey struct {
+	op      fsnotify.Op
+	modTime time.Time
+	size    int64
+}
+
+// dedupeEntry 最近一次分发的事件
+type dedupeEntry struct {
+	key  dedupeKey
+	seen time.Time
+}
+
+// deduper 丢弃窗口期内与上次分发完全相同的事件
+type deduper struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   map[string]dedupeEntry
+}
+
+// WithDedupe 在 window 时间内，同一路径的事件类型、修改时间和大小都未变化时丢弃重复事件
+// 去抖动之后仍可能出现"内容没变的重复 WRITE"，此选项在分发前再过滤一次
+func WithDedupe(window time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.dedupe = &deduper{window: window, last: make(map[string]dedupeEntry)}
+	}
+}
+
+// duplicate 判断事件是否与窗口期内上次分发的事件相同，不同时记录本次事件
+func (d *deduper) duplicate(ev Event) bool {
+	path := filepath.Clean(ev.Path)
+	key := dedupeKey{op: ev.Op}
+	if info, err := os.Stat(path); err == nil {
+		key.modTime, key.size = info.ModTime(), info.Size()
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 顺带清理过期记录，避免大量路径时内存无限增长
+	if len(d.last) > 1024 {
+		for p, e := range d.last {
+			if now.Sub(e.seen) > d.window {
+				delete(d.last, p)
+			}
+		}
+	}
+
+	prev, ok := d.last[path]
+	if ok && prev.key == key && now.Sub(prev.seen) <= d.window {
+		return true
+	}
+	d.last[path] = dedupeEntry{key: key, seen: now}
+	return false
+}
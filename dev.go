@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// devStopTimeout 重启时等待旧进程退出的时间，超时后强制结束
+const devStopTimeout = 5 * time.Second
+
+// ANSI 颜色，设置 NO_COLOR 环境变量时关闭
+const (
+	ansiGreen = "\033[32m"
+	ansiRed   = "\033[31m"
+	ansiReset = "\033[0m"
+)
+
+// devRunner Go 项目开发模式：源码变化后重新构建（或测试），成功后重启程序
+type devRunner struct {
+	dir      string   // 项目目录
+	test     bool     // true 时运行 go test ./...，不启动程序
+	bin      string   // 构建产物路径
+	args     []string // 传给程序的参数
+	debounce *Debouncer
+
+	mu     sync.Mutex // 串行化构建与重启
+	child  *exec.Cmd
+	exited chan struct{} // child 退出时关闭
+}
+
+// runDev dev 子命令：监控 Go 源码，自动构建/测试并重启程序
+func runDev(args []string) int {
+	fs := flag.NewFlagSet("dev", flag.ExitOnError)
+	test := fs.Bool("test", false, "run go test ./... on change instead of building and restarting the binary")
+	debounce := fs.Duration("debounce", 200*time.Millisecond, "wait this long after the last change before rebuilding")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s dev [flags] [dir] [-- program args]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dir := "."
+	rest := fs.Args()
+	if len(rest) > 0 && rest[0] != "--" {
+		dir, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+
+	tmp, err := os.MkdirTemp("", "watchdog-dev-")
+	if err != nil {
+		return exitErr(err, "failed to create build directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	bin := filepath.Join(tmp, "app")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	d := &devRunner{
+		dir:      dir,
+		test:     *test,
+		bin:      bin,
+		args:     rest,
+		debounce: NewDebouncer(*debounce),
+	}
+
+	watcher, err := NewFileWatcher(d,
+		WithRecursive(true),
+		WithDebounce(*debounce),
+		WithExclude("vendor", ".git"),
+		WithExtensions(".go", ".mod"),
+	)
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	if _, err := watcher.Watch(dir); err != nil {
+		return exitErr(err, "failed to watch path %s: %v", dir, err)
+	}
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+	logf("dev: watching %s for Go changes (Ctrl+C to stop)", dir)
+
+	d.rebuild()
+	waitForSignal()
+
+	d.mu.Lock()
+	d.stop()
+	d.mu.Unlock()
+	logf("Shutting down...")
+	return ExitOK
+}
+
+// trigger 任意源码变化都合并为一次构建
+func (d *devRunner) trigger(string) error {
+	d.debounce.Debounce("build", d.rebuild)
+	return nil
+}
+
+// OnCreate 实现 EventHandler 接口
+func (d *devRunner) OnCreate(path string) error { return d.trigger(path) }
+
+// OnWrite 实现 EventHandler 接口
+func (d *devRunner) OnWrite(path string) error { return d.trigger(path) }
+
+// OnRemove 实现 EventHandler 接口
+func (d *devRunner) OnRemove(path string) error { return d.trigger(path) }
+
+// OnRename 实现 EventHandler 接口
+func (d *devRunner) OnRename(path string) error { return d.trigger(path) }
+
+// OnChmod 实现 EventHandler 接口，权限变化不触发构建
+func (d *devRunner) OnChmod(string) error { return nil }
+
+// rebuild 构建（或测试）项目，成功后重启程序
+func (d *devRunner) rebuild() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var cmd *exec.Cmd
+	label := "build"
+	if d.test {
+		label = "test"
+		cmd = exec.Command("go", "test", "./...")
+	} else {
+		cmd = exec.Command("go", "build", "-o", d.bin, ".")
+	}
+	cmd.Dir = d.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	logf("dev: running %s", cmd)
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		logf("%s %s failed in %v: %v", colorize(ansiRed, "✘"), label, elapsed, err)
+		return
+	}
+	logf("%s %s succeeded in %v", colorize(ansiGreen, "✔"), label, elapsed)
+
+	if !d.test {
+		d.stop()
+		d.start()
+	}
+}
+
+// start 启动构建好的程序，输出直接转发到终端（调用方需持有 d.mu）
+func (d *devRunner) start() {
+	cmd := exec.Command(d.bin, d.args...)
+	cmd.Dir = d.dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		logf("dev: failed to start program: %v", err)
+		return
+	}
+	logf("dev: started pid %d", cmd.Process.Pid)
+
+	exited := make(chan struct{})
+	d.child, d.exited = cmd, exited
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			logf("dev: pid %d exited: %v", cmd.Process.Pid, err)
+		}
+		close(exited)
+	}()
+}
+
+// stop 结束正在运行的程序：先发送中断信号，超时后强制结束（调用方需持有 d.mu）
+func (d *devRunner) stop() {
+	if d.child == nil {
+		return
+	}
+	proc, exited := d.child.Process, d.exited
+	d.child, d.exited = nil, nil
+
+	// Windows 不支持发送中断信号
+	if runtime.GOOS == "windows" || proc.Signal(os.Interrupt) != nil {
+		proc.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(devStopTimeout):
+		logf("dev: pid %d did not exit after %v, killing", proc.Pid, devStopTimeout)
+		proc.Kill()
+		<-exited
+	}
+}
+
+// colorize 用 ANSI 颜色包装文本
+func colorize(color, s string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	return color + s + ansiReset
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 内容差异处理器的默认限制
+const (
+	defaultDiffMaxFile  = 1 << 20  // 超过 1MB 的文件不做差异比较
+	defaultDiffMaxCache = 64 << 20 // 旧版本缓存总大小上限
+	diffMaxLines        = 1000     // 去掉首尾相同的行后，变化区域超过此行数时不计算差异；LCS 表为 O(n*m)，1000 行约 8MB
+	diffContext         = 3        // unified diff 上下文行数
+)
+
+// DiffHandler 内容差异处理器：缓存匹配文本文件的上一版本，
+// 写入时生成 unified diff，其余事件原样转发给下一个处理器
+// 文件第一次被观察到时只建立缓存，之后的写入才会产生差异；可用 Prime 预先缓存已有文件
+type DiffHandler struct {
+	next     EventHandler
+	patterns []string
+	emit     func(path, diff string) error
+
+	MaxFileSize  int64 // 单个文件大小上限
+	MaxCacheSize int64 // 缓存总大小上限，超出时淘汰最久未用的文件
+
+	mu    sync.Mutex
+	lru   *list.List // 元素为 *diffEntry，最近使用的在前
+	index map[string]*list.Element
+	size  int64
+}
+
+// diffEntry 缓存的文件旧版本
+type diffEntry struct {
+	path    string
+	content []byte
+}
+
+// NewDiffHandler 创建内容差异处理器，patterns 为文件名模式（如 "*.yaml"，语法同 WithExclude）
+// emit 为 nil 时把差异写入日志
+func NewDiffHandler(next EventHandler, emit func(path, diff string) error, patterns ...string) *DiffHandler {
+	if emit == nil {
+		emit = func(path, diff string) error {
+			logf("[DIFF] %s\n%s", path, diff)
+			return nil
+		}
+	}
+	return &DiffHandler{
+		next:         next,
+		patterns:     patterns,
+		emit:         emit,
+		MaxFileSize:  defaultDiffMaxFile,
+		MaxCacheSize: defaultDiffMaxCache,
+		lru:          list.New(),
+		index:        make(map[string]*list.Element),
+	}
+}
+
+func (h *DiffHandler) OnCreate(path string) error {
+	h.remember(path)
+	return h.next.OnCreate(path)
+}
+
+func (h *DiffHandler) OnWrite(path string) error {
+	if err := h.diff(path); err != nil {
+		return err
+	}
+	return h.next.OnWrite(path)
+}
+
+func (h *DiffHandler) OnRemove(path string) error {
+	h.forget(path)
+	return h.next.OnRemove(path)
+}
+
+func (h *DiffHandler) OnRename(path string) error {
+	h.forget(path)
+	return h.next.OnRename(path)
+}
+
+func (h *DiffHandler) OnChmod(path string) error {
+	return h.next.OnChmod(path)
+}
+
+// Init 转发给下一个处理器的 Initializer
+func (h *DiffHandler) Init(ctx context.Context) error {
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 转发给下一个处理器的 Closer
+func (h *DiffHandler) Close() error {
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Prime 遍历目录，缓存已有的匹配文件，使启动后的第一次写入也能产生差异
+func (h *DiffHandler) Prime(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			h.remember(path)
+		}
+		return nil
+	})
+}
+
+// matches 判断文件是否需要做差异比较
+func (h *DiffHandler) matches(path string) bool {
+	if len(h.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range h.patterns {
+		if matchGlob(pattern, filepath.Base(path)) || matchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// read 读取匹配的文本文件，不匹配、过大或非文本时返回 false
+func (h *DiffHandler) read(path string) ([]byte, bool) {
+	if !h.matches(path) {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > h.MaxFileSize {
+		return nil, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !isText(content) {
+		return nil, false
+	}
+	return content, true
+}
+
+// diff 与缓存的旧版本比较并输出差异，然后更新缓存
+func (h *DiffHandler) diff(path string) error {
+	content, ok := h.read(path)
+	if !ok {
+		h.forget(path)
+		return nil
+	}
+
+	h.mu.Lock()
+	var old []byte
+	known := false
+	if elem, ok := h.index[path]; ok {
+		old, known = elem.Value.(*diffEntry).content, true
+	}
+	h.storeLocked(path, content)
+	h.mu.Unlock()
+
+	if !known || bytes.Equal(old, content) {
+		return nil
+	}
+	if d := unifiedDiff(path, string(old), string(content)); d != "" {
+		return h.emit(path, d)
+	}
+	return nil
+}
+
+// remember 缓存文件当前内容
+func (h *DiffHandler) remember(path string) {
+	content, ok := h.read(path)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.storeLocked(path, content)
+}
+
+// storeLocked 写入缓存并按总大小淘汰，调用方需持有 h.mu
+func (h *DiffHandler) storeLocked(path string, content []byte) {
+	if elem, ok := h.index[path]; ok {
+		entry := elem.Value.(*diffEntry)
+		h.size += int64(len(content) - len(entry.content))
+		entry.content = content
+		h.lru.MoveToFront(elem)
+	} else {
+		h.index[path] = h.lru.PushFront(&diffEntry{path: path, content: content})
+		h.size += int64(len(content))
+	}
+
+	for h.size > h.MaxCacheSize && h.lru.Len() > 1 {
+		oldest := h.lru.Back()
+		entry := oldest.Value.(*diffEntry)
+		h.lru.Remove(oldest)
+		delete(h.index, entry.path)
+		h.size -= int64(len(entry.content))
+	}
+}
+
+// forget 移除文件缓存
+func (h *DiffHandler) forget(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if elem, ok := h.index[path]; ok {
+		h.size -= int64(len(elem.Value.(*diffEntry).content))
+		h.lru.Remove(elem)
+		delete(h.index, path)
+	}
+}
+
+// isText 粗略判断内容是否为文本：合法 UTF-8 且不含 NUL
+func isText(content []byte) bool {
+	sample := content
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	return bytes.IndexByte(sample, 0) < 0 && utf8.Valid(content)
+}
+
+// unifiedDiff 生成按行比较的 unified diff，无差异时返回空字符串
+func unifiedDiff(path, oldText, newText string) string {
+	a, b := splitLines(oldText), splitLines(newText)
+
+	// 首尾相同的行不参与 LCS，大文件中的局部修改只需比较变化区域 a[pre:ea] 与 b[pre:eb]
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	ea, eb := len(a), len(b)
+	for ea > pre && eb > pre && a[ea-1] == b[eb-1] {
+		ea--
+		eb--
+	}
+	if ea-pre > diffMaxLines || eb-pre > diffMaxLines {
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(change too large to diff: %d -> %d lines)\n", path, path, ea-pre, eb-pre)
+	}
+
+	// 最长公共子序列：lcs[i-pre][j-pre] 为 a[i:ea] 与 b[j:eb] 的 LCS 长度
+	lcs := make([][]int32, ea-pre+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, eb-pre+1)
+	}
+	for i := ea - 1; i >= pre; i-- {
+		for j := eb - 1; j >= pre; j-- {
+			if a[i] == b[j] {
+				lcs[i-pre][j-pre] = lcs[i-pre+1][j-pre+1] + 1
+			} else {
+				lcs[i-pre][j-pre] = max(lcs[i-pre+1][j-pre], lcs[i-pre][j-pre+1])
+			}
+		}
+	}
+
+	// 回溯得到编辑脚本
+	type line struct {
+		kind byte // ' '、'-'、'+'
+		text string
+		ai   int // 该行在旧文件中的序号（从 0 开始）
+		bi   int // 该行在新文件中的序号
+	}
+	script := make([]line, 0, len(a)+eb-pre)
+	for i := 0; i < pre; i++ {
+		script = append(script, line{' ', a[i], i, i})
+	}
+	i, j := pre, pre
+	for i < ea || j < eb {
+		switch {
+		case i < ea && j < eb && a[i] == b[j]:
+			script = append(script, line{' ', a[i], i, j})
+			i++
+			j++
+		case i < ea && (j == eb || lcs[i-pre+1][j-pre] >= lcs[i-pre][j-pre+1]):
+			// 相同代价时先输出删除行，与常见 diff 工具一致
+			script = append(script, line{'-', a[i], i, j})
+			i++
+		default:
+			script = append(script, line{'+', b[j], i, j})
+			j++
+		}
+	}
+	for ; i < len(a); i, j = i+1, j+1 {
+		script = append(script, line{' ', a[i], i, j})
+	}
+
+	// 按上下文行数切分 hunk
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", path, path)
+	changed := false
+	for k := 0; k < len(script); {
+		if script[k].kind == ' ' {
+			k++
+			continue
+		}
+		changed = true
+		start := max(k-diffContext, 0)
+		end := k
+		for end < len(script) {
+			if script[end].kind != ' ' {
+				end++
+				continue
+			}
+			// 连续未变化的行超过两倍上下文时结束当前 hunk
+			run := end
+			for run < len(script) && script[run].kind == ' ' {
+				run++
+			}
+			if run == len(script) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(script))
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := 0, 0
+		for _, l := range script[start:end] {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+		// 按 unified diff 约定，某一侧行数为 0 时起始行号不加 1
+		oldStart, newStart := script[start].ai, script[start].bi
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, l := range script[start:end] {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		k = end
+	}
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
+
+// splitLines 按行切分，忽略末尾换行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dirBatchMaxHold 目录窗口最长保持打开的时间（相对窗口长度的倍数），避免持续写入的目录永远不分发
+const dirBatchMaxHold = 10
+
+// dirBatch 一个目录窗口内累积的文件事件
+type dirBatch struct {
+	events   []Event // 未达到阈值前保留的事件，达到阈值后不再保留
+	summary  BulkSummary
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// dirCoalescer 两级合并的第二级：按目录汇总逐文件去抖动后的事件
+type dirCoalescer struct {
+	window    time.Duration
+	threshold int
+
+	mu      sync.Mutex
+	batches map[string]*dirBatch // 目录 -> 窗口
+}
+
+// WithDirectoryCoalescing 两级合并：逐文件去抖动后的事件不直接分发，而是按所在目录再等待 window；
+// 窗口内同一目录树下累计达到 threshold 个文件事件时（如解压出 5 万个文件），以一次目录级汇总代替逐文件分发——
+// 实现 BulkHandler 的处理器收到 BulkSummary（Reason 为 "directory"），其他处理器收到对公共祖先目录的一个 WRITE；
+// 未达到阈值时窗口关闭后照常逐个分发。需要同时启用去抖动，优先通道的事件不参与
+func WithDirectoryCoalescing(window time.Duration, threshold int) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.dirs = &dirCoalescer{window: window, threshold: threshold, batches: make(map[string]*dirBatch)}
+	}
+}
+
+// add 把事件并入所在目录树的窗口：已有窗口覆盖该目录时并入，否则以所在目录开一个新窗口，并吸收其下已有的窗口
+func (c *dirCoalescer) add(fw *FileWatcher, ev Event) {
+	dir := filepath.Dir(ev.Path)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, b := "", (*dirBatch)(nil)
+	for k, batch := range c.batches {
+		if isUnder(k, dir) {
+			key, b = k, batch
+			break
+		}
+	}
+	if b == nil {
+		key = dir
+		b = &dirBatch{summary: BulkSummary{Reason: "directory", Started: ev.FirstSeen, Ops: make(map[string]int)}, deadline: now.Add(c.window * dirBatchMaxHold)}
+		for k, child := range c.batches {
+			if isUnder(dir, k) {
+				child.timer.Stop()
+				b.merge(child, c.threshold)
+				delete(c.batches, k)
+			}
+		}
+		c.batches[key] = b
+	} else {
+		b.timer.Stop()
+	}
+	b.append(ev, c.threshold)
+
+	wait := min(c.window, time.Until(b.deadline))
+	b.timer = time.AfterFunc(wait, func() {
+		c.mu.Lock()
+		if c.batches[key] != b {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.batches, key)
+		c.mu.Unlock()
+		c.release(fw, b)
+	})
+}
+
+// append 记录一个事件，达到阈值后只累计汇总
+func (b *dirBatch) append(ev Event, threshold int) {
+	s := &b.summary
+	s.Events++
+	for _, op := range knownOps {
+		if ev.Has(op) {
+			s.Ops[op.String()]++
+		}
+	}
+	if s.Root == "" {
+		s.Root = ev.Path
+	} else {
+		s.Root = commonAncestor(s.Root, ev.Path)
+	}
+	if ev.FirstSeen.Before(s.Started) {
+		s.Started = ev.FirstSeen
+	}
+	if s.Events < threshold {
+		b.events = append(b.events, ev)
+	} else {
+		b.events = nil
+	}
+}
+
+// merge 并入另一个窗口
+func (b *dirBatch) merge(other *dirBatch, threshold int) {
+	s, o := &b.summary, &other.summary
+	s.Events += o.Events
+	for op, n := range o.Ops {
+		s.Ops[op] += n
+	}
+	if s.Root == "" {
+		s.Root = o.Root
+	} else if o.Root != "" {
+		s.Root = commonAncestor(s.Root, o.Root)
+	}
+	if o.Started.Before(s.Started) {
+		s.Started = o.Started
+	}
+	if s.Events < threshold {
+		b.events = append(b.events, other.events...)
+	} else {
+		b.events = nil
+	}
+}
+
+// release 窗口关闭：达到阈值时分发一次目录级汇总，否则逐个分发
+func (c *dirCoalescer) release(fw *FileWatcher, b *dirBatch) {
+	if b.summary.Events < c.threshold {
+		// 合并的子窗口打乱了顺序，按收到时间分发
+		sort.SliceStable(b.events, func(i, j int) bool { return b.events[i].FirstSeen.Before(b.events[j].FirstSeen) })
+		for _, ev := range b.events {
+			fw.dispatchEvent(ev)
+		}
+		return
+	}
+	b.summary.Ended = time.Now()
+	fw.explainf(b.summary.Root, "%d event(s) coalesced into one directory-level event", b.summary.Events)
+	fw.reconcile(b.summary)
+}
+
+// drain 停止并移除所有窗口
+func (c *dirCoalescer) drain() []*dirBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	batches := make([]*dirBatch, 0, len(c.batches))
+	for key, b := range c.batches {
+		b.timer.Stop()
+		batches = append(batches, b)
+		delete(c.batches, key)
+	}
+	return batches
+}
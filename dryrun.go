@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// dryRunKey 上下文中标记演练模式的键
+type dryRunKey struct{}
+
+// WithDryRun 开启演练模式：过滤、去抖动和规则匹配照常执行，但执行命令、移动和删除文件、
+// 提交等动作只记录 "would ..." 日志，用于在真实流量上安全地验证新规则；
+// 处理器可通过 Init 收到的 ctx 调用 IsDryRun 判断
+func WithDryRun(enabled bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.dryRun = enabled
+	}
+}
+
+// ContextWithDryRun 返回标记了演练模式的上下文
+func ContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun 判断上下文是否处于演练模式
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// wouldDo 演练模式下记录本应执行的动作
+func wouldDo(format string, args ...any) {
+	logf("dry-run: would %s", fmt.Sprintf(tr(format), args...))
+}
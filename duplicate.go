@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DuplicateAction 发现重复文件后的处理方式
+type DuplicateAction int
+
+const (
+	DuplicateReport   DuplicateAction = iota // 只报告
+	DuplicateHardlink                        // 把新文件替换为指向已有文件的硬链接，节省空间；两个路径共用一个 inode，之后修改其中一个另一个也会变
+	DuplicateRemove                          // 删除新文件
+)
+
+// ParseDuplicateAction 解析 "report"、"hardlink" 或 "remove"
+func ParseDuplicateAction(s string) (DuplicateAction, error) {
+	switch s {
+	case "report", "":
+		return DuplicateReport, nil
+	case "hardlink":
+		return DuplicateHardlink, nil
+	case "remove":
+		return DuplicateRemove, nil
+	}
+	return 0, fmt.Errorf("unknown duplicate action %q: want report, hardlink or remove", s)
+}
+
+// Duplicate 一个与已有文件内容相同的新文件
+type Duplicate struct {
+	Path     string // 新写入的文件
+	Original string // 内容相同的已有文件
+	Size     int64
+	SHA256   string
+}
+
+// indexedFile 索引中的一个文件，哈希在需要比较时才计算并缓存
+type indexedFile struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// DuplicateHandler 重复文件检测处理器：维护监控目录下文件的内容索引（按大小分组，只在大小相同时才计算哈希），
+// 新建或修改的文件稳定 settle 时间后与已有文件比较，内容相同时调用 OnDuplicate，并按 Action 处理新文件；
+// 所有事件同时转发给下一个处理器
+type DuplicateHandler struct {
+	next        EventHandler
+	root        string
+	action      DuplicateAction
+	settle      *Debouncer
+	OnDuplicate func(Duplicate) // 发现重复时调用，默认记录日志；需在交给监控器之前设置
+
+	ctx    context.Context
+	dryRun bool
+
+	mu     sync.Mutex
+	files  map[string]*indexedFile
+	bySize map[int64]map[string]bool
+}
+
+// NewDuplicateHandler 创建重复文件检测处理器，Init 时为 root 下已有的文件建立索引
+func NewDuplicateHandler(next EventHandler, root string, settle time.Duration, action DuplicateAction) *DuplicateHandler {
+	return &DuplicateHandler{
+		next:   next,
+		root:   root,
+		action: action,
+		settle: NewDebouncer(settle),
+		OnDuplicate: func(d Duplicate) {
+			logf("duplicate: %s has the same content as %s (%s)", d.Path, d.Original, formatBytes(d.Size))
+		},
+		ctx:    context.Background(),
+		files:  make(map[string]*indexedFile),
+		bySize: make(map[int64]map[string]bool),
+	}
+}
+
+func (h *DuplicateHandler) OnCreate(path string) error {
+	h.schedule(path)
+	return h.next.OnCreate(path)
+}
+
+func (h *DuplicateHandler) OnWrite(path string) error {
+	h.schedule(path)
+	return h.next.OnWrite(path)
+}
+
+func (h *DuplicateHandler) OnRemove(path string) error {
+	h.forget(path)
+	return h.next.OnRemove(path)
+}
+
+func (h *DuplicateHandler) OnRename(path string) error {
+	h.forget(path)
+	return h.next.OnRename(path)
+}
+
+func (h *DuplicateHandler) OnChmod(path string) error {
+	return h.next.OnChmod(path)
+}
+
+// Init 为已有文件建立索引，再转发给下一个处理器的 Initializer
+func (h *DuplicateHandler) Init(ctx context.Context) error {
+	h.ctx = ctx
+	h.dryRun = IsDryRun(ctx)
+	n := 0
+	err := filepath.WalkDir(h.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil // 读不了的目录跳过，不影响其他文件
+		}
+		if info, err := d.Info(); err == nil {
+			h.mu.Lock()
+			h.add(path, info)
+			h.mu.Unlock()
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logf("duplicate detection: indexed %d file(s) under %s", n, h.root)
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 处理仍在等待稳定的文件，再转发给下一个处理器的 Closer
+func (h *DuplicateHandler) Close() error {
+	h.settle.Flush()
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// add 把文件加入索引，调用方持有 h.mu
+func (h *DuplicateHandler) add(path string, info os.FileInfo) {
+	h.remove(path)
+	h.files[path] = &indexedFile{size: info.Size(), modTime: info.ModTime()}
+	if h.bySize[info.Size()] == nil {
+		h.bySize[info.Size()] = make(map[string]bool)
+	}
+	h.bySize[info.Size()][path] = true
+}
+
+// remove 从索引中移除文件，调用方持有 h.mu
+func (h *DuplicateHandler) remove(path string) {
+	f := h.files[path]
+	if f == nil {
+		return
+	}
+	delete(h.files, path)
+	delete(h.bySize[f.size], path)
+	if len(h.bySize[f.size]) == 0 {
+		delete(h.bySize, f.size)
+	}
+}
+
+// forget 文件被删除或移走
+func (h *DuplicateHandler) forget(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(path)
+}
+
+// schedule 文件每次变化后重新计时，稳定后检查
+func (h *DuplicateHandler) schedule(path string) {
+	h.settle.Debounce(path, func() { h.check(path) })
+}
+
+// sum 返回文件的哈希，索引中的哈希仍对应当前的大小和修改时间时直接使用，否则在锁外重新计算并缓存；
+// 文件已消失时移出索引并返回空字符串
+func (h *DuplicateHandler) sum(path string) string {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		h.forget(path)
+		return ""
+	}
+	h.mu.Lock()
+	f := h.files[path]
+	if f == nil || info.Size() != f.size || !info.ModTime().Equal(f.modTime) {
+		h.add(path, info)
+		f = h.files[path]
+	}
+	sum := f.sum
+	h.mu.Unlock()
+	if sum != "" {
+		return sum
+	}
+
+	if sum, err = hashFile(path); err != nil {
+		return ""
+	}
+	h.mu.Lock()
+	// 计算期间文件可能又变了，只有索引项仍是这个版本时才缓存
+	if f := h.files[path]; f != nil && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+		f.sum = sum
+	}
+	h.mu.Unlock()
+	return sum
+}
+
+// check 把文件加入索引，并与大小相同的已有文件比较内容；哈希在锁外计算，不阻塞其他事件
+func (h *DuplicateHandler) check(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	h.mu.Lock()
+	h.add(path, info)
+	var others []string
+	if info.Size() > 0 {
+		for other := range h.bySize[info.Size()] {
+			if other != path {
+				others = append(others, other)
+			}
+		}
+	}
+	h.mu.Unlock()
+	if len(others) == 0 {
+		return
+	}
+
+	sum := h.sum(path)
+	if sum == "" {
+		return
+	}
+	var dup *Duplicate
+	for _, other := range others {
+		// 已经是同一个文件（硬链接）的不算重复
+		if oi, err := os.Stat(other); err == nil && os.SameFile(info, oi) {
+			continue
+		}
+		if h.sum(other) == sum {
+			dup = &Duplicate{Path: path, Original: other, Size: info.Size(), SHA256: sum}
+			break
+		}
+	}
+	if dup == nil {
+		return
+	}
+	if h.OnDuplicate != nil {
+		h.OnDuplicate(*dup)
+	}
+	h.apply(*dup)
+}
+
+// unchanged 在删除或替换前重新计算两个文件的哈希，确认比较之后都没有被修改
+func (h *DuplicateHandler) unchanged(d Duplicate) bool {
+	for _, path := range []string{d.Path, d.Original} {
+		if sum, err := hashFile(path); err != nil || sum != d.SHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// apply 按 Action 处理重复的新文件
+func (h *DuplicateHandler) apply(d Duplicate) {
+	switch h.action {
+	case DuplicateHardlink:
+		if h.dryRun {
+			wouldDo("replace %s with a hard link to %s", d.Path, d.Original)
+			return
+		}
+		if !h.unchanged(d) {
+			logf("duplicate: %s or %s changed since the comparison, leaving it", d.Path, d.Original)
+			return
+		}
+		tmp := d.Path + ".dedupe"
+		err := SelfWriteContext(h.ctx, func() error {
+			if err := os.Link(d.Original, tmp); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, d.Path); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			return nil
+		}, d.Path, tmp)
+		if err != nil {
+			logf("duplicate: hardlink %s: %v", d.Path, err)
+		}
+	case DuplicateRemove:
+		if h.dryRun {
+			wouldDo("remove %s", d.Path)
+			return
+		}
+		if !h.unchanged(d) {
+			logf("duplicate: %s or %s changed since the comparison, leaving it", d.Path, d.Original)
+			return
+		}
+		if err := SelfWriteContext(h.ctx, func() error { return os.Remove(d.Path) }, d.Path); err != nil {
+			logf("duplicate: remove %s: %v", d.Path, err)
+			return
+		}
+		h.forget(d.Path)
+	}
+}
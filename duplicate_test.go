@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDuplicateHandler 在已有 original 的目录上创建并初始化处理器，返回 original 的路径
+func newTestDuplicateHandler(t *testing.T, action DuplicateAction) (*DuplicateHandler, string) {
+	t.Helper()
+	root := t.TempDir()
+	original := filepath.Join(root, "original.bin")
+	writeFile(t, original)
+	h := NewDuplicateHandler(NopHandler{}, root, time.Hour, action)
+	if err := h.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return h, original
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	ai, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(ai, bi)
+}
+
+func TestDuplicateHandlerActions(t *testing.T) {
+	for _, action := range []DuplicateAction{DuplicateReport, DuplicateHardlink, DuplicateRemove} {
+		h, original := newTestDuplicateHandler(t, action)
+		var found []Duplicate
+		h.OnDuplicate = func(d Duplicate) { found = append(found, d) }
+
+		second := filepath.Join(h.root, "copy.bin")
+		writeFile(t, second)
+		h.check(second)
+		if len(found) != 1 || found[0].Original != original {
+			t.Fatalf("action %d: duplicates = %+v, want copy.bin matching original.bin", action, found)
+		}
+
+		_, err := os.Stat(second)
+		switch action {
+		case DuplicateReport:
+			if err != nil || sameFile(t, second, original) {
+				t.Errorf("report: second changed (err %v)", err)
+			}
+		case DuplicateHardlink:
+			if err != nil || !sameFile(t, second, original) {
+				t.Errorf("hardlink: second is not a hard link to the original (err %v)", err)
+			}
+		case DuplicateRemove:
+			if !os.IsNotExist(err) {
+				t.Errorf("remove: second still exists (err %v)", err)
+			}
+		}
+		if _, err := os.Stat(original); err != nil {
+			t.Errorf("action %d: original lost: %v", action, err)
+		}
+	}
+}
+
+func TestDuplicateHandlerDifferentContent(t *testing.T) {
+	h, _ := newTestDuplicateHandler(t, DuplicateRemove)
+	h.OnDuplicate = func(d Duplicate) { t.Errorf("unexpected duplicate %+v", d) }
+	other := filepath.Join(h.root, "other.bin")
+	if err := os.WriteFile(other, []byte("DATA"), 0o600); err != nil { // 大小相同，内容不同
+		t.Fatal(err)
+	}
+	h.check(other)
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("file with different content removed: %v", err)
+	}
+}
+
+func TestDuplicateHandlerSkipsChangedFiles(t *testing.T) {
+	for _, action := range []DuplicateAction{DuplicateHardlink, DuplicateRemove} {
+		h, original := newTestDuplicateHandler(t, action)
+		// 比较之后、处理之前原文件被改写
+		h.OnDuplicate = func(Duplicate) {
+			if err := os.WriteFile(original, []byte("edit"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		second := filepath.Join(h.root, "copy.bin")
+		writeFile(t, second)
+		h.check(second)
+
+		if _, err := os.Stat(second); err != nil {
+			t.Errorf("action %d: second removed after the original changed: %v", action, err)
+		} else if sameFile(t, second, original) {
+			t.Errorf("action %d: second linked to a changed original", action)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"watchdogdemo/handlers"
+)
+
+// loadSealer 读取落盘加密的密钥：keyFile（--encryption-key-file）优先，否则取环境变量 WATCHDOG_ENCRYPTION_KEY，
+// 内容为十六进制或 base64 编码的 16/24/32 字节密钥；都没有时返回 nil（不加密）。
+// acceptPlaintext（--encryption-accept-plaintext）允许读取启用加密之前写入的明文记录
+func loadSealer(keyFile string, acceptPlaintext bool) (*handlers.Sealer, error) {
+	text := []byte(os.Getenv(envName("encryption-key")))
+	source := envName("encryption-key")
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read encryption key: %w", err)
+		}
+		text, source = data, keyFile
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+	key, err := handlers.ParseKey(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	sealer, err := handlers.NewSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	sealer.AcceptPlaintext = acceptPlaintext
+	return sealer, nil
+}
+
+// readSealedLines 逐行读取 sealer 加密写入的 JSON Lines 文件（如账本、检查点），对每行明文调用 fn，
+// 返回设置了密钥时读到的明文行数，调用方据此重写文件完成加密迁移。
+// 缺少密钥、遇到不被接受的明文或没有一行能解密（密钥不对）时返回错误；
+// 其余无法解密的行（如崩溃时写了一半的最后一行）跳过，与无法解析的明文行一样
+func readSealedLines(r io.Reader, sealer *handlers.Sealer, fn func(line []byte)) (plaintext int, err error) {
+	opened, failed := 0, 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, err := sealer.OpenLine(scanner.Bytes())
+		if errors.Is(err, handlers.ErrKeyRequired) || errors.Is(err, handlers.ErrPlaintext) {
+			return 0, err
+		}
+		if err != nil {
+			failed++
+			continue
+		}
+		opened++
+		if sealer != nil && handlers.Plaintext(line) {
+			plaintext++
+		}
+		fn(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if opened == 0 && failed > 0 {
+		return 0, handlers.ErrDecrypt
+	}
+	return plaintext, nil
+}
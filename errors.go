@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// 可用 errors.Is 判断的错误类别
+var (
+	// ErrPathNotFound 要监控的路径不存在
+	ErrPathNotFound = errors.New("path not found")
+	// ErrWatchLimitExceeded 超出系统 watch 数量限制（如 inotify max_user_watches）
+	ErrWatchLimitExceeded = errors.New("watch limit exceeded")
+	// ErrBackendFailed fsnotify 后端无法创建，或运行中失效且未能恢复
+	ErrBackendFailed = errors.New("fsnotify backend failed")
+	// ErrAlreadyWatching 路径已经通过 Watch 注册过
+	ErrAlreadyWatching = errors.New("path already watched")
+	// ErrNotWatching 路径未注册或已通过 WatchHandle.Close 注销
+	ErrNotWatching = errors.New("path not watched")
+	// ErrStopped 监控器已停止
+	ErrStopped = errors.New("watcher stopped")
+	// ErrEventLoop 事件由处理器之间的循环引起，已丢弃以打断循环
+	ErrEventLoop = errors.New("event loop detected")
+	// ErrBulkActive 已处于批量模式
+	ErrBulkActive = errors.New("bulk mode already active")
+	// ErrNotBulk 未处于批量模式
+	ErrNotBulk = errors.New("bulk mode not active")
+	// ErrPermissionDenied 无权读取或监控路径
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrSpecialFile 遇到按 WithSpecialFiles 策略应报错的命名管道、套接字、设备或稀疏文件
+	ErrSpecialFile = errors.New("special file")
+
+	errNotAFile = errors.New("not a regular file")
+)
+
+// ErrorHandler 错误处理器接口，应用可据此对监控错误做出反应（重启、告警、退出等）
+type ErrorHandler interface {
+	OnError(err error)
+}
+
+// ErrorHandlerFunc 允许直接使用函数作为 ErrorHandler
+type ErrorHandlerFunc func(err error)
+
+// OnError 实现 ErrorHandler 接口
+func (f ErrorHandlerFunc) OnError(err error) {
+	f(err)
+}
+
+// LoggingErrorHandler 默认错误处理器，只记录日志
+type LoggingErrorHandler struct{}
+
+// OnError 实现 ErrorHandler 接口
+func (h *LoggingErrorHandler) OnError(err error) {
+	logf("watcher error: %v", err)
+}
+
+// HandlerError 事件处理器在重试后仍然失败
+type HandlerError struct {
+	Op       string // 事件类型，如 CREATE、WRITE
+	Path     string // 事件路径
+	Attempts int    // 实际尝试次数
+	Err      error  // 最后一次的错误
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("handler %s failed for %s after %d attempt(s): %v", e.Op, e.Path, e.Attempts, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error { return e.Err }
+
+// WatchError 添加或维护 watch 时失败
+type WatchError struct {
+	Path string
+	Err  error
+}
+
+func (e *WatchError) Error() string {
+	return fmt.Sprintf("watch %s: %v", e.Path, e.Err)
+}
+
+func (e *WatchError) Unwrap() error { return e.Err }
+
+// PartialWatchError 递归注册部分成功：根路径已注册并返回了 WatchHandle，但部分子目录未能添加。
+// 调用方可据 Failed 决定继续运行还是用 WatchHandle.Close 注销；errors.Is 对任一失败的原因成立
+type PartialWatchError struct {
+	Path   string
+	Added  int           // 成功添加的目录数，包括根路径
+	Failed []*WatchError // 未能添加的目录及原因；超出 watch 数量限制时遍历提前结束，其后的目录不在其中
+}
+
+func (e *PartialWatchError) Error() string {
+	return fmt.Sprintf("watch %s: %d director(ies) added, %d failed, first: %v", e.Path, e.Added, len(e.Failed), e.Failed[0])
+}
+
+func (e *PartialWatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, err := range e.Failed {
+		errs[i] = err
+	}
+	return errs
+}
+
+// classifyWatchError 将底层错误归类为哨兵错误，保留原始错误信息
+func classifyWatchError(path string, err error) error {
+	var we *WatchError
+	if errors.As(err, &we) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		err = fmt.Errorf("%w: %w", ErrPathNotFound, err)
+	case errors.Is(err, os.ErrPermission):
+		err = fmt.Errorf("%w: %w", ErrPermissionDenied, err)
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EMFILE):
+		// inotify 在 watch 数量耗尽时返回 ENOSPC，实例数耗尽时返回 EMFILE
+		err = fmt.Errorf("%w: %w", ErrWatchLimitExceeded, err)
+	}
+	return &WatchError{Path: path, Err: err}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event 分发给处理器的完整事件信息
+type Event struct {
+	Path string      // 事件发生的路径
+	Op   fsnotify.Op // 事件类型（位掩码，可能同时包含多种操作）
+	MIME string      // 启用 MIME 探测时为检测到的内容类型，否则为空
+
+	Truncated bool         // 启用截断检测时，写入使文件变小则为 true
+	Attr      *AttrChange  // 启用属性跟踪时 CHMOD 的具体变化，未知时为 nil
+	Xattr     *XattrChange // 启用 xattr 监控时扩展属性的变化，未变化时为 nil
+	Git       *GitInfo     // 启用 git 状态标注时为路径相对 HEAD 的状态
+	Sampled   int          // 启用采样时，自该路径上一个分发的事件以来被采样略去的事件数
+	Bytes     int64        // 启用字节统计时，自该路径上一个分发的事件以来写入的字节数
+	Special   string       // 启用 WithSpecialFiles 时路径的特殊类型（fifo、socket、device、sparse），普通文件为空
+	Inode     *InodeInfo   // 启用 inode 跟踪时文件的设备号、inode 号和硬链接、重命名关系，文件已不存在时为 nil
+
+	// 收到原始事件的时间，同时包含墙上时钟和单调时钟读数；
+	// 去抖动合并多个事件时分别为最早和最晚一次，未合并时两者相同
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Has 判断事件是否包含指定操作
+func (e Event) Has(op fsnotify.Op) bool {
+	return e.Op.Has(op)
+}
+
+// Age 返回从最早收到原始事件到现在的时间（按单调时钟计算，不受系统时间调整影响）
+func (e Event) Age() time.Duration {
+	return time.Since(e.FirstSeen)
+}
+
+// EventAwareHandler 可选接口：需要完整事件信息（如 MIME 类型）的处理器实现此接口
+// 实现后分发器只调用 OnEvent，不再按事件类型调用 OnCreate/OnWrite 等方法
+type EventAwareHandler interface {
+	OnEvent(ev Event) error
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// archiveSchema 归档文件中事件记录的 Avro schema
+const archiveSchema = `{"type":"record","name":"Event","namespace":"watchdogdemo","fields":[` +
+	`{"name":"time","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+	`{"name":"op","type":"string"},` +
+	`{"name":"path","type":"string"},` +
+	`{"name":"size","type":["null","long"],"default":null},` +
+	`{"name":"mime","type":["null","string"],"default":null}]}`
+
+// archiveBlockEvents 每个 Avro 数据块最多包含的事件数
+const archiveBlockEvents = 1000
+
+// EventArchive 归档处理器：把分发的事件追加到按小时切分的 Avro 容器文件（deflate 压缩），
+// 目录按 date=YYYY-MM-DD/hour=HH 分区（UTC），DuckDB（read_avro）和 Athena 可以直接按分区查询长期的变更历史。
+// 事件先攒在内存中，每 1000 个或每个刷新间隔写出一个数据块；文件写到一半时已写出的数据块仍可读取
+type EventArchive struct {
+	dir        string
+	flushEvery time.Duration
+
+	// Retention 保留策略：每小时在后台清理过期分区，并合并已结束小时内的多个文件；零值只合并不清理。需在交给监控器之前设置
+	Retention ArchiveRetention
+
+	mu    sync.Mutex
+	f     *os.File
+	hour  time.Time // 当前文件对应的小时（UTC）
+	sync  [16]byte  // 当前文件的同步标记
+	block bytes.Buffer
+	count int // block 中的事件数
+}
+
+// NewEventArchive 创建归档处理器，文件写入 dir 下的分区目录，flushEvery 为数据块的最长缓冲时间（不为正时为 10 秒）
+func NewEventArchive(dir string, flushEvery time.Duration) (*EventArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if flushEvery <= 0 {
+		flushEvery = 10 * time.Second
+	}
+	return &EventArchive{dir: dir, flushEvery: flushEvery}, nil
+}
+
+// OnEvent 实现 EventAwareHandler 接口
+func (h *EventArchive) OnEvent(ev Event) error {
+	now := time.Now().UTC()
+	var size *int64
+	if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+		if info, err := os.Lstat(ev.Path); err == nil && info.Mode().IsRegular() {
+			n := info.Size()
+			size = &n
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hour := now.Truncate(time.Hour); h.f == nil || !hour.Equal(h.hour) {
+		if err := h.rotate(hour); err != nil {
+			return err
+		}
+	}
+	b := &h.block
+	avroLong(b, now.UnixMicro())
+	avroString(b, formatOps(ev.Op))
+	avroString(b, ev.Path)
+	if size != nil {
+		avroLong(b, 1)
+		avroLong(b, *size)
+	} else {
+		avroLong(b, 0)
+	}
+	if ev.MIME != "" {
+		avroLong(b, 1)
+		avroString(b, ev.MIME)
+	} else {
+		avroLong(b, 0)
+	}
+	h.count++
+	if h.count >= archiveBlockEvents {
+		return h.flush()
+	}
+	return nil
+}
+
+// OnCreate 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *EventArchive) OnCreate(string) error { return nil }
+
+// OnWrite 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *EventArchive) OnWrite(string) error { return nil }
+
+// OnRemove 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *EventArchive) OnRemove(string) error { return nil }
+
+// OnRename 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *EventArchive) OnRename(string) error { return nil }
+
+// OnChmod 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *EventArchive) OnChmod(string) error { return nil }
+
+// Init 启动定时刷新和每小时的清理合并，直到监控停止
+func (h *EventArchive) Init(ctx context.Context) error {
+	go func() {
+		ticker := time.NewTicker(h.flushEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.mu.Lock()
+				if err := h.flush(); err != nil {
+					logf("archive: %v", err)
+				}
+				h.mu.Unlock()
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			res, err := PruneArchive(h.dir, h.Retention, true, time.Now())
+			if err != nil {
+				logf("archive: %v", err)
+			}
+			logPrune(h.dir, res)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Close 写出缓冲的事件并关闭当前文件
+func (h *EventArchive) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeFile()
+}
+
+// rotate 关闭当前文件，在 hour 对应的分区中创建新文件并写入文件头。调用方持有 mu
+func (h *EventArchive) rotate(hour time.Time) error {
+	if err := h.closeFile(); err != nil {
+		return err
+	}
+	dir := filepath.Join(h.dir, "date="+hour.Format("2006-01-02"), "hour="+hour.Format("15"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if _, err := rand.Read(h.sync[:]); err != nil {
+		return err
+	}
+	// 文件名包含打开时间和同步标记，同一小时内重启或多个监控组写同一目录时不会冲突
+	name := fmt.Sprintf("events-%s-%s.avro", time.Now().UTC().Format("20060102T150405Z"), hex.EncodeToString(h.sync[:4]))
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	header.WriteString("Obj\x01")
+	avroLong(&header, 2) // 元数据 map：一个包含两项的块，以 0 结束
+	avroString(&header, "avro.schema")
+	avroString(&header, archiveSchema)
+	avroString(&header, "avro.codec")
+	avroString(&header, "deflate")
+	avroLong(&header, 0)
+	header.Write(h.sync[:])
+	if _, err := f.Write(header.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	h.f, h.hour = f, hour
+	logf("Archiving events to %s", f.Name())
+	return nil
+}
+
+// flush 把缓冲的事件压缩后写成一个数据块。调用方持有 mu
+func (h *EventArchive) flush() error {
+	if h.count == 0 || h.f == nil {
+		return nil
+	}
+	var compressed bytes.Buffer
+	zw, _ := flate.NewWriter(&compressed, flate.DefaultCompression) // 级别合法时不会出错
+	zw.Write(h.block.Bytes())
+	zw.Close()
+
+	var out bytes.Buffer
+	avroLong(&out, int64(h.count))
+	avroLong(&out, int64(compressed.Len()))
+	out.Write(compressed.Bytes())
+	out.Write(h.sync[:])
+	h.block.Reset()
+	h.count = 0
+	if _, err := h.f.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("write %s: %w", h.f.Name(), err)
+	}
+	return nil
+}
+
+// closeFile 写出缓冲的事件并关闭当前文件。调用方持有 mu
+func (h *EventArchive) closeFile() error {
+	if h.f == nil {
+		return nil
+	}
+	err := h.flush()
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	h.f = nil
+	return err
+}
+
+// avroLong 按 Avro 二进制编码写入 long（zigzag 变长整数）
+func avroLong(b *bytes.Buffer, n int64) {
+	b.Write(binary.AppendUvarint(nil, uint64(n<<1^n>>63)))
+}
+
+// avroString 按 Avro 二进制编码写入 string/bytes（长度前缀）
+func avroString(b *bytes.Buffer, s string) {
+	avroLong(b, int64(len(s)))
+	b.WriteString(s)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Command 对文件执行的外部命令
+// 命令通过 shell 执行，文件路径作为 $1 传入，同时设置环境变量 WATCHDOG_FILE
+type Command struct {
+	Line string // shell 命令行，如 `gzip -k "$1"`
+	Dir  string // 工作目录，空表示当前目录
+}
+
+// Run 对 path 执行命令，命令退出码非 0 时返回错误（包含命令输出）；演练模式下只记录日志
+func (c Command) Run(ctx context.Context, path string) error {
+	if IsDryRun(ctx) {
+		wouldDo("run %q on %s", c.Line, path)
+		return nil
+	}
+	out, err := c.output(ctx, path)
+	if err != nil {
+		return fmt.Errorf("command %q on %s: %w: %s", c.Line, path, err, out)
+	}
+	return nil
+}
+
+// output 对 path 执行命令，返回合并的标准输出和标准错误
+func (c Command) output(ctx context.Context, path string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.Line, path)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Line, "sh", path)
+	}
+	cmd.Dir = c.Dir
+	cmd.Env = append(os.Environ(), "WATCHDOG_FILE="+path)
+
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,43 @@
+package main
+
+import "errors"
+
+// 进程退出码，包装脚本和 systemd（RestartPreventExitStatus=、SuccessExitStatus=）可据此区分失败原因
+const (
+	ExitOK           = 0 // 正常退出，包括收到 SIGINT/SIGTERM 后的正常关闭
+	ExitFailure      = 1 // 其他错误（diff 子命令表示存在差异）
+	ExitConfig       = 2 // 命令行参数或配置错误
+	ExitPathNotFound = 3 // 要监控的路径不存在
+	ExitWatchLimit   = 4 // 超出系统 watch 数量限制
+	ExitBackend      = 5 // fsnotify 后端无法创建或运行中失效
+	ExitTimeout      = 6 // wait 子命令超时仍未等到匹配的事件
+)
+
+// exitCode 返回错误对应的退出码
+func exitCode(err error) int {
+	var ce *ConfigError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &ce):
+		return ExitConfig
+	case errors.Is(err, ErrPathNotFound):
+		return ExitPathNotFound
+	case errors.Is(err, ErrWatchLimitExceeded):
+		return ExitWatchLimit
+	case errors.Is(err, ErrBackendFailed):
+		return ExitBackend
+	}
+	return ExitFailure
+}
+
+// exitWith 记录日志并返回指定的退出码
+func exitWith(code int, format string, args ...any) int {
+	logf(format, args...)
+	return code
+}
+
+// exitErr 记录日志并返回 err 对应的退出码
+func exitErr(err error, format string, args ...any) int {
+	return exitWith(exitCode(err), format, args...)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+)
+
+// WithExplain 开启诊断模式：为每个原始事件记录它经过的每一步判断——命中的排除模式、
+// 是否被去抖动合并或抵消、被哪个过滤器丢弃、哪些处理器和订阅收到了它，
+// 用于回答"为什么这个文件没有触发动作"
+func WithExplain(enabled bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.explain = enabled
+	}
+}
+
+// explainf 诊断模式下记录事件的处理过程
+func (fw *FileWatcher) explainf(path, format string, args ...any) {
+	if !fw.explain {
+		return
+	}
+	logf("[EXPLAIN] %s: %s", path, fmt.Sprintf(tr(format), args...))
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveLimits 解压的上限，用于防范压缩炸弹；0 表示不限
+type ArchiveLimits struct {
+	MaxBytes int64 // 解压后的总字节数
+	MaxFiles int   // 解压出的文件数
+}
+
+// DefaultArchiveLimits 默认解压上限：1 GiB、1 万个文件
+var DefaultArchiveLimits = ArchiveLimits{MaxBytes: 1 << 30, MaxFiles: 10000}
+
+// errArchiveLimit 超出解压上限
+var errArchiveLimit = errors.New("archive exceeds extraction limits")
+
+// archiveSuffixes 支持自动解压的扩展名
+var archiveSuffixes = []string{".zip", ".tar.gz", ".tgz"}
+
+// ArchiveExtractor 自动解压处理器：.zip/.tar.gz 文件到达并稳定 settle 时间后解压到目标目录下
+// 与压缩包同名（去掉扩展名）的子目录，再把解压出的每个文件作为 CREATE 事件交给下一个处理器；
+// 拒绝越出目标目录的条目（../、绝对路径、符号链接），超出 ArchiveLimits 时放弃整个压缩包。
+// 所有事件（包括压缩包本身）同时转发给下一个处理器
+type ArchiveExtractor struct {
+	next      EventHandler
+	target    string
+	limits    ArchiveLimits
+	debouncer *Debouncer
+
+	ctx    context.Context
+	dryRun bool
+}
+
+// NewArchiveExtractor 创建自动解压处理器
+func NewArchiveExtractor(next EventHandler, target string, settle time.Duration, limits ArchiveLimits) (*ArchiveExtractor, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveExtractor{
+		next:      next,
+		target:    abs,
+		limits:    limits,
+		debouncer: NewDebouncer(settle),
+		ctx:       context.Background(),
+	}, nil
+}
+
+func (h *ArchiveExtractor) OnCreate(path string) error {
+	h.schedule(path)
+	return h.next.OnCreate(path)
+}
+
+func (h *ArchiveExtractor) OnWrite(path string) error {
+	h.schedule(path)
+	return h.next.OnWrite(path)
+}
+
+func (h *ArchiveExtractor) OnRemove(path string) error {
+	return h.next.OnRemove(path)
+}
+
+func (h *ArchiveExtractor) OnRename(path string) error {
+	return h.next.OnRename(path)
+}
+
+func (h *ArchiveExtractor) OnChmod(path string) error {
+	return h.next.OnChmod(path)
+}
+
+// Init 记录上下文并转发给下一个处理器的 Initializer
+func (h *ArchiveExtractor) Init(ctx context.Context) error {
+	h.ctx = ctx
+	h.dryRun = IsDryRun(ctx)
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 解压仍在等待稳定的压缩包，再转发给下一个处理器的 Closer
+func (h *ArchiveExtractor) Close() error {
+	h.debouncer.Flush()
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// schedule 压缩包每次变化后重新计时，稳定后解压
+func (h *ArchiveExtractor) schedule(path string) {
+	if archiveName(path) == "" {
+		return
+	}
+	if abs, err := filepath.Abs(path); err != nil || isUnder(h.target, abs) {
+		return // 不解压目标目录中的压缩包，避免嵌套解压
+	}
+	h.debouncer.Debounce(path, func() { h.extract(path) })
+}
+
+// archiveName 返回去掉压缩包扩展名的文件名，不是支持的压缩包时返回空字符串
+func archiveName(path string) string {
+	base := filepath.Base(path)
+	for _, suffix := range archiveSuffixes {
+		if len(base) > len(suffix) && strings.HasSuffix(strings.ToLower(base), suffix) {
+			return base[:len(base)-len(suffix)]
+		}
+	}
+	return ""
+}
+
+// extract 解压到临时目录，成功后改名为目标子目录并为解压出的文件产生事件
+func (h *ArchiveExtractor) extract(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return // 稳定前已被删除或移走
+	}
+	dest := filepath.Join(h.target, archiveName(path))
+	if h.dryRun {
+		wouldDo("extract %s into %s", path, dest)
+		return
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		logf("extract: %s already exists, skipping %s", dest, path)
+		return
+	}
+	tmp := dest + ".extracting"
+
+	var files []string
+	err := SelfWriteContext(h.ctx, func() error {
+		os.RemoveAll(tmp)
+		if err := os.MkdirAll(tmp, 0o755); err != nil {
+			return err
+		}
+		var err error
+		if strings.HasSuffix(strings.ToLower(path), ".zip") {
+			files, err = h.extractZip(path, tmp)
+		} else {
+			files, err = h.extractTarGz(path, tmp)
+		}
+		if err == nil {
+			err = os.Rename(tmp, dest)
+		}
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+		return err
+	}, tmp, dest)
+	if err != nil {
+		logf("extract: %s: %v", path, err)
+		return
+	}
+	logf("extract: %s -> %s (%d file(s))", path, dest, len(files))
+
+	for _, rel := range files {
+		file := filepath.Join(dest, rel)
+		if err := h.next.OnCreate(file); err != nil {
+			logf("extract: handler failed for %s: %v", file, err)
+		}
+	}
+}
+
+// extractTarGz 解压 .tar.gz，返回解压出的文件（相对 dir）
+func (h *ArchiveExtractor) extractTarGz(path, dir string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var files []string
+	var written int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, err := archiveEntryPath(dir, hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0o755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if h.limits.MaxFiles > 0 && len(files) >= h.limits.MaxFiles {
+				return nil, fmt.Errorf("%w: more than %d files", errArchiveLimit, h.limits.MaxFiles)
+			}
+			n, err := h.writeEntry(name, tr, hdr.FileInfo().Mode().Perm(), written)
+			if err != nil {
+				return nil, err
+			}
+			written += n
+			files = append(files, strings.TrimPrefix(name, dir+string(filepath.Separator)))
+		default:
+			// 符号链接、硬链接和设备文件可能指向目标目录之外，不解压
+			logf("extract: skipping %s (unsupported entry type)", hdr.Name)
+		}
+	}
+}
+
+// extractZip 解压 .zip，返回解压出的文件（相对 dir）
+func (h *ArchiveExtractor) extractZip(path, dir string) ([]string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var files []string
+	var written int64
+	for _, zf := range zr.File {
+		name, err := archiveEntryPath(dir, zf.Name)
+		if err != nil {
+			return nil, err
+		}
+		mode := zf.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(name, 0o755); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			logf("extract: skipping %s (unsupported entry type)", zf.Name)
+			continue
+		}
+		if h.limits.MaxFiles > 0 && len(files) >= h.limits.MaxFiles {
+			return nil, fmt.Errorf("%w: more than %d files", errArchiveLimit, h.limits.MaxFiles)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		n, err := h.writeEntry(name, rc, mode.Perm(), written)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		written += n
+		files = append(files, strings.TrimPrefix(name, dir+string(filepath.Separator)))
+	}
+	return files, nil
+}
+
+// archiveEntryPath 返回条目在 dir 下的路径，拒绝绝对路径和越出 dir 的条目
+func archiveEntryPath(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry %q escapes the target directory", name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// writeEntry 写出一个文件条目，written 为之前已写出的字节数，超出总字节数上限时返回错误
+func (h *ArchiveExtractor) writeEntry(name string, r io.Reader, perm os.FileMode, written int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm|0o600)
+	if err != nil {
+		return 0, err
+	}
+	if h.limits.MaxBytes > 0 {
+		// 多读一个字节以识别超限，不信任压缩包中声明的大小
+		r = io.LimitReader(r, h.limits.MaxBytes-written+1)
+	}
+	n, err := io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	if h.limits.MaxBytes > 0 && written+n > h.limits.MaxBytes {
+		return n, fmt.Errorf("%w: more than %s uncompressed", errArchiveLimit, formatBytes(h.limits.MaxBytes))
+	}
+	return n, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// eventFilter 事件过滤器，keep 可修改事件，返回 false 表示丢弃
+type eventFilter struct {
+	name string // 用于 explain 模式的说明
+	keep func(ev *Event) bool
+}
+
+// allOps 所有事件类型
+const allOps = fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename | fsnotify.Chmod
+
+// knownOps 所有事件类型，按固定顺序
+var knownOps = []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod}
+
+// WithOps 只分发指定类型的事件，如 WithOps(fsnotify.Create|fsnotify.Write)
+func WithOps(ops fsnotify.Op) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.opMask = &ops
+		fw.filters = append(fw.filters, eventFilter{"op filter " + formatOps(ops), func(ev *Event) bool {
+			ev.Op &= ops
+			return ev.Op != 0
+		}})
+	}
+}
+
+// WithExtensions 只分发指定扩展名的文件事件（如 ".csv"、"json"，大小写不敏感）
+func WithExtensions(exts ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		set := make(map[string]bool, len(exts))
+		for _, ext := range exts {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			set[ext] = true
+		}
+		fw.extensions = set
+		fw.filters = append(fw.filters, eventFilter{"extension filter", func(ev *Event) bool {
+			return set[strings.ToLower(filepath.Ext(ev.Path))]
+		}})
+	}
+}
+
+// WithSizeRange 只分发大小在 [minSize, maxSize] 字节范围内的文件事件，maxSize 为 0 表示不限上限
+// 已删除或无法 stat 的路径（如 REMOVE、RENAME 事件）不受影响
+func WithSizeRange(minSize, maxSize int64) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.minSize, fw.maxSize = minSize, maxSize
+		fw.filters = append(fw.filters, eventFilter{"size filter", func(ev *Event) bool {
+			info, err := os.Stat(ev.Path)
+			if err != nil || !info.Mode().IsRegular() {
+				return true
+			}
+			if info.Size() < minSize {
+				return false
+			}
+			return maxSize == 0 || info.Size() <= maxSize
+		}})
+	}
+}
+
+// applyFilters 依次应用所有过滤器，返回 false 表示事件被丢弃
+func (fw *FileWatcher) applyFilters(ev *Event) bool {
+	for _, filter := range fw.filters {
+		if !filter.keep(ev) {
+			fw.explainf(ev.Path, "dropped by %s", filter.name)
+			fw.stats.add(&fw.stats.filtered)
+			return false
+		}
+	}
+	return true
+}
+
+// ParseOps 解析逗号分隔的事件类型列表，如 "create,write"
+func ParseOps(s string) (fsnotify.Op, error) {
+	var ops fsnotify.Op
+	for _, name := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "create":
+			ops |= fsnotify.Create
+		case "write":
+			ops |= fsnotify.Write
+		case "remove":
+			ops |= fsnotify.Remove
+		case "rename":
+			ops |= fsnotify.Rename
+		case "chmod":
+			ops |= fsnotify.Chmod
+		case "":
+		default:
+			return 0, fmt.Errorf("unknown op %q (want create, write, remove, rename or chmod)", name)
+		}
+	}
+	return ops, nil
+}
+
+// formatOps 将事件类型格式化为 ParseOps 可解析的形式，如 "create,write"
+func formatOps(op fsnotify.Op) string {
+	var names []string
+	for _, o := range []struct {
+		op   fsnotify.Op
+		name string
+	}{
+		{fsnotify.Create, "create"},
+		{fsnotify.Write, "write"},
+		{fsnotify.Remove, "remove"},
+		{fsnotify.Rename, "rename"},
+		{fsnotify.Chmod, "chmod"},
+	} {
+		if op.Has(o.op) {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseSize 解析带单位的大小，如 "512"、"10K"、"1.5MB"、"2G"（1024 进制）
+func ParseSize(raw string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	return int64(value * float64(multiplier)), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// pollFilesystems 原生 watch 收不到其他主机（或 FUSE 后端）所做修改的文件系统，自动选择时改为轮询
+var pollFilesystems = map[string]bool{
+	"nfs": true, "cifs": true, "smb": true, "smb2": true, "9p": true,
+	"ceph": true, "afs": true, "lustre": true, "vboxsf": true, "fuse": true, "virtiofs": true,
+}
+
+// WithAutoBackend 注册时检测每个根路径所在的文件系统（ext4、xfs、NFS、CIFS、FUSE、overlayfs 等）并按根路径选择监控方式，记录在日志中：
+// 本地文件系统使用原生 watch；网络文件系统和 FUSE 上其他主机的修改不会产生 inotify 事件，改为每 pollInterval 轮询一次，
+// 避免在网络挂载上静默地收不到任何事件。fanotify 需要 CAP_SYS_ADMIN 且 fsnotify 尚不支持，目前不会选择；
+// 无法识别文件系统的平台（非 Linux）一律使用原生 watch
+func WithAutoBackend(pollInterval time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.autoBackend = pollInterval
+		fw.usePoller(pollInterval)
+	}
+}
+
+// Filesystem 返回启用 WithAutoBackend 时检测到的根路径文件系统类型，未检测时为空
+func (h *WatchHandle) Filesystem() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.root.fsType
+}
+
+// pollRoot 检测根路径的文件系统，需要轮询时以轮询注册整个根路径并返回 true
+func (fw *FileWatcher) pollRoot(root *watchRoot) bool {
+	dir := root.path
+	if root.file {
+		dir = filepath.Dir(dir)
+	}
+	fsType, err := filesystemType(dir)
+	if err != nil || fsType == "" {
+		return false
+	}
+	root.fsType = fsType
+	if !pollFilesystems[fsType] {
+		logf("%s is on %s, using native watches", root.path, fsType)
+		return false
+	}
+	logf("%s is on %s, which does not report changes made elsewhere as events; polling every %s", root.path, fsType, fw.autoBackend)
+	root.polled = true
+	fw.poller.poll(fw, root, root.path, fw.autoBackend, false)
+	return true
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// fsMagic statfs 返回的文件系统魔数
+var fsMagic = map[uint32]string{
+	0xEF53:     "ext4", // ext2/ext3/ext4 共用
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlayfs",
+	0x73717368: "squashfs",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x517B:     "smb",
+	0x01021997: "9p",
+	0x00C36400: "ceph",
+	0x5346414F: "afs",
+	0x0BD00BD0: "lustre",
+	0x786F4256: "vboxsf",
+	0x65735546: "fuse",
+	0x6A656A63: "virtiofs",
+	0x4D44:     "vfat",
+	0x5346544E: "ntfs",
+	0x2011BAB0: "exfat",
+	0xF2F52010: "f2fs",
+}
+
+// filesystemType 返回路径所在的文件系统类型，未知的魔数以十六进制表示
+func filesystemType(path string) (string, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", err
+	}
+	magic := uint32(st.Type)
+	if name, ok := fsMagic[magic]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("0x%x", magic), nil
+}
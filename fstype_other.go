@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// filesystemType 当前平台不识别文件系统类型
+func filesystemType(path string) (string, error) {
+	return "", nil
+}
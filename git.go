@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// GitInfo 事件路径相对 HEAD 的 git 状态
+type GitInfo struct {
+	Tracked  bool // 文件已被 git 跟踪
+	Modified bool // 工作区或暂存区相对 HEAD 有改动（含删除）
+}
+
+// ignoreRule 一条 .gitignore 规则
+type ignoreRule struct {
+	pattern  string // 去掉前导和末尾 / 的模式
+	negate   bool   // 以 ! 开头，重新包含
+	dirOnly  bool   // 以 / 结尾，只匹配目录
+	anchored bool   // 含 /，相对 .gitignore 所在目录匹配
+}
+
+// gitRepo 一个仓库的忽略规则
+type gitRepo struct {
+	root  string                  // 仓库根目录（绝对路径）
+	mu    sync.RWMutex            // 保护 rules
+	rules map[string][]ignoreRule // 键为 .gitignore 所在目录相对仓库根的路径，根目录为 "."
+}
+
+// gitAware git 感知配置
+type gitAware struct {
+	annotate bool // 为事件附加 git 状态
+
+	mu    sync.Mutex
+	repos map[string]*gitRepo // 键为目录绝对路径，值为所在仓库（nil 表示不在仓库中）
+	noGit bool                // 找不到 git 命令，跳过状态标注
+}
+
+// WithGitAware 启用 git 感知：跳过 .git 目录，遵守 .gitignore（及 .git/info/exclude）；
+// annotate 为 true 时在 Event.Git 中标注文件是否被跟踪、是否相对 HEAD 有改动（需要 git 命令）
+func WithGitAware(annotate bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.git = &gitAware{annotate: annotate, repos: make(map[string]*gitRepo)}
+		if annotate {
+			if _, err := exec.LookPath("git"); err != nil {
+				logf("git not found in PATH, events will not be annotated with git status")
+				fw.git.noGit = true
+			}
+		}
+	}
+}
+
+// repoFor 返回包含目录 dir 的仓库，首次访问时加载其忽略规则
+func (g *gitAware) repoFor(dir string) *gitRepo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var visited []string
+	for cur := dir; ; cur = filepath.Dir(cur) {
+		if repo, ok := g.repos[cur]; ok {
+			for _, v := range visited {
+				g.repos[v] = repo
+			}
+			return repo
+		}
+		visited = append(visited, cur)
+		if _, err := os.Stat(filepath.Join(cur, ".git")); err == nil {
+			repo := loadGitRepo(cur)
+			for _, v := range visited {
+				g.repos[v] = repo
+			}
+			return repo
+		}
+		if parent := filepath.Dir(cur); parent == cur {
+			break
+		}
+	}
+	for _, v := range visited {
+		g.repos[v] = nil
+	}
+	return nil
+}
+
+// loadGitRepo 自顶向下加载仓库中的 .gitignore，已忽略的目录不再深入
+func loadGitRepo(root string) *gitRepo {
+	repo := &gitRepo{root: root, rules: make(map[string][]ignoreRule)}
+	repo.loadFile(".", filepath.Join(root, ".git", "info", "exclude"))
+
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || (p != root && repo.ignored(p, true)) {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(root, p)
+		repo.loadFile(filepath.ToSlash(rel), filepath.Join(p, ".gitignore"))
+		return nil
+	})
+	return repo
+}
+
+// loadFile 解析一个忽略文件，追加到 base 目录的规则中
+func (r *gitRepo) loadFile(base, file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	rules := parseGitignore(data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if filepath.Base(file) == ".gitignore" {
+		// .git/info/exclude 与根目录 .gitignore 共用 "."，重新加载时保留 exclude 规则
+		var kept []ignoreRule
+		if base == "." {
+			kept = parseGitignoreFile(filepath.Join(r.root, ".git", "info", "exclude"))
+		}
+		r.rules[base] = append(kept, rules...)
+		return
+	}
+	r.rules[base] = append(r.rules[base], rules...)
+}
+
+// parseGitignoreFile 读取并解析忽略文件，读取失败时返回 nil
+func parseGitignoreFile(file string) []ignoreRule {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return parseGitignore(data)
+}
+
+// parseGitignore 解析 .gitignore 内容
+func parseGitignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// match 判断规则是否匹配相对 .gitignore 所在目录的路径 rel
+func (rule ignoreRule) match(rel string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if rule.anchored {
+		return matchSegments(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+	}
+	ok, _ := path.Match(rule.pattern, path.Base(rel))
+	return ok
+}
+
+// ignored 判断仓库内的绝对路径是否被忽略（任一上级目录被忽略时同样忽略）
+func (r *gitRepo) ignored(abs string, isDir bool) bool {
+	rel, err := filepath.Rel(r.root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if parts[i] == ".git" {
+			return true
+		}
+		prefixIsDir := i < len(parts)-1 || isDir
+		if r.matchLocked(strings.Join(parts[:i+1], "/"), prefixIsDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLocked 按 git 的优先级（深层目录的规则、后出现的规则优先）判断单个路径
+func (r *gitRepo) matchLocked(rel string, isDir bool) bool {
+	bases := make([]string, 0, len(r.rules))
+	for base := range r.rules {
+		if base == "." || rel == base || strings.HasPrefix(rel, base+"/") {
+			bases = append(bases, base)
+		}
+	}
+	// 浅层目录先处理，深层目录的规则可以覆盖
+	level := func(base string) int {
+		if base == "." {
+			return 0
+		}
+		return strings.Count(base, "/") + 1
+	}
+	sort.Slice(bases, func(i, j int) bool { return level(bases[i]) < level(bases[j]) })
+
+	ignored := false
+	for _, base := range bases {
+		sub := rel
+		if base != "." {
+			sub = strings.TrimPrefix(rel, base+"/")
+		}
+		for _, rule := range r.rules[base] {
+			if rule.match(sub, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// ignored 判断路径是否应按 git 规则忽略
+func (g *gitAware) ignored(p string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	if filepath.Base(abs) == ".git" || strings.Contains(abs, string(filepath.Separator)+".git"+string(filepath.Separator)) {
+		return true
+	}
+	repo := g.repoFor(filepath.Dir(abs))
+	if repo == nil {
+		return false
+	}
+	info, err := os.Lstat(abs)
+	return repo.ignored(abs, err == nil && info.IsDir())
+}
+
+// observe .gitignore 变化时重新加载对应目录的规则
+func (g *gitAware) observe(event fsnotify.Event) {
+	if filepath.Base(event.Name) != ".gitignore" {
+		return
+	}
+	abs, err := filepath.Abs(event.Name)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(abs)
+	repo := g.repoFor(dir)
+	if repo == nil {
+		return
+	}
+
+	rel, _ := filepath.Rel(repo.root, dir)
+	base := filepath.ToSlash(rel)
+	if _, err := os.Stat(abs); err != nil {
+		repo.mu.Lock()
+		delete(repo.rules, base)
+		if base == "." {
+			repo.rules[base] = parseGitignoreFile(filepath.Join(repo.root, ".git", "info", "exclude"))
+		}
+		repo.mu.Unlock()
+		return
+	}
+	repo.loadFile(base, abs)
+}
+
+// status 通过 git 命令查询路径相对 HEAD 的状态
+func (g *gitAware) status(p string) *GitInfo {
+	if !g.annotate || g.noGit {
+		return nil
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil
+	}
+	repo := g.repoFor(filepath.Dir(abs))
+	if repo == nil {
+		return nil
+	}
+
+	out, err := exec.Command("git", "-C", repo.root, "status", "--porcelain", "--", abs).Output()
+	if err != nil {
+		return nil
+	}
+	line := strings.TrimSpace(string(out))
+	switch {
+	case line == "":
+		// 没有改动：要么被跟踪且干净，要么不存在于仓库中
+		err := exec.Command("git", "-C", repo.root, "ls-files", "--error-unmatch", "--", abs).Run()
+		return &GitInfo{Tracked: err == nil}
+	case strings.HasPrefix(line, "??"):
+		return &GitInfo{}
+	default:
+		return &GitInfo{Tracked: true, Modified: true}
+	}
+}
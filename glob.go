@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob 判断相对路径是否匹配模式
+// 模式语法与 path.Match 相同，额外支持 ** 匹配零个或多个目录层级；
+// 不含 / 的模式（如 node_modules、*.log）匹配任意一级路径元素
+func matchGlob(pattern, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	pattern = filepath.ToSlash(pattern)
+
+	if !strings.Contains(pattern, "/") {
+		for _, name := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments 逐段匹配，处理 ** 通配
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// ** 可以吞掉 0 到全部剩余路径段
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// validGlob 检查模式语法是否合法
+func validGlob(pattern string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hasGlobMeta 判断路径是否包含通配符
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globWatch WatchGlob 注册的通配符模式
+type globWatch struct {
+	pattern string
+	opts    []WatchOption
+	matched map[string]*WatchHandle // 由该模式注册的匹配路径
+}
+
+// WatchGlob 监控匹配通配符模式的所有路径（filepath.Glob 语法，如 "services/*/config"），返回当前匹配数；
+// rescan > 0 时每隔 rescan 重新展开模式，监控新出现的匹配并注销已消失的匹配；
+// rescan 为 0 且没有任何匹配时返回 ErrPathNotFound
+func (fw *FileWatcher) WatchGlob(pattern string, rescan time.Duration, opts ...WatchOption) (int, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return 0, &ConfigError{Problems: []string{fmt.Sprintf("invalid glob %q: %v", pattern, err)}}
+	}
+	g := &globWatch{pattern: pattern, opts: opts, matched: make(map[string]*WatchHandle)}
+	if _, errs := fw.expandGlob(g); len(errs) > 0 {
+		return len(g.matched), errors.Join(errs...)
+	}
+	if len(g.matched) == 0 && rescan <= 0 {
+		return 0, &WatchError{Path: pattern, Err: fmt.Errorf("%w: no matches", ErrPathNotFound)}
+	}
+	if rescan > 0 {
+		go fw.rescanGlob(g, rescan)
+	}
+	return len(g.matched), nil
+}
+
+// expandGlob 展开模式：监控新匹配，注销已消失的匹配，返回新增的匹配数和注册失败的错误
+func (fw *FileWatcher) expandGlob(g *globWatch) (added int, errs []error) {
+	matches, _ := filepath.Glob(g.pattern) // 模式已校验，Glob 只会返回 ErrBadPattern
+	current := make(map[string]bool, len(matches))
+	for _, path := range matches {
+		current[path] = true
+		if g.matched[path] != nil {
+			continue
+		}
+		h, err := fw.Watch(path, g.opts...)
+		if errors.Is(err, ErrAlreadyWatching) {
+			continue // 已由其他参数注册，不归该模式管理
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if h == nil {
+			continue
+		}
+		g.matched[path] = h
+		added++
+	}
+	for path, h := range g.matched {
+		if !current[path] {
+			h.Close()
+			delete(g.matched, path)
+			logf("%s no longer matches %s, unwatched", path, g.pattern)
+		}
+	}
+	return added, errs
+}
+
+// rescanGlob 定期重新展开模式，直到监控器停止
+func (fw *FileWatcher) rescanGlob(g *globWatch, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.done:
+			return
+		case <-ticker.C:
+		}
+		added, errs := fw.expandGlob(g)
+		for _, err := range errs {
+			fw.reportError(err)
+		}
+		if added > 0 {
+			logf("Watching %d new match(es) for %s", added, g.pattern)
+		}
+	}
+}
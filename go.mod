@@ -1,7 +1,8 @@
 module watchdogdemo
 
-go 1.21
+go 1.23
 
-require github.com/fsnotify/fsnotify v1.7.0
-
-require golang.org/x/sys v0.4.0 // indirect
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/sys v0.4.0
+)
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// watchGroup 监控组：拥有独立的路径、过滤器、去抖动和处理器，与其他组运行在同一进程中
+type watchGroup struct {
+	name    string // 未使用配置文件的 groups 时为空
+	cfg     *watchConfig
+	shared  *SharedBackend // 多个组共享的后端
+	loops   *LoopDetector  // 所有组共享的循环检测器
+	watcher *FileWatcher
+}
+
+// groupConfigs 为配置文件中的每个监控组生成独立配置（按组名排序）：组内设置覆盖顶层设置和 profile，
+// WATCHDOG_* 环境变量和命令行参数仍对所有组生效；语言、输出、控制接口、统计和关闭超时是进程级设置，只取顶层配置，如
+// {"exclude": ".git", "groups": {"web": {"paths": ["/srv/www"], "debounce": "50ms"}, "ingest": {"hot-folder": "/data/in", "exec": "ingest.sh"}}}
+func (c *watchConfig) groupConfigs() ([]*watchGroup, error) {
+	names := make([]string, 0, len(c.file.groups))
+	for name := range c.file.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([]*watchGroup, 0, len(names))
+	files := make(map[string]string)
+	for _, name := range names {
+		cfg := &watchConfig{explicit: c.explicit, file: c.file}
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		cfg.register(fs)
+
+		settings := c.file.settings(c.Config)
+		values := make(map[string]string)
+		var paths []string
+		if err := mergeSettings(values, &paths, c.file.groups[name]); err != nil {
+			return nil, fmt.Errorf("config file %s: group %s: %w", c.Config, name, err)
+		}
+		for key, value := range values {
+			settings[key] = setting{value, "config file " + c.Config + " group " + name}
+		}
+		if err := cfg.apply(fs, settings); err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 && cfg.HotFolder == "" {
+			return nil, fmt.Errorf("config file %s: group %s has no paths", c.Config, name)
+		}
+		cfg.Paths = paths
+		// 多个组写同一个录制文件、账本或检查点文件会互相覆盖
+		for _, file := range []string{cfg.Record, cfg.Ledger, cfg.Checkpoint} {
+			if file == "" {
+				continue
+			}
+			if other, ok := files[file]; ok {
+				return nil, fmt.Errorf("config file %s: groups %s and %s both write %s", c.Config, other, name, file)
+			}
+			files[file] = name
+		}
+		groups = append(groups, &watchGroup{name: name, cfg: cfg})
+	}
+
+	// 组 A 写入组 B 监控的路径、B 又写回 A 时事件会无限循环
+	outputs := make(map[string][]string, len(groups))
+	paths := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		if g.cfg.WritesTo != "" {
+			outputs[g.name] = strings.Split(g.cfg.WritesTo, ",")
+		}
+		paths[g.name] = g.cfg.Paths
+		if g.cfg.HotFolder != "" {
+			paths[g.name] = append(paths[g.name], g.cfg.HotFolder)
+		}
+	}
+	if cycle := outputCycle(outputs, paths); cycle != nil {
+		return nil, fmt.Errorf("config file %s: watch groups write into each other's paths: %s", c.Config, formatCycle(cycle))
+	}
+	return groups, nil
+}
+
+// setup 按组配置创建监控器并添加监控路径（尚未启动），返回退出时调用的 cleanup；失败时返回非零退出码
+func (g *watchGroup) setup() (cleanup func(), code int) {
+	var closers []func() error
+	cleanup = func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	fail := func(code int) (func(), int) {
+		cleanup()
+		return nil, code
+	}
+	cfg := g.cfg
+	if g.name != "" {
+		logf("Starting watch group %s", g.name)
+	}
+
+	handler, hot, err := cfg.handler()
+	if err != nil {
+		return fail(exitWith(ExitConfig, "%v", err))
+	}
+	opts, err := cfg.options()
+	if err != nil {
+		return fail(exitWith(ExitConfig, "%v", err))
+	}
+	if g.shared != nil {
+		opts = append(opts, WithSharedBackend(g.shared))
+	}
+	if g.loops != nil {
+		opts = append(opts, WithLoopDetection(g.loops))
+	}
+	if cfg.Record != "" {
+		sealer, err := cfg.sealer()
+		if err != nil {
+			return fail(exitWith(ExitConfig, "%v", err))
+		}
+		f, err := os.Create(cfg.Record)
+		if err != nil {
+			return fail(exitErr(err, "failed to create recording: %v", err))
+		}
+		closers = append(closers, f.Close)
+		opts = append(opts, WithRecorder(sealer.NewWriter(f)))
+	}
+
+	watcher, err := NewFileWatcher(handler, opts...)
+	if err != nil {
+		return fail(exitErr(err, "failed to create watcher: %v", err))
+	}
+	closers = append(closers, watcher.Stop)
+	g.watcher = watcher
+
+	// 添加要监控的路径（默认监控当前目录）
+	watchPath := cfg.root()
+	if hot != nil {
+		if _, err := watcher.Watch(hot.Dir, hot.WatchOptions()...); err != nil {
+			return fail(exitErr(err, "failed to watch path %s: %v", hot.Dir, err))
+		}
+		watchPath = hot.Dir
+		logf("Watching: %s (recursive: %v)", hot.Dir, false)
+	} else {
+		paths := cfg.Paths
+		if len(paths) == 0 && len(cfg.listed) == 0 {
+			paths = []string{"."}
+		}
+		for _, path := range paths {
+			// 不存在的路径含通配符时按模式展开，如 'services/*/config'
+			if _, err := os.Stat(path); err != nil && hasGlobMeta(path) {
+				n, err := watcher.WatchGlob(path, cfg.GlobRescan)
+				if err != nil {
+					return fail(exitErr(err, "failed to watch path %s: %v", path, err))
+				}
+				logf("Watching %d match(es) for %s (recursive: %v)", n, path, true)
+				continue
+			}
+			h, err := watcher.Watch(path)
+			var partial *PartialWatchError
+			if errors.As(err, &partial) {
+				for _, failed := range partial.Failed {
+					logf("failed to watch %s: %v", failed.Path, failed.Err)
+				}
+			}
+			if err != nil {
+				return fail(exitErr(err, "failed to watch path %s: %v", path, err))
+			}
+			logf("Watching: %s (recursive: %v)", path, true)
+			if skipped := h.Inaccessible(); len(skipped) > 0 {
+				logf("Skipped %d inaccessible subtree(s) under %s", len(skipped), path)
+			}
+		}
+		// 列表是某一时刻的快照，期间已删除或重复的项跳过
+		listed := 0
+		for _, path := range cfg.listed {
+			_, err := watcher.Watch(path, Recursive(false))
+			if errors.Is(err, ErrPathNotFound) || errors.Is(err, ErrAlreadyWatching) {
+				logf("skipping %s: %v", path, err)
+				continue
+			}
+			if err != nil {
+				return fail(exitErr(err, "failed to watch path %s: %v", path, err))
+			}
+			listed++
+		}
+		if len(cfg.listed) > 0 {
+			logf("Watching %d path(s) listed in %s", listed, cfg.FilesFrom)
+		}
+	}
+	if dh, ok := handler.(*DiffHandler); ok {
+		dh.Prime(watchPath)
+	}
+	return cleanup, ExitOK
+}
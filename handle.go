@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// WatchHandle 一次 Watch 调用注册的根路径：Close 精确注销该根路径及其递归添加的子目录，
+// 其他根路径仍需要的目录保持监控；Update 以新的选项重新注册
+type WatchHandle struct {
+	fw *FileWatcher
+
+	mu   sync.Mutex
+	root *watchRoot
+}
+
+// Path 返回注册的根路径（规范写法）
+func (h *WatchHandle) Path() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.root.path
+}
+
+// Close 注销根路径，重复调用或根路径已被其他方式注销时返回 ErrNotWatching
+func (h *WatchHandle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.fw.removeRoot(h.root) {
+		return &WatchError{Path: h.root.path, Err: ErrNotWatching}
+	}
+	return nil
+}
+
+// Update 以新的选项重新注册根路径（选项同 Watch，如 Update(Recursive(false), Exclude("cache"))）：
+// 先按新选项添加 watch，再移除新选项不再需要的目录，期间不会漏掉事件；失败时保留原注册，
+// 部分子目录失败时与 Watch 一样仍然生效并返回 *PartialWatchError
+func (h *WatchHandle) Update(opts ...WatchOption) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fw := h.fw
+	if fw.stopped() {
+		return ErrStopped
+	}
+	old := h.root
+	root := fw.newRoot(old.path, opts)
+	if old.file {
+		root.file = true
+		root.recursive = false
+	}
+	if err := validateRoot(root); err != nil {
+		return err
+	}
+	if err := fw.checkOutputs(root); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	registered := false
+	for _, r := range fw.roots {
+		registered = registered || r == old
+	}
+	fw.mu.Unlock()
+	if !registered {
+		return &WatchError{Path: old.path, Err: ErrNotWatching}
+	}
+
+	err := fw.addRoot(root)
+	var partial *PartialWatchError
+	if err != nil && !errors.As(err, &partial) {
+		// 回滚只为新选项添加的 watch
+		fw.releaseWatches(root)
+		return err
+	}
+	fw.mu.Lock()
+	for i, r := range fw.roots {
+		if r == old {
+			root.change = old.change
+			fw.roots[i] = root
+		}
+	}
+	fw.mu.Unlock()
+	fw.releaseWatches(old)
+	h.root = root
+	return err
+}
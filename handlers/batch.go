@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Compressor 批量负载的压缩算法
+type Compressor struct {
+	Encoding  string // HTTP Content-Encoding / Kafka 头中使用的名称，如 gzip、zstd
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// Gzip 返回 gzip 压缩，level 同 compress/gzip（如 gzip.BestSpeed、gzip.DefaultCompression）
+func Gzip(level int) *Compressor {
+	return &Compressor{Encoding: "gzip", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}}
+}
+
+// EventBatch 一批要发送的事件
+type EventBatch struct {
+	Records  []Record
+	Payload  []byte // 每行一个 Record 的 JSON（NDJSON），按 Encoding 压缩
+	Encoding string // 压缩算法名称，不压缩时为空
+	Size     int    // 压缩前的负载字节数
+}
+
+// BatchOptions 批次的发送条件，任一条件满足即发送；都为 0 时每个事件单独发送
+type BatchOptions struct {
+	MaxEvents  int           // 批次达到该事件数时发送
+	MaxBytes   int           // 未压缩负载达到该字节数时发送
+	MaxDelay   time.Duration // 批次中第一个事件最多等待多久
+	Compressor *Compressor   // 为 nil 时不压缩
+}
+
+// BatchHandler 由 Batch 创建，把事件攒成批次交给 send
+type BatchHandler struct {
+	*wrapped
+	send func(EventBatch) error
+	opts BatchOptions
+
+	sending sync.Mutex // 串行发送，批次按顺序到达
+	mu      sync.Mutex
+	records []Record
+	buf     bytes.Buffer // 已编码的 NDJSON
+	timer   *time.Timer
+
+	// OnError 接收 send 返回的错误，默认记录日志（该批次被丢弃，需要不丢失时在 send 中重试或暂存）；需在交给监控器之前设置
+	OnError func(batch EventBatch, err error)
+}
+
+// Batch 把事件按数量、大小或时间攒成批次，编码为 NDJSON 并可选压缩后交给 send（如一次 HTTP POST 或一条 Kafka 消息），
+// 转发海量小事件时大幅减少请求数和带宽：
+//
+//	export := handlers.Batch(func(b handlers.EventBatch) error {
+//		req, _ := http.NewRequest("POST", url, bytes.NewReader(b.Payload))
+//		req.Header.Set("Content-Type", "application/x-ndjson")
+//		req.Header.Set("Content-Encoding", b.Encoding)
+//		...
+//	}, handlers.BatchOptions{MaxEvents: 1000, MaxBytes: 1 << 20, MaxDelay: 2 * time.Second, Compressor: handlers.Gzip(gzip.BestSpeed)})
+//
+// 达到数量或大小时在分发的调用中同步发送，超时由后台定时器发送，发送失败交给 OnError；Close 发送剩余的事件。
+// 需要 zstd 时用第三方实现构造 Compressor{Encoding: "zstd", NewWriter: ...}
+func Batch(send func(EventBatch) error, opts BatchOptions) *BatchHandler {
+	b := &BatchHandler{
+		send: send,
+		opts: opts,
+		OnError: func(batch EventBatch, err error) {
+			log.Printf("batch of %d event(s) failed: %v", len(batch.Records), err)
+		},
+	}
+	b.wrapped = &wrapped{handle: b.handle}
+	return b
+}
+
+// handle 把事件加入当前批次，满足数量或大小条件时发送
+func (b *BatchHandler) handle(op fsnotify.Op, path string) error {
+	r := Record{Op: op.String(), Path: path, Time: time.Now()}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.records = append(b.records, r)
+	b.buf.Write(line)
+	b.buf.WriteByte('\n')
+	full := len(b.records) >= b.opts.MaxEvents && b.opts.MaxEvents > 0 ||
+		b.buf.Len() >= b.opts.MaxBytes && b.opts.MaxBytes > 0 ||
+		b.opts.MaxEvents <= 0 && b.opts.MaxBytes <= 0 && b.opts.MaxDelay <= 0
+	if !full && b.timer == nil && b.opts.MaxDelay > 0 {
+		b.timer = time.AfterFunc(b.opts.MaxDelay, b.Flush)
+	}
+	b.mu.Unlock()
+	if full {
+		b.Flush()
+	}
+	return nil
+}
+
+// Flush 立即发送当前批次（如果有事件），失败交给 OnError
+func (b *BatchHandler) Flush() {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+	b.mu.Lock()
+	if len(b.records) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	batch, err := b.take()
+	b.mu.Unlock()
+	if err == nil {
+		err = b.send(batch)
+	}
+	if err != nil && b.OnError != nil {
+		b.OnError(batch, err)
+	}
+}
+
+// take 取出当前批次并编码负载，调用方持有 mu
+func (b *BatchHandler) take() (EventBatch, error) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := EventBatch{Records: b.records, Size: b.buf.Len()}
+	payload := bytes.Clone(b.buf.Bytes())
+	b.records = nil
+	b.buf.Reset()
+	if c := b.opts.Compressor; c != nil {
+		var out bytes.Buffer
+		w, err := c.NewWriter(&out)
+		if err != nil {
+			return batch, fmt.Errorf("%s: %w", c.Encoding, err)
+		}
+		if _, err := w.Write(payload); err != nil {
+			return batch, fmt.Errorf("%s: %w", c.Encoding, err)
+		}
+		if err := w.Close(); err != nil {
+			return batch, fmt.Errorf("%s: %w", c.Encoding, err)
+		}
+		payload, batch.Encoding = out.Bytes(), c.Encoding
+	}
+	batch.Payload = payload
+	return batch, nil
+}
+
+// Close 发送剩余的事件
+func (b *BatchHandler) Close() error {
+	b.Flush()
+	return b.wrapped.Close()
+}
@@ -0,0 +1,319 @@
+// Package handlers 提供可组合的事件处理器工具：过滤、广播、异步、重试、限流（阻塞或暂存到磁盘）、故障暂存（可加密落盘）、批量导出和日志
+//
+// Handler 与 watchdogdemo 的 EventHandler 方法集相同，组合结果可以直接传给 NewFileWatcher：
+//
+//	h := handlers.Logging(handlers.Retry(handlers.Async(upload, 4), handlers.DefaultPolicy()))
+//
+// 组合器会把 Init(ctx)/Close() 转发给被包装的处理器，因此 Initializer/Closer 在组合后仍然生效
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Handler 与 watchdogdemo 的 EventHandler 方法集一致
+type Handler interface {
+	OnCreate(path string) error
+	OnWrite(path string) error
+	OnRemove(path string) error
+	OnRename(path string) error
+	OnChmod(path string) error
+}
+
+// Func 用一个函数实现 Handler，op 为单一事件类型
+type Func func(op fsnotify.Op, path string) error
+
+// OnCreate 实现 Handler 接口
+func (f Func) OnCreate(path string) error { return f(fsnotify.Create, path) }
+
+// OnWrite 实现 Handler 接口
+func (f Func) OnWrite(path string) error { return f(fsnotify.Write, path) }
+
+// OnRemove 实现 Handler 接口
+func (f Func) OnRemove(path string) error { return f(fsnotify.Remove, path) }
+
+// OnRename 实现 Handler 接口
+func (f Func) OnRename(path string) error { return f(fsnotify.Rename, path) }
+
+// OnChmod 实现 Handler 接口
+func (f Func) OnChmod(path string) error { return f(fsnotify.Chmod, path) }
+
+// Call 按事件类型调用处理器的对应方法
+func Call(h Handler, op fsnotify.Op, path string) error {
+	switch op {
+	case fsnotify.Create:
+		return h.OnCreate(path)
+	case fsnotify.Write:
+		return h.OnWrite(path)
+	case fsnotify.Remove:
+		return h.OnRemove(path)
+	case fsnotify.Rename:
+		return h.OnRename(path)
+	case fsnotify.Chmod:
+		return h.OnChmod(path)
+	}
+	return fmt.Errorf("unsupported op %v", op)
+}
+
+// wrapped 组合器的公共部分：事件交给 handle，生命周期转发给 inner
+type wrapped struct {
+	handle func(op fsnotify.Op, path string) error
+	inner  []Handler
+}
+
+func (w *wrapped) OnCreate(path string) error { return w.handle(fsnotify.Create, path) }
+func (w *wrapped) OnWrite(path string) error  { return w.handle(fsnotify.Write, path) }
+func (w *wrapped) OnRemove(path string) error { return w.handle(fsnotify.Remove, path) }
+func (w *wrapped) OnRename(path string) error { return w.handle(fsnotify.Rename, path) }
+func (w *wrapped) OnChmod(path string) error  { return w.handle(fsnotify.Chmod, path) }
+
+// Init 将初始化转发给被包装的处理器
+func (w *wrapped) Init(ctx context.Context) error {
+	for _, h := range w.inner {
+		if i, ok := h.(interface{ Init(context.Context) error }); ok {
+			if err := i.Init(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 将关闭转发给被包装的处理器
+func (w *wrapped) Close() error {
+	var errs []error
+	for _, h := range w.inner {
+		if c, ok := h.(interface{ Close() error }); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Filter 只把 pred 返回 true 的事件交给 h
+func Filter(h Handler, pred func(op fsnotify.Op, path string) bool) Handler {
+	return &wrapped{
+		inner: []Handler{h},
+		handle: func(op fsnotify.Op, path string) error {
+			if !pred(op, path) {
+				return nil
+			}
+			return Call(h, op, path)
+		},
+	}
+}
+
+// Tee 把每个事件依次交给所有处理器，一个失败不影响其他处理器，返回所有错误
+func Tee(hs ...Handler) Handler {
+	return &wrapped{
+		inner: hs,
+		handle: func(op fsnotify.Op, path string) error {
+			var errs []error
+			for _, h := range hs {
+				errs = append(errs, Call(h, op, path))
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// Logging 记录每次调用的事件、耗时和错误后交给 h
+func Logging(h Handler) Handler {
+	return &wrapped{
+		inner: []Handler{h},
+		handle: func(op fsnotify.Op, path string) error {
+			start := time.Now()
+			err := Call(h, op, path)
+			elapsed := time.Since(start).Round(time.Microsecond)
+			if err != nil {
+				log.Printf("[%s] %s failed in %v: %v", op, path, elapsed, err)
+			} else {
+				log.Printf("[%s] %s handled in %v", op, path, elapsed)
+			}
+			return err
+		},
+	}
+}
+
+// permanentError 标记不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误包装为不可重试错误，Retry 和监控器的分发器都会立即放弃该事件
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Policy 重试策略（指数退避 + 随机抖动），语义与 watchdogdemo 的 RetryPolicy 一致
+type Policy struct {
+	MaxAttempts int           // 最大尝试次数（含首次）
+	Backoff     time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff  time.Duration // 退避上限，0 表示不限制
+	Jitter      float64       // 随机抖动比例（0~1），避免多个事件同时重试
+}
+
+// DefaultPolicy 返回默认重试策略：最多 3 次，100ms 起步，上限 2s，抖动 20%
+func DefaultPolicy() Policy {
+	return Policy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second, Jitter: 0.2}
+}
+
+// delay 在 [1-Jitter, 1+Jitter] 范围内随机缩放 backoff，不超过 MaxBackoff
+func (p Policy) delay(backoff time.Duration) time.Duration {
+	d := float64(backoff)
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// Retry 按策略重试失败的调用，返回最后一次的错误；Permanent 标记的错误不重试
+func Retry(h Handler, p Policy) Handler {
+	return &wrapped{
+		inner: []Handler{h},
+		handle: func(op fsnotify.Op, path string) error {
+			backoff := p.Backoff
+			for attempt := 1; ; attempt++ {
+				err := Call(h, op, path)
+				if err == nil || attempt >= p.MaxAttempts || IsPermanent(err) {
+					return err
+				}
+				time.Sleep(p.delay(backoff))
+				backoff *= 2
+				if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+					backoff = p.MaxBackoff
+				}
+			}
+		},
+	}
+}
+
+// RateLimit 将调用速率限制在每秒 rps 次以内，超出时阻塞等待（对分发器形成背压）；rps <= 0 表示不限速
+func RateLimit(h Handler, rps float64) Handler {
+	if !(rps > 0) {
+		return &wrapped{
+			inner:  []Handler{h},
+			handle: func(op fsnotify.Op, path string) error { return Call(h, op, path) },
+		}
+	}
+	interval := time.Duration(float64(time.Second) / rps)
+	var mu sync.Mutex
+	var next time.Time
+	return &wrapped{
+		inner: []Handler{h},
+		handle: func(op fsnotify.Op, path string) error {
+			mu.Lock()
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			wait := next.Sub(now)
+			next = next.Add(interval)
+			mu.Unlock()
+
+			time.Sleep(wait)
+			return Call(h, op, path)
+		},
+	}
+}
+
+// asyncTask 异步队列中的一次调用
+type asyncTask struct {
+	op   fsnotify.Op
+	path string
+}
+
+// ErrClosed 处理器已关闭，不再接收调用
+var ErrClosed = errors.New("handler closed")
+
+// AsyncHandler 由 Async 创建，在后台 worker 中执行调用
+type AsyncHandler struct {
+	*wrapped
+	queues []chan asyncTask
+	wg     sync.WaitGroup
+	mu     sync.RWMutex // 入队持读锁，Close 持写锁，关闭队列时没有正在进行的发送
+	closed bool
+
+	// OnError 接收后台调用返回的错误，默认记录日志；需在交给监控器之前设置
+	OnError func(op fsnotify.Op, path string, err error)
+}
+
+// Async 在 workers 个后台 goroutine 中执行 h，调用立即返回；
+// 同一路径的事件总是由同一个 worker 按顺序处理；Close 等待队列中的调用执行完毕
+func Async(h Handler, workers int) *AsyncHandler {
+	if workers < 1 {
+		workers = 1
+	}
+	a := &AsyncHandler{
+		queues: make([]chan asyncTask, workers),
+		OnError: func(op fsnotify.Op, path string, err error) {
+			log.Printf("async handler %s failed for %s: %v", op, path, err)
+		},
+	}
+	a.wrapped = &wrapped{inner: []Handler{h}, handle: a.enqueue}
+	for i := range a.queues {
+		q := make(chan asyncTask, 64)
+		a.queues[i] = q
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			for t := range q {
+				if err := Call(h, t.op, t.path); err != nil && a.OnError != nil {
+					a.OnError(t.op, t.path, err)
+				}
+			}
+		}()
+	}
+	return a
+}
+
+// enqueue 按路径哈希选择 worker；Close 之后返回 ErrClosed
+func (a *AsyncHandler) enqueue(op fsnotify.Op, path string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(path))
+	a.queues[hash.Sum32()%uint32(len(a.queues))] <- asyncTask{op: op, path: path}
+	return nil
+}
+
+// Close 停止接收调用，等待队列清空后关闭被包装的处理器
+func (a *AsyncHandler) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		for _, q := range a.queues {
+			close(q)
+		}
+	}
+	a.mu.Unlock()
+	a.wg.Wait()
+	return a.wrapped.Close()
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrKeyRequired 数据已加密，但没有提供密钥
+var ErrKeyRequired = errors.New("data is encrypted, an encryption key is required")
+
+// ErrDecrypt 无法解密：密钥不对或数据被篡改
+var ErrDecrypt = errors.New("cannot decrypt (wrong key?)")
+
+// ErrPlaintext 设置了密钥，却读到未加密的记录，而 Sealer.AcceptPlaintext 未开启
+var ErrPlaintext = errors.New("unencrypted record found while an encryption key is set")
+
+// Sealer 用 AES-GCM 加密落盘的记录（暂存文件、事件日志、快照），路径和文件名不以明文出现在磁盘上。
+// 每条记录（一行）单独加密为 base64(nonce || 密文)，文件仍可按行追加、截断和压缩。
+// 以 { 开头的行是明文 JSON，默认拒绝，避免能写文件的人用伪造的明文记录绕过加密
+type Sealer struct {
+	aead cipher.AEAD
+
+	// AcceptPlaintext 读取时接受明文行，用于迁移启用加密之前写入的文件；需在使用前设置
+	AcceptPlaintext bool
+}
+
+// NewSealer 用 16、24 或 32 字节的密钥（AES-128/192/256）创建 Sealer
+func NewSealer(key []byte) (*Sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// ParseKey 解析文本形式的密钥：十六进制（如 openssl rand -hex 32 的输出）或 base64，首尾空白被忽略
+func ParseKey(text []byte) ([]byte, error) {
+	text = bytes.TrimSpace(text)
+	validLen := func(n int) bool { return n == 16 || n == 24 || n == 32 }
+	if key, err := hex.DecodeString(string(text)); err == nil && validLen(len(key)) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(string(text)); err == nil && validLen(len(key)) {
+		return key, nil
+	}
+	return nil, errors.New("encryption key must be 16, 24 or 32 bytes, hex or base64 encoded")
+}
+
+// Seal 加密一条记录，返回不含换行的 base64 文本
+func (s *Sealer) Seal(plaintext []byte) []byte {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) // crypto/rand 不会失败
+	}
+	return base64.StdEncoding.AppendEncode(nil, s.aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+// Open 解密 Seal 的结果
+func (s *Sealer) Open(sealed []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.AppendDecode(nil, bytes.TrimSpace(sealed))
+	n := s.aead.NonceSize()
+	if err != nil || len(data) < n {
+		return nil, errors.New("not an encrypted record")
+	}
+	plaintext, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// OpenLine 解密一行（不含换行），空行原样返回。s 为 nil 时只接受明文 JSON 行，遇到加密的行返回 ErrKeyRequired；
+// 否则明文行只在 AcceptPlaintext 开启时原样返回，未开启时返回 ErrPlaintext
+func (s *Sealer) OpenLine(line []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return line, nil
+	}
+	if trimmed[0] == '{' {
+		if s != nil && !s.AcceptPlaintext {
+			return nil, ErrPlaintext
+		}
+		return line, nil
+	}
+	if s == nil {
+		return nil, ErrKeyRequired
+	}
+	return s.Open(trimmed)
+}
+
+// Plaintext 判断一行是否为明文 JSON 记录
+func Plaintext(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// NewWriter 返回按行加密写入 w 的 Writer，适合 JSON Lines；未以换行结尾的数据留到下一次写入。s 为 nil 时直接返回 w
+func (s *Sealer) NewWriter(w io.Writer) io.Writer {
+	if s == nil {
+		return w
+	}
+	return &sealWriter{s: s, w: w}
+}
+
+// sealWriter 由 NewWriter 创建
+type sealWriter struct {
+	s   *Sealer
+	w   io.Writer
+	buf []byte // 尚未遇到换行的数据
+}
+
+// Write 加密并写出 p 中完整的行
+func (sw *sealWriter) Write(p []byte) (int, error) {
+	sw.buf = append(sw.buf, p...)
+	for {
+		i := bytes.IndexByte(sw.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := append(sw.s.Seal(sw.buf[:i]), '\n')
+		sw.buf = sw.buf[i+1:]
+		if _, err := sw.w.Write(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// NewReader 返回按行解密 r 的 Reader，每行按 OpenLine 的规则处理
+func (s *Sealer) NewReader(r io.Reader) io.Reader {
+	return &openReader{s: s, br: bufio.NewReader(r)}
+}
+
+// openReader 由 NewReader 创建
+type openReader struct {
+	s    *Sealer
+	br   *bufio.Reader
+	buf  []byte // 已解密、尚未读出的数据
+	line int
+	err  error
+}
+
+// Read 读出已解密的数据，用完后解密下一行
+func (or *openReader) Read(p []byte) (int, error) {
+	for len(or.buf) == 0 {
+		if or.err != nil {
+			return 0, or.err
+		}
+		line, err := or.br.ReadBytes('\n')
+		if err != nil {
+			or.err = err
+			if len(line) == 0 {
+				continue
+			}
+		}
+		or.line++
+		newline := bytes.HasSuffix(line, []byte("\n"))
+		plain, openErr := or.s.OpenLine(bytes.TrimSuffix(line, []byte("\n")))
+		if openErr != nil {
+			or.err = fmt.Errorf("line %d: %w", or.line, openErr)
+			continue
+		}
+		if newline {
+			plain = append(plain, '\n')
+		}
+		or.buf = plain
+	}
+	n := copy(p, or.buf)
+	or.buf = or.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Record 一个事件的 JSON 形式，暂存文件和批量导出的负载中每行一个
+type Record struct {
+	Op   string    `json:"op"` // fsnotify.Op 的名称，如 WRITE
+	Path string    `json:"path"`
+	Time time.Time `json:"time"` // 收到（或进入暂存）的时间
+}
+
+// ErrSpoolFull 暂存文件达到大小上限，事件未能暂存
+var ErrSpoolFull = errors.New("spool full")
+
+// spoolCompactSize 已投递部分超过该大小且占文件一半以上时重写文件，回收空间
+const spoolCompactSize = 1 << 20
+
+// spool 磁盘上先进先出的事件队列（JSON Lines），全部取出后清空文件；
+// 进程退出时未投递的事件留在文件中，下次打开同一文件时继续投递。调用方负责加锁
+type spool struct {
+	path     string
+	max      int64    // 未投递事件占用的字节数上限，0 表示不限
+	w        *os.File // 追加写入
+	r        *os.File
+	br       *bufio.Reader
+	head     *Record // 已读出、尚未 pop 的队首
+	headLen  int64
+	pending  int
+	size     int64   // 文件大小
+	consumed int64   // 文件开头已投递的字节数
+	sealer   *Sealer // 非 nil 时加密写入的行
+}
+
+// openSpool 打开（必要时创建）暂存文件，统计上次遗留的事件数；max 为未投递事件的字节数上限，0 表示不限
+func openSpool(path string, max int64) (*spool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &spool{path: path, max: max}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(s.r)
+	if err != nil {
+		s.r.Close()
+		s.w.Close()
+		return nil, err
+	}
+	s.pending = bytes.Count(data, []byte("\n"))
+	s.size = int64(len(data))
+	s.r.Seek(0, io.SeekStart)
+	return s, nil
+}
+
+// open 打开写入和读取的文件句柄
+func (s *spool) open() error {
+	w, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(s.path)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	s.w, s.r = w, r
+	if s.br == nil {
+		s.br = bufio.NewReader(r)
+	} else {
+		s.br.Reset(r)
+	}
+	return nil
+}
+
+// setSealer 设置加密写入的密钥并用它读出队首事件：上次遗留的事件已加密而没有密钥或密钥不对时返回错误，
+// 避免把它们当作无法解析的行丢弃
+func (s *spool) setSealer(sealer *Sealer) error {
+	s.sealer = sealer
+	if s.head != nil || s.pending == 0 {
+		return nil
+	}
+	line, err := s.br.ReadBytes('\n')
+	if err != nil {
+		s.r.Seek(s.consumed, io.SeekStart)
+		s.br.Reset(s.r)
+		return nil // 由 peek 处理
+	}
+	e, err := s.decode(line)
+	if err != nil {
+		s.r.Seek(s.consumed, io.SeekStart)
+		s.br.Reset(s.r)
+		if errors.Is(err, ErrKeyRequired) || errors.Is(err, ErrDecrypt) || errors.Is(err, ErrPlaintext) {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		return nil // 无法解析的行由 peek 跳过
+	}
+	s.head, s.headLen = &e, int64(len(line))
+	return nil
+}
+
+// encode 编码一行（含换行），设置了密钥时加密
+func (s *spool) encode(r Record) ([]byte, error) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	if s.sealer != nil {
+		line = s.sealer.Seal(line)
+	}
+	return append(line, '\n'), nil
+}
+
+// decode 解析一行，必要时解密
+func (s *spool) decode(line []byte) (Record, error) {
+	var e Record
+	plain, err := s.sealer.OpenLine(bytes.TrimSpace(line))
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(plain, &e); err != nil {
+		return e, err
+	}
+	if parseOp(e.Op) == 0 {
+		return e, fmt.Errorf("unknown op %q", e.Op)
+	}
+	return e, nil
+}
+
+// push 追加一个事件，超出大小上限时返回 ErrSpoolFull
+func (s *spool) push(op fsnotify.Op, path string) error {
+	line, err := s.encode(Record{Op: op.String(), Path: path, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if s.max > 0 && s.size-s.consumed+int64(len(line)) > s.max {
+		return fmt.Errorf("%w: %s holds %d event(s)", ErrSpoolFull, s.path, s.pending)
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("spool %s: %w", s.path, err)
+	}
+	s.pending++
+	s.size += int64(len(line))
+	return nil
+}
+
+// peek 返回队首事件（不移除），队列为空时返回 false；无法解析的行被跳过并记录日志
+func (s *spool) peek() (Record, fsnotify.Op, bool) {
+	for s.head == nil {
+		if s.pending == 0 {
+			return Record{}, 0, false
+		}
+		line, err := s.br.ReadBytes('\n')
+		if err != nil {
+			// 文件被外部截断或损坏，丢弃计数
+			log.Printf("spool %s: %v, %d event(s) lost", s.path, err, s.pending)
+			s.reset()
+			return Record{}, 0, false
+		}
+		e, err := s.decode(line)
+		if err != nil {
+			log.Printf("spool %s: skipping unreadable entry %q: %v", s.path, bytes.TrimSpace(line), err)
+			s.headLen = int64(len(line))
+			s.pop()
+			continue
+		}
+		s.head, s.headLen = &e, int64(len(line))
+	}
+	return *s.head, parseOp(s.head.Op), true
+}
+
+// pop 移除队首事件，队列清空时截断文件，已投递部分过大时重写文件
+func (s *spool) pop() {
+	s.head = nil
+	s.pending--
+	s.consumed += s.headLen
+	switch {
+	case s.pending <= 0:
+		s.reset()
+	case s.consumed >= spoolCompactSize && s.consumed*2 >= s.size:
+		if err := s.compact(); err != nil {
+			log.Printf("spool %s: compaction failed: %v", s.path, err)
+		}
+	}
+}
+
+// reset 清空队列和文件
+func (s *spool) reset() {
+	s.head, s.pending, s.size, s.consumed = nil, 0, 0, 0
+	s.w.Truncate(0)
+	s.r.Seek(0, io.SeekStart)
+	s.br.Reset(s.r)
+}
+
+// compact 去掉已投递的行：未读部分写入临时文件后替换暂存文件并重新打开
+func (s *spool) compact() error {
+	rest, err := io.ReadAll(s.br)
+	if err != nil {
+		return err
+	}
+	// 先关闭再替换，Windows 不能替换打开中的文件
+	s.r.Close()
+	s.w.Close()
+	if err := s.replace(rest); err != nil {
+		s.open()
+		s.r.Seek(s.consumed, io.SeekStart)
+		s.br.Reset(s.r)
+		return err
+	}
+	s.size, s.consumed = int64(len(rest)), 0
+	return s.open()
+}
+
+// replace 用 data 原子地替换暂存文件
+func (s *spool) replace(data []byte) error {
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// close 把未投递的事件重写为新的暂存文件（去掉已投递的行）后关闭；
+// 进程崩溃时没有机会重写，下次启动会重复投递已投递过的部分
+func (s *spool) close() error {
+	var rest bytes.Buffer
+	if s.head != nil {
+		line, _ := s.encode(*s.head)
+		rest.Write(line)
+	}
+	_, err := io.Copy(&rest, s.br)
+	s.r.Close()
+	s.w.Close()
+	if err != nil || s.pending == 0 || s.consumed == 0 && s.head == nil {
+		return err
+	}
+	return s.replace(rest.Bytes())
+}
+
+// parseOp 解析 fsnotify.Op 的名称，未知名称返回 0
+func parseOp(name string) fsnotify.Op {
+	for _, op := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod} {
+		if op.String() == name {
+			return op
+		}
+	}
+	return 0
+}
+
+// SpoolHandler 由 Spool 创建，h 失败（如下游不可用）时把事件暂存到磁盘，恢复后按顺序补投
+type SpoolHandler struct {
+	*wrapped
+	h Handler
+
+	mu       sync.Mutex
+	spool    *spool
+	failing  bool // 下游不可用，用于只在开始和恢复时记录日志
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	started  sync.Once
+	stopped  sync.Once
+	attempts int // 当前队首的投递次数
+
+	// Backoff 下游不可用时重试队首事件的初始间隔，之后每次翻倍直到 MaxBackoff；需在交给监控器之前设置
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Sealer 非 nil 时暂存文件中的事件加密存放，见 Sealer；需在交给监控器之前设置
+	Sealer *Sealer
+}
+
+// Spool 在 h 返回错误时把事件按顺序追加到 spoolFile（最多 maxBytes 字节，0 表示不限），
+// 后台 goroutine 以指数退避重试队首事件，成功后依次补投；有暂存事件时新事件也排在后面，同一路径的事件保持顺序，
+// 下游（消息队列、webhook、采集服务）中断十分钟也不丢失变更通知。暂存文件已满时调用返回 ErrSpoolFull，交给监控器的错误报告：
+//
+//	kafka, _ := handlers.Spool(produce, "/var/spool/watchdog/kafka.jsonl", 512<<20)
+//	watcher, _ := NewFileWatcher(kafka)
+//
+// 可与 Throttle 组合：handlers.Throttle(kafka, limit, ...) 限速后的投递失败同样进入暂存。
+// Init 时开始补投（包括上次退出时遗留的事件）；Close 停止补投，未投递的事件留在文件中
+func Spool(h Handler, spoolFile string, maxBytes int64) (*SpoolHandler, error) {
+	s, err := openSpool(spoolFile, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+	sh := &SpoolHandler{
+		h:          h,
+		spool:      s,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		Backoff:    time.Second,
+		MaxBackoff: time.Minute,
+	}
+	sh.wrapped = &wrapped{inner: []Handler{h}, handle: sh.handle}
+	if s.pending > 0 {
+		log.Printf("spool: %d event(s) left in %s from a previous run", s.pending, spoolFile)
+		sh.failing = true
+	}
+	return sh, nil
+}
+
+// Spooled 返回暂存中等待投递的事件数
+func (sh *SpoolHandler) Spooled() int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.spool.pending
+}
+
+// handle 没有暂存事件时直接调用，失败或已有暂存事件时暂存
+func (sh *SpoolHandler) handle(op fsnotify.Op, path string) error {
+	sh.mu.Lock()
+	if sh.spool.pending == 0 {
+		sh.mu.Unlock()
+		err := Call(sh.h, op, path)
+		if err == nil {
+			return nil
+		}
+		sh.mu.Lock()
+		if !sh.failing {
+			sh.failing = true
+			log.Printf("spool: handler failed (%v), spooling events to %s", err, sh.spool.path)
+		}
+	}
+	err := sh.spool.push(op, path)
+	sh.mu.Unlock()
+	select {
+	case sh.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// drain 依次补投暂存的事件，队首失败时退避重试，直到 Close
+func (sh *SpoolHandler) drain() {
+	defer close(sh.done)
+	backoff := sh.Backoff
+	for {
+		sh.mu.Lock()
+		e, op, ok := sh.spool.peek()
+		if !ok {
+			if sh.failing {
+				sh.failing = false
+				log.Printf("spool: handler recovered, %s drained", sh.spool.path)
+			}
+			sh.mu.Unlock()
+			select {
+			case <-sh.wake:
+				continue
+			case <-sh.stop:
+				return
+			}
+		}
+		sh.mu.Unlock()
+
+		if err := Call(sh.h, op, e.Path); err != nil {
+			sh.attempts++
+			if sh.attempts == 1 || sh.attempts%10 == 0 {
+				log.Printf("spool: %s %s still failing after %d attempt(s), retrying in %v: %v", op, e.Path, sh.attempts, backoff, err)
+			}
+			select {
+			case <-time.After(backoff):
+			case <-sh.stop:
+				return
+			}
+			backoff = min(backoff*2, max(sh.MaxBackoff, sh.Backoff))
+			continue
+		}
+		sh.attempts, backoff = 0, sh.Backoff
+		sh.mu.Lock()
+		sh.spool.pop()
+		sh.mu.Unlock()
+	}
+}
+
+// Init 初始化被包装的处理器后开始补投暂存的事件；暂存文件中遗留的事件已加密而 Sealer 未设置或密钥不对、或设置了 Sealer 而遗留的是明文（未开启 AcceptPlaintext）时返回错误
+func (sh *SpoolHandler) Init(ctx context.Context) error {
+	sh.mu.Lock()
+	err := sh.spool.setSealer(sh.Sealer)
+	sh.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	if err := sh.wrapped.Init(ctx); err != nil {
+		return err
+	}
+	sh.started.Do(func() { go sh.drain() })
+	return nil
+}
+
+// Close 停止补投（正在进行的一次投递完成后），关闭暂存文件和被包装的处理器；未投递的事件留在暂存文件中
+func (sh *SpoolHandler) Close() error {
+	sh.stopped.Do(func() {
+		close(sh.stop)
+		sh.started.Do(func() { close(sh.done) }) // 从未 Init 时没有后台 goroutine
+		<-sh.done
+		sh.mu.Lock()
+		sh.spool.close()
+		sh.mu.Unlock()
+	})
+	return sh.wrapped.Close()
+}
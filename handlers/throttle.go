@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Limit 一个目标（webhook、Slack、Kafka 等）的速率限制，令牌桶：平均每秒 Rate 次，最多连续 Burst 次
+type Limit struct {
+	Rate  float64
+	Burst int // 小于 1 时按 1
+}
+
+// ThrottledHandler 由 Throttle 创建，超出速率限制的调用暂存到磁盘，由后台按限制的速率投递
+type ThrottledHandler struct {
+	*wrapped
+	h     Handler
+	limit Limit
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	spool    *spool
+	spooling bool // 正在暂存，用于只在开始和清空时记录日志
+
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	started sync.Once
+	stopped sync.Once
+
+	// OnError 接收后台投递返回的错误，默认记录日志；需在交给监控器之前设置
+	OnError func(op fsnotify.Op, path string, err error)
+	// Sealer 非 nil 时暂存文件中的事件加密存放，见 Sealer；需在交给监控器之前设置
+	Sealer *Sealer
+}
+
+// Throttle 按 limit 限制对 h 的调用速率。与 RateLimit 阻塞分发器不同，超出限制的调用立即返回，
+// 事件按顺序追加到 spoolFile，由后台 goroutine 在令牌允许时投递；有暂存事件时新事件也排在后面，保持顺序。
+// 每个目标单独包装并使用各自的暂存文件，批量变更时不会让下游 API 因请求过多而限流或封禁 IP：
+//
+//	webhook, _ := handlers.Throttle(postWebhook, handlers.Limit{Rate: 5, Burst: 20}, "/var/spool/watchdog/webhook.jsonl")
+//	slack, _ := handlers.Throttle(notifySlack, handlers.Limit{Rate: 1}, "/var/spool/watchdog/slack.jsonl")
+//	watcher, _ := NewFileWatcher(handlers.Tee(webhook, slack))
+//
+// Init 时开始投递（包括上次退出时遗留在暂存文件中的事件）；Close 停止投递，未投递的事件留在文件中
+func Throttle(h Handler, limit Limit, spoolFile string) (*ThrottledHandler, error) {
+	if limit.Rate <= 0 {
+		return nil, fmt.Errorf("throttle: rate must be positive, got %g", limit.Rate)
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	s, err := openSpool(spoolFile, 0)
+	if err != nil {
+		return nil, fmt.Errorf("throttle: %w", err)
+	}
+	t := &ThrottledHandler{
+		h:      h,
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+		spool:  s,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		OnError: func(op fsnotify.Op, path string, err error) {
+			log.Printf("throttled handler %s failed for %s: %v", op, path, err)
+		},
+	}
+	t.wrapped = &wrapped{inner: []Handler{h}, handle: t.handle}
+	if s.pending > 0 {
+		log.Printf("throttle: %d event(s) left in %s from a previous run", s.pending, spoolFile)
+		t.spooling = true
+	}
+	return t, nil
+}
+
+// Spooled 返回暂存中等待投递的事件数
+func (t *ThrottledHandler) Spooled() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.spool.pending
+}
+
+// handle 有令牌且没有暂存事件时直接调用，否则暂存
+func (t *ThrottledHandler) handle(op fsnotify.Op, path string) error {
+	t.mu.Lock()
+	if t.spool.pending == 0 && t.reserve(time.Now()) == 0 {
+		t.mu.Unlock()
+		return Call(t.h, op, path)
+	}
+	err := t.spool.push(op, path)
+	if err == nil && !t.spooling {
+		t.spooling = true
+		log.Printf("throttle: rate limit of %g/s exceeded, spooling events to %s", t.limit.Rate, t.spool.path)
+	}
+	t.mu.Unlock()
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// reserve 补充令牌后取一个，返回 0；令牌不足时返回需要等待的时间。调用方持有 mu
+func (t *ThrottledHandler) reserve(now time.Time) time.Duration {
+	t.tokens = min(float64(t.limit.Burst), t.tokens+now.Sub(t.last).Seconds()*t.limit.Rate)
+	t.last = now
+	if t.tokens >= 1 {
+		t.tokens--
+		return 0
+	}
+	return time.Duration((1 - t.tokens) / t.limit.Rate * float64(time.Second))
+}
+
+// drain 按速率限制依次投递暂存的事件，直到 Close
+func (t *ThrottledHandler) drain() {
+	defer close(t.done)
+	for {
+		t.mu.Lock()
+		e, op, ok := t.spool.peek()
+		if !ok {
+			if t.spooling {
+				t.spooling = false
+				log.Printf("throttle: spool %s drained", t.spool.path)
+			}
+			t.mu.Unlock()
+			select {
+			case <-t.wake:
+				continue
+			case <-t.stop:
+				return
+			}
+		}
+		wait := t.reserve(time.Now())
+		t.mu.Unlock()
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+				continue
+			case <-t.stop:
+				return
+			}
+		}
+
+		if err := Call(t.h, op, e.Path); err != nil && t.OnError != nil {
+			t.OnError(op, e.Path, err)
+		}
+		t.mu.Lock()
+		t.spool.pop()
+		t.mu.Unlock()
+	}
+}
+
+// Init 初始化被包装的处理器后开始投递暂存的事件；暂存文件中遗留的事件已加密而 Sealer 未设置或密钥不对、或设置了 Sealer 而遗留的是明文（未开启 AcceptPlaintext）时返回错误
+func (t *ThrottledHandler) Init(ctx context.Context) error {
+	t.mu.Lock()
+	err := t.spool.setSealer(t.Sealer)
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("throttle: %w", err)
+	}
+	if err := t.wrapped.Init(ctx); err != nil {
+		return err
+	}
+	t.started.Do(func() { go t.drain() })
+	return nil
+}
+
+// Close 停止投递（正在进行的一次投递完成后），关闭暂存文件和被包装的处理器；未投递的事件留在暂存文件中
+func (t *ThrottledHandler) Close() error {
+	t.stopped.Do(func() {
+		close(t.stop)
+		t.started.Do(func() { close(t.done) }) // 从未 Init 时没有后台 goroutine
+		<-t.done
+		t.mu.Lock()
+		t.spool.close()
+		t.mu.Unlock()
+	})
+	return t.wrapped.Close()
+}
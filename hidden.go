@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// junkNames 操作系统自动生成的垃圾文件
+var junkNames = map[string]bool{
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"ehthumbs.db": true,
+	"desktop.ini": true,
+}
+
+// tempSuffixes 编辑器和工具常见的临时文件后缀
+var tempSuffixes = []string{"~", ".swp", ".swx", ".tmp"}
+
+// hiddenConfig 隐藏文件过滤配置
+type hiddenConfig struct {
+	roots []string // 生效的根路径，为空表示对所有根路径生效
+}
+
+// WithSkipHidden 忽略隐藏文件/目录（以 . 开头）、系统垃圾文件（Thumbs.db、.DS_Store 等）
+// 和编辑器临时文件；递归监控时不会进入隐藏目录
+// 指定 roots 时只对这些根路径生效，其他根路径照常监控隐藏文件
+func WithSkipHidden(roots ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		cleaned := make([]string, len(roots))
+		for i, root := range roots {
+			cleaned[i] = filepath.Clean(root)
+		}
+		fw.hidden = &hiddenConfig{roots: cleaned}
+	}
+}
+
+// isHiddenName 判断单个路径元素是否为隐藏、垃圾或临时文件
+func isHiddenName(name string) bool {
+	if name == "." || name == ".." || name == "" {
+		return false
+	}
+	if strings.HasPrefix(name, ".") || junkNames[strings.ToLower(name)] {
+		return true
+	}
+	for _, suffix := range tempSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesTo 判断全局隐藏文件过滤是否对该根路径生效
+func (h *hiddenConfig) appliesTo(root string) bool {
+	if len(h.roots) == 0 {
+		return true
+	}
+	for _, r := range h.roots {
+		if r == filepath.Clean(root) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenUnder 判断 root 下的 path 是否为隐藏路径（只检查 root 之下的路径元素）
+func hiddenUnder(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return isHiddenName(filepath.Base(path))
+	}
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if isHiddenName(name) {
+			return true
+		}
+	}
+	return false
+}
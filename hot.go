@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	hotBucketSize = 10 * time.Second // 滑动窗口的分桶粒度
+	hotCapacity   = 256              // 每个桶最多跟踪的路径数
+	hotTopN       = 10               // 每个窗口返回的路径数
+)
+
+// hotWindows 统计最活跃路径的滑动窗口
+var hotWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// HotWindow 一个滑动窗口内原始事件最多的路径和目录（近似值）
+type HotWindow struct {
+	Window string      `json:"window"`
+	Paths  []PathCount `json:"paths"`
+	Dirs   []PathCount `json:"dirs"`
+}
+
+// topK 用 Space-Saving 算法在固定容量内近似统计出现最多的键：
+// 容量满时新键替换计数最小的键并继承其计数，计数可能高估但不会低估，真正频繁的键不会被挤掉
+type topK map[string]uint64
+
+// add 计数加一
+func (t topK) add(key string) {
+	if _, ok := t[key]; ok || len(t) < hotCapacity {
+		t[key]++
+		return
+	}
+	minKey, minCount := "", ^uint64(0)
+	for k, c := range t {
+		if c < minCount || c == minCount && k < minKey {
+			minKey, minCount = k, c
+		}
+	}
+	delete(t, minKey)
+	t[key] = minCount + 1
+}
+
+// hotBucket 一个时间桶内的路径和目录计数
+type hotBucket struct {
+	start       int64 // 桶的序号（Unix 时间 / 桶大小），用于识别过期的桶
+	paths, dirs topK
+}
+
+// hotTracker 按时间分桶的最活跃路径统计，受 statsCollector.mu 保护
+type hotTracker struct {
+	buckets []*hotBucket // 环形缓冲区，覆盖最长的窗口
+}
+
+// newHotTracker 创建最活跃路径统计
+func newHotTracker() *hotTracker {
+	longest := hotWindows[len(hotWindows)-1]
+	return &hotTracker{buckets: make([]*hotBucket, int(longest/hotBucketSize))}
+}
+
+// observe 记录一个原始事件
+func (h *hotTracker) observe(path string, now time.Time) {
+	n := now.UnixNano() / int64(hotBucketSize)
+	i := int(n % int64(len(h.buckets)))
+	b := h.buckets[i]
+	if b == nil || b.start != n {
+		b = &hotBucket{start: n, paths: make(topK), dirs: make(topK)}
+		h.buckets[i] = b
+	}
+	b.paths.add(path)
+	b.dirs.add(filepath.Dir(path))
+}
+
+// top 合并每个窗口内的桶，返回各窗口的前 hotTopN 个路径和目录
+func (h *hotTracker) top(now time.Time) []HotWindow {
+	current := now.UnixNano() / int64(hotBucketSize)
+	windows := make([]HotWindow, 0, len(hotWindows))
+	for _, window := range hotWindows {
+		oldest := current - int64(window/hotBucketSize)
+		paths, dirs := make(map[string]uint64), make(map[string]uint64)
+		for _, b := range h.buckets {
+			if b == nil || b.start <= oldest {
+				continue
+			}
+			for k, c := range b.paths {
+				paths[k] += c
+			}
+			for k, c := range b.dirs {
+				dirs[k] += c
+			}
+		}
+		windows = append(windows, HotWindow{Window: shortDuration(window), Paths: topCounts(paths, hotTopN), Dirs: topCounts(dirs, hotTopN)})
+	}
+	return windows
+}
+
+// topCounts 按计数从高到低返回前 n 项
+func topCounts(m map[string]uint64, n int) []PathCount {
+	top := make([]PathCount, 0, len(m))
+	for path, count := range m {
+		top = append(top, PathCount{Path: path, Events: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Events != top[j].Events {
+			return top[i].Events > top[j].Events
+		}
+		return top[i].Path < top[j].Path
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// shortDuration 格式化整分钟的时长，如 "5m"
+func shortDuration(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	}
+	return d.String()
+}
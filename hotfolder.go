@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 热文件夹中的子目录名
+const (
+	hotProcessingDir = "processing"
+	hotDoneDir       = "done"
+	hotFailedDir     = "failed"
+)
+
+// hotFolderSettle 文件最后一次变化后等待多久、且大小不再变化才认领，避免处理仍在写入的文件
+const hotFolderSettle = 2 * time.Second
+
+// hotFolderCloseTimeout Close 默认等待正在进行的处理多久
+const hotFolderCloseTimeout = 30 * time.Second
+
+// HotFolder 投递目录处理器：新文件到达并稳定 hotFolderSettle 后先原子移入 processing/ 认领，
+// 处理成功移到 done/，失败移到 failed/；重启时恢复 processing/ 中未完成的文件
+type HotFolder struct {
+	Dir     string                                       // 投递目录（只处理直接文件）
+	Process func(ctx context.Context, path string) error // 处理函数，参数为 processing/ 中的路径
+
+	Checkpoints *Checkpoints // 非空时记录处理是否已完成，恢复 processing/ 中的文件时不重复执行已成功的处理
+	Action      string       // 检查点中的动作名，如命令行；不同动作各自记录
+
+	CloseTimeout time.Duration // Close 等待正在进行的处理的时间，超时后处理随上下文取消，文件留在 processing/
+
+	ctx       context.Context
+	settle    *Debouncer
+	mu        sync.Mutex // 串行处理已稳定的文件
+	running   inflight   // 去抖动定时器上正在执行的认领和处理，Close 据此等待
+	closed    atomic.Bool
+	rehearsed sync.Map // 演练模式下已处理过的文件，文件不会被移走，避免重复演练
+}
+
+// NewHotFolder 创建热文件夹处理器
+func NewHotFolder(dir string, process func(ctx context.Context, path string) error) *HotFolder {
+	return &HotFolder{
+		Dir:          filepath.Clean(dir),
+		Process:      process,
+		CloseTimeout: hotFolderCloseTimeout,
+		ctx:          context.Background(),
+		settle:       NewDebouncer(hotFolderSettle),
+	}
+}
+
+// Init 创建子目录，恢复上次崩溃时未完成的文件，并处理启动前已存在的文件
+func (h *HotFolder) Init(ctx context.Context) error {
+	h.ctx = ctx
+	if IsDryRun(ctx) {
+		// 演练模式不创建子目录、不移动文件，只演练启动前已存在的文件
+		entries, err := os.ReadDir(h.Dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				h.schedule(filepath.Join(h.Dir, entry.Name()))
+			}
+		}
+		return nil
+	}
+	for _, name := range []string{hotProcessingDir, hotDoneDir, hotFailedDir} {
+		if err := os.MkdirAll(filepath.Join(h.Dir, name), 0o755); err != nil {
+			return err
+		}
+	}
+
+	// processing/ 中残留的文件说明上次处理未完成，重新处理
+	if entries, err := os.ReadDir(filepath.Join(h.Dir, hotProcessingDir)); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				logf("hot folder: resuming %s", entry.Name())
+				h.run(filepath.Join(h.Dir, hotProcessingDir, entry.Name()))
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(h.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			h.schedule(filepath.Join(h.Dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// WatchOptions 返回监控投递目录时应使用的选项（排除内部子目录）
+func (h *HotFolder) WatchOptions() []WatchOption {
+	return []WatchOption{Recursive(false), Exclude(hotProcessingDir, hotDoneDir, hotFailedDir)}
+}
+
+func (h *HotFolder) OnCreate(path string) error { h.schedule(path); return nil }
+func (h *HotFolder) OnWrite(path string) error  { h.schedule(path); return nil }
+func (h *HotFolder) OnRemove(path string) error { return nil }
+func (h *HotFolder) OnRename(path string) error { return nil }
+func (h *HotFolder) OnChmod(path string) error  { return nil }
+
+// schedule 文件每次变化后重新计时，稳定后大小仍未变化才认领；
+// 写入不一定都产生事件（如网络文件系统），大小变了就继续等待
+func (h *HotFolder) schedule(path string) {
+	if filepath.Dir(filepath.Clean(path)) != h.Dir {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	size := info.Size()
+	h.settle.Debounce(path, func() {
+		h.running.begin()
+		defer h.running.end()
+		if info, err := os.Stat(path); err == nil && info.Size() != size {
+			h.schedule(path)
+			return
+		}
+		h.claim(path)
+	})
+}
+
+// claim 把投递目录中已稳定的文件原子移入 processing/ 后处理
+// 重命名失败说明文件已被认领或已消失，直接忽略
+func (h *HotFolder) claim(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed.Load() {
+		return
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	if IsDryRun(h.ctx) {
+		h.rehearse(path)
+		return
+	}
+
+	// processing/ 中可能残留上次未能恢复的同名文件，不能覆盖它
+	processing, err := renameUnique(path, filepath.Join(h.Dir, hotProcessingDir, filepath.Base(path)))
+	if err != nil {
+		return
+	}
+	h.run(processing)
+}
+
+// run 处理 processing/ 中的文件，并按结果移到 done/ 或 failed/
+func (h *HotFolder) run(processing string) {
+	target := hotDoneDir
+	end, err := h.process(processing)
+	if err != nil && h.ctx.Err() != nil {
+		// 停止时被中断的处理不算失败，文件留在 processing/，下次启动时恢复
+		logf("hot folder: %s interrupted, will resume on next start", filepath.Base(processing))
+		return
+	}
+	if err != nil {
+		logf("hot folder: %s failed: %v", filepath.Base(processing), err)
+		target = hotFailedDir
+	} else {
+		logf("hot folder: %s done", filepath.Base(processing))
+	}
+
+	if _, err := renameUnique(processing, filepath.Join(h.Dir, target, filepath.Base(processing))); err != nil {
+		logf("hot folder: move %s to %s/: %v", filepath.Base(processing), target, err)
+		return
+	}
+	end()
+}
+
+// process 调用处理函数；启用检查点时，上次崩溃前已成功的处理不再执行。
+// 返回的 end 在文件移出 processing/ 后结束检查点中的事件
+func (h *HotFolder) process(processing string) (end func(), err error) {
+	if h.Checkpoints == nil {
+		return func() {}, h.Process(h.ctx, processing)
+	}
+	id, err := h.Checkpoints.Begin(processing)
+	if err != nil {
+		return func() {}, fmt.Errorf("record checkpoint: %w", err)
+	}
+	end = func() {
+		if err := h.Checkpoints.End(id); err != nil {
+			logf("hot folder: record checkpoint for %s: %v", filepath.Base(processing), err)
+		}
+	}
+	action := h.Action
+	if action == "" {
+		action = "process"
+	}
+	return end, h.Checkpoints.Do(id, action, func() error { return h.Process(h.ctx, processing) })
+}
+
+// Close 放弃仍在等待稳定的文件（它们留在投递目录中，下次启动时处理），
+// 最多等待 CloseTimeout 让正在进行的处理结束，再关闭检查点文件
+func (h *HotFolder) Close() error {
+	h.settle.Cancel()
+	h.closed.Store(true)
+	idle := make(chan struct{})
+	go func() {
+		h.running.wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-time.After(h.CloseTimeout):
+		logf("hot folder: %d file(s) still processing after %s, leaving them in %s/", h.running.count(), h.CloseTimeout, hotProcessingDir)
+	}
+	if h.Checkpoints != nil {
+		return h.Checkpoints.Close()
+	}
+	return nil
+}
+
+// rehearse 演练模式下处理文件：处理函数仍会被调用（Command 只记录日志），文件原地不动
+func (h *HotFolder) rehearse(path string) {
+	if _, done := h.rehearsed.LoadOrStore(path, true); done {
+		return
+	}
+	wouldDo("move %s to %s/", path, hotProcessingDir)
+	target := hotDoneDir
+	if err := h.Process(h.ctx, path); err != nil {
+		logf("hot folder: %s failed: %v", filepath.Base(path), err)
+		target = hotFailedDir
+	}
+	wouldDo("move %s to %s/", filepath.Base(path), target)
+}
+
+// reservePath 以 O_EXCL 创建空的占位文件原子地占用目标路径，已存在时追加时间戳重试，
+// 避免覆盖之前的同名文件，并发移动同名文件时也不会选中同一个路径；
+// 调用方随后用 os.Rename 覆盖占位文件，失败时删除它
+func reservePath(path string) (string, error) {
+	ext := filepath.Ext(path)
+	for candidate := path; ; candidate = fmt.Sprintf("%s.%s%s", path[:len(path)-len(ext)], time.Now().Format("20060102T150405.000000000"), ext) {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			return candidate, f.Close()
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+}
+
+// renameUnique 把 src 移到 dst，dst 已存在时改用 reservePath 选出的路径，返回实际的目标路径
+func renameUnique(src, dst string) (string, error) {
+	dest, err := reservePath(dst)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, dest); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	return dest, nil
+}
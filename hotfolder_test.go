@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestHotFolder 创建已初始化的热文件夹，process 失败与否由文件名中是否含 "bad" 决定
+func newTestHotFolder(t *testing.T, ctx context.Context) *HotFolder {
+	t.Helper()
+	h := NewHotFolder(t.TempDir(), func(ctx context.Context, path string) error {
+		if strings.Contains(filepath.Base(path), "bad") {
+			return errors.New("bad input")
+		}
+		return nil
+	})
+	if err := h.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// listDir 返回目录中的文件名
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestHotFolderMovesByResult(t *testing.T) {
+	h := newTestHotFolder(t, context.Background())
+	for _, name := range []string{"good.txt", "bad.txt"} {
+		path := filepath.Join(h.Dir, name)
+		writeFile(t, path)
+		h.claim(path)
+	}
+	if got := listDir(t, filepath.Join(h.Dir, hotDoneDir)); len(got) != 1 || got[0] != "good.txt" {
+		t.Errorf("done/ = %v, want [good.txt]", got)
+	}
+	if got := listDir(t, filepath.Join(h.Dir, hotFailedDir)); len(got) != 1 || got[0] != "bad.txt" {
+		t.Errorf("failed/ = %v, want [bad.txt]", got)
+	}
+	if got := listDir(t, filepath.Join(h.Dir, hotProcessingDir)); len(got) != 0 {
+		t.Errorf("processing/ = %v, want empty", got)
+	}
+}
+
+func TestHotFolderKeepsEarlierSameName(t *testing.T) {
+	h := newTestHotFolder(t, context.Background())
+	path := filepath.Join(h.Dir, "report.txt")
+	for i := 0; i < 3; i++ {
+		writeFile(t, path)
+		h.claim(path)
+	}
+	got := listDir(t, filepath.Join(h.Dir, hotDoneDir))
+	if len(got) != 3 {
+		t.Fatalf("done/ = %v, want 3 files", got)
+	}
+	for _, name := range got {
+		if !strings.HasPrefix(name, "report.") || filepath.Ext(name) != ".txt" {
+			t.Errorf("unexpected name %q in done/", name)
+		}
+	}
+}
+
+func TestHotFolderResumesProcessing(t *testing.T) {
+	dir := t.TempDir()
+	mkdir(t, filepath.Join(dir, hotProcessingDir))
+	writeFile(t, filepath.Join(dir, hotProcessingDir, "left.txt"))
+
+	h := NewHotFolder(dir, func(context.Context, string) error { return nil })
+	if err := h.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if got := listDir(t, filepath.Join(dir, hotDoneDir)); len(got) != 1 || got[0] != "left.txt" {
+		t.Errorf("done/ = %v, want [left.txt]", got)
+	}
+}
+
+func TestHotFolderCloseLeavesInterruptedInProcessing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan struct{})
+	h := NewHotFolder(t.TempDir(), func(ctx context.Context, path string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.CloseTimeout = 10 * time.Millisecond
+	if err := h.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(h.Dir, "slow.txt")
+	writeFile(t, path)
+	done := make(chan struct{})
+	go func() {
+		h.running.begin()
+		defer h.running.end()
+		h.claim(path)
+		close(done)
+	}()
+	<-started
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 与 Shutdown 相同：Close 返回后才取消上下文
+	cancel()
+	<-done
+
+	if got := listDir(t, filepath.Join(h.Dir, hotProcessingDir)); len(got) != 1 || got[0] != "slow.txt" {
+		t.Errorf("processing/ = %v, want [slow.txt]", got)
+	}
+	if got := listDir(t, filepath.Join(h.Dir, hotFailedDir)); len(got) != 0 {
+		t.Errorf("failed/ = %v, want empty", got)
+	}
+}
+
+func TestHotFolderCloseWaitsForProcessing(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := newTestHotFolder(t, context.Background())
+	h.Process = func(context.Context, string) error {
+		close(started)
+		<-release
+		return nil
+	}
+	path := filepath.Join(h.Dir, "a.txt")
+	writeFile(t, path)
+	h.running.begin()
+	go func() {
+		defer h.running.end()
+		h.claim(path)
+	}()
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		h.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a file was being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	if got := listDir(t, filepath.Join(h.Dir, hotDoneDir)); len(got) != 1 {
+		t.Errorf("done/ = %v, want [a.txt]", got)
+	}
+}
+
+func TestRenameUniqueConcurrent(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		path := filepath.Join(src, fmt.Sprintf("%d.txt", i))
+		if err := os.WriteFile(path, []byte(path), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := renameUnique(path, filepath.Join(dst, "same.txt")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	got := listDir(t, dst)
+	if len(got) != n {
+		t.Fatalf("destination has %d file(s), want %d: %v", len(got), n, got)
+	}
+	for _, name := range got {
+		if info, err := os.Stat(filepath.Join(dst, name)); err != nil || info.Size() == 0 {
+			t.Errorf("%s is an empty placeholder", name)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// isUnder 判断 path 是否等于 root 或位于 root 之下
+func isUnder(root, path string) bool {
+	if root == path || root == "." {
+		return true
+	}
+	return strings.HasPrefix(path, root+string(filepath.Separator)) ||
+		strings.HasSuffix(root, string(filepath.Separator)) && strings.HasPrefix(path, root)
+}
+
+// WithExclude 排除匹配模式的路径（相对监控根路径，支持 ** ，如 "node_modules"、"build/**"）
+// 匹配的目录在递归遍历时整棵跳过，不会添加 watch；匹配路径及其子路径的事件都不会分发
+func WithExclude(patterns ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.excludes = append(fw.excludes, patterns...)
+	}
+}
+
+// excluded 判断 path 或其任一上级目录（相对 root）是否匹配排除模式
+func excluded(patterns []string, root, path string) bool {
+	_, ok := excludedBy(patterns, root, path)
+	return ok
+}
+
+// excludedBy 同 excluded，同时返回匹配的模式
+func excludedBy(patterns []string, root, path string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range patterns {
+		// 依次检查 a、a/b、a/b/c，上级目录被排除时子路径同样排除
+		for i := 0; i <= len(rel); i++ {
+			if i == len(rel) || rel[i] == '/' {
+				if matchGlob(pattern, rel[:i]) {
+					return pattern, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// ignored 判断 root 下的 path 是否应完全忽略：既不添加 watch，也不分发事件
+// 对目录返回 true 时，递归遍历会跳过整棵子树
+func (fw *FileWatcher) ignored(root *watchRoot, path string) bool {
+	return fw.ignoreReason(root, path) != ""
+}
+
+// ignoreReason 返回路径被忽略的原因，未被忽略时为空
+func (fw *FileWatcher) ignoreReason(root *watchRoot, path string) string {
+	if root.skipHidden && hiddenUnder(root.path, path) {
+		return "hidden path"
+	}
+	if fw.git != nil && fw.git.ignored(path) {
+		return "ignored by .gitignore"
+	}
+	rootPath, path := fw.fold(root.path), fw.fold(path)
+	if pattern, ok := excludedBy(fw.foldAll(fw.excludes), rootPath, path); ok {
+		return fmt.Sprintf("exclude pattern %q", pattern)
+	}
+	if pattern, ok := excludedBy(fw.foldAll(root.excludes), rootPath, path); ok {
+		return fmt.Sprintf("exclude pattern %q of root %s", pattern, root.path)
+	}
+	return ""
+}
+
+// WithMaxDepth 限制递归监控的深度：根目录为第 0 层，只为深度不超过 n 的目录添加 watch
+// 第 n 层目录中文件的事件仍会上报；负数表示不限制
+func WithMaxDepth(n int) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.maxDepth = n
+	}
+}
+
+// depth 返回 path 相对 root 的目录层级，root 本身为 0
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// tooDeep 判断目录是否超出根路径的最大递归深度，超出时不添加 watch
+func (fw *FileWatcher) tooDeep(root *watchRoot, dir string) bool {
+	return root.maxDepth >= 0 && depth(root.path, dir) > root.maxDepth
+}
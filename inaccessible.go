@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// WithSkipInaccessible 递归遍历遇到无权读取或监控的子目录时跳过该子树并记录，而不是让整个 Watch 失败；
+// 跳过的子树可用 WatchHandle.Inaccessible 查看，根路径本身不可访问时仍返回错误。
+// 单次 Watch 可用 SkipInaccessible 覆盖
+func WithSkipInaccessible() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.skipInaccessible = true
+	}
+}
+
+// SkipInaccessible 设置该根路径是否跳过无权访问的子树（见 WithSkipInaccessible）
+func SkipInaccessible(skip bool) WatchOption {
+	return func(r *watchRoot) {
+		r.skipInaccessible = skip
+	}
+}
+
+// Inaccessible 返回注册（及之后新建子目录）时因无权访问而跳过的子树，错误可用 errors.Is 与 ErrPermissionDenied 比较
+func (h *WatchHandle) Inaccessible() []*WatchError {
+	h.mu.Lock()
+	root := h.root
+	h.mu.Unlock()
+
+	h.fw.mu.Lock()
+	defer h.fw.mu.Unlock()
+	return append([]*WatchError(nil), root.inaccessible...)
+}
+
+// skipInaccessibleDir 判断根路径下的 path 是否因无权访问而应跳过，是则记录到根路径的报告中
+func (fw *FileWatcher) skipInaccessibleDir(root *watchRoot, path string, err error) bool {
+	if !root.skipInaccessible || path == root.path || !errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	we, _ := classifyWatchError(path, err).(*WatchError)
+	logf("skipping inaccessible %s: %v", path, err)
+	fw.mu.Lock()
+	root.inaccessible = append(root.inaccessible, we)
+	fw.mu.Unlock()
+	return true
+}
@@ -0,0 +1,47 @@
+package main
+
+import "sync"
+
+// inflight 统计正在执行的处理器调用，Shutdown 据此等待
+// 与 sync.WaitGroup 不同，等待期间仍允许新的调用开始（如去抖动定时器恰好触发）
+type inflight struct {
+	mu   sync.Mutex
+	n    int
+	idle *sync.Cond
+}
+
+// begin 标记一个调用开始
+func (f *inflight) begin() {
+	f.mu.Lock()
+	f.n++
+	f.mu.Unlock()
+}
+
+// end 标记一个调用结束
+func (f *inflight) end() {
+	f.mu.Lock()
+	f.n--
+	if f.n == 0 && f.idle != nil {
+		f.idle.Broadcast()
+	}
+	f.mu.Unlock()
+}
+
+// count 返回正在执行的调用数量
+func (f *inflight) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// wait 阻塞直到没有正在执行的调用
+func (f *inflight) wait() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.idle == nil {
+		f.idle = sync.NewCond(&f.mu)
+	}
+	for f.n > 0 {
+		f.idle.Wait()
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// InjectedEvent 外部系统通过 /inject 注入的事件
+type InjectedEvent struct {
+	Path   string `json:"path"`
+	Op     string `json:"op"`               // ParseOps 可解析的类型，如 "create" 或 "create,write"
+	Source string `json:"source,omitempty"` // 事件来源，如 "s3"，在 explain 模式中说明
+}
+
+// Inject 把外部系统报告的变化作为原始事件注入，如"对象已上传到 S3，经 FUSE 挂载后路径 X 已在本地出现"：
+// 注入的事件与文件系统事件一样经过忽略规则、去抖动、过滤器和路由，所有变化由同一套规则处理。
+// path 可以是绝对路径或与注册时相同写法的相对路径，不在已注册的根路径下时返回 ErrNotWatching
+func (fw *FileWatcher) Inject(path string, op fsnotify.Op, source string) error {
+	if fw.stopped() {
+		return ErrStopped
+	}
+	if op == 0 || op&^allOps != 0 {
+		return fmt.Errorf("invalid op %d", op)
+	}
+	name, ok := fw.injectPath(path)
+	if !ok {
+		return &WatchError{Path: path, Err: ErrNotWatching}
+	}
+	if source == "" {
+		source = "API"
+	}
+	fw.explainf(name, "injected by %s", source)
+	fw.handleEvent(fsnotify.Event{Name: name, Op: op})
+	return nil
+}
+
+// injectPath 把注入的路径换成与根路径相同的写法（根路径以相对路径注册时，绝对路径换成相对路径）
+func (fw *FileWatcher) injectPath(path string) (string, bool) {
+	path = fw.rootPath(path)
+	if fw.lookupRoot(path) != nil {
+		return path, true
+	}
+	abs := absPath(path)
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, root := range fw.roots {
+		rootAbs := absPath(root.path)
+		if !isUnder(rootAbs, abs) {
+			continue
+		}
+		rel, err := filepath.Rel(rootAbs, abs)
+		if err != nil {
+			continue
+		}
+		return filepath.Join(root.path, rel), true
+	}
+	return "", false
+}
+
+// registerInject 注册 /inject 端点：POST 一个 InjectedEvent 或其数组（JSON），
+// 或使用 ?path=&op=&source= 查询参数注入单个事件；没有认证，只注册在 --control-socket 的控制接口上
+func registerInject(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/inject", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		var events []InjectedEvent
+		if q := r.URL.Query(); q.Get("path") != "" {
+			events = append(events, InjectedEvent{Path: q.Get("path"), Op: q.Get("op"), Source: q.Get("source")})
+		} else {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+				err = json.Unmarshal(body, &events)
+			} else {
+				var ev InjectedEvent
+				err = json.Unmarshal(body, &ev)
+				events = append(events, ev)
+			}
+			if err != nil {
+				http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		// 先全部校验，避免只注入一部分
+		ops := make([]fsnotify.Op, len(events))
+		for i, ev := range events {
+			op, err := ParseOps(ev.Op)
+			if err == nil && op == 0 {
+				err = errors.New("op is required")
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("event %d: %v", i, err), http.StatusBadRequest)
+				return
+			}
+			if _, ok := fw.injectPath(ev.Path); !ok {
+				http.Error(w, fmt.Sprintf("event %d: %s is not under a watched root", i, ev.Path), http.StatusNotFound)
+				return
+			}
+			ops[i] = op
+		}
+		for i, ev := range events {
+			if err := fw.Inject(ev.Path, ops[i], ev.Source); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		writeJSON(w, map[string]int{"injected": len(events)})
+	})
+}
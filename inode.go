@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// inodeRenameWindow 重命名的 RENAME 和 CREATE 两个事件之间最长的间隔，超过后不再关联
+const inodeRenameWindow = 5 * time.Second
+
+// FileID 文件在文件系统中的唯一标识：设备号加 inode 号，硬链接的各个路径相同
+type FileID struct {
+	Device uint64 `json:"device"`
+	Inode  uint64 `json:"inode"`
+}
+
+// InodeInfo 启用 inode 跟踪时事件路径对应的文件信息（仅 Unix）
+type InodeInfo struct {
+	FileID
+	Links       uint64   `json:"links"`                  // 硬链接数
+	Paths       []string `json:"paths,omitempty"`        // 监控范围内已知的、指向同一文件的其他路径
+	HardlinkOf  string   `json:"hardlink_of,omitempty"`  // 该路径是为已有文件新建的硬链接时，为已有的路径
+	RenamedFrom string   `json:"renamed_from,omitempty"` // 该路径由重命名而来时，为原路径
+}
+
+// inodeOrigin 创建事件的来历，分发时写入 InodeInfo
+type inodeOrigin struct {
+	hardlinkOf, renamedFrom string
+}
+
+// movedAway 被重命名走、等待在新路径上出现的文件
+type movedAway struct {
+	path string
+	at   time.Time
+}
+
+// inodeTracker 按 inode 跟踪监控范围内的文件
+type inodeTracker struct {
+	mu      sync.Mutex
+	paths   map[string]FileID
+	files   map[FileID]map[string]bool
+	moved   map[FileID]movedAway
+	origins map[string]inodeOrigin // 尚未分发的创建事件的来历
+}
+
+// WithInodeTracking 按设备号和 inode 号跟踪文件（仅 Unix）：识别指向同一文件的多个路径、新建的硬链接，
+// 并把重命名产生的 RENAME 和 CREATE 关联起来；结果记在 Event.Inode 中，HardLinks 返回同一文件的已知路径
+func WithInodeTracking() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.inodes = &inodeTracker{
+			paths:   make(map[string]FileID),
+			files:   make(map[FileID]map[string]bool),
+			moved:   make(map[FileID]movedAway),
+			origins: make(map[string]inodeOrigin),
+		}
+	}
+}
+
+// record 注册时记录已有文件
+func (t *inodeTracker) record(path string, info os.FileInfo) {
+	id, _, ok := fileID(info)
+	if !ok || info.IsDir() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(filepath.Clean(path), id)
+}
+
+// add 把路径加入索引，调用方持有 t.mu
+func (t *inodeTracker) add(path string, id FileID) {
+	t.remove(path)
+	t.paths[path] = id
+	if t.files[id] == nil {
+		t.files[id] = make(map[string]bool)
+	}
+	t.files[id][path] = true
+}
+
+// remove 从索引中移除路径，返回它原来的标识；调用方持有 t.mu
+func (t *inodeTracker) remove(path string) (FileID, bool) {
+	id, ok := t.paths[path]
+	if !ok {
+		return id, false
+	}
+	delete(t.paths, path)
+	delete(t.files[id], path)
+	if len(t.files[id]) == 0 {
+		delete(t.files, id)
+	}
+	return id, true
+}
+
+// observe 在原始事件到达时更新索引，识别硬链接和重命名
+func (t *inodeTracker) observe(fw *FileWatcher, event fsnotify.Event, now time.Time) {
+	path := filepath.Clean(event.Name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, m := range t.moved {
+		if now.Sub(m.at) > inodeRenameWindow {
+			delete(t.moved, id)
+		}
+	}
+
+	switch {
+	case event.Has(fsnotify.Rename):
+		if id, ok := t.remove(path); ok {
+			t.moved[id] = movedAway{path: path, at: now}
+		}
+		delete(t.origins, path)
+		return
+	case event.Has(fsnotify.Remove):
+		t.remove(path)
+		delete(t.origins, path)
+		return
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return
+	}
+	id, _, ok := fileID(info)
+	if !ok {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		if m, ok := t.moved[id]; ok {
+			delete(t.moved, id)
+			t.origins[path] = inodeOrigin{renamedFrom: m.path}
+			fw.explainf(path, "renamed from %s", m.path)
+		} else {
+			for other := range t.files[id] {
+				if other != path {
+					t.origins[path] = inodeOrigin{hardlinkOf: other}
+					fw.explainf(path, "new hard link to %s", other)
+					break
+				}
+			}
+		}
+	}
+	if t.paths[path] != id {
+		t.add(path, id)
+	}
+}
+
+// take 返回路径当前的 inode 信息并清除创建事件的来历，文件已不存在时返回 nil
+func (t *inodeTracker) take(path string) *InodeInfo {
+	path = filepath.Clean(path)
+	info, statErr := os.Lstat(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	origin := t.origins[path]
+	delete(t.origins, path)
+	if statErr != nil {
+		return nil
+	}
+	id, links, ok := fileID(info)
+	if !ok {
+		return nil
+	}
+	return &InodeInfo{FileID: id, Links: links, Paths: t.others(path, id), HardlinkOf: origin.hardlinkOf, RenamedFrom: origin.renamedFrom}
+}
+
+// others 返回同一文件的其他已知路径，调用方持有 t.mu
+func (t *inodeTracker) others(path string, id FileID) []string {
+	var paths []string
+	for other := range t.files[id] {
+		if other != path {
+			paths = append(paths, other)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// HardLinks 返回监控范围内已知的、与 path 指向同一文件的其他路径；未启用 WithInodeTracking 时返回 nil
+func (fw *FileWatcher) HardLinks(path string) []string {
+	if fw.inodes == nil {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+	id, _, ok := fileID(info)
+	if !ok {
+		return nil
+	}
+	fw.inodes.mu.Lock()
+	defer fw.inodes.mu.Unlock()
+	return fw.inodes.others(filepath.Clean(path), id)
+}
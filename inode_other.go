@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileID 当前平台的 FileInfo 不提供 inode，返回 false
+func fileID(info os.FileInfo) (FileID, uint64, bool) {
+	return FileID{}, 0, false
+}
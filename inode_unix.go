@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID 返回文件的设备号、inode 号和硬链接数
+func fileID(info os.FileInfo) (FileID, uint64, bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return FileID{Device: uint64(st.Dev), Inode: uint64(st.Ino)}, uint64(st.Nlink), true
+	}
+	return FileID{}, 0, false
+}
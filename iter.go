@@ -0,0 +1,122 @@
+package main
+
+import (
+	"iter"
+	"sync"
+)
+
+// subscriberBuffer 每个订阅者的事件缓冲，消费者跟不上时分发器会等待
+const subscriberBuffer = 64
+
+// subscriber 一个事件订阅者；ch 不会被关闭，发送方可能仍持有快照，监控器停止时改为关闭 stop
+type subscriber struct {
+	ch   chan Event
+	done chan struct{} // 订阅者退出时关闭，让阻塞中的分发器放弃发送
+	stop chan struct{} // 监控器停止时关闭，订阅者读完缓冲中的事件后结束
+	once sync.Once
+}
+
+// NopHandler 不做任何处理的处理器，只通过 All 或 Subscribe 消费事件时使用
+type NopHandler struct{}
+
+// OnCreate 实现 EventHandler 接口
+func (NopHandler) OnCreate(string) error { return nil }
+
+// OnWrite 实现 EventHandler 接口
+func (NopHandler) OnWrite(string) error { return nil }
+
+// OnRemove 实现 EventHandler 接口
+func (NopHandler) OnRemove(string) error { return nil }
+
+// OnRename 实现 EventHandler 接口
+func (NopHandler) OnRename(string) error { return nil }
+
+// OnChmod 实现 EventHandler 接口
+func (NopHandler) OnChmod(string) error { return nil }
+
+// All 以迭代器形式返回此后分发的事件（与处理器收到的相同），监控器停止后迭代结束；
+// 提前 break 时自动取消订阅：
+//
+//	for ev := range fw.All() {
+//		if ev.Has(fsnotify.Write) { ... }
+//	}
+func (fw *FileWatcher) All() iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		sub := fw.subscribe()
+		defer fw.unsubscribe(sub)
+		for {
+			select {
+			case ev := <-sub.ch:
+				if !yield(ev) {
+					return
+				}
+			case <-sub.stop:
+				for {
+					select {
+					case ev := <-sub.ch:
+						if !yield(ev) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// subscribe 注册订阅者，监控器已停止时返回已关闭的订阅
+func (fw *FileWatcher) subscribe() *subscriber {
+	sub := &subscriber{ch: make(chan Event, subscriberBuffer), done: make(chan struct{}), stop: make(chan struct{})}
+	fw.subsMu.Lock()
+	defer fw.subsMu.Unlock()
+	if fw.subs == nil {
+		close(sub.stop)
+		return sub
+	}
+	fw.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe 取消订阅
+func (fw *FileWatcher) unsubscribe(sub *subscriber) {
+	sub.once.Do(func() { close(sub.done) })
+	fw.subsMu.Lock()
+	delete(fw.subs, sub)
+	fw.subsMu.Unlock()
+}
+
+// publish 将事件发送给所有订阅者。发送时不持有 subsMu：消费者可能在循环体中调用 Stop，
+// 持锁阻塞在它已满的缓冲上会与 closeSubscribers 互相等待；监控器开始停止后只在缓冲有空位时发送
+func (fw *FileWatcher) publish(ev Event) {
+	fw.subsMu.RLock()
+	subs := make([]*subscriber, 0, len(fw.subs))
+	for sub := range fw.subs {
+		subs = append(subs, sub)
+	}
+	fw.subsMu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- ev:
+			continue
+		default:
+		}
+		select {
+		case sub.ch <- ev:
+		case <-sub.done:
+		case <-fw.done:
+		}
+	}
+}
+
+// closeSubscribers 停止时结束所有订阅，之后的订阅立即结束
+func (fw *FileWatcher) closeSubscribers() {
+	fw.subsMu.Lock()
+	defer fw.subsMu.Unlock()
+	for sub := range fw.subs {
+		close(sub.stop)
+	}
+	fw.subs = nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencyBuckets 延迟直方图的桶上界
+var latencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// LatencyStats 从收到原始事件到处理器执行完毕的延迟直方图
+type LatencyStats struct {
+	Buckets []time.Duration `json:"buckets"` // 各桶上界
+	Counts  []uint64        `json:"counts"`  // 延迟不超过对应上界的事件数（累计），最后一项为全部事件
+	Sum     time.Duration   `json:"sum"`
+	Max     time.Duration   `json:"max"`
+	Slow    uint64          `json:"slow"` // 超出延迟预算的事件数
+}
+
+// latencyTracker 统计事件延迟，超出预算时记录警告
+type latencyTracker struct {
+	mu     sync.Mutex
+	budget time.Duration // 0 表示不检查
+	counts []uint64      // 每个桶的事件数（非累计），最后一项为超出所有上界的事件
+	sum    time.Duration
+	max    time.Duration
+	slow   uint64
+}
+
+// WithLatencyBudget 设置延迟预算：事件从收到到处理器执行完毕超过 budget 时记录警告，
+// 如 WithLatencyBudget(2*time.Second) 对应"变化后 2 秒内完成处理"的 SLO
+func WithLatencyBudget(budget time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.latency.budget = budget
+	}
+}
+
+// observe 记录一个事件的延迟，start 为开始分发的时间
+func (t *latencyTracker) observe(ev Event, start time.Time) {
+	if ev.FirstSeen.IsZero() {
+		return
+	}
+	now := time.Now()
+	total := now.Sub(ev.FirstSeen)
+
+	t.mu.Lock()
+	if t.counts == nil {
+		t.counts = make([]uint64, len(latencyBuckets)+1)
+	}
+	i := 0
+	for i < len(latencyBuckets) && total > latencyBuckets[i] {
+		i++
+	}
+	t.counts[i]++
+	t.sum += total
+	if total > t.max {
+		t.max = total
+	}
+	slow := t.budget > 0 && total > t.budget
+	if slow {
+		t.slow++
+	}
+	t.mu.Unlock()
+
+	if slow {
+		logf("slow event: path=%q op=%s latency=%v budget=%v queued=%v handler=%v",
+			ev.Path, formatOps(ev.Op), total.Round(time.Millisecond), t.budget,
+			start.Sub(ev.FirstSeen).Round(time.Millisecond), now.Sub(start).Round(time.Millisecond))
+	}
+}
+
+// snapshot 返回当前统计
+func (t *latencyTracker) snapshot() LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := LatencyStats{
+		Buckets: latencyBuckets,
+		Counts:  make([]uint64, len(latencyBuckets)+1),
+		Sum:     t.sum,
+		Max:     t.max,
+		Slow:    t.slow,
+	}
+	var cum uint64
+	for i := range s.Counts {
+		if t.counts != nil {
+			cum += t.counts[i]
+		}
+		s.Counts[i] = cum
+	}
+	return s
+}
+
+// Latency 返回事件延迟直方图
+func (fw *FileWatcher) Latency() LatencyStats {
+	return fw.latency.snapshot()
+}
+
+// registerMetrics 注册 /metrics 端点，以 Prometheus 文本格式输出延迟直方图、最活跃的路径和写入字节数
+func registerMetrics(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		s := fw.Latency()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP watchdog_event_latency_seconds Time from receiving a file system event to handler completion.")
+		fmt.Fprintln(w, "# TYPE watchdog_event_latency_seconds histogram")
+		for i, b := range s.Buckets {
+			fmt.Fprintf(w, "watchdog_event_latency_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(b.Seconds(), 'g', -1, 64), s.Counts[i])
+		}
+		total := s.Counts[len(s.Counts)-1]
+		fmt.Fprintf(w, "watchdog_event_latency_seconds_bucket{le=\"+Inf\"} %d\n", total)
+		fmt.Fprintf(w, "watchdog_event_latency_seconds_sum %g\n", s.Sum.Seconds())
+		fmt.Fprintf(w, "watchdog_event_latency_seconds_count %d\n", total)
+		fmt.Fprintln(w, "# HELP watchdog_slow_events_total Events that exceeded the latency budget.")
+		fmt.Fprintln(w, "# TYPE watchdog_slow_events_total counter")
+		fmt.Fprintf(w, "watchdog_slow_events_total %d\n", s.Slow)
+		fmt.Fprintln(w, "# HELP watchdog_hot_events Raw events on the most active paths and directories over sliding windows (approximate).")
+		fmt.Fprintln(w, "# TYPE watchdog_hot_events gauge")
+		for _, hw := range fw.Stats().Hot {
+			for _, kind := range []struct {
+				name   string
+				counts []PathCount
+			}{{"path", hw.Paths}, {"dir", hw.Dirs}} {
+				for _, p := range kind.counts {
+					fmt.Fprintf(w, "watchdog_hot_events{window=%q,kind=%q,path=%q} %d\n", hw.Window, kind.name, p.Path, p.Events)
+				}
+			}
+		}
+		if st := fw.Stats(); st.BytesWritten > 0 {
+			fmt.Fprintln(w, "# HELP watchdog_bytes_written_total Bytes written to watched files, from size deltas.")
+			fmt.Fprintln(w, "# TYPE watchdog_bytes_written_total counter")
+			fmt.Fprintf(w, "watchdog_bytes_written_total %d\n", st.BytesWritten)
+			fmt.Fprintln(w, "# HELP watchdog_dir_bytes_written_total Bytes written per directory (top directories by the last hour).")
+			fmt.Fprintln(w, "# TYPE watchdog_dir_bytes_written_total counter")
+			for _, d := range st.BytesByDir {
+				fmt.Fprintf(w, "watchdog_dir_bytes_written_total{dir=%q} %d\n", d.Path, d.Total)
+			}
+			fmt.Fprintln(w, "# HELP watchdog_dir_bytes_written_last_hour Bytes written per directory over the last hour.")
+			fmt.Fprintln(w, "# TYPE watchdog_dir_bytes_written_last_hour gauge")
+			for _, d := range st.BytesByDir {
+				fmt.Fprintf(w, "watchdog_dir_bytes_written_last_hour{dir=%q} %d\n", d.Path, d.LastHour)
+			}
+		}
+	})
+}
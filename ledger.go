@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"watchdogdemo/handlers"
+)
+
+// ledgerSettle --ledger 在查账和计算哈希之前等待文件没有新事件的时间
+const ledgerSettle = 2 * time.Second
+
+// ledgerEntry 账本文件中的一行（JSON Lines）
+type ledgerEntry struct {
+	Path   string    `json:"path"`
+	SHA256 string    `json:"sha256"`
+	Time   time.Time `json:"time"`
+}
+
+// Ledger 已处理文件账本：持久化记录 (路径, 内容哈希)，重启或回放时内容未变的已处理文件不再触发下游处理；
+// 路径按绝对路径记录，文件以 JSON Lines 追加写入（仅所有者可读写），每条记录写入后同步到磁盘，
+// 打开时同一路径只保留最后一条并压缩
+type Ledger struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	sealer  *handlers.Sealer
+	entries map[string]string // 路径 -> 内容哈希
+}
+
+// OpenLedger 打开（不存在时创建）账本文件；sealer 非 nil 时每行加密存放，已有的明文记录在打开时重写为密文
+func OpenLedger(path string, sealer *handlers.Sealer) (*Ledger, error) {
+	l := &Ledger{path: path, sealer: sealer, entries: make(map[string]string)}
+	lines, plaintext, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	if lines > len(l.entries) || plaintext > 0 {
+		if err := l.compact(); err != nil {
+			return nil, err
+		}
+	}
+	l.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load 读取已有记录，返回行数和设置了密钥时读到的明文行数
+func (l *Ledger) load() (lines, plaintext int, err error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	plaintext, err = readSealedLines(f, l.sealer, func(line []byte) {
+		var e ledgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// 崩溃时可能留下不完整的最后一行
+			return
+		}
+		l.entries[absPath(e.Path)] = e.SHA256
+		lines++
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("read ledger %s: %w", l.path, err)
+	}
+	return lines, plaintext, nil
+}
+
+// compact 重写账本文件，每个路径只保留一条记录
+func (l *Ledger) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(l.sealer.NewWriter(w))
+	now := time.Now()
+	for path, sum := range l.entries {
+		if err = enc.Encode(ledgerEntry{Path: path, SHA256: sum, Time: now}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		// 重命名之前落盘，崩溃后不会留下内容不完整的账本
+		err = tmp.Sync()
+	}
+	if err := errors.Join(err, tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+// AlreadyProcessed 判断文件当前内容是否已标记为处理过；文件不可读时返回 false
+func (l *Ledger) AlreadyProcessed(path string) bool {
+	sum, err := hashFile(path)
+	return err == nil && l.processed(path, sum)
+}
+
+// MarkProcessed 将文件当前内容标记为已处理
+func (l *Ledger) MarkProcessed(path string) error {
+	sum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	return l.mark(path, sum)
+}
+
+// processed 判断路径的记录是否为该哈希
+func (l *Ledger) processed(path, sum string) bool {
+	path = absPath(path)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries[path] == sum
+}
+
+// mark 追加写入账本文件并同步到磁盘，成功后才记录路径和哈希
+func (l *Ledger) mark(path, sum string) error {
+	path = absPath(path)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.entries[path] == sum {
+		return nil
+	}
+	if err := json.NewEncoder(l.sealer.NewWriter(l.f)).Encode(ledgerEntry{Path: path, SHA256: sum, Time: time.Now()}); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.entries[path] = sum
+	return nil
+}
+
+// Close 关闭账本文件
+func (l *Ledger) Close() error {
+	return l.f.Close()
+}
+
+// LedgerHandler 跳过账本中内容未变的已处理文件的创建/写入事件，下一个处理器成功处理后记入账本；
+// 每个事件都要计算文件哈希，应放在等待文件稳定的处理器（如 NewSettleHandler）之后，而不是对写入中的文件逐个 WRITE 计算
+type LedgerHandler struct {
+	next   EventHandler
+	ledger *Ledger
+	dryRun bool // Init 时取自监控器的上下文，演练模式下不记账
+}
+
+// NewLedgerHandler 用账本包装处理器，Close 时同时关闭账本
+func NewLedgerHandler(next EventHandler, ledger *Ledger) *LedgerHandler {
+	return &LedgerHandler{next: next, ledger: ledger}
+}
+
+func (h *LedgerHandler) OnCreate(path string) error { return h.process(path, h.next.OnCreate) }
+func (h *LedgerHandler) OnWrite(path string) error  { return h.process(path, h.next.OnWrite) }
+func (h *LedgerHandler) OnRemove(path string) error { return h.next.OnRemove(path) }
+func (h *LedgerHandler) OnRename(path string) error { return h.next.OnRename(path) }
+func (h *LedgerHandler) OnChmod(path string) error  { return h.next.OnChmod(path) }
+
+// process 内容已处理过时跳过，否则交给下一个处理器并在成功后记账；目录和已消失的文件直接转发。
+// 记账失败只记录日志：下一个处理器已经成功，返回错误会让重试再执行一遍
+func (h *LedgerHandler) process(path string, fn func(string) error) error {
+	sum, err := hashFile(path)
+	if err != nil {
+		return fn(path)
+	}
+	if h.ledger.processed(path, sum) {
+		logf("%s already processed, skipping", path)
+		return nil
+	}
+	if err := fn(path); err != nil {
+		return err
+	}
+	if h.dryRun {
+		// 演练模式下处理器只记录了本应执行的动作，记账会让之后的正式运行跳过这些文件
+		wouldDo("record %s in the ledger", path)
+		return nil
+	}
+	if err := h.ledger.mark(path, sum); err != nil {
+		logf("ledger: record %s: %v", path, err)
+	}
+	return nil
+}
+
+// Init 记录是否为演练模式，并转发给下一个处理器的 Initializer
+func (h *LedgerHandler) Init(ctx context.Context) error {
+	h.dryRun = IsDryRun(ctx)
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 转发给下一个处理器的 Closer，并关闭账本
+func (h *LedgerHandler) Close() error {
+	var err error
+	if closer, ok := h.next.(Closer); ok {
+		err = closer.Close()
+	}
+	return errors.Join(err, h.ledger.Close())
+}
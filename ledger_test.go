@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"watchdogdemo/handlers"
+)
+
+func openTestLedger(t *testing.T, path string, sealer *handlers.Sealer) *Ledger {
+	t.Helper()
+	l, err := OpenLedger(path, sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestLedgerPersistsAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	ledgerPath := filepath.Join(dir, "ledger.jsonl")
+	file := filepath.Join(dir, "in.csv")
+	writeFile(t, file)
+
+	l := openTestLedger(t, ledgerPath, nil)
+	if err := l.MarkProcessed(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if l.AlreadyProcessed(file) {
+		t.Error("changed content reported as processed")
+	}
+	if err := l.MarkProcessed(file); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if n := countLines(t, ledgerPath); n != 2 {
+		t.Fatalf("ledger has %d line(s) before reopening, want 2", n)
+	}
+
+	l = openTestLedger(t, ledgerPath, nil)
+	if !l.AlreadyProcessed(file) {
+		t.Error("processed content forgotten after reopening")
+	}
+	if n := countLines(t, ledgerPath); n != 1 {
+		t.Errorf("ledger has %d line(s) after reopening, want 1", n)
+	}
+	if info, err := os.Stat(ledgerPath); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("ledger mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestLedgerNormalizesPaths(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "in.csv")
+	writeFile(t, file)
+	rel, err := filepath.Rel(wd, file)
+	if err != nil {
+		t.Skip("temp dir not reachable by a relative path:", err)
+	}
+
+	l := openTestLedger(t, filepath.Join(dir, "ledger.jsonl"), nil)
+	if err := l.MarkProcessed(rel); err != nil {
+		t.Fatal(err)
+	}
+	if !l.AlreadyProcessed(file) {
+		t.Error("absolute path not found after marking the relative path")
+	}
+	if !l.AlreadyProcessed(filepath.Join(dir, ".", "in.csv")) {
+		t.Error("unclean path not found")
+	}
+}
+
+func TestLedgerEncrypted(t *testing.T) {
+	sealer, err := handlers.NewSealer(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ledgerPath := filepath.Join(dir, "ledger.jsonl")
+	file := filepath.Join(dir, "secret-name.csv")
+	writeFile(t, file)
+
+	l := openTestLedger(t, ledgerPath, sealer)
+	if err := l.MarkProcessed(file); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	data, err := os.ReadFile(ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-name") {
+		t.Error("ledger stores the path in plaintext")
+	}
+
+	if _, err := OpenLedger(ledgerPath, nil); err == nil {
+		t.Error("encrypted ledger opened without a key")
+	}
+	l = openTestLedger(t, ledgerPath, sealer)
+	if !l.AlreadyProcessed(file) {
+		t.Error("processed content forgotten after reopening with the key")
+	}
+}
+
+// countingHandler 统计 CREATE 次数，err 非空时返回它
+type countingHandler struct {
+	NopHandler
+	creates int
+	err     error
+}
+
+func (h *countingHandler) OnCreate(string) error {
+	h.creates++
+	return h.err
+}
+
+func TestLedgerHandler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "in.csv")
+	writeFile(t, file)
+	next := &countingHandler{err: errors.New("downstream failed")}
+	h := NewLedgerHandler(next, openTestLedger(t, filepath.Join(dir, "ledger.jsonl"), nil))
+
+	if err := h.OnCreate(file); err == nil {
+		t.Fatal("downstream error not returned")
+	}
+	next.err = nil
+	if err := h.OnCreate(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.OnCreate(file); err != nil {
+		t.Fatal(err)
+	}
+	if next.creates != 2 {
+		t.Errorf("downstream called %d time(s), want 2 (failed once, then skipped once processed)", next.creates)
+	}
+}
+
+func TestLedgerHandlerMarkFailureNotRetried(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "in.csv")
+	writeFile(t, file)
+	next := &countingHandler{}
+	ledger := openTestLedger(t, filepath.Join(dir, "ledger.jsonl"), nil)
+	h := NewLedgerHandler(next, ledger)
+
+	// 账本文件已关闭，记账会失败
+	ledger.f.Close()
+	if err := h.OnCreate(file); err != nil {
+		t.Errorf("mark failure returned %v; a retry would run the downstream handler again", err)
+	}
+	if next.creates != 1 {
+		t.Errorf("downstream called %d time(s), want 1", next.creates)
+	}
+	if ledger.AlreadyProcessed(file) {
+		t.Error("failed mark recorded in memory")
+	}
+}
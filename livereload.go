@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// liveReloadProtocol LiveReload 官方协议标识，浏览器扩展握手时使用
+const liveReloadProtocol = "http://livereload.com/protocols/official-7"
+
+// liveReloadSnippet 注入 HTML 页面的脚本标签
+const liveReloadSnippet = `<script src="/livereload.js"></script>`
+
+// liveReloadScript 浏览器端脚本：CSS 变化时只替换样式表，其余变化刷新页面
+const liveReloadScript = `(function () {
+  var proto = location.protocol === "https:" ? "wss://" : "ws://";
+  function connect() {
+    var ws = new WebSocket(proto + location.host + "/livereload");
+    ws.onopen = function () {
+      ws.send(JSON.stringify({command: "hello", protocols: ["` + liveReloadProtocol + `"]}));
+    };
+    ws.onmessage = function (e) {
+      var msg = JSON.parse(e.data);
+      if (msg.command !== "reload") return;
+      if (msg.liveCSS && /\.css$/i.test(msg.path)) {
+        var hit = false;
+        document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+          var url = new URL(link.href);
+          if (url.pathname === msg.path) {
+            url.searchParams.set("livereload", Date.now());
+            link.href = url.toString();
+            hit = true;
+          }
+        });
+        if (hit) return;
+      }
+      location.reload();
+    };
+    ws.onclose = function () { setTimeout(connect, 1000); };
+  }
+  connect();
+})();
+`
+
+// DefaultLiveReloadPatterns 默认触发刷新的静态资源
+var DefaultLiveReloadPatterns = []string{"*.html", "*.htm", "*.css", "*.js"}
+
+// LiveReload 静态资源变化时通知浏览器刷新的事件处理器
+// 兼容 LiveReload 协议（浏览器扩展可直接连接），也可以通过注入的 /livereload.js 使用
+type LiveReload struct {
+	root     string
+	patterns []string
+
+	mu      sync.Mutex
+	clients map[*wsConn]struct{}
+}
+
+// NewLiveReload 创建 LiveReload 处理器，root 为站点根目录，patterns 为空时使用 DefaultLiveReloadPatterns
+func NewLiveReload(root string, patterns ...string) *LiveReload {
+	if len(patterns) == 0 {
+		patterns = DefaultLiveReloadPatterns
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &LiveReload{
+		root:     root,
+		patterns: patterns,
+		clients:  make(map[*wsConn]struct{}),
+	}
+}
+
+// OnCreate 实现 EventHandler 接口
+func (lr *LiveReload) OnCreate(path string) error { return lr.reload(path) }
+
+// OnWrite 实现 EventHandler 接口
+func (lr *LiveReload) OnWrite(path string) error { return lr.reload(path) }
+
+// OnRemove 实现 EventHandler 接口
+func (lr *LiveReload) OnRemove(path string) error { return lr.reload(path) }
+
+// OnRename 实现 EventHandler 接口
+func (lr *LiveReload) OnRename(path string) error { return lr.reload(path) }
+
+// OnChmod 实现 EventHandler 接口，权限变化不刷新
+func (lr *LiveReload) OnChmod(string) error { return nil }
+
+// OnEvent 实现 EventAwareHandler 接口，合并后的事件（如 CREATE|WRITE）只刷新一次
+func (lr *LiveReload) OnEvent(ev Event) error {
+	if ev.Op == fsnotify.Chmod {
+		return nil
+	}
+	return lr.reload(ev.Path)
+}
+
+// Close 实现 Closer 接口，断开所有浏览器连接
+func (lr *LiveReload) Close() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		c.writeFrame(wsClose, nil)
+		c.Close()
+		delete(lr.clients, c)
+	}
+	return nil
+}
+
+// matches 判断文件变化是否需要刷新
+func (lr *LiveReload) matches(path string) bool {
+	for _, pattern := range lr.patterns {
+		if matchGlob(pattern, filepath.Base(path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload 向所有已连接的浏览器广播刷新消息
+func (lr *LiveReload) reload(file string) error {
+	if !lr.matches(file) {
+		return nil
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	rel, err := filepath.Rel(lr.root, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	urlPath := "/" + filepath.ToSlash(rel)
+	msg, _ := json.Marshal(map[string]any{
+		"command": "reload",
+		"path":    urlPath,
+		"liveCSS": true,
+	})
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		if err := c.WriteText(msg); err != nil {
+			c.Close()
+			delete(lr.clients, c)
+		}
+	}
+	logf("[RELOAD] %s (%d browser(s))", urlPath, len(lr.clients))
+	return nil
+}
+
+// Handler 返回 HTTP 处理器：提供 /livereload（WebSocket）和 /livereload.js，
+// 其余请求交给 files（可为 nil），并在返回的 HTML 页面中注入脚本；路径中有以 . 开头的段（.git、.env 等）时返回 404
+func (lr *LiveReload) Handler(files http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livereload", lr.serveWebSocket)
+	mux.HandleFunc("/livereload.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(liveReloadScript))
+	})
+	if files != nil {
+		mux.Handle("/", hideDotPaths(lr.injectHTML(files)))
+	}
+	return mux
+}
+
+// serveWebSocket 处理浏览器连接，响应 LiveReload 握手
+func (lr *LiveReload) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	lr.mu.Lock()
+	lr.clients[c] = struct{}{}
+	lr.mu.Unlock()
+
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, c)
+		lr.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg struct {
+			Command string `json:"command"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Command == "hello" {
+			reply, _ := json.Marshal(map[string]any{
+				"command":    "hello",
+				"protocols":  []string{liveReloadProtocol},
+				"serverName": "watchdogdemo",
+			})
+			if err := c.WriteText(reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// hideDotPaths 拒绝任何一段以 . 开头的路径，避免把站点目录中的 .git、.env 等文件暴露出去
+func hideDotPaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, segment := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(segment, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// injectHTML 读取 HTML 页面并在 </body> 前插入脚本，其他文件交给 files
+func (lr *LiveReload) injectHTML(files http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		ext := strings.ToLower(path.Ext(name))
+		if r.Method != http.MethodGet || (ext != ".html" && ext != ".htm") {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		content, err := os.ReadFile(filepath.Join(lr.root, filepath.FromSlash(name)))
+		if err != nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		if i := bytes.LastIndex(bytes.ToLower(content), []byte("</body>")); i >= 0 {
+			content = append(content[:i:i], append([]byte(liveReloadSnippet), content[i:]...)...)
+		} else {
+			content = append(content, liveReloadSnippet...)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(content)
+	})
+}
+
+// runLiveReload livereload 子命令：提供静态文件服务，资源变化时自动刷新浏览器
+func runLiveReload(args []string) int {
+	fs := flag.NewFlagSet("livereload", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:35729", "HTTP listen address (35729 is the port LiveReload browser extensions connect to; use :35729 to serve other machines)")
+	patterns := fs.String("patterns", strings.Join(DefaultLiveReloadPatterns, ","), "comma-separated file patterns that trigger a reload")
+	noServe := fs.Bool("no-serve", false, "only serve the LiveReload endpoint, not the files themselves")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s livereload [flags] [dir]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	lr := NewLiveReload(dir, strings.Split(*patterns, ",")...)
+	watcher, err := NewFileWatcher(lr,
+		WithRecursive(true),
+		WithDebounce(100*time.Millisecond),
+		WithSkipHidden(),
+		WithExclude("node_modules"),
+	)
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	if _, err := watcher.Watch(dir); err != nil {
+		return exitErr(err, "failed to watch path %s: %v", dir, err)
+	}
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+
+	var files http.Handler
+	if !*noServe {
+		files = http.FileServer(http.Dir(dir))
+	}
+	server := &http.Server{Addr: *addr, Handler: lr.Handler(files)}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatalf("livereload server: %v", err)
+		}
+	}()
+	logf("livereload: serving %s on %s", dir, *addr)
+
+	waitForSignal()
+	server.Close()
+	logf("Shutting down...")
+	return ExitOK
+}
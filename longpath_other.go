@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// longPath 只在 Windows 上需要转换，其他平台原样返回
+func longPath(path string) string { return path }
+
+// stripLongPath 只在 Windows 上需要转换，其他平台原样返回
+func stripLongPath(path string) string { return path }
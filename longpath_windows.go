@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath 将路径转换为 \\?\ 扩展长度形式（UNC 共享 \\server\share 转换为 \\?\UNC\server\share），
+// 使后端注册和遍历不受 MAX_PATH（260 字符）限制；已带前缀的路径原样返回。
+// 带前缀的路径不再经过 Win32 规范化（不解析 .、.. 和 /），所以先转换为干净的绝对路径
+func longPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	switch {
+	case strings.HasPrefix(path, `\\`):
+		return `\\?\UNC\` + path[2:]
+	case len(path) >= 3 && path[1] == ':' && path[2] == '\\':
+		return `\\?\` + path
+	}
+	return path
+}
+
+// stripLongPath 去掉 \\?\ 前缀，还原为普通路径，保证事件路径与根路径、模式使用同一种写法
+func stripLongPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, `\\?\UNC\`):
+		return `\\` + path[len(`\\?\UNC\`):]
+	case strings.HasPrefix(path, `\\?\`) && len(path) >= 6 && path[5] == ':':
+		return path[len(`\\?\`):]
+	}
+	return path
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, in, want string
+	}{
+		{"drive", `C:\data\in`, `\\?\C:\data\in`},
+		{"drive with dots", `C:\data\.\tmp\..\in`, `\\?\C:\data\in`},
+		{"forward slashes", `C:/data/in`, `\\?\C:\data\in`},
+		{"UNC", `\\server\share\dir`, `\\?\UNC\server\share\dir`},
+		{"already prefixed", `\\?\C:\data\in`, `\\?\C:\data\in`},
+		{"already prefixed UNC", `\\?\UNC\server\share`, `\\?\UNC\server\share`},
+		{"device", `\\.\pipe\name`, `\\.\pipe\name`},
+		{"relative", `data\in`, longPath(filepath.Join(wd, "data", "in"))},
+		{"relative with dots", `.\data\..\in`, longPath(filepath.Join(wd, "in"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := longPath(tt.in); got != tt.want {
+				t.Errorf("longPath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripLongPath(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"drive", `\\?\C:\data\in`, `C:\data\in`},
+		{"UNC", `\\?\UNC\server\share\dir`, `\\server\share\dir`},
+		{"plain drive", `C:\data\in`, `C:\data\in`},
+		{"plain UNC", `\\server\share`, `\\server\share`},
+		{"volume GUID", `\\?\Volume{0b1c}\dir`, `\\?\Volume{0b1c}\dir`},
+		{"device", `\\.\pipe\name`, `\\.\pipe\name`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripLongPath(tt.in); got != tt.want {
+				t.Errorf("stripLongPath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongPathRoundTrip(t *testing.T) {
+	for _, path := range []string{`C:\data\in`, `D:\`, `\\server\share\dir`, `C:\a\very\deep\tree\file.txt`} {
+		if got := stripLongPath(longPath(path)); got != path {
+			t.Errorf("stripLongPath(longPath(%q)) = %q", path, got)
+		}
+	}
+}
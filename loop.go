@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLoopHops 命令行默认的事件循环跳数上限
+const DefaultLoopHops = 10
+
+// loopWindow 处理器完成后仍把同名路径上的事件归因于它的时间
+const loopWindow = 2 * time.Second
+
+// LoopDetector 用事件来源标记发现处理器之间的事件循环，如 A→B 镜像的同时另有 B→A 镜像，或 B 位于 A 内：
+// 处理器处理路径 P 期间及完成后 loopWindow 内，另一个同名路径上出现的事件被视为由 P 的处理引起，跳数加一；
+// 跳数达到上限时丢弃事件以打断循环，并以 ErrEventLoop 上报。多个监控器共享同一个检测器时可发现跨监控器的循环
+type LoopDetector struct {
+	maxHops int
+
+	mu    sync.Mutex
+	marks map[string]*loopMark // 文件名 -> 最近一次处理留下的来源标记
+}
+
+// loopMark 一次处理留下的来源标记
+type loopMark struct {
+	path  string
+	hops  int
+	since time.Time // 开始处理的时间
+	until time.Time // 归因截止时间，处理中为零值
+}
+
+// NewLoopDetector 创建事件循环检测器，maxHops 为允许的最大连锁跳数
+func NewLoopDetector(maxHops int) *LoopDetector {
+	return &LoopDetector{maxHops: maxHops, marks: make(map[string]*loopMark)}
+}
+
+// WithLoopDetection 在分发前检查事件是否由处理器之间的循环引起（见 LoopDetector）
+func WithLoopDetection(d *LoopDetector) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.loops = d
+	}
+}
+
+// begin 处理事件前检查来源并留下标记；构成循环时返回 ErrEventLoop
+func (d *LoopDetector) begin(ev Event) (*loopMark, error) {
+	key := filepath.Base(ev.Path)
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hops := 0
+	if m := d.marks[key]; m != nil && m.path != ev.Path && !ev.FirstSeen.Before(m.since) &&
+		(m.until.IsZero() || !ev.FirstSeen.After(m.until)) {
+		hops = m.hops + 1
+		if hops >= d.maxHops {
+			return nil, fmt.Errorf("%w: %d hops, last caused by handling %s", ErrEventLoop, hops, m.path)
+		}
+	}
+	// 顺带清理过期标记
+	if len(d.marks) > 1024 {
+		for k, m := range d.marks {
+			if !m.until.IsZero() && now.After(m.until) {
+				delete(d.marks, k)
+			}
+		}
+	}
+	mark := &loopMark{path: ev.Path, hops: hops, since: now}
+	d.marks[key] = mark
+	return mark, nil
+}
+
+// end 处理完成，开始计算归因窗口
+func (d *LoopDetector) end(mark *loopMark) {
+	d.mu.Lock()
+	mark.until = time.Now().Add(loopWindow)
+	d.mu.Unlock()
+}
+
+// WithOutputDirs 声明处理器写入的目录（如镜像的目标目录）：Watch 时若输出目录位于监控范围内（且未被排除），
+// 或监控路径位于输出目录内，返回 ConfigError，避免处理器的输出再次触发自身
+func WithOutputDirs(dirs ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.outputs = append(fw.outputs, dirs...)
+	}
+}
+
+// checkOutputs 校验处理器的输出目录与根路径不重叠
+func (fw *FileWatcher) checkOutputs(root *watchRoot) error {
+	var problems []string
+	for _, dir := range fw.outputs {
+		dir = fw.rootPath(dir)
+		switch {
+		case !root.file && fw.needs(root, dir):
+			problems = append(problems, fmt.Sprintf("output directory %s is inside watched path %s; exclude it or events will loop", dir, root.path))
+		case isUnder(dir, root.path):
+			problems = append(problems, fmt.Sprintf("watched path %s is inside output directory %s; events will loop", root.path, dir))
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// outputCycle 检查监控组之间的输出关系是否成环：组 A 写入组 B 监控的路径、B 又写入 A 监控的路径等，
+// 返回环上的组名，如 [a b a]；outputs 和 paths 均按组名索引
+func outputCycle(outputs, paths map[string][]string) []string {
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	overlaps := func(dirs, roots []string) bool {
+		for _, dir := range dirs {
+			for _, root := range roots {
+				dir, root := absPath(dir), absPath(root)
+				if isUnder(root, dir) || isUnder(dir, root) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// 深度优先搜索，state: 0 未访问，1 在当前路径上，2 已完成
+	state := make(map[string]int)
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = 1
+		stack = append(stack, name)
+		for _, next := range names {
+			if next == name || !overlaps(outputs[name], paths[next]) {
+				continue
+			}
+			switch state[next] {
+			case 1:
+				i := slices.Index(stack, next)
+				return append(append([]string(nil), stack[i:]...), next)
+			case 0:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = 2
+		return nil
+	}
+	for _, name := range names {
+		if state[name] == 0 {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// absPath 返回绝对路径，无法获取时返回清理后的原路径
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// formatCycle 格式化环上的组名，如 "a -> b -> a"
+func formatCycle(cycle []string) string {
+	return strings.Join(cycle, " -> ")
+}
@@ -1,90 +1,305 @@
 package main
 
 import (
-	"log"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 // EventHandler 定义事件处理器接口（观察者模式）
+// 处理方法返回的错误会交给分发器，按重试策略决定是否重试
 type EventHandler interface {
-	OnCreate(path string)
-	OnWrite(path string)
-	OnRemove(path string)
-	OnRename(path string)
-	OnChmod(path string)
+	OnCreate(path string) error
+	OnWrite(path string) error
+	OnRemove(path string) error
+	OnRename(path string) error
+	OnChmod(path string) error
+}
+
+// Initializer 可选接口：处理器在监控启动时初始化资源（连接池、客户端等）
+// ctx 在监控停止时取消，可用于约束处理器的后台任务
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// Closer 可选接口：处理器在监控停止时释放资源；
+// 调用 Close 时 Init 收到的上下文尚未取消（Shutdown 超时除外），可用于收尾
+type Closer interface {
+	Close() error
 }
 
 // LoggingHandler 一个简单的日志处理器实现
 type LoggingHandler struct{}
 
-func (h *LoggingHandler) OnCreate(path string) {
-	log.Printf("[CREATE] %s", path)
+func (h *LoggingHandler) OnCreate(path string) error {
+	logf("[CREATE] %s", path)
+	return nil
 }
 
-func (h *LoggingHandler) OnWrite(path string) {
-	log.Printf("[WRITE] %s", path)
+func (h *LoggingHandler) OnWrite(path string) error {
+	logf("[WRITE] %s", path)
+	return nil
 }
 
-func (h *LoggingHandler) OnRemove(path string) {
-	log.Printf("[REMOVE] %s", path)
+func (h *LoggingHandler) OnRemove(path string) error {
+	logf("[REMOVE] %s", path)
+	return nil
 }
 
-func (h *LoggingHandler) OnRename(path string) {
-	log.Printf("[RENAME] %s", path)
+func (h *LoggingHandler) OnRename(path string) error {
+	logf("[RENAME] %s", path)
+	return nil
 }
 
-func (h *LoggingHandler) OnChmod(path string) {
-	log.Printf("[CHMOD] %s", path)
+func (h *LoggingHandler) OnChmod(path string) error {
+	logf("[CHMOD] %s", path)
+	return nil
 }
 
+func (h *LoggingHandler) OnChmodDetail(path string, change AttrChange) error {
+	logf("[CHMOD] %s (%s)", path, change)
+	return nil
+}
+
+func (h *LoggingHandler) OnAttrib(path string, change XattrChange) error {
+	logf("[ATTRIB] %s (xattrs changed: %v)", path, change.Keys())
+	return nil
+}
+
+func (h *LoggingHandler) OnTruncate(path string) error {
+	logf("[TRUNCATE] %s", path)
+	return nil
+}
+
+func (h *LoggingHandler) OnProgress(path string, bytesSoFar int64, rate float64) error {
+	logf("[PROGRESS] %s %s (%s/s)", path, formatBytes(bytesSoFar), formatBytes(int64(rate)))
+	return nil
+}
+
+// DefaultDebounceLimit FileWatcher 默认最多同时挂起的去抖动定时器数量
+const DefaultDebounceLimit = 100000
+
 // Debouncer 事件去抖动器，避免事件风暴
 type Debouncer struct {
 	mu       sync.Mutex
-	timers   map[string]*time.Timer
+	timers   map[string]*debounceEntry
+	order    *list.List // 按最近一次触发排序，队首最旧
 	duration time.Duration
+	limit    int               // 挂起定时器上限，0 表示不限制
+	forced   uint64            // 因超出上限被提前触发的次数
+	adaptive *adaptiveDebounce // SetAdaptive 启用的自适应窗口，nil 时所有路径使用 duration
+}
+
+// debounceEntry 一个挂起的定时器
+type debounceEntry struct {
+	timer    *time.Timer
+	elem     *list.Element
+	callback func()
 }
 
 // NewDebouncer 创建新的去抖动器
 func NewDebouncer(duration time.Duration) *Debouncer {
 	return &Debouncer{
-		timers:   make(map[string]*time.Timer),
+		timers:   make(map[string]*debounceEntry),
+		order:    list.New(),
 		duration: duration,
 	}
 }
 
+// SetLimit 限制同时挂起的定时器数量，超出时提前触发最久未更新的条目，避免大量不同路径持续变化时内存无限增长
+func (d *Debouncer) SetLimit(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.limit = n
+}
+
 // Debounce 对指定路径的事件进行去抖动处理
 func (d *Debouncer) Debounce(path string, callback func()) {
+	d.debounce(path, callback)
+}
+
+// debounce 同 Debounce，返回本次使用的窗口
+func (d *Debouncer) debounce(path string, callback func()) time.Duration {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	duration := d.duration
+	if a := d.adaptive; a != nil {
+		now := time.Now()
+		duration = a.window(path, now)
+		if len(a.paths) > max(1024, 2*len(d.timers)) {
+			a.prune(d.timers, now)
+		}
+	}
 
-	// 如果已存在该路径的定时器，先停止它
-	if timer, exists := d.timers[path]; exists {
-		timer.Stop()
+	// 如果已存在该路径的定时器，先停止它；已触发但还在等锁的旧定时器会发现条目被替换而放弃执行
+	var elem *list.Element
+	if old, exists := d.timers[path]; exists {
+		old.timer.Stop()
+		elem = old.elem
+		d.order.MoveToBack(elem)
+	} else {
+		elem = d.order.PushBack(path)
 	}
 
 	// 创建新的定时器
-	d.timers[path] = time.AfterFunc(d.duration, func() {
-		callback()
+	entry := &debounceEntry{elem: elem, callback: callback}
+	entry.timer = time.AfterFunc(duration, func() {
 		d.mu.Lock()
+		if d.timers[path] != entry {
+			d.mu.Unlock()
+			return
+		}
 		delete(d.timers, path)
+		d.order.Remove(elem)
 		d.mu.Unlock()
+		callback()
 	})
+	d.timers[path] = entry
+
+	// 超出上限时强制提前触发最旧的条目
+	var evicted []*debounceEntry
+	for d.limit > 0 && len(d.timers) > d.limit {
+		oldest := d.order.Front()
+		e := d.timers[oldest.Value.(string)]
+		delete(d.timers, oldest.Value.(string))
+		d.order.Remove(oldest)
+		d.forced++
+		evicted = append(evicted, e)
+	}
+	d.mu.Unlock()
+
+	// 条目已移除，即使定时器恰好已触发也会放弃执行，这里同步执行回调，持续超限时对事件循环形成背压
+	for _, e := range evicted {
+		e.timer.Stop()
+		e.callback()
+	}
+	return duration
+}
+
+// Pending 返回尚未触发的定时器数量
+func (d *Debouncer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.timers)
+}
+
+// Flush 立即按最旧优先的顺序执行所有挂起的回调，返回执行的数量
+func (d *Debouncer) Flush() int {
+	entries := d.drain()
+	for _, e := range entries {
+		e.callback()
+	}
+	return len(entries)
+}
+
+// Cancel 丢弃所有挂起的回调，返回丢弃的数量
+func (d *Debouncer) Cancel() int {
+	return len(d.drain())
+}
+
+// drain 停止并移除所有挂起的定时器，按最旧优先返回
+func (d *Debouncer) drain() []*debounceEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entries := make([]*debounceEntry, 0, len(d.timers))
+	for elem := d.order.Front(); elem != nil; elem = elem.Next() {
+		e := d.timers[elem.Value.(string)]
+		e.timer.Stop()
+		entries = append(entries, e)
+	}
+	d.timers = make(map[string]*debounceEntry)
+	d.order.Init()
+	return entries
+}
+
+// Forced 返回因超出上限被提前触发的次数
+func (d *Debouncer) Forced() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.forced
 }
 
 // FileWatcher 文件监控器
 type FileWatcher struct {
-	watcher   *fsnotify.Watcher
-	handler   EventHandler
-	done      chan struct{}
-	recursive bool
-	debouncer *Debouncer
+	mu               sync.Mutex // 保护 watcher 和 roots，后端重建时会替换 watcher
+	watcher          watchBackend
+	shared           *SharedBackend // WithSharedBackend 设置的共享后端，nil 时独占一个 fsnotify watcher
+	roots            []*watchRoot   // 通过 Watch 注册的根路径，用于后端重建后重新注册
+	handler          EventHandler
+	routes           []*route // 主处理器（routes[0]）和 WithHandler 注册的处理器
+	priority         []string // 跳过去抖动立即分发的路径模式
+	dryRun           bool     // 演练模式，动作只记录日志
+	explain          bool     // 诊断模式，记录每个事件的处理过程
+	canon            *canonConfig
+	foldCase         bool // 模式匹配忽略大小写
+	done             chan struct{}
+	failed           chan struct{} // 后端失效且无法恢复时关闭
+	recursive        bool
+	debouncer        *Debouncer
+	coalescer        *coalescer
+	debounceLimit    int
+	flushOnStop      bool           // Stop 时执行（而不是丢弃）挂起的去抖动事件
+	loop             sync.WaitGroup // 事件循环，Stop 时等待其退出
+	inflight         inflight       // 正在执行的处理器调用
+	latency          latencyTracker // 事件从收到到处理完毕的延迟
+	stats            *statsCollector
+	bulk             bulkMode   // 批量模式期间只汇总事件
+	mutes            muteList   // Mute 注册的临时静音规则
+	pause            pauseGate  // Pause 暂停分发时保留的事件
+	self             selfWrites // SelfWrite 标记的自身写入
+	loops            *LoopDetector
+	outputs          []string      // WithOutputDirs 声明的处理器输出目录
+	observer         *rateObserver // WithObserveOnly 的目录速率统计，非空时不分发事件
+	dirs             *dirCoalescer // WithDirectoryCoalescing 的目录级合并
+	sampler          *sampler      // WithSampling 的热点路径采样
+	bindings         bindings      // Subscribe/OnWrite 等注册的函数订阅
+	subsMu           sync.RWMutex
+	subs             map[*subscriber]struct{} // All 等订阅者，停止后为 nil
+	retry            RetryPolicy
+	errors           ErrorHandler
+	restart          *restartConfig
+	mime             *mimeFilter
+	hidden           *hiddenConfig
+	sizes            *sizeTracker
+	progress         *progressTracker
+	uploads          *uploadDetector
+	inodes           *inodeTracker
+	special          *SpecialFilePolicy
+	skipInaccessible bool
+	walkProgress     WalkProgressFunc
+	budget           *watchBudget
+	poller           *treePoller
+	autoBackend      time.Duration // 启用 WithAutoBackend 时网络文件系统的轮询间隔
+	container        time.Duration // 容器模式的对账间隔
+	normalizer       *normalizer
+	attrs            *attrTracker
+	xattrs           *xattrTracker
+	quota            *quotaMonitor
+	retention        *retention
+	dedupe           *deduper
+	git              *gitAware
+	recorder         *recorder
+	maxDepth         int      // 递归监控最大深度，负数表示不限制
+	excludes         []string // 排除模式，遍历时剪枝整棵子树
+
+	// 事件过滤配置，filters 按选项顺序执行，其余字段用于校验
+	filters    []eventFilter
+	opMask     *fsnotify.Op // WithOps 指定的事件类型，nil 表示未设置
+	extensions map[string]bool
+	minSize    int64
+	maxSize    int64
+
+	ctx    context.Context // 监控生命周期上下文，Stop 时取消
+	cancel context.CancelFunc
+	// retryCtx 重试退避的上下文，开始停止时即取消，Stop/Shutdown 不必等待退避结束
+	retryCtx    context.Context
+	cancelRetry context.CancelFunc
 }
 
 // WatcherOption 配置选项函数类型
@@ -104,73 +319,355 @@ func WithDebounce(duration time.Duration) WatcherOption {
 	}
 }
 
-// NewFileWatcher 创建新的文件监控器
-func NewFileWatcher(handler EventHandler, opts ...WatcherOption) (*FileWatcher, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+// WithDebounceLimit 限制同时挂起的去抖动定时器数量（默认 DefaultDebounceLimit，0 表示不限制），
+// 超出时最久未更新的路径被提前分发
+func WithDebounceLimit(n int) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.debounceLimit = n
 	}
+}
+
+// WithFlushOnStop 设置 Stop 时如何处理仍在去抖动窗口内的事件：
+// true（默认）立即分发，保证停止前最后一批变化不丢失；false 丢弃并记录数量
+func WithFlushOnStop(flush bool) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.flushOnStop = flush
+	}
+}
 
+// WithRetry 设置处理器失败时的重试策略
+func WithRetry(policy RetryPolicy) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.retry = policy
+	}
+}
+
+// WithErrorHandler 设置错误处理器，替代默认的日志输出
+func WithErrorHandler(h ErrorHandler) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.errors = h
+	}
+}
+
+// NewFileWatcher 创建新的文件监控器
+// 配置非法时返回描述所有问题的错误
+func NewFileWatcher(handler EventHandler, opts ...WatcherOption) (*FileWatcher, error) {
 	fw := &FileWatcher{
-		watcher:   watcher,
 		handler:   handler,
 		done:      make(chan struct{}),
+		failed:    make(chan struct{}),
 		recursive: false,
 		debouncer: nil,
+		coalescer: newCoalescer(),
+		errors:    &LoggingErrorHandler{},
+		maxDepth:  -1,
+
+		debounceLimit: DefaultDebounceLimit,
+		flushOnStop:   true,
+		subs:          make(map[*subscriber]struct{}),
+		stats:         newStatsCollector(),
 	}
+	fw.routes = []*route{{handler: handler}}
 
 	// 应用配置选项
 	for _, opt := range opts {
 		opt(fw)
 	}
 
+	// 校验配置，避免运行时才出现异常行为
+	if err := fw.validate(); err != nil {
+		return nil, err
+	}
+	if fw.debouncer != nil {
+		fw.debouncer.SetLimit(fw.debounceLimit)
+	}
+
+	var err error
+	if fw.shared != nil {
+		fw.watcher, err = fw.shared.client()
+	} else {
+		fw.watcher, err = newFsnotifyBackend()
+	}
+	if err != nil {
+		return nil, err
+	}
+	base := context.WithValue(context.Background(), watcherKey{}, fw)
+	if fw.dryRun {
+		base = ContextWithDryRun(base)
+		if fw.retention != nil {
+			fw.retention.dryRun = true
+		}
+	}
+	fw.ctx, fw.cancel = context.WithCancel(base)
+	fw.retryCtx, fw.cancelRetry = context.WithCancel(fw.ctx)
+
 	return fw, nil
 }
 
-// Watch 添加要监控的路径
-func (fw *FileWatcher) Watch(path string) error {
-	if fw.recursive {
-		return fw.watchRecursive(path)
+// Watch 添加要监控的路径，opts 可覆盖该路径的递归、排除等全局配置，如
+// Watch("logs", Recursive(false), Exclude("tmp/**"))
+// 路径是文件时自动使用单文件模式：监控其父目录并只保留该文件的事件，
+// 编辑器用"写临时文件再重命名覆盖"的方式保存时 watch 不会失效
+// 返回的 WatchHandle 可注销（Close）或修改（Update）这一次注册，包括递归添加的子目录；
+// 错误可用 errors.Is 与 ErrPathNotFound、ErrAlreadyWatching、
+// ErrWatchLimitExceeded、ErrPermissionDenied、ErrStopped 比较；
+// 递归注册部分子目录失败时根路径仍会注册，同时返回 WatchHandle 和 *PartialWatchError
+func (fw *FileWatcher) Watch(path string, opts ...WatchOption) (*WatchHandle, error) {
+	if fw.stopped() {
+		return nil, ErrStopped
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, classifyWatchError(path, err)
+	}
+
+	root := fw.newRoot(path, opts)
+	if !info.IsDir() {
+		root.file = true
+		root.recursive = false
 	}
-	return fw.watcher.Add(path)
+	if err := validateRoot(root); err != nil {
+		return nil, err
+	}
+	if err := fw.checkOutputs(root); err != nil {
+		return nil, err
+	}
+
+	fw.mu.Lock()
+	for _, r := range fw.roots {
+		if r.path == root.path {
+			fw.mu.Unlock()
+			return nil, &WatchError{Path: path, Err: ErrAlreadyWatching}
+		}
+	}
+	fw.mu.Unlock()
+
+	err = fw.addRoot(root)
+	var partial *PartialWatchError
+	if err != nil && !errors.As(err, &partial) {
+		// 回滚已添加的子目录
+		fw.releaseWatches(root)
+		return nil, err
+	}
+
+	fw.mu.Lock()
+	fw.roots = append(fw.roots, root)
+	fw.mu.Unlock()
+	return &WatchHandle{fw: fw, root: root}, err
+}
+
+// validateRoot 校验根路径的排除模式
+func validateRoot(root *watchRoot) error {
+	for _, pattern := range root.excludes {
+		if pattern == "" || !validGlob(pattern) {
+			return &ConfigError{Problems: []string{fmt.Sprintf("invalid exclude pattern %q for %s", pattern, root.path)}}
+		}
+	}
+	return nil
+}
+
+// addRoot 在当前后端上注册根路径
+func (fw *FileWatcher) addRoot(root *watchRoot) error {
+	if fw.autoBackend > 0 && fw.pollRoot(root) {
+		return nil
+	}
+	if fw.container > 0 {
+		fw.reconcileRoot(root)
+	}
+	if root.file {
+		if info, err := os.Stat(root.path); err == nil {
+			fw.recordInitial(root.path, info)
+		}
+		// 直接监控文件会在重命名覆盖后失效，改为监控父目录
+		dir := filepath.Dir(root.path)
+		if err := fw.addWatch(dir); err != nil {
+			return classifyWatchError(dir, err)
+		}
+		return nil
+	}
+	if root.recursive {
+		return fw.watchRecursive(root)
+	}
+	if err := fw.addWatch(root.path); err != nil {
+		return classifyWatchError(root.path, err)
+	}
+	return nil
+}
+
+// backend 返回当前使用的后端
+func (fw *FileWatcher) backend() watchBackend {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.watcher
+}
+
+// addWatch 在当前后端上注册目录，Windows 上使用长路径形式
+func (fw *FileWatcher) addWatch(path string) error {
+	return fw.backend().Add(longPath(path))
 }
 
 // watchRecursive 递归添加目录监控
-func (fw *FileWatcher) watchRecursive(root string) error {
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+// 遍历使用长路径形式，避免深层目录超出 MAX_PATH；回调中的路径已还原为普通形式
+func (fw *FileWatcher) watchRecursive(root *watchRoot) error {
+	// 重新注册（如后端重启）时重新统计不可访问的子树
+	fw.mu.Lock()
+	root.inaccessible = nil
+	fw.mu.Unlock()
+	if fw.budget != nil {
+		fw.planBudget(root)
+	}
+	partial := &PartialWatchError{Path: root.path}
+	files := 0
+	// fail 记录子目录的失败并继续遍历其他子树，根路径失败时整体失败
+	fail := func(path string, info os.FileInfo, err error) error {
+		if fw.skipInaccessibleDir(root, path, err) {
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		we := classifyWatchError(path, err)
+		if path == root.path {
+			return we
+		}
+		partial.Failed = append(partial.Failed, we.(*WatchError))
+		if errors.Is(we, ErrWatchLimitExceeded) {
+			// 其余目录同样会失败
+			return filepath.SkipAll
+		}
+		if info != nil && info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	err := filepath.Walk(longPath(root.path), func(path string, info os.FileInfo, err error) error {
+		path = stripLongPath(path)
 		if err != nil {
-			return err
+			return fail(path, info, err)
 		}
-		if info.IsDir() {
-			log.Printf("Adding watch: %s", path)
-			if err := fw.watcher.Add(path); err != nil {
-				return err
+		if fw.ignored(root, path) {
+			if info.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
+		}
+		if fw.special != nil && !info.IsDir() && !fw.allowSpecial(path, info) {
+			return nil
+		}
+		fw.recordInitial(path, info)
+		if !info.IsDir() {
+			files++
+			return nil
+		}
+		if fw.tooDeep(root, path) {
+			return filepath.SkipDir
+		}
+		if root.pollDepth >= 0 && depth(root.path, path) >= root.pollDepth {
+			fw.poller.poll(fw, root, path, fw.budget.interval, false)
+			return filepath.SkipDir
+		}
+		logf("Adding watch: %s", path)
+		if err := fw.addWatch(path); err != nil {
+			return fail(path, info, err)
+		}
+		partial.Added++
+		if fw.walkProgress != nil {
+			fw.walkProgress(partial.Added, files, path)
 		}
 		return nil
 	})
+	if fw.walkProgress != nil {
+		fw.walkProgress(partial.Added, files, "")
+	}
+	if err != nil {
+		return err
+	}
+	if len(partial.Failed) > 0 {
+		return partial
+	}
+	return nil
+}
+
+// recordInitial 注册时记录文件初始状态，供截断检测、属性跟踪对比
+func (fw *FileWatcher) recordInitial(path string, info os.FileInfo) {
+	if fw.sizes != nil && info.Mode().IsRegular() {
+		fw.sizes.record(path, info.Size())
+	}
+	if fw.attrs != nil {
+		fw.attrs.record(path, info)
+	}
+	if fw.inodes != nil {
+		fw.inodes.record(path, info)
+	}
+	if fw.normalizer != nil {
+		fw.normalizer.record(path, info)
+	}
+	if fw.xattrs != nil {
+		fw.xattrs.record(path)
+	}
 }
 
 // Start 启动监控（非阻塞，启动后台goroutine）
-func (fw *FileWatcher) Start() {
-	go fw.eventLoop()
+// 如果处理器实现了 Initializer，会先调用 Init，失败时不启动
+func (fw *FileWatcher) Start() error {
+	if fw.stopped() {
+		return ErrStopped
+	}
+	for _, r := range fw.routes {
+		if initializer, ok := r.handler.(Initializer); ok {
+			if err := initializer.Init(fw.ctx); err != nil {
+				return fmt.Errorf("init handler: %w", err)
+			}
+		}
+	}
+	fw.loop.Add(1)
+	go func() {
+		defer fw.loop.Done()
+		fw.eventLoop()
+	}()
+	if fw.quota != nil {
+		go fw.runQuota()
+	}
+	if fw.retention != nil {
+		go fw.runRetention()
+	}
+	if fw.container > 0 {
+		logContainer(fw.container)
+	}
+	if fw.poller != nil {
+		go fw.runPoller()
+	}
+	if fw.xattrs != nil {
+		warnXattrUnsupported()
+		if fw.xattrs.interval > 0 {
+			go fw.pollXattrs()
+		}
+	}
+	return nil
 }
 
 // eventLoop 事件处理循环
 func (fw *FileWatcher) eventLoop() {
 	for {
+		w := fw.backend()
 		select {
-		case event, ok := <-fw.watcher.Events:
+		case event, ok := <-w.events():
 			if !ok {
+				if fw.recoverBackend() {
+					continue
+				}
 				return
 			}
 			fw.handleEvent(event)
 
-		case err, ok := <-fw.watcher.Errors:
+		case err, ok := <-w.errors():
 			if !ok {
+				if fw.recoverBackend() {
+					continue
+				}
 				return
 			}
-			log.Printf("watcher error: %v", err)
+			fw.reportError(err)
 
 		case <-fw.done:
 			return
@@ -180,87 +677,412 @@ func (fw *FileWatcher) eventLoop() {
 
 // handleEvent 处理事件（支持去抖动）
 func (fw *FileWatcher) handleEvent(event fsnotify.Event) {
+	seen := time.Now()
+	event.Name = fw.canonical(stripLongPath(event.Name))
+	fw.explainf(event.Name, "received %s", formatOps(event.Op))
+	fw.stats.receive(event)
+	root := fw.lookupRoot(event.Name)
+	if root == nil {
+		// 父目录只为单文件监控而注册，其他文件的事件直接忽略
+		if fw.fileParentOnly(event.Name) {
+			fw.explainf(event.Name, "ignored: sibling of a single-file watch")
+			fw.stats.add(&fw.stats.ignored)
+			return
+		}
+		root = fw.newRoot(filepath.Dir(event.Name), nil)
+	}
+	if reason := fw.ignoreReason(root, event.Name); reason != "" {
+		fw.explainf(event.Name, "ignored: %s", reason)
+		fw.stats.add(&fw.stats.ignored)
+		return
+	}
+	if pattern := fw.muted(event.Name); pattern != "" {
+		fw.explainf(event.Name, "muted by %q", pattern)
+		fw.stats.add(&fw.stats.muted)
+		return
+	}
+	if fw.selfWritten(event.Name) {
+		fw.explainf(event.Name, "ignored: written by the watcher itself")
+		fw.stats.add(&fw.stats.self)
+		return
+	}
+	if fw.container > 0 && event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(event.Name); err == nil && isWhiteout(info) {
+			fw.explainf(event.Name, "overlayfs whiteout, reporting as remove")
+			event.Op = fsnotify.Remove
+		}
+	}
+	if fw.special != nil && !fw.allowSpecial(event.Name, nil) {
+		return
+	}
+	if fw.normalizer != nil {
+		var ok bool
+		if event, ok = fw.normalizer.normalize(fw, event); !ok {
+			return
+		}
+	}
+	if root.file && fw.notifyFileChange(root, event) {
+		fw.explainf(event.Name, "consumed by OnChange callbacks")
+		return
+	}
+	if fw.sizes != nil {
+		fw.stats.written(event.Name, fw.sizes.observe(event))
+	}
+	if fw.attrs != nil {
+		fw.attrs.observe(event)
+	}
+	if fw.inodes != nil {
+		fw.inodes.observe(fw, event, seen)
+	}
+	if fw.container > 0 {
+		fw.poller.refresh(root, event.Name)
+	}
+	if fw.xattrs != nil {
+		fw.xattrs.observe(event)
+	}
+	if fw.quota != nil {
+		fw.quota.observe(event)
+	}
+	if fw.retention != nil {
+		fw.retention.observe(event)
+	}
+	if fw.git != nil {
+		fw.git.observe(event)
+	}
+	if fw.progress != nil && fw.observer == nil {
+		fw.progress.observe(fw, event, seen)
+	}
+
 	// 如果是新建目录且启用了递归监控，动态添加watch
-	if fw.recursive && event.Has(fsnotify.Create) {
-		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-			log.Printf("Adding watch for new directory: %s", event.Name)
-			fw.watcher.Add(event.Name)
+	if root.recursive && event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !fw.tooDeep(root, event.Name) && !fw.divert(root, event.Name) {
+			logf("Adding watch for new directory: %s", event.Name)
+			if err := fw.addWatch(event.Name); err != nil && !fw.skipInaccessibleDir(root, event.Name, err) {
+				fw.reportError(classifyWatchError(event.Name, err))
+			}
 		}
 	}
 
-	// 如果启用了去抖动，则延迟处理
+	// 观察模式只统计速率，不分发
+	if fw.observer != nil {
+		fw.explainf(event.Name, "counted by observe-only mode, not dispatched")
+		fw.observer.observe(event.Name, seen)
+		return
+	}
+
+	// 优先通道的事件立即分发，不经过批量模式和去抖动
+	if fw.urgent(event.Name) {
+		fw.explainf(event.Name, "matched a priority pattern, dispatching immediately")
+		fw.dispatchEvent(Event{Path: event.Name, Op: event.Op, FirstSeen: seen, LastSeen: seen})
+		return
+	}
+
+	// 批量模式下只汇总，结束时统一分发
+	if fw.bulk.absorb(event) {
+		fw.explainf(event.Name, "absorbed by bulk mode")
+		fw.stats.add(&fw.stats.bulk)
+		return
+	}
+
+	// 如果启用了去抖动，则合并窗口内的事件后延迟处理
 	if fw.debouncer != nil {
-		fw.debouncer.Debounce(event.Name, func() {
-			fw.dispatchEvent(event)
+		if fw.coalescer.add(event, seen) {
+			fw.stats.add(&fw.stats.coalesced)
+		}
+		window := fw.debouncer.debounce(event.Name, func() {
+			if merged, ok := fw.coalescer.take(event.Name); ok {
+				fw.explainf(event.Name, "debounce window closed, merged into %s", formatOps(merged.Op))
+				if fw.dirs == nil {
+					fw.dispatchEvent(merged)
+				} else if probe := merged; fw.applyFilters(&probe) {
+					fw.explainf(event.Name, "waiting in the directory window of %v", fw.dirs.window)
+					fw.dirs.add(fw, merged)
+				}
+			} else {
+				fw.explainf(event.Name, "suppressed: events in the debounce window cancelled out")
+				fw.stats.add(&fw.stats.suppressed)
+			}
 		})
+		fw.explainf(event.Name, "debouncing for %v", window)
 	} else {
-		fw.dispatchEvent(event)
+		fw.dispatchEvent(Event{Path: event.Name, Op: event.Op, FirstSeen: seen, LastSeen: seen})
+	}
+}
+
+// dispatchEvent 补全事件信息后分发，ev 只需包含路径、事件类型和收到时间；事件被过滤器丢弃时返回 false，
+// 启用上传识别时留待上传完成再分发的事件返回 true
+func (fw *FileWatcher) dispatchEvent(ev Event) bool {
+	if fw.pause.hold(fw, ev) {
+		return true
+	}
+	if fw.uploads != nil {
+		if pattern := fw.uploads.temporary(fw, ev.Path); pattern != "" {
+			fw.explainf(ev.Path, "ignored: upload temp file matching %q", pattern)
+			fw.stats.add(&fw.stats.filtered)
+			return false
+		}
+		if fw.uploads.hold(fw, ev) {
+			return true
+		}
+	}
+	return fw.dispatchReady(ev)
+}
+
+// dispatchReady 不经过上传识别，补全事件信息后分发
+func (fw *FileWatcher) dispatchReady(ev Event) bool {
+	if fw.sizes != nil {
+		ev.Truncated = fw.sizes.takeTruncated(ev.Path)
+		if fw.sizes.accounting {
+			var reconciled int64
+			ev.Bytes, reconciled = fw.sizes.takeBytes(ev.Path)
+			fw.stats.written(ev.Path, reconciled)
+		}
+	}
+	if fw.attrs != nil {
+		ev.Attr = fw.attrs.take(ev.Path)
+	}
+	if fw.inodes != nil {
+		ev.Inode = fw.inodes.take(ev.Path)
+	}
+	if fw.special != nil {
+		if info, err := os.Lstat(ev.Path); err == nil {
+			ev.Special = specialKind(info)
+		}
+	}
+	if fw.xattrs != nil {
+		ev.Xattr = fw.xattrs.take(ev.Path)
+	}
+	if !fw.applyFilters(&ev) {
+		return false
+	}
+	if fw.mime != nil && !fw.mime.enrich(&ev) {
+		fw.explainf(ev.Path, "dropped by MIME filter (detected %q)", ev.MIME)
+		fw.stats.add(&fw.stats.filtered)
+		return false
 	}
+	if fw.dedupe != nil && fw.dedupe.duplicate(ev) {
+		fw.explainf(ev.Path, "dropped as a duplicate within the dedupe window")
+		fw.stats.add(&fw.stats.filtered)
+		return false
+	}
+	if fw.sampler != nil {
+		if policy := fw.sampler.sample(fw, &ev); policy != nil {
+			fw.explainf(ev.Path, "dropped by sampling policy %s", policy)
+			fw.stats.add(&fw.stats.sampled)
+			return false
+		}
+	}
+	if fw.git != nil {
+		ev.Git = fw.git.status(ev.Path)
+	}
+
+	if fw.recorder != nil {
+		fw.recorder.record(ev)
+	}
+	fw.deliver(ev)
+	return true
 }
 
-// dispatchEvent 分发事件到对应的处理方法
-func (fw *FileWatcher) dispatchEvent(event fsnotify.Event) {
+// deliver 将事件交给过滤条件匹配的每个处理器
+func (fw *FileWatcher) deliver(ev Event) {
+	if fw.loops != nil {
+		mark, err := fw.loops.begin(ev)
+		if err != nil {
+			fw.explainf(ev.Path, "dropped: %v", err)
+			fw.stats.add(&fw.stats.filtered)
+			fw.reportError(&WatchError{Path: ev.Path, Err: err})
+			return
+		}
+		defer fw.loops.end(mark)
+	}
+	fw.inflight.begin()
+	defer fw.inflight.end()
+	defer fw.latency.observe(ev, time.Now())
+	fw.stats.dispatch(ev.Op)
+	defer fw.publish(ev)
+	defer fw.callBindings(ev)
+
+	for i, r := range fw.routes {
+		if e, ok := r.accept(fw, ev); ok {
+			fw.explainf(ev.Path, "handler #%d (%T) receives %s", i, r.handler, formatOps(e.Op))
+			fw.dispatchTo(r.handler, e)
+		} else {
+			fw.explainf(ev.Path, "handler #%d (%T) skipped by its filter", i, r.handler)
+		}
+	}
+}
+
+// dispatchTo 按处理器实现的接口调用对应方法
+func (fw *FileWatcher) dispatchTo(h EventHandler, ev Event) {
+	// 需要完整事件信息的处理器只接收 OnEvent
+	if eh, ok := h.(EventAwareHandler); ok {
+		fw.invoke(ev.Op.String(), ev.Path, func(string) error {
+			return eh.OnEvent(ev)
+		})
+		return
+	}
+
 	// fsnotify 使用位掩码表示事件类型
 	// 一个事件可能同时包含多种操作
 
-	if event.Has(fsnotify.Create) {
-		fw.handler.OnCreate(event.Name)
+	if ev.Has(fsnotify.Create) {
+		fw.invoke("CREATE", ev.Path, h.OnCreate)
 	}
-	if event.Has(fsnotify.Write) {
-		fw.handler.OnWrite(event.Name)
+	if ev.Has(fsnotify.Write) {
+		if th, ok := h.(TruncateHandler); ok && ev.Truncated {
+			fw.invoke("TRUNCATE", ev.Path, th.OnTruncate)
+		} else {
+			fw.invoke("WRITE", ev.Path, h.OnWrite)
+		}
 	}
-	if event.Has(fsnotify.Remove) {
-		fw.handler.OnRemove(event.Name)
+	if ev.Has(fsnotify.Remove) {
+		fw.invoke("REMOVE", ev.Path, h.OnRemove)
 	}
-	if event.Has(fsnotify.Rename) {
-		fw.handler.OnRename(event.Name)
+	if ev.Has(fsnotify.Rename) {
+		fw.invoke("RENAME", ev.Path, h.OnRename)
 	}
-	if event.Has(fsnotify.Chmod) {
-		fw.handler.OnChmod(event.Name)
+	if ev.Has(fsnotify.Chmod) {
+		if ah, ok := h.(AttribHandler); ok && ev.Xattr != nil {
+			fw.invoke("ATTRIB", ev.Path, func(path string) error {
+				return ah.OnAttrib(path, *ev.Xattr)
+			})
+		} else if ch, ok := h.(ChmodDetailHandler); ok && ev.Attr != nil {
+			fw.invoke("CHMOD", ev.Path, func(path string) error {
+				return ch.OnChmodDetail(path, *ev.Attr)
+			})
+		} else {
+			fw.invoke("CHMOD", ev.Path, h.OnChmod)
+		}
+	}
+}
+
+// invoke 按重试策略调用处理方法，最终仍失败时上报错误并放弃该事件；开始停止后不再重试
+func (fw *FileWatcher) invoke(op, path string, fn func(string) error) {
+	attempts, err := fw.retry.Do(fw.retryCtx, func() error {
+		return fn(path)
+	})
+	if err != nil {
+		fw.stats.handlerError(op)
+		fw.reportError(&HandlerError{Op: op, Path: path, Attempts: attempts, Err: err})
+	}
+}
+
+// reportError 将错误交给错误处理器
+func (fw *FileWatcher) reportError(err error) {
+	if fw.errors != nil {
+		fw.errors.OnError(err)
 	}
 }
 
 // Stop 停止监控
+// 关闭后端并等待事件循环退出后，按 WithFlushOnStop 分发或丢弃仍在去抖动窗口内的事件，
+// 如果处理器实现了 Closer，最后调用 Close；不等待其他 goroutine 中正在执行的处理器调用，
+// 需要等待时使用 Shutdown
+// 重复调用返回 ErrStopped
 func (fw *FileWatcher) Stop() error {
-	close(fw.done)
-	return fw.watcher.Close()
+	return fw.shutdown(nil)
 }
 
-func main() {
-	// 创建事件处理器
-	handler := &LoggingHandler{}
-
-	// 创建文件监控器（启用递归监控和100ms去抖动）
-	watcher, err := NewFileWatcher(
-		handler,
-		WithRecursive(true),
-		WithDebounce(100*time.Millisecond),
-	)
-	if err != nil {
-		log.Fatalf("failed to create watcher: %v", err)
-	}
-	defer watcher.Stop()
+// Shutdown 优雅停止：不再接收新事件，处理完挂起的去抖动事件，
+// 等待正在执行的处理器调用结束后关闭处理器，最后取消上下文；
+// ctx 到期时不再等待，先取消再关闭，返回的错误包含 ctx.Err()
+// 重复调用返回 ErrStopped
+func (fw *FileWatcher) Shutdown(ctx context.Context) error {
+	return fw.shutdown(ctx)
+}
 
-	// 添加要监控的路径（监控当前目录）
-	watchPath := "."
-	if len(os.Args) > 1 {
-		watchPath = os.Args[1]
+// shutdown Stop 和 Shutdown 的共同实现，ctx 为 nil 时不等待正在执行的处理器调用
+func (fw *FileWatcher) shutdown(ctx context.Context) error {
+	fw.mu.Lock()
+	if fw.stopped() {
+		fw.mu.Unlock()
+		return ErrStopped
 	}
+	close(fw.done)
+	fw.mu.Unlock()
+	fw.cancelRetry()
 
-	if err := watcher.Watch(watchPath); err != nil {
-		log.Fatalf("failed to watch path %s: %v", watchPath, err)
+	err := fw.backend().Close()
+	fw.loop.Wait()
+	// 暂停期间保留的事件在关闭处理器之前补发
+	fw.Resume()
+
+	if ctx == nil {
+		fw.drainDebounced()
+	} else {
+		drained := make(chan struct{})
+		go func() {
+			fw.drainDebounced()
+			fw.inflight.wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			logf("Shutdown deadline reached with %d handler call(s) in flight", fw.inflight.count())
+			err = errors.Join(err, ctx.Err())
+			// 到期后先取消，让仍在执行的调用和 Close 中的收尾尽快返回
+			fw.cancel()
+		}
 	}
+	fw.closeSubscribers()
 
-	log.Printf("Watching: %s (recursive: %v)", watchPath, true)
-	log.Println("Press Ctrl+C to stop...")
+	// 处理器的 Close 可能还要用 Init 时拿到的上下文完成收尾（冲刷挂起的文件、
+	// 等待后台任务），因此先关闭处理器再取消上下文
+	for _, r := range fw.routes {
+		if closer, ok := r.handler.(Closer); ok {
+			if cerr := closer.Close(); cerr != nil {
+				err = errors.Join(err, fmt.Errorf("close handler: %w", cerr))
+			}
+		}
+	}
+	fw.cancel()
+	return err
+}
 
-	// 启动监控
-	watcher.Start()
+// drainDebounced 停止时处理挂起的去抖动事件
+func (fw *FileWatcher) drainDebounced() {
+	defer fw.drainUploads()
+	if fw.debouncer == nil {
+		return
+	}
+	if fw.flushOnStop {
+		if n := fw.debouncer.Flush(); n > 0 {
+			logf("Flushed %d pending debounced event(s)", n)
+		}
+		if fw.dirs != nil {
+			for _, b := range fw.dirs.drain() {
+				fw.dirs.release(fw, b)
+			}
+		}
+		return
+	}
+	if n := fw.debouncer.Cancel(); n > 0 {
+		logf("Discarded %d pending debounced event(s)", n)
+	}
+	if fw.dirs != nil {
+		n := 0
+		for _, b := range fw.dirs.drain() {
+			n += b.summary.Events
+		}
+		if n > 0 {
+			logf("Discarded %d event(s) pending in directory windows", n)
+		}
+	}
+}
 
-	// 等待中断信号
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+func main() {
+	// 日志语言默认取自 LANG，watch/replay 可用 --lang 覆盖
+	language = languageFromEnv()
+	os.Exit(run(os.Args[1:]))
+}
 
-	log.Println("Shutting down...")
+// run 执行子命令（默认 watch），返回进程退出码
+func run(args []string) int {
+	if len(args) > 0 {
+		if cmd, ok := subcommands[args[0]]; ok {
+			return cmd(args[1:])
+		}
+	}
+	return runWatch(args)
 }
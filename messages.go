@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// language 运行时日志使用的语言
+var language = "en"
+
+// catalog 日志消息目录：英文格式串 -> 各语言译文，未收录的消息（如 [CREATE] 等事件标签）按原文输出；
+// 译文保留原格式串的参数顺序，key=value 形式的字段名不翻译，便于日志解析
+var catalog = map[string]map[string]string{
+	"zh": {
+		// 监控
+		"Adding watch: %s":                                                    "添加监控：%s",
+		"Adding watch for new directory: %s":                                  "为新目录添加监控：%s",
+		"Watching %d path(s) listed in %s":                                    "正在监控 %[2]s 中列出的 %[1]d 个路径",
+		"Watching %d match(es) for %s (recursive: %v)":                        "正在监控 %[2]s 的 %[1]d 个匹配（递归：%[3]v）",
+		"Watching %d new match(es) for %s":                                    "开始监控 %[2]s 的 %[1]d 个新匹配",
+		"%s no longer matches %s, unwatched":                                  "%s 不再匹配 %s，已停止监控",
+		"Starting watch group %s":                                             "启动监控组 %s",
+		"Watch group %s:":                                                     "监控组 %s：",
+		"%s already processed, skipping":                                      "%s 已处理过，跳过",
+		"ledger: record %s: %v":                                               "账本：记录 %s 失败：%v",
+		"skipping %s: %v":                                                     "跳过 %s：%v",
+		"Watching: %s (recursive: %v)":                                        "正在监控：%s（递归：%v）",
+		"Press Ctrl+C to stop...":                                             "按 Ctrl+C 停止……",
+		"Shutting down...":                                                    "正在关闭……",
+		"shutdown: %v":                                                        "关闭：%v",
+		"Shutdown deadline reached with %d handler call(s) in flight":         "关闭超时，仍有 %d 个处理器调用未完成",
+		"Flushed %d pending debounced event(s)":                               "已分发 %d 个去抖动中的事件",
+		"Discarded %d pending debounced event(s)":                             "已丢弃 %d 个去抖动中的事件",
+		"extract: %s already exists, skipping %s":                             "extract：%s 已存在，跳过 %s",
+		"extract: %s: %v":                                                     "extract：%s：%v",
+		"extract: %s -> %s (%d file(s))":                                      "extract：%s -> %s（%d 个文件）",
+		"extract: handler failed for %s: %v":                                  "extract：处理 %s 失败：%v",
+		"extract: skipping %s (unsupported entry type)":                       "extract：跳过 %s（不支持的条目类型）",
+		"scan: %s: %v":                                                        "scan：%s：%v",
+		"scan: move %s: %v":                                                   "scan：移动 %s 失败：%v",
+		"scan: %s is infected (%s), moved to %s":                              "scan：%s 已感染（%s），已移到 %s",
+		"scan: %s is infected, moved to %s":                                   "scan：%s 已感染，已移到 %s",
+		"scan: handler failed for %s: %v":                                     "scan：处理 %s 失败：%v",
+		"thumbnail: %s -> %d file(s) under %s":                                "thumbnail：%[1]s -> %[3]s 下的 %[2]d 个文件",
+		"duplicate: %s has the same content as %s (%s)":                       "duplicate：%s 与 %s 内容相同（%s）",
+		"duplicate detection: indexed %d file(s) under %s":                    "重复文件检测：已为 %[2]s 下的 %[1]d 个文件建立索引",
+		"duplicate: %s or %s changed since the comparison, leaving it":        "duplicate：%s 或 %s 在比较后被修改，不做处理",
+		"duplicate: hardlink %s: %v":                                          "duplicate：为 %s 建立硬链接失败：%v",
+		"duplicate: remove %s: %v":                                            "duplicate：删除 %s 失败：%v",
+		"checksum: %s: %v":                                                    "checksum：%s：%v",
+		"%s: %s: %v":                                                          "%s：%s：%v",
+		"thumbnail: %s: %v":                                                   "thumbnail：%s：%v",
+		"Discarded %d unfinished upload(s)":                                   "已丢弃 %d 个未完成的上传",
+		"Flushed %d unfinished upload(s)":                                     "已分发 %d 个未完成的上传",
+		"Discarded %d event(s) pending in directory windows":                  "已丢弃目录窗口中的 %d 个事件",
+		"watcher error: %v":                                                   "监控错误：%v",
+		"fsnotify backend closed unexpectedly, restarting...":                 "fsnotify 后端意外关闭，正在重启……",
+		"fsnotify backend restarted, %d root(s) re-registered":                "fsnotify 后端已重启，重新注册了 %d 个根路径",
+		"Bulk mode started: %s":                                               "批量模式开始：%s",
+		"Bulk mode ended: %s, %d event(s) under %s in %v":                     "批量模式结束：%s，%[3]s 下 %[2]d 个事件，耗时 %[4]v",
+		"Bulk mode %q timed out after %v, resuming":                           "批量模式 %q 在 %v 后超时，恢复正常分发",
+		"slow event: path=%q op=%s latency=%v budget=%v queued=%v handler=%v": "慢事件：path=%q op=%s latency=%v budget=%v queued=%v handler=%v",
+		"git not found in PATH, events will not be annotated with git status": "PATH 中未找到 git，事件不会标注 git 状态",
+		"xattr monitoring requested but %v":                                   "已启用扩展属性监控，但%v",
+		"[QUOTA] %s exceeded %d bytes (now %d bytes)":                         "[QUOTA] %s 超出 %d 字节（当前 %d 字节）",
+		"[QUOTA] %s back under %d bytes (now %d bytes)":                       "[QUOTA] %s 已回落到 %d 字节以下（当前 %d 字节）",
+		"[ATTRIB] %s (xattrs changed: %v)":                                    "[ATTRIB] %s（扩展属性变化：%v）",
+		"[RELOAD] %s (%d browser(s))":                                         "[RELOAD] %s（%d 个浏览器）",
+
+		// 控制接口、录制与回放
+		"Control API listening on %s":                          "控制接口监听于 %s",
+		"control API: %v":                                      "控制接口：%v",
+		"failed to start control API: %v":                      "启动控制接口失败：%v",
+		"failed to create recording: %v":                       "创建录制文件失败：%v",
+		"failed to open output: %v":                            "打开输出文件失败：%v",
+		"failed to open recording: %v":                         "打开录制文件失败：%v",
+		"Replaying %s at %gx":                                  "以 %[2]g 倍速回放 %[1]s",
+		"replay stopped: %v":                                   "回放中止：%v",
+		"Replay finished":                                      "回放完成",
+		"backfill %s stopped: %v":                              "回填 %s 中止：%v",
+		"Backfilled %d file(s) from %s":                        "已从 %[2]s 回填 %[1]d 个文件",
+		"invalid %s %q: want YYYY-MM-DD or a duration like 7d": "%s %q 无效：应为 YYYY-MM-DD 或 7d 这样的时长",
+
+		// 创建和启动
+		"failed to create watcher: %v":                              "创建监控器失败：%v",
+		"failed to watch path %s: %v":                               "监控路径 %s 失败：%v",
+		"failed to start watcher: %v":                               "启动监控器失败：%v",
+		"failed to create %s: %v":                                   "创建 %s 失败：%v",
+		"failed to create build directory: %v":                      "创建构建目录失败：%v",
+		"failed to create temp directory: %v":                       "创建临时目录失败：%v",
+		"failed to create report: %v":                               "创建报告文件失败：%v",
+		"failed to create snapshot: %v":                             "创建快照文件失败：%v",
+		"failed to open journal: %v":                                "打开事件日志失败：%v",
+		"failed to load pipeline: %v":                               "加载流水线失败：%v",
+		"invalid pipeline: %v":                                      "流水线配置无效：%v",
+		"invalid --since %q: want YYYY-MM-DD or a duration like 7d": "--since %q 无效：应为 YYYY-MM-DD 或 7d 这样的时长",
+		"--files, --dirs and --rate must be positive":               "--files、--dirs 和 --rate 必须为正数",
+
+		// 热文件夹、保留策略、自动提交
+		"hot folder: resuming %s":                                               "热文件夹：继续处理 %s",
+		"hot folder: %s done":                                                   "热文件夹：%s 处理完成",
+		"hot folder: %s interrupted, will resume on next start":                 "热文件夹：%s 的处理被中断，下次启动时恢复",
+		"hot folder: %d file(s) still processing after %s, leaving them in %s/": "热文件夹：仍有 %d 个文件未处理完（已等待 %s），留在 %s/ 中",
+		"hot folder: %s failed: %v":                                             "热文件夹：%s 处理失败：%v",
+		"hot folder: move %s to %s/: %v":                                        "热文件夹：移动 %s 到 %s/ 失败：%v",
+		"hot folder: record checkpoint for %s: %v":                              "热文件夹：记录 %s 的检查点失败：%v",
+		"action %q for event %s already completed, skipping":                    "事件 %[2]s 的动作 %[1]q 已完成，跳过",
+		"retention: %v":                                                         "保留策略：%v",
+		"retention: read %s: %v":                                                "保留策略：读取 %s 失败：%v",
+		"retention: removing %s":                                                "保留策略：删除 %s",
+		"retention: archiving %s -> %s":                                         "保留策略：归档 %s -> %s",
+		"auto-commit: %s":                                                       "自动提交：%s",
+		"auto-commit: render message: %v":                                       "自动提交：生成提交信息失败：%v",
+		"auto-commit: git add: %v: %s":                                          "自动提交：git add 失败：%v：%s",
+		"auto-commit: git commit: %v: %s":                                       "自动提交：git commit 失败：%v：%s",
+
+		// 试运行
+		"dry-run: would %s":                 "试运行：将会%s",
+		"run %q on %s":                      "对 %[2]s 执行 %[1]q",
+		"record %s in the ledger":           "将 %s 记入账本",
+		"move %s to %s/":                    "将 %s 移动到 %s/",
+		"remove %s (retention)":             "删除 %s（保留策略）",
+		"archive %s -> %s (retention)":      "归档 %s -> %s（保留策略）",
+		"commit %d file(s) in %s: %s":       "在 %[2]s 中提交 %[1]d 个文件：%[3]s",
+		"extract %s into %s":                "将 %s 解压到 %s",
+		"generate %d thumbnail(s) for %s":   "为 %[2]s 生成 %[1]d 个缩略图",
+		"remove %s":                         "删除 %s",
+		"replace %s with a hard link to %s": "把 %s 替换为指向 %s 的硬链接",
+		"record sha256 %s for %s":           "记录 %[2]s 的 sha256 %[1]s",
+		"remove %s from %s":                 "从 %[2]s 中删除 %[1]s",
+
+		// 决策说明（--explain）
+		"received %s":                            "收到 %s",
+		"ignored: %s":                            "忽略：%s",
+		"muted by %q":                            "已被 %q 静音",
+		"ignored: written by the watcher itself": "忽略：监控器自身的写入",
+		"counted by observe-only mode, not dispatched": "已由观察模式计数，不分发",
+		"dropped: %v": "已丢弃：%v",
+		"ignored: sibling of a single-file watch":                 "忽略：单文件监控的同目录文件",
+		"consumed by OnChange callbacks":                          "由 OnChange 回调处理",
+		"matched a priority pattern, dispatching immediately":     "匹配优先模式，立即分发",
+		"absorbed by bulk mode":                                   "由批量模式汇总",
+		"debouncing for %v":                                       "去抖动 %v",
+		"waiting in the directory window of %v":                   "进入 %v 的目录窗口",
+		"%d event(s) coalesced into one directory-level event":    "%d 个事件合并为一个目录级事件",
+		"debounce window closed, merged into %s":                  "去抖动窗口结束，合并为 %s",
+		"suppressed: events in the debounce window cancelled out": "抑制：去抖动窗口内的事件相互抵消",
+		"dropped by %s":                                           "被 %s 丢弃",
+		"dropped by MIME filter (detected %q)":                    "被 MIME 过滤器丢弃（检测为 %q）",
+		"settled at %s, progress reporting finished":              "大小稳定在 %s，停止上报进度",
+		"ignored: upload temp file matching %q":                   "忽略：匹配 %q 的上传临时文件",
+		"waiting for the upload to settle for %v":                 "等待上传稳定 %v",
+		"upload complete at %s":                                   "上传完成，大小 %s",
+		"upload abandoned before completion, dropping its events": "上传未完成即被放弃，丢弃相关事件",
+		"ignored: %s file":                                        "忽略：%s 文件",
+		"skipping inaccessible %s: %v":                            "跳过无权访问的 %s：%v",
+		"Skipped %d inaccessible subtree(s) under %s":             "已跳过 %[2]s 下 %[1]d 个无权访问的子树",
+		"failed to watch %s: %v":                                  "无法监控 %s：%v",
+		"Registering: %d dir(s), %d file(s) so far, at %s":        "正在注册：已添加 %d 个目录，遍历 %d 个文件，当前 %s",
+		"Watch budget: %s has %d directories, watching %d level(s) natively (%d watches) and polling %d deeper director(ies) every %s": "watch 预算：%s 共 %d 个目录，前 %d 层使用原生 watch（%d 个），更深的 %d 个目录每 %s 轮询一次",
+		"Polling new directory %s (watch budget)":                                                                              "受 watch 预算限制，轮询新目录 %s",
+		"normalized: %s reported as write, path was replaced":                                                                  "规范化：路径已被替换，%s 改为写入",
+		"normalized: %s dropped, nothing visible changed":                                                                      "规范化：没有可见变化，丢弃 %s",
+		"%s is on %s, using native watches":                                                                                    "%s 位于 %s，使用原生 watch",
+		"%s is on %s, which does not report changes made elsewhere as events; polling every %s":                                "%s 位于 %s，其他主机的修改不会产生事件，改为每 %s 轮询一次",
+		"%s is on overlayfs: changes to lower layers made outside the container are only picked up by reconciliation every %s": "%s 位于 overlayfs：容器外对下层的修改只能由每 %s 一次的对账发现",
+		"Container mode: running under %s, reconciling every %s":                                                               "容器模式：运行在 %s 中，每 %s 对账一次",
+		"Container mode: no container runtime detected, reconciling every %s anyway":                                           "容器模式：未检测到容器运行时，仍每 %s 对账一次",
+		"overlayfs whiteout, reporting as remove":                                                                              "overlayfs whiteout 文件，报告为删除",
+		"injected by %s":      "由 %s 注入",
+		"Stopped watching %s": "已停止监控 %s",
+		"Dispatch paused":     "已暂停分发",
+		"Dispatch resumed, releasing %d held event(s)":                            "已恢复分发，补发 %d 个保留的事件",
+		"Dispatch resumed, releasing %d held event(s), %d dropped over the limit": "已恢复分发，补发 %d 个保留的事件，%d 个因超出上限被丢弃",
+		"held while dispatch is paused":                                           "分发已暂停，事件已保留",
+		"failed to reach the watcher: %v":                                         "无法连接监控进程：%v",
+		"unexpected response: %v":                                                 "无法解析响应：%v",
+		"invalid --for %q: %v":                                                    "--for %q 无效：%v",
+		"--timeout and --debounce must not be negative":                           "--timeout 和 --debounce 不能为负数",
+		"invalid pattern %q":                                                      "无效的模式 %q",
+		"invalid pattern %q: %v":                                                  "无效的模式 %q：%v",
+		"timed out after %s waiting for %s":                                       "等待 %[2]s 超时（%[1]s）",
+		"interrupted before a matching event":                                     "在等到匹配的事件前被中断",
+		"Output pipe closed (%v), stopping":                                       "输出管道已关闭（%v），停止监控",
+		"--stdin-commands cannot be combined with --files-from -":                 "--stdin-commands 不能与 --files-from - 同时使用",
+		"QUIT received on stdin, stopping":                                        "从 stdin 收到 QUIT，停止监控",
+		"stdin closed, stopping":                                                  "stdin 已关闭，停止监控",
+		"Archiving events to %s":                                                  "事件归档写入 %s",
+		"archive: %v":                                                             "事件归档：%v",
+		"Event archive %s: removed %d file(s) (%s), compacted %d hour(s); %d file(s), %s left": "事件归档 %s：删除 %d 个文件（%s），合并 %d 个小时；剩余 %d 个文件，%s",
+		"invalid --max-age %q":                            "--max-age %q 无效",
+		"invalid --max-size %q":                           "--max-size %q 无效",
+		"prune %s: %v":                                    "清理 %s：%v",
+		"renamed from %s":                                 "由 %s 重命名而来",
+		"new hard link to %s":                             "新建的指向 %s 的硬链接",
+		"dropped by sampling policy %s":                   "被采样规则 %s 略去",
+		"dropped as a duplicate within the dedupe window": "去重窗口内的重复事件，已丢弃",
+		"handler #%d (%T) receives %s":                    "处理器 #%d (%T) 收到 %s",
+		"handler #%d (%T) skipped by its filter":          "处理器 #%d (%T) 的过滤条件不匹配，跳过",
+		"fires %s binding %q":                             "触发 %s 绑定 %q",
+
+		// 子命令
+		"dev: watching %s for Go changes (Ctrl+C to stop)": "dev：正在监控 %s 中的 Go 代码变化（Ctrl+C 停止）",
+		"dev: running %s":                                      "dev：运行 %s",
+		"dev: failed to start program: %v":                     "dev：启动程序失败：%v",
+		"dev: started pid %d":                                  "dev：已启动进程 %d",
+		"dev: pid %d exited: %v":                               "dev：进程 %d 已退出：%v",
+		"dev: pid %d did not exit after %v, killing":           "dev：进程 %d 在 %v 后仍未退出，强制结束",
+		"%s %s failed in %v: %v":                               "%s %s 失败，耗时 %v：%v",
+		"%s %s succeeded in %v":                                "%s %s 成功，耗时 %v",
+		"%s step %s failed: %v":                                "%s 步骤 %s 失败：%v",
+		"%s step %s succeeded in %v":                           "%s 步骤 %s 成功，耗时 %v",
+		"pipeline: watching %s, steps: %s":                     "pipeline：正在监控 %s，步骤：%s",
+		"pipeline: ran %d step(s) for %d change(s), %d failed": "pipeline：为 %[2]d 个变更执行了 %[1]d 个步骤，%[3]d 个失败",
+		"livereload: serving %s on %s":                         "livereload：在 %[2]s 上提供 %[1]s",
+		"livereload server: %v":                                "livereload 服务：%v",
+		"snapshot %s: %v":                                      "快照 %s：%v",
+		"write snapshot: %v":                                   "写入快照失败：%v",
+		"Snapshot of %s: %d entries written to %s":             "%s 的快照：%d 个条目已写入 %s",
+		"stress: %d files in %d dirs, %d writes/s, burst %d every %v, for %v": "stress：%d 个文件，%d 个目录，每秒 %d 次写入，每 %[5]v 突发 %[4]d 次，持续 %[6]v",
+		"stress: write %s: %v": "stress：写入 %s 失败：%v",
+	},
+}
+
+// Languages 返回支持的日志语言
+func Languages() []string {
+	langs := []string{"en"}
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// SetLanguage 设置运行时日志的语言（en 或 zh），应在启动监控之前调用
+func SetLanguage(lang string) error {
+	if _, ok := catalog[lang]; !ok && lang != "en" {
+		return fmt.Errorf("unsupported language %q (want %s)", lang, strings.Join(Languages(), " or "))
+	}
+	language = lang
+	return nil
+}
+
+// languageFromEnv 按 LC_ALL、LC_MESSAGES、LANG 的顺序推断日志语言，如 zh_CN.UTF-8 为 zh，无法识别时为 en
+func languageFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		lang := strings.ToLower(v)
+		if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+			lang = lang[:i]
+		}
+		if _, ok := catalog[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// tr 返回格式串在当前语言下的译文
+func tr(format string) string {
+	if msg, ok := catalog[language][format]; ok {
+		return msg
+	}
+	return format
+}
+
+// logf 以当前语言记录日志
+func logf(format string, args ...any) {
+	log.Printf(tr(format), args...)
+}
+
+// fatalf 以当前语言记录日志后退出
+func fatalf(format string, args ...any) {
+	log.Fatalf(tr(format), args...)
+}
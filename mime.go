@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// sniffLen http.DetectContentType 最多检查的字节数
+const sniffLen = 512
+
+// mimeFilter MIME 探测与过滤配置
+type mimeFilter struct {
+	patterns []string // 允许的 MIME 模式，如 image/*；为空表示只探测不过滤
+}
+
+// WithMIMEDetection 对新建和写入的文件读取文件头探测 MIME 类型，结果填入 Event.MIME
+// 指定 patterns（如 "image/*"、"application/pdf"）时，只分发类型匹配的新建/写入事件，
+// 删除、重命名等无法读取内容的事件不受影响
+func WithMIMEDetection(patterns ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.mime = &mimeFilter{patterns: patterns}
+	}
+}
+
+// enrich 为事件填充 MIME 类型，返回 false 表示事件被过滤
+func (f *mimeFilter) enrich(ev *Event) bool {
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return true
+	}
+
+	mimeType, ok := detectMIME(ev.Path)
+	if !ok {
+		// 目录或已被删除的文件，无法探测内容
+		return true
+	}
+	ev.MIME = mimeType
+	return f.match(mimeType)
+}
+
+// match 判断 MIME 类型是否匹配任一模式（忽略 charset 等参数）
+func (f *mimeFilter) match(mimeType string) bool {
+	if len(f.patterns) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = mimeType
+	}
+	for _, pattern := range f.patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// detectMIME 读取文件头部的魔数探测内容类型
+func detectMIME(name string) (string, bool) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", false
+	}
+	return http.DetectContentType(buf[:n]), true
+}
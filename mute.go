@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// muteRule 一条临时静音规则
+type muteRule struct {
+	pattern string
+	until   time.Time
+}
+
+// muteList Mute 注册的静音规则，过期规则在匹配时顺带清理
+type muteList struct {
+	mu    sync.Mutex
+	rules []*muteRule
+}
+
+// Mute 在 duration 内丢弃匹配 pattern 的事件（模式语法同 WithPriority，以 / 开头的模式按绝对路径匹配），
+// 供即将改写已知目录的自动化任务屏蔽自身操作引起的回声事件，避免处理器修改被监控文件造成反馈循环；
+// 返回的 unmute 提前解除静音，如
+// unmute, _ := fw.Mute("/srv/site/public/**", time.Minute); defer unmute()
+func (fw *FileWatcher) Mute(pattern string, duration time.Duration) (unmute func(), err error) {
+	if pattern == "" || !validGlob(pattern) {
+		return nil, &ConfigError{Problems: []string{fmt.Sprintf("invalid mute pattern %q", pattern)}}
+	}
+	if duration <= 0 {
+		return nil, &ConfigError{Problems: []string{fmt.Sprintf("mute duration must be positive, got %v", duration)}}
+	}
+	rule := &muteRule{pattern: pattern, until: time.Now().Add(duration)}
+	m := &fw.mutes
+	m.mu.Lock()
+	m.rules = append(m.rules, rule)
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, r := range m.rules {
+			if r == rule {
+				m.rules = append(m.rules[:i], m.rules[i+1:]...)
+				return
+			}
+		}
+	}, nil
+}
+
+// muted 返回匹配路径的静音模式，未静音时为空
+func (fw *FileWatcher) muted(path string) string {
+	m := &fw.mutes
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.rules) == 0 {
+		return ""
+	}
+	now := time.Now()
+	live := m.rules[:0]
+	for _, r := range m.rules {
+		if now.Before(r.until) {
+			live = append(live, r)
+		}
+	}
+	clear(m.rules[len(live):])
+	m.rules = live
+	for _, r := range m.rules {
+		if fw.matchPath(r.pattern, path) {
+			return r.pattern
+		}
+	}
+	return ""
+}
+
+// registerMute 注册静音端点：POST /mute?pattern=...&duration=30s
+func registerMute(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/mute", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil {
+			http.Error(w, "invalid duration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := fw.Mute(r.URL.Query().Get("pattern"), duration); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
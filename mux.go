@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// sharedQueueSize 共享后端为每个监控器缓冲的事件数，避免一个处理较慢的监控器立即阻塞其他监控器
+const sharedQueueSize = 256
+
+// watchBackend 监控器使用的 fsnotify 后端：独占的 fsnotify.Watcher，或 SharedBackend 中的一个客户端
+type watchBackend interface {
+	Add(name string) error
+	Remove(name string) error
+	WatchList() []string
+	Close() error
+	events() <-chan fsnotify.Event
+	errors() <-chan error
+}
+
+// fsnotifyBackend 独占一个 fsnotify.Watcher（在 Linux 上即一个 inotify 实例）的后端
+type fsnotifyBackend struct {
+	*fsnotify.Watcher
+}
+
+func (b fsnotifyBackend) events() <-chan fsnotify.Event { return b.Events }
+func (b fsnotifyBackend) errors() <-chan error          { return b.Errors }
+
+// newFsnotifyWatcher 创建 fsnotify watcher，inotify 实例数耗尽（max_user_instances）时返回 ErrWatchLimitExceeded
+func newFsnotifyWatcher() (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		if errors.Is(err, syscall.EMFILE) {
+			return nil, fmt.Errorf("%w: %w", ErrWatchLimitExceeded, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrBackendFailed, err)
+	}
+	return w, nil
+}
+
+// newFsnotifyBackend 创建独占后端
+func newFsnotifyBackend() (watchBackend, error) {
+	w, err := newFsnotifyWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return fsnotifyBackend{w}, nil
+}
+
+// SharedBackend 多个 FileWatcher（或监控组）共享的 fsnotify 后端，只占用一个 inotify 实例：
+// 每个监控器注册的目录作为它的前缀，事件按所在目录路由给注册了该目录的监控器；
+// 同一目录被多个监控器注册时只在后端添加一次，最后一个注册者移除时才真正移除
+type SharedBackend struct {
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	refs    map[string]int // 目录 -> 注册它的监控器数
+	clients map[*sharedClient]struct{}
+	closed  bool
+}
+
+// NewSharedBackend 创建共享后端，通过 WithSharedBackend 交给多个监控器使用，所有监控器停止后调用 Close
+func NewSharedBackend() (*SharedBackend, error) {
+	w, err := newFsnotifyWatcher()
+	if err != nil {
+		return nil, err
+	}
+	s := &SharedBackend{
+		watcher: w,
+		refs:    make(map[string]int),
+		clients: make(map[*sharedClient]struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// WithSharedBackend 使用共享后端而不是独占一个 fsnotify watcher；
+// 共享后端失效时，启用了 WithAutoRestart 的监控器改用独占后端恢复
+func WithSharedBackend(s *SharedBackend) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.shared = s
+	}
+}
+
+// Close 关闭共享后端，仍在使用它的监控器会收到后端失效
+func (s *SharedBackend) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.watcher.Close()
+}
+
+// run 从后端读取事件并分发给各监控器，后端关闭后关闭所有监控器的通道
+func (s *SharedBackend) run() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				s.fail()
+				return
+			}
+			s.route(event)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				s.fail()
+				return
+			}
+			s.mu.Lock()
+			targets := make([]*sharedClient, 0, len(s.clients))
+			for c := range s.clients {
+				targets = append(targets, c)
+			}
+			s.mu.Unlock()
+			for _, c := range targets {
+				select {
+				case c.errs <- err:
+				case <-c.done:
+				}
+			}
+		}
+	}
+}
+
+// route 将事件交给注册了其所在目录（或该目录本身）的监控器；
+// 发送时不持有锁，监控器的事件循环可以在处理事件时添加目录
+func (s *SharedBackend) route(event fsnotify.Event) {
+	dir := filepath.Dir(event.Name)
+	s.mu.Lock()
+	var targets []*sharedClient
+	for c := range s.clients {
+		if c.dirs[dir] || c.dirs[event.Name] {
+			targets = append(targets, c)
+		}
+	}
+	// 目录被删除或移走后后端已自动移除 watch，同步注册信息
+	if event.Has(fsnotify.Remove|fsnotify.Rename) && s.refs[event.Name] > 0 {
+		delete(s.refs, event.Name)
+		for c := range s.clients {
+			delete(c.dirs, event.Name)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range targets {
+		select {
+		case c.queue <- event:
+		case <-c.done:
+		}
+	}
+}
+
+// fail 后端关闭：关闭所有监控器的事件通道，只由 run 调用
+func (s *SharedBackend) fail() {
+	s.mu.Lock()
+	s.closed = true
+	clients := s.clients
+	s.clients = make(map[*sharedClient]struct{})
+	s.mu.Unlock()
+	for c := range clients {
+		close(c.queue)
+		close(c.errs)
+	}
+}
+
+// client 为一个监控器创建客户端
+func (s *SharedBackend) client() (*sharedClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("%w: shared backend closed", ErrBackendFailed)
+	}
+	c := &sharedClient{
+		s:     s,
+		dirs:  make(map[string]bool),
+		queue: make(chan fsnotify.Event, sharedQueueSize),
+		errs:  make(chan error, 1),
+		done:  make(chan struct{}),
+	}
+	s.clients[c] = struct{}{}
+	return c, nil
+}
+
+// sharedClient 一个监控器在共享后端上的视图，实现 watchBackend
+type sharedClient struct {
+	s     *SharedBackend
+	dirs  map[string]bool // 该监控器注册的目录，由 s.mu 保护
+	queue chan fsnotify.Event
+	errs  chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (c *sharedClient) events() <-chan fsnotify.Event { return c.queue }
+func (c *sharedClient) errors() <-chan error          { return c.errs }
+
+// Add 注册目录；目录可能被删除后重建，因此总是在后端重新添加
+func (c *sharedClient) Add(name string) error {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("%w: shared backend closed", ErrBackendFailed)
+	}
+	if err := s.watcher.Add(name); err != nil {
+		return err
+	}
+	if !c.dirs[name] {
+		c.dirs[name] = true
+		s.refs[name]++
+	}
+	return nil
+}
+
+// Remove 注销目录，没有其他监控器注册该目录时从后端移除
+func (c *sharedClient) Remove(name string) error {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !c.dirs[name] {
+		return fmt.Errorf("%w: %s", fsnotify.ErrNonExistentWatch, name)
+	}
+	return c.release(name)
+}
+
+// release 释放目录的一个引用，调用方需持有 s.mu
+func (c *sharedClient) release(name string) error {
+	s := c.s
+	delete(c.dirs, name)
+	if s.refs[name]--; s.refs[name] > 0 {
+		return nil
+	}
+	delete(s.refs, name)
+	if s.closed {
+		return nil
+	}
+	return s.watcher.Remove(name)
+}
+
+// WatchList 返回该监控器注册的目录
+func (c *sharedClient) WatchList() []string {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	list := make([]string, 0, len(c.dirs))
+	for name := range c.dirs {
+		list = append(list, name)
+	}
+	return list
+}
+
+// Close 注销该监控器的所有目录，共享后端本身保持运行
+func (c *sharedClient) Close() error {
+	s := c.s
+	s.mu.Lock()
+	delete(s.clients, c)
+	for name := range c.dirs {
+		c.release(name)
+	}
+	s.mu.Unlock()
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// normalizer 按平台无关的语义调整原始事件，记录各路径的权限和所有者以识别无实际变化的 CHMOD
+type normalizer struct {
+	mu    sync.Mutex
+	attrs map[string]fileAttrs
+}
+
+// WithNormalize 启用跨平台的事件规范化，处理器在 Linux、macOS 和 Windows 上看到相同语义的事件：
+//
+//   - CREATE：路径新出现，包括重命名或移入的目标
+//   - WRITE：普通文件的内容变化；目录本身不产生 WRITE（Windows 在子项变化时报告目录 WRITE）
+//   - REMOVE：路径已不存在；处理时路径已重新出现（Windows 替换保存先报 REMOVE 再 CREATE）改为 WRITE
+//   - RENAME：旧路径被移走，新路径另有 CREATE；处理时旧路径已被另一个文件占据（原子替换保存）改为 WRITE
+//   - CHMOD：只在权限位或所有者确实变化时报告；macOS 的属性事件洪流、Linux 上写入和 touch 伴随的
+//     ATTRIB（只有时间变化）被丢弃，WRITE 与 CHMOD 同时出现时只保留 WRITE
+func WithNormalize() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.normalizer = &normalizer{attrs: make(map[string]fileAttrs)}
+	}
+}
+
+// record 记录初始遍历时的属性
+func (n *normalizer) record(path string, info os.FileInfo) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attrs[filepath.Clean(path)] = snapshot(info)
+}
+
+// normalize 返回规范化后的事件，事件应丢弃时返回 false
+func (n *normalizer) normalize(fw *FileWatcher, event fsnotify.Event) (fsnotify.Event, bool) {
+	path := filepath.Clean(event.Name)
+	info, err := os.Lstat(path)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prev, known := n.attrs[path]
+	if err != nil {
+		delete(n.attrs, path)
+	} else {
+		n.attrs[path] = snapshot(info)
+	}
+
+	op := event.Op
+	if op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename) {
+		if err == nil && !info.IsDir() {
+			fw.explainf(path, "normalized: %s reported as write, path was replaced", formatOps(op))
+			return fsnotify.Event{Name: event.Name, Op: fsnotify.Write}, true
+		}
+		return event, true
+	}
+	if err != nil {
+		return event, true
+	}
+	if op.Has(fsnotify.Write) && info.IsDir() {
+		op &^= fsnotify.Write
+	}
+	if op.Has(fsnotify.Chmod) && (op.Has(fsnotify.Write) || op.Has(fsnotify.Create) || known && !attrsChanged(prev, snapshot(info))) {
+		op &^= fsnotify.Chmod
+	}
+	if op == 0 {
+		fw.explainf(path, "normalized: %s dropped, nothing visible changed", formatOps(event.Op))
+		fw.stats.add(&fw.stats.ignored)
+		return event, false
+	}
+	event.Op = op
+	return event, true
+}
+
+// attrsChanged 判断权限位或所有者是否变化，只有时间变化不算
+func attrsChanged(a, b fileAttrs) bool {
+	return a.mode != b.mode || a.uid != b.uid || a.gid != b.gid
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, n *normalizer, path string) // 在事件发生前准备 path
+		op     fsnotify.Op
+		want   fsnotify.Op
+		wantOK bool
+	}{
+		{
+			name:  "directory write dropped",
+			setup: func(t *testing.T, n *normalizer, path string) { mkdir(t, path) },
+			op:    fsnotify.Write,
+		},
+		{
+			name:   "file write kept",
+			setup:  func(t *testing.T, n *normalizer, path string) { writeFile(t, path) },
+			op:     fsnotify.Write,
+			want:   fsnotify.Write,
+			wantOK: true,
+		},
+		{
+			name: "chmod with only a time change dropped",
+			setup: func(t *testing.T, n *normalizer, path string) {
+				writeFile(t, path)
+				recordAttrs(t, n, path)
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			op: fsnotify.Chmod,
+		},
+		{
+			name: "chmod with a mode change kept",
+			setup: func(t *testing.T, n *normalizer, path string) {
+				writeFile(t, path)
+				recordAttrs(t, n, path)
+				if err := os.Chmod(path, 0o400); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(path, 0o600) })
+			},
+			op:     fsnotify.Chmod,
+			want:   fsnotify.Chmod,
+			wantOK: true,
+		},
+		{
+			name:   "remove on a replaced path becomes write",
+			setup:  func(t *testing.T, n *normalizer, path string) { writeFile(t, path) },
+			op:     fsnotify.Remove,
+			want:   fsnotify.Write,
+			wantOK: true,
+		},
+		{
+			name:   "rename on a replaced path becomes write",
+			setup:  func(t *testing.T, n *normalizer, path string) { writeFile(t, path) },
+			op:     fsnotify.Rename,
+			want:   fsnotify.Write,
+			wantOK: true,
+		},
+		{
+			name:   "remove on a missing path kept",
+			setup:  func(t *testing.T, n *normalizer, path string) {},
+			op:     fsnotify.Remove,
+			want:   fsnotify.Remove,
+			wantOK: true,
+		},
+		{
+			name:   "rename of a directory kept",
+			setup:  func(t *testing.T, n *normalizer, path string) { mkdir(t, path) },
+			op:     fsnotify.Rename,
+			want:   fsnotify.Rename,
+			wantOK: true,
+		},
+		{
+			name:   "write with chmod keeps only write",
+			setup:  func(t *testing.T, n *normalizer, path string) { writeFile(t, path) },
+			op:     fsnotify.Write | fsnotify.Chmod,
+			want:   fsnotify.Write,
+			wantOK: true,
+		},
+		{
+			name:   "create with chmod keeps only create",
+			setup:  func(t *testing.T, n *normalizer, path string) { writeFile(t, path) },
+			op:     fsnotify.Create | fsnotify.Chmod,
+			want:   fsnotify.Create,
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, err := NewFileWatcher(nopHandler{}, WithNormalize())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fw.Stop()
+			path := filepath.Join(t.TempDir(), "target")
+			tt.setup(t, fw.normalizer, path)
+
+			got, ok := fw.normalizer.normalize(fw, fsnotify.Event{Name: path, Op: tt.op})
+			if ok != tt.wantOK {
+				t.Fatalf("normalize(%s) kept = %v, want %v", tt.op, ok, tt.wantOK)
+			}
+			if ok && got.Op != tt.want {
+				t.Errorf("normalize(%s) = %s, want %s", tt.op, got.Op, tt.want)
+			}
+		})
+	}
+}
+
+func mkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordAttrs 模拟初始遍历记录 path 的属性
+func recordAttrs(t *testing.T, n *normalizer, path string) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.record(path, info)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DirRate 一个目录在滚动窗口内的事件速率
+type DirRate struct {
+	Dir    string    `json:"dir"`
+	Rate   float64   `json:"rate"`   // 窗口内平均每秒事件数
+	Peak   int       `json:"peak"`   // 窗口内单秒最多事件数
+	Window int       `json:"window"` // 窗口内事件数
+	Total  int64     `json:"total"`  // 开始观察以来的事件数
+	Last   time.Time `json:"last"`   // 最近一次事件的时间
+}
+
+// dirCounter 一个目录的滚动计数：按秒分桶的环形缓冲区
+type dirCounter struct {
+	buckets []int
+	seconds []int64 // 每个桶对应的 Unix 秒，过期的桶视为 0
+	total   int64
+	last    time.Time
+}
+
+// rateObserver 观察模式下按目录统计事件速率
+type rateObserver struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	dirs map[string]*dirCounter
+}
+
+// WithObserveOnly 观察模式：不分发任何事件（处理器、回调和订阅都收不到），只按事件所在目录统计 window 内的滚动速率，
+// 通过 Rates 或控制接口的 /rates 查询，用于在启用开销大的处理器之前评估事件量；排除、忽略和新目录的递归监控照常生效
+func WithObserveOnly(window time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.observer = &rateObserver{window: window, dirs: make(map[string]*dirCounter)}
+	}
+}
+
+// observe 记录一个事件
+func (o *rateObserver) observe(path string, now time.Time) {
+	n := o.size()
+	sec := now.Unix()
+	dir := filepath.Dir(path)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c := o.dirs[dir]
+	if c == nil {
+		c = &dirCounter{buckets: make([]int, n), seconds: make([]int64, n)}
+		o.dirs[dir] = c
+	}
+	i := int(sec % int64(n))
+	if c.seconds[i] != sec {
+		c.seconds[i], c.buckets[i] = sec, 0
+	}
+	c.buckets[i]++
+	c.total++
+	c.last = now
+}
+
+// size 窗口内的桶数，至少 1
+func (o *rateObserver) size() int {
+	return max(int(o.window/time.Second), 1)
+}
+
+// rates 返回各目录的速率，按速率从高到低排序
+func (o *rateObserver) rates(now time.Time) []DirRate {
+	n := o.size()
+	oldest := now.Unix() - int64(n)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	rates := make([]DirRate, 0, len(o.dirs))
+	for dir, c := range o.dirs {
+		r := DirRate{Dir: dir, Total: c.total, Last: c.last}
+		for i, count := range c.buckets {
+			if c.seconds[i] > oldest {
+				r.Window += count
+				r.Peak = max(r.Peak, count)
+			}
+		}
+		r.Rate = float64(r.Window) / float64(n)
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Window != rates[j].Window {
+			return rates[i].Window > rates[j].Window
+		}
+		return rates[i].Dir < rates[j].Dir
+	})
+	return rates
+}
+
+// Rates 返回观察模式下各目录的滚动事件速率，按速率从高到低排序；未启用 WithObserveOnly 时返回 nil
+func (fw *FileWatcher) Rates() []DirRate {
+	if fw.observer == nil {
+		return nil
+	}
+	return fw.observer.rates(time.Now())
+}
+
+// registerRates 观察模式下注册 /rates 端点，返回 JSON 格式的目录速率；?top=N 只返回速率最高的 N 个目录
+func registerRates(mux *http.ServeMux, fw *FileWatcher) {
+	if fw.observer == nil {
+		return
+	}
+	mux.HandleFunc("/rates", func(w http.ResponseWriter, r *http.Request) {
+		rates := fw.Rates()
+		if s := r.URL.Query().Get("top"); s != "" {
+			top, err := strconv.Atoi(s)
+			if err != nil || top < 0 {
+				http.Error(w, "invalid top: "+s, http.StatusBadRequest)
+				return
+			}
+			rates = rates[:min(top, len(rates))]
+		}
+		writeJSON(w, struct {
+			Window string    `json:"window"`
+			Dirs   []DirRate `json:"dirs"`
+		}{fw.observer.window.String(), rates})
+	})
+}
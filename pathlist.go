@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readPathList 读取路径列表：默认每行一个（兼容 \r\n），null 为 true 时以 NUL 分隔（find -print0、fd -0），空项忽略
+func readPathList(r io.Reader, null bool) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if null {
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, 0); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+	}
+	var paths []string
+	for scanner.Scan() {
+		path := scanner.Text()
+		if !null {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// readFilesFrom 读取 --files-from 指定的路径列表，"-" 表示标准输入
+func (c *watchConfig) readFilesFrom(stdin io.Reader) error {
+	if c.FilesFrom == "" {
+		return nil
+	}
+	r := stdin
+	if c.FilesFrom != "-" {
+		f, err := os.Open(c.FilesFrom)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	paths, err := readPathList(r, c.Null)
+	if err != nil {
+		return fmt.Errorf("read --files-from %s: %w", c.FilesFrom, err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("--files-from %s: no paths listed", c.FilesFrom)
+	}
+	c.listed = paths
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// pauseMaxHeld 暂停期间最多保留的事件数，超出的丢弃并在恢复时报告
+const pauseMaxHeld = 100000
+
+// pauseGate 暂停分发：事件照常接收、去抖动，但在分发前保留，恢复时按顺序补发
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	until   time.Time // 自动恢复的时间，零值表示直到 Resume
+	timer   *time.Timer
+	held    []Event
+	dropped int
+}
+
+// Pause 暂停向处理器分发事件，duration 后自动恢复（不为正时直到调用 Resume）：
+// 期间事件照常接收和合并，最多保留 100000 个，恢复（或停止监控）时按顺序补发，供维护窗口或处理器下游不可用时使用。
+// 已暂停时延长或缩短暂停时间
+func (fw *FileWatcher) Pause(duration time.Duration) {
+	g := &fw.pause
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	g.paused, g.until = true, time.Time{}
+	if duration > 0 {
+		g.until = time.Now().Add(duration)
+		g.timer = time.AfterFunc(duration, func() { fw.Resume() })
+	}
+	logf("Dispatch paused")
+}
+
+// Resume 恢复分发并补发暂停期间保留的事件，返回补发的数量；未暂停时返回 0
+func (fw *FileWatcher) Resume() int {
+	g := &fw.pause
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		return 0
+	}
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	held, dropped := g.held, g.dropped
+	g.paused, g.held, g.dropped = false, nil, 0
+	g.mu.Unlock()
+
+	if dropped > 0 {
+		logf("Dispatch resumed, releasing %d held event(s), %d dropped over the limit", len(held), dropped)
+	} else {
+		logf("Dispatch resumed, releasing %d held event(s)", len(held))
+	}
+	for _, ev := range held {
+		fw.dispatchEvent(ev)
+	}
+	return len(held)
+}
+
+// Paused 返回是否已暂停、保留的事件数和自动恢复的时间（零值表示直到 Resume）
+func (fw *FileWatcher) Paused() (paused bool, held int, until time.Time) {
+	g := &fw.pause
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused, len(g.held), g.until
+}
+
+// hold 暂停时保留事件，返回 true 表示事件已被保留（或超出上限被丢弃）
+func (g *pauseGate) hold(fw *FileWatcher, ev Event) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	if len(g.held) >= pauseMaxHeld {
+		g.dropped++
+		fw.stats.add(&fw.stats.muted)
+		return true
+	}
+	fw.explainf(ev.Path, "held while dispatch is paused")
+	g.held = append(g.held, ev)
+	return true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// PipeHandler 每个事件向 w 写一行 JSON（格式同 WithRecorder，可直接交给 replay 和 report），写完立即刷新；
+// 下游关闭管道（写入失败）后不再写入并关闭 Closed，供命令行正常退出
+type PipeHandler struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	enc    *json.Encoder
+	start  time.Time
+	closed chan struct{}
+	failed bool
+}
+
+// NewPipeHandler 创建写入 w 的管道处理器
+func NewPipeHandler(w io.Writer) *PipeHandler {
+	bw := bufio.NewWriter(w)
+	return &PipeHandler{w: bw, enc: json.NewEncoder(bw), closed: make(chan struct{})}
+}
+
+var (
+	stdoutPipeOnce sync.Once
+	stdoutPipe     *PipeHandler
+)
+
+// pipeStdout 返回写入标准输出的管道处理器，所有监控组共用一个，避免行交错
+func pipeStdout() *PipeHandler {
+	stdoutPipeOnce.Do(func() { stdoutPipe = NewPipeHandler(os.Stdout) })
+	return stdoutPipe
+}
+
+// OnEvent 实现 EventAwareHandler 接口
+func (h *PipeHandler) OnEvent(ev Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if h.start.IsZero() {
+		h.start = now
+	}
+	h.write(newRecordedEvent(ev, h.start, now))
+	return nil
+}
+
+// WriteJSON 写入一行 JSON（如 --stdin-commands 的应答），与事件行不会交错
+func (h *PipeHandler) WriteJSON(v any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.write(v)
+}
+
+// write 编码并刷新一行，调用方持有 mu；下游已退出时不再写入，处理器也不必重试
+func (h *PipeHandler) write(v any) {
+	if h.failed {
+		return
+	}
+	err := h.enc.Encode(v)
+	if err == nil {
+		err = h.w.Flush()
+	}
+	if err != nil {
+		h.failed = true
+		close(h.closed)
+		logf("Output pipe closed (%v), stopping", err)
+	}
+}
+
+// Closed 在下游关闭管道后关闭
+func (h *PipeHandler) Closed() <-chan struct{} { return h.closed }
+
+// OnCreate 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *PipeHandler) OnCreate(string) error { return nil }
+
+// OnWrite 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *PipeHandler) OnWrite(string) error { return nil }
+
+// OnRemove 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *PipeHandler) OnRemove(string) error { return nil }
+
+// OnRename 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *PipeHandler) OnRename(string) error { return nil }
+
+// OnChmod 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *PipeHandler) OnChmod(string) error { return nil }
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PipelineStep 流水线中的一个构建步骤
+type PipelineStep struct {
+	Name    string   `json:"name"`
+	Inputs  []string `json:"inputs"`   // 触发本步骤的文件模式（相对根目录，语法同 WithExclude）
+	Outputs []string `json:"outputs"`  // 本步骤生成的文件模式，其变化不会再触发任何步骤
+	After   []string `json:"after"`    // 依赖的步骤，依赖运行成功后本步骤也会运行
+	Command string   `json:"command"`  // shell 命令
+	PerFile bool     `json:"per_file"` // true 时对每个变化的输入文件各运行一次，文件路径作为 $1 传入
+}
+
+// PipelineConfig 流水线配置文件格式
+type PipelineConfig struct {
+	Steps []PipelineStep `json:"steps"`
+}
+
+// LoadPipelineConfig 读取 JSON 格式的流水线配置
+func LoadPipelineConfig(path string) (*PipelineConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PipelineConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Pipeline 增量构建流水线：文件变化只运行受影响的步骤及其下游步骤
+type Pipeline struct {
+	root    string
+	steps   []*PipelineStep // 拓扑序
+	settle  *Debouncer
+	mu      sync.Mutex
+	pending map[string]struct{} // 等待处理的变化文件
+	runMu   sync.Mutex          // 串行化流水线运行
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewPipeline 创建流水线，校验步骤名称、依赖关系和模式，并按依赖排序
+func NewPipeline(root string, settle time.Duration, steps ...PipelineStep) (*Pipeline, error) {
+	ordered, err := orderSteps(steps)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pipeline{
+		root:    root,
+		steps:   ordered,
+		settle:  NewDebouncer(settle),
+		pending: make(map[string]struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// orderSteps 校验步骤并做拓扑排序，存在环或未知依赖时返回 ConfigError
+func orderSteps(steps []PipelineStep) ([]*PipelineStep, error) {
+	var problems []string
+	byName := make(map[string]*PipelineStep, len(steps))
+	for i := range steps {
+		s := &steps[i]
+		switch {
+		case s.Name == "":
+			problems = append(problems, fmt.Sprintf("pipeline step %d has no name", i+1))
+			continue
+		case byName[s.Name] != nil:
+			problems = append(problems, fmt.Sprintf("pipeline step %q defined twice", s.Name))
+			continue
+		}
+		byName[s.Name] = s
+		if s.Command == "" {
+			problems = append(problems, fmt.Sprintf("pipeline step %q has no command", s.Name))
+		}
+		if len(s.Inputs) == 0 && len(s.After) == 0 {
+			problems = append(problems, fmt.Sprintf("pipeline step %q has neither inputs nor after", s.Name))
+		}
+		if s.PerFile && len(s.Inputs) == 0 {
+			problems = append(problems, fmt.Sprintf("pipeline step %q is per_file but has no inputs", s.Name))
+		}
+		for _, p := range append(append([]string(nil), s.Inputs...), s.Outputs...) {
+			if !validGlob(p) {
+				problems = append(problems, fmt.Sprintf("pipeline step %q: invalid pattern %q", s.Name, p))
+			}
+		}
+	}
+	for _, s := range byName {
+		for _, dep := range s.After {
+			if byName[dep] == nil {
+				problems = append(problems, fmt.Sprintf("pipeline step %q depends on unknown step %q", s.Name, dep))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+
+	// 深度优先拓扑排序，按配置顺序遍历保证结果稳定
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var ordered []*PipelineStep
+	var visit func(s *PipelineStep, chain []string) error
+	visit = func(s *PipelineStep, chain []string) error {
+		switch state[s.Name] {
+		case visiting:
+			return &ConfigError{Problems: []string{fmt.Sprintf("pipeline has a dependency cycle: %s -> %s", strings.Join(chain, " -> "), s.Name)}}
+		case visited:
+			return nil
+		}
+		state[s.Name] = visiting
+		for _, dep := range s.After {
+			if err := visit(byName[dep], append(chain, s.Name)); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = visited
+		ordered = append(ordered, s)
+		return nil
+	}
+	for i := range steps {
+		if err := visit(&steps[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// OnCreate 实现 EventHandler 接口
+func (p *Pipeline) OnCreate(path string) error { return p.changed(path) }
+
+// OnWrite 实现 EventHandler 接口
+func (p *Pipeline) OnWrite(path string) error { return p.changed(path) }
+
+// OnRemove 实现 EventHandler 接口
+func (p *Pipeline) OnRemove(path string) error { return p.changed(path) }
+
+// OnRename 实现 EventHandler 接口
+func (p *Pipeline) OnRename(path string) error { return p.changed(path) }
+
+// OnChmod 实现 EventHandler 接口，权限变化不触发构建
+func (p *Pipeline) OnChmod(string) error { return nil }
+
+// Close 实现 Closer 接口，终止正在运行的步骤
+func (p *Pipeline) Close() error {
+	p.cancel()
+	return nil
+}
+
+// changed 记录变化文件，静默期结束后统一运行一轮
+func (p *Pipeline) changed(path string) error {
+	rel := p.rel(path)
+	if p.generated(rel) {
+		return nil
+	}
+	p.mu.Lock()
+	p.pending[path] = struct{}{}
+	p.mu.Unlock()
+	p.settle.Debounce("pipeline", p.run)
+	return nil
+}
+
+// rel 返回相对根目录的路径
+func (p *Pipeline) rel(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if rel, err := filepath.Rel(p.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// generated 判断文件是否为某个步骤的产物
+func (p *Pipeline) generated(rel string) bool {
+	for _, s := range p.steps {
+		if matchAny(s.Outputs, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny 判断相对路径是否匹配任一模式
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// run 处理累积的变化：找出直接受影响的步骤，沿依赖向下游传播，按拓扑序运行
+func (p *Pipeline) run() {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+
+	p.mu.Lock()
+	files := make([]string, 0, len(p.pending))
+	for f := range p.pending {
+		files = append(files, f)
+	}
+	p.pending = make(map[string]struct{})
+	p.mu.Unlock()
+	sort.Strings(files)
+
+	// 每个步骤直接匹配的输入文件
+	inputs := make(map[string][]string)
+	for _, s := range p.steps {
+		for _, f := range files {
+			if matchAny(s.Inputs, p.rel(f)) {
+				inputs[s.Name] = append(inputs[s.Name], f)
+			}
+		}
+	}
+
+	succeeded := make(map[string]bool)
+	var ran, failed []string
+	for _, s := range p.steps {
+		if p.ctx.Err() != nil {
+			return
+		}
+		upstream := false
+		for _, dep := range s.After {
+			upstream = upstream || succeeded[dep]
+		}
+		if len(inputs[s.Name]) == 0 && !upstream {
+			continue
+		}
+
+		ran = append(ran, s.Name)
+		if err := p.runStep(s, inputs[s.Name], upstream); err != nil {
+			failed = append(failed, s.Name)
+			logf("%s step %s failed: %v", colorize(ansiRed, "✘"), s.Name, err)
+			continue
+		}
+		succeeded[s.Name] = true
+	}
+	if len(ran) > 0 {
+		logf("pipeline: ran %d step(s) for %d change(s), %d failed", len(ran), len(files), len(failed))
+	}
+}
+
+// runStep 运行单个步骤；PerFile 步骤对每个仍存在的输入文件各运行一次，
+// 仅因上游步骤而触发时不带文件参数运行一次
+func (p *Pipeline) runStep(s *PipelineStep, files []string, upstream bool) error {
+	cmd := Command{Line: s.Command, Dir: p.root}
+	start := time.Now()
+
+	var targets []string
+	if s.PerFile {
+		for _, f := range files {
+			if _, err := os.Stat(f); err == nil {
+				targets = append(targets, f)
+			}
+		}
+		if len(targets) == 0 && !upstream {
+			return nil
+		}
+	}
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	for _, f := range targets {
+		if err := cmd.Run(p.ctx, f); err != nil {
+			return err
+		}
+	}
+	logf("%s step %s succeeded in %v", colorize(ansiGreen, "✔"), s.Name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// runPipeline pipeline 子命令：按配置文件运行增量构建流水线
+func runPipeline(args []string) int {
+	fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	config := fs.String("config", "pipeline.json", "pipeline configuration file (JSON)")
+	settle := fs.Duration("settle", 200*time.Millisecond, "wait this long after the last change before running the pipeline")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s pipeline [flags] [dir]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	cfg, err := LoadPipelineConfig(*config)
+	if err != nil {
+		return exitWith(ExitConfig, "failed to load pipeline: %v", err)
+	}
+	p, err := NewPipeline(dir, *settle, cfg.Steps...)
+	if err != nil {
+		return exitWith(ExitConfig, "invalid pipeline: %v", err)
+	}
+
+	watcher, err := NewFileWatcher(p,
+		WithRecursive(true),
+		WithDebounce(100*time.Millisecond),
+		WithSkipHidden(),
+	)
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	if _, err := watcher.Watch(dir); err != nil {
+		return exitErr(err, "failed to watch path %s: %v", dir, err)
+	}
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+	names := make([]string, len(p.steps))
+	for i, s := range p.steps {
+		names[i] = s.Name
+	}
+	logf("pipeline: watching %s, steps: %s", dir, strings.Join(names, " -> "))
+
+	waitForSignal()
+	logf("Shutting down...")
+	return ExitOK
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pollEntry 轮询子树中一个路径上次的状态
+type pollEntry struct {
+	dir     bool
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// polledTree 一个轮询的目录树
+type polledTree struct {
+	entries   map[string]pollEntry // 其下各路径上次的状态
+	interval  time.Duration
+	due       time.Time // 下次扫描的时间
+	reconcile bool      // 原生 watch 之外的对账扫描：原生事件会更新状态，新目录照常添加 watch
+}
+
+// treePoller 以轮询代替或补充原生 watch 的目录树（超出 watch 预算的深层目录、网络文件系统上的根路径、容器模式的对账），
+// 对比大小、修改时间和权限，合成 CREATE/WRITE/REMOVE/CHMOD 事件
+type treePoller struct {
+	interval time.Duration // 各目录树中最短的轮询间隔
+
+	mu    sync.Mutex
+	trees map[*watchRoot]map[string]*polledTree // 根路径 -> 轮询的目录 -> 状态
+}
+
+// usePoller 启用轮询，多个选项都需要轮询时按最短的间隔检查
+func (fw *FileWatcher) usePoller(interval time.Duration) {
+	if fw.poller == nil {
+		fw.poller = &treePoller{interval: interval, trees: make(map[*watchRoot]map[string]*polledTree)}
+	} else if interval < fw.poller.interval {
+		fw.poller.interval = interval
+	}
+}
+
+// Polled 返回该根路径下以轮询代替或补充原生 watch 的目录（整个根路径被轮询或对账时即根路径本身）
+func (h *WatchHandle) Polled() []string {
+	h.mu.Lock()
+	root := h.root
+	h.mu.Unlock()
+	if h.fw.poller == nil {
+		return nil
+	}
+	return h.fw.poller.polled(root)
+}
+
+// covered 判断 dir 是否位于根路径已轮询的目录树内
+func (p *treePoller) covered(root *watchRoot, dir string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for tree, t := range p.trees[root] {
+		if !t.reconcile && isUnder(tree, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// poll 把目录加入轮询，记录其当前状态作为基线
+func (p *treePoller) poll(fw *FileWatcher, root *watchRoot, dir string, interval time.Duration, reconcile bool) {
+	entries := fw.scanTree(root, dir)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.trees[root] == nil {
+		p.trees[root] = make(map[string]*polledTree)
+	}
+	p.trees[root][dir] = &polledTree{entries: entries, interval: interval, due: time.Now().Add(interval), reconcile: reconcile}
+}
+
+// refresh 原生事件到达时更新对账扫描中该路径的状态，避免下次扫描重复报告
+func (p *treePoller) refresh(root *watchRoot, path string) {
+	info, err := os.Lstat(path)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for dir, t := range p.trees[root] {
+		if !t.reconcile || !isUnder(dir, path) {
+			continue
+		}
+		if err != nil {
+			for entry := range t.entries {
+				if isUnder(path, entry) {
+					delete(t.entries, entry)
+				}
+			}
+			continue
+		}
+		// 目录树的根不在 entries 中，单文件则是文件本身
+		if path != dir || !info.IsDir() {
+			t.entries[path] = pollEntry{dir: info.IsDir(), size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}
+		}
+	}
+}
+
+// drop 停止轮询根路径的所有目录
+func (p *treePoller) drop(root *watchRoot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.trees, root)
+}
+
+// polled 返回根路径轮询的目录，按路径排序
+func (p *treePoller) polled(root *watchRoot) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dirs := make([]string, 0, len(p.trees[root]))
+	for dir := range p.trees[root] {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// scanTree 记录目录树内（不含目录本身）各路径的状态；轮询的是单个文件时记录文件本身，不存在时返回 nil
+func (fw *FileWatcher) scanTree(root *watchRoot, dir string) map[string]pollEntry {
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+	entries := make(map[string]pollEntry)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir && info.IsDir() {
+			return nil
+		}
+		if fw.ignored(root, path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && !root.recursive && path != dir {
+			entries[path] = pollEntry{dir: true, mode: info.Mode(), modTime: info.ModTime()}
+			return filepath.SkipDir
+		}
+		entries[path] = pollEntry{dir: info.IsDir(), size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}
+		return nil
+	})
+	return entries
+}
+
+// runPoller 周期性轮询，为变化合成事件
+func (fw *FileWatcher) runPoller() {
+	ticker := time.NewTicker(fw.poller.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fw.done:
+			return
+		case <-ticker.C:
+			for _, event := range fw.poller.changes(fw) {
+				fw.handleEvent(event)
+			}
+		}
+	}
+}
+
+// changes 重新扫描到期的轮询目录，返回与上次相比的变化；
+// 深层目录已删除时停止轮询（其父目录有原生 watch），根路径本身则继续轮询，等待重新出现
+func (p *treePoller) changes(fw *FileWatcher) []fsnotify.Event {
+	type tree struct {
+		root *watchRoot
+		dir  string
+	}
+	now := time.Now()
+	p.mu.Lock()
+	var trees []tree
+	for root, dirs := range p.trees {
+		for dir, t := range dirs {
+			if !now.Before(t.due) {
+				t.due = now.Add(t.interval)
+				trees = append(trees, tree{root, dir})
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	var created, removed, changed []fsnotify.Event
+	for _, t := range trees {
+		cur := fw.scanTree(t.root, t.dir)
+		p.mu.Lock()
+		pt, ok := p.trees[t.root][t.dir]
+		if !ok {
+			// 扫描期间根路径已注销
+			p.mu.Unlock()
+			continue
+		}
+		prev := pt.entries
+		if cur == nil && t.dir != t.root.path {
+			delete(p.trees[t.root], t.dir)
+		} else {
+			pt.entries = cur
+		}
+		p.mu.Unlock()
+
+		for path, old := range prev {
+			if _, ok := cur[path]; !ok {
+				removed = append(removed, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+			} else if n := cur[path]; !n.dir && (n.size != old.size || !n.modTime.Equal(old.modTime)) {
+				changed = append(changed, fsnotify.Event{Name: path, Op: fsnotify.Write})
+			} else if n.mode != old.mode {
+				changed = append(changed, fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+			}
+		}
+		for path := range cur {
+			if _, ok := prev[path]; !ok {
+				created = append(created, fsnotify.Event{Name: path, Op: fsnotify.Create})
+			}
+		}
+	}
+	// 先建父目录再建子项，先删子项再删父目录
+	sort.Slice(created, func(i, j int) bool { return created[i].Name < created[j].Name })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name > removed[j].Name })
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Name < changed[j].Name })
+	return append(append(removed, created...), changed...)
+}
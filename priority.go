@@ -0,0 +1,15 @@
+package main
+
+// WithPriority 为关键路径开启优先通道：匹配任一模式的事件跳过去抖动、合并和批量模式，
+// 收到后立即分发；其余路径照常走合并流程。模式语法同 WithExclude，以 / 开头的模式按绝对路径匹配，如
+// WithPriority("/etc/**", "*.lock")
+func WithPriority(patterns ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.priority = append(fw.priority, patterns...)
+	}
+}
+
+// urgent 判断事件是否走优先通道
+func (fw *FileWatcher) urgent(path string) bool {
+	return len(fw.priority) > 0 && fw.matchAnyPath(fw.priority, path)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ProgressHandler 可选接口：需要显示大文件写入进度的处理器实现此接口（见 WithArrivalProgress）
+// rate 为最近一个间隔内的平均写入速度（字节/秒），写入暂停时为 0
+type ProgressHandler interface {
+	OnProgress(path string, bytesSoFar int64, rate float64) error
+}
+
+// arrival 一个正在写入的文件
+type arrival struct {
+	size    int64     // 上次检查时的大小
+	checked time.Time // 上次检查的时间
+	changed time.Time // 大小最近一次变化的时间
+}
+
+// progressTracker 跟踪正在写入的文件，定期上报进度直到大小稳定
+type progressTracker struct {
+	interval time.Duration
+	settle   time.Duration
+	minSize  int64
+
+	mu      sync.Mutex
+	files   map[string]*arrival
+	running bool // 定期检查的 goroutine 是否在运行
+}
+
+// WithArrivalProgress 为正在写入的大文件上报进度：文件创建或写入后每隔 interval 检查一次大小，
+// 达到 minSize 后调用实现了 ProgressHandler 的处理器的 OnProgress，直到大小 settle 时间内不再变化
+// （视为写入完成）或文件被删除、移走；用于显示经 SMB/FTP 上传的文件的进度。
+// 进度不经过去抖动，写入完成后照常分发合并后的 CREATE/WRITE 事件
+func WithArrivalProgress(interval, settle time.Duration, minSize int64) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.progress = &progressTracker{interval: interval, settle: settle, minSize: minSize, files: make(map[string]*arrival)}
+	}
+}
+
+// observe 根据原始事件开始或停止跟踪文件
+func (p *progressTracker) observe(fw *FileWatcher, event fsnotify.Event, now time.Time) {
+	path := filepath.Clean(event.Name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		delete(p.files, path)
+		return
+	}
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return
+	}
+	if a := p.files[path]; a != nil {
+		a.changed = now
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	p.files[path] = &arrival{size: info.Size(), checked: now, changed: now}
+	if !p.running {
+		p.running = true
+		go p.run(fw)
+	}
+}
+
+// run 定期检查跟踪的文件，没有文件或监控停止时退出
+func (p *progressTracker) run(fw *FileWatcher) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.done:
+			p.mu.Lock()
+			p.running = false
+			p.mu.Unlock()
+			return
+		case now := <-ticker.C:
+			if !p.tick(fw, now) {
+				return
+			}
+		}
+	}
+}
+
+// progressReport 一次待上报的进度
+type progressReport struct {
+	path string
+	size int64
+	rate float64
+}
+
+// tick 检查一次所有文件并上报进度，没有剩余文件时返回 false
+func (p *progressTracker) tick(fw *FileWatcher, now time.Time) bool {
+	var reports []progressReport
+
+	p.mu.Lock()
+	for path, a := range p.files {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			delete(p.files, path)
+			continue
+		}
+		var rate float64
+		if info.Size() != a.size {
+			rate = float64(info.Size()-a.size) / now.Sub(a.checked).Seconds()
+			a.changed = now
+		} else if now.Sub(a.changed) >= p.settle {
+			delete(p.files, path)
+			if info.Size() >= p.minSize {
+				fw.explainf(path, "settled at %s, progress reporting finished", formatBytes(info.Size()))
+			}
+			continue
+		}
+		a.size, a.checked = info.Size(), now
+		if a.size >= p.minSize {
+			reports = append(reports, progressReport{path: path, size: a.size, rate: max(rate, 0)})
+		}
+	}
+	running := len(p.files) > 0
+	p.running = running
+	p.mu.Unlock()
+
+	for _, r := range reports {
+		fw.reportProgress(r)
+	}
+	return running
+}
+
+// reportProgress 把进度交给实现了 ProgressHandler 且接受该路径写入事件的处理器
+func (fw *FileWatcher) reportProgress(r progressReport) {
+	ev := Event{Path: r.path, Op: fsnotify.Write}
+	for _, rt := range fw.routes {
+		h, ok := rt.handler.(ProgressHandler)
+		if !ok {
+			continue
+		}
+		if _, ok := rt.accept(fw, ev); !ok {
+			continue
+		}
+		fw.inflight.begin()
+		fw.invoke("PROGRESS", r.path, func(path string) error {
+			return h.OnProgress(path, r.size, r.rate)
+		})
+		fw.inflight.end()
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// QuotaRule 目录容量阈值规则
+type QuotaRule struct {
+	Dir       string                              // 统计的目录（含所有子目录）
+	Limit     int64                               // 阈值（字节）
+	OnExceed  func(dir string, size, limit int64) // 总大小首次超过阈值时调用
+	OnRecover func(dir string, size, limit int64) // 超限后回落到阈值以内时调用，可为 nil
+}
+
+// quotaState 单条规则的运行状态
+type quotaState struct {
+	rule     QuotaRule
+	total    int64
+	exceeded bool
+}
+
+// quotaMonitor 基于事件增量更新、定期全量校准的目录容量统计
+type quotaMonitor struct {
+	mu       sync.Mutex
+	interval time.Duration    // 全量校准间隔，0 表示只在启动时校准
+	files    map[string]int64 // 已知文件大小
+	states   []*quotaState
+}
+
+// WithQuota 维护目录总大小，超过阈值时回调（如 "/data 超过 100GB"）
+// 事件到达时增量更新，每隔 reconcile 重新遍历目录校准，修正丢失的事件
+func WithQuota(reconcile time.Duration, rules ...QuotaRule) WatcherOption {
+	return func(fw *FileWatcher) {
+		m := &quotaMonitor{interval: reconcile, files: make(map[string]int64)}
+		for _, rule := range rules {
+			rule.Dir = filepath.Clean(rule.Dir)
+			m.states = append(m.states, &quotaState{rule: rule})
+		}
+		fw.quota = m
+	}
+}
+
+// DirSize 返回配置了容量规则的目录当前统计的总大小
+func (fw *FileWatcher) DirSize(dir string) (int64, bool) {
+	if fw.quota == nil {
+		return 0, false
+	}
+	dir = filepath.Clean(dir)
+
+	fw.quota.mu.Lock()
+	defer fw.quota.mu.Unlock()
+	for _, st := range fw.quota.states {
+		if st.rule.Dir == dir {
+			return st.total, true
+		}
+	}
+	return 0, false
+}
+
+// observe 根据原始事件增量更新大小
+func (m *quotaMonitor) observe(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	var size int64
+	exists := false
+	if !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		size, exists = info.Size(), true
+	}
+
+	m.mu.Lock()
+	prev, known := m.files[path]
+	if exists {
+		m.files[path] = size
+	} else {
+		if !known {
+			m.mu.Unlock()
+			return
+		}
+		delete(m.files, path)
+	}
+	delta := size - prev
+	fired := m.applyDeltaLocked(path, delta)
+	m.mu.Unlock()
+
+	for _, fire := range fired {
+		fire()
+	}
+}
+
+// applyDeltaLocked 把大小变化累加到包含该文件的规则上，返回需要触发的回调
+func (m *quotaMonitor) applyDeltaLocked(path string, delta int64) []func() {
+	var fired []func()
+	for _, st := range m.states {
+		if !isUnder(st.rule.Dir, path) {
+			continue
+		}
+		st.total += delta
+		if fire := st.checkLocked(); fire != nil {
+			fired = append(fired, fire)
+		}
+	}
+	return fired
+}
+
+// checkLocked 检查是否跨越阈值，返回需要触发的回调
+func (st *quotaState) checkLocked() func() {
+	rule, total := st.rule, st.total
+	switch {
+	case !st.exceeded && total > rule.Limit:
+		st.exceeded = true
+		if rule.OnExceed != nil {
+			return func() { rule.OnExceed(rule.Dir, total, rule.Limit) }
+		}
+	case st.exceeded && total <= rule.Limit:
+		st.exceeded = false
+		if rule.OnRecover != nil {
+			return func() { rule.OnRecover(rule.Dir, total, rule.Limit) }
+		}
+	}
+	return nil
+}
+
+// reconcile 重新遍历所有规则目录，校准文件大小和总量
+func (m *quotaMonitor) reconcile() {
+	files := make(map[string]int64)
+	totals := make([]int64, len(m.states))
+	for i, st := range m.states {
+		filepath.Walk(st.rule.Dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			files[filepath.Clean(path)] = info.Size()
+			totals[i] += info.Size()
+			return nil
+		})
+	}
+
+	m.mu.Lock()
+	m.files = files
+	var fired []func()
+	for i, st := range m.states {
+		st.total = totals[i]
+		if fire := st.checkLocked(); fire != nil {
+			fired = append(fired, fire)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, fire := range fired {
+		fire()
+	}
+}
+
+// runQuota 启动时校准一次，之后按间隔定期校准
+func (fw *FileWatcher) runQuota() {
+	fw.quota.reconcile()
+	if fw.quota.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(fw.quota.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.done:
+			return
+		case <-ticker.C:
+			fw.quota.reconcile()
+		}
+	}
+}
+
+// logQuotaExceeded 命令行模式下的默认超限回调
+func logQuotaExceeded(dir string, size, limit int64) {
+	logf("[QUOTA] %s exceeded %d bytes (now %d bytes)", dir, limit, size)
+}
+
+// logQuotaRecovered 命令行模式下的默认恢复回调
+func logQuotaRecovered(dir string, size, limit int64) {
+	logf("[QUOTA] %s back under %d bytes (now %d bytes)", dir, limit, size)
+}
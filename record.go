@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// recordedEvent 录制文件中的一行（JSON Lines）
+type recordedEvent struct {
+	Offset    time.Duration `json:"offset"` // 相对第一个事件的时间（纳秒）
+	Time      time.Time     `json:"time"`   // 分发时的墙上时间，用于生成变更报告
+	Path      string        `json:"path"`
+	Op        string        `json:"op"` // 格式同 --ops，如 "create,write"
+	MIME      string        `json:"mime,omitempty"`
+	Truncated bool          `json:"truncated,omitempty"`
+	Attr      *AttrChange   `json:"attr,omitempty"`
+	Xattr     *XattrChange  `json:"xattr,omitempty"`
+	Git       *GitInfo      `json:"git,omitempty"`
+	Age       time.Duration `json:"age,omitempty"`   // 分发时距最早收到原始事件的时间（纳秒）
+	Span      time.Duration `json:"span,omitempty"`  // 合并的原始事件从最早到最晚的时间（纳秒）
+	Size      *int64        `json:"size,omitempty"`  // 创建/写入事件分发时的文件大小，用于统计字节变动
+	Bytes     int64         `json:"bytes,omitempty"` // 启用字节统计时的 Event.Bytes
+	Inode     *InodeInfo    `json:"inode,omitempty"`
+	Special   string        `json:"special,omitempty"`
+}
+
+// recorder 将分发给处理器的事件连同时间写入录制文件
+type recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+}
+
+// WithRecorder 将每个分发给处理器的事件（经过过滤和信息补充后）以 JSON Lines 写入 w，
+// 录制结果可通过 Replay 回放，用于在不接触文件系统的情况下测试处理器逻辑
+func WithRecorder(w io.Writer) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.recorder = &recorder{enc: json.NewEncoder(w)}
+	}
+}
+
+// record 写入一个事件，写入失败不影响分发
+func (r *recorder) record(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	r.enc.Encode(newRecordedEvent(ev, r.start, now))
+}
+
+// newRecordedEvent 生成事件的录制格式，start 为第一个事件的时间
+func newRecordedEvent(ev Event, start, now time.Time) recordedEvent {
+	var size *int64
+	if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+		if info, err := os.Lstat(ev.Path); err == nil && info.Mode().IsRegular() {
+			n := info.Size()
+			size = &n
+		}
+	}
+	return recordedEvent{
+		Offset:    now.Sub(start),
+		Time:      now,
+		Path:      ev.Path,
+		Op:        formatOps(ev.Op),
+		MIME:      ev.MIME,
+		Truncated: ev.Truncated,
+		Attr:      ev.Attr,
+		Xattr:     ev.Xattr,
+		Git:       ev.Git,
+		Age:       now.Sub(ev.FirstSeen),
+		Span:      ev.LastSeen.Sub(ev.FirstSeen),
+		Size:      size,
+		Bytes:     ev.Bytes,
+		Inode:     ev.Inode,
+		Special:   ev.Special,
+	}
+}
+
+// Replay 读取 WithRecorder 录制的事件并依次交给处理器（经过重试和错误上报），
+// speed 为回放倍速：1 按原始间隔，10 为十倍速，<=0 不等待；
+// 回放不读取文件系统，也不需要先调用 Watch 或 Start
+func (fw *FileWatcher) Replay(ctx context.Context, r io.Reader, speed float64) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	start := time.Now()
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec recordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("replay line %d: %w", line, err)
+		}
+		op, err := ParseOps(rec.Op)
+		if err != nil {
+			return fmt.Errorf("replay line %d: %w", line, err)
+		}
+
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(rec.Offset) / speed))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(due)):
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		fw.deliver(Event{
+			Path:      rec.Path,
+			Op:        op,
+			MIME:      rec.MIME,
+			Truncated: rec.Truncated,
+			Attr:      rec.Attr,
+			Xattr:     rec.Xattr,
+			Git:       rec.Git,
+			Bytes:     rec.Bytes,
+			Inode:     rec.Inode,
+			Special:   rec.Special,
+			FirstSeen: now.Add(-rec.Age),
+			LastSeen:  now.Add(rec.Span - rec.Age),
+		})
+	}
+	return scanner.Err()
+}
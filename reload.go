@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileChangeDebounce OnFileChange 的默认去抖动时间，足以合并编辑器一次保存产生的多个事件
+const fileChangeDebounce = 100 * time.Millisecond
+
+// fileChange 单个文件的变更回调
+type fileChange struct {
+	debouncer *Debouncer
+	callbacks []func()
+	silent    bool // 仅由 OnFileChange 注册，不分发给事件处理器
+}
+
+// OnFileChange 在文件内容变化时调用 fn，适用于"配置文件改了就重新加载"
+// 内置去抖动和原子替换处理：编辑器写临时文件再重命名覆盖时只回调一次，
+// 且只在去抖动结束后文件存在时回调（替换过程中短暂消失不会触发）
+// 同一文件可多次注册；仅通过本方法监控的文件不会再分发给 EventHandler
+func (fw *FileWatcher) OnFileChange(path string, fn func()) error {
+	path = filepath.Clean(path)
+
+	fw.mu.Lock()
+	if root := fw.findRootLocked(path); root != nil && root.file {
+		if root.change == nil {
+			root.change = &fileChange{debouncer: NewDebouncer(fw.fileChangeDelay())}
+		}
+		root.change.callbacks = append(root.change.callbacks, fn)
+		fw.mu.Unlock()
+		return nil
+	}
+	fw.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return classifyWatchError(path, err)
+	}
+	if info.IsDir() {
+		return &WatchError{Path: path, Err: errNotAFile}
+	}
+	if _, err := fw.Watch(path); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	root := fw.findRootLocked(path)
+	root.change = &fileChange{
+		debouncer: NewDebouncer(fw.fileChangeDelay()),
+		callbacks: []func(){fn},
+		silent:    true,
+	}
+	return nil
+}
+
+// fileChangeDelay 回调去抖动时间：优先使用全局去抖动配置
+func (fw *FileWatcher) fileChangeDelay() time.Duration {
+	if fw.debouncer != nil && fw.debouncer.duration > 0 {
+		return fw.debouncer.duration
+	}
+	return fileChangeDebounce
+}
+
+// findRootLocked 查找路径完全相同的已注册根路径，调用方需持有 fw.mu
+func (fw *FileWatcher) findRootLocked(path string) *watchRoot {
+	for _, root := range fw.roots {
+		if root.path == path {
+			return root
+		}
+	}
+	return nil
+}
+
+// notifyFileChange 触发单文件回调，返回 true 表示事件不再分发给处理器
+func (fw *FileWatcher) notifyFileChange(root *watchRoot, event fsnotify.Event) bool {
+	fw.mu.Lock()
+	change := root.change
+	var callbacks []func()
+	if change != nil {
+		callbacks = append(callbacks, change.callbacks...)
+	}
+	fw.mu.Unlock()
+
+	if change == nil || filepath.Clean(event.Name) != root.path {
+		return false
+	}
+	if event.Op == fsnotify.Chmod {
+		// 仅属性变化，内容未变
+		return change.silent
+	}
+
+	change.debouncer.Debounce(root.path, func() {
+		// 原子替换过程中文件可能短暂不存在，只在最终存在时回调
+		if _, err := os.Stat(root.path); err != nil {
+			return
+		}
+		for _, fn := range callbacks {
+			fn()
+		}
+	})
+	return change.silent
+}
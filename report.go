@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DirChanges 一个目录在报告周期内的变更
+type DirChanges struct {
+	Dir      string `json:"dir"`
+	Added    int    `json:"added"`
+	Modified int    `json:"modified"`
+	Removed  int    `json:"removed"`
+	Bytes    int64  `json:"bytes"` // 字节变动：每次创建/写入前后大小之差的绝对值，删除计入最后已知大小
+}
+
+// ChangeReport 一个周期（天或周）的变更汇总
+type ChangeReport struct {
+	Period string       `json:"period"` // "day" 或 "week"
+	Start  time.Time    `json:"start"`
+	End    time.Time    `json:"end"`
+	Dirs   []DirChanges `json:"dirs"` // 按目录名排序
+	Total  DirChanges   `json:"total"`
+}
+
+// BuildReports 读取事件日志（--record 录制的 JSON Lines），按天或周（周一开始）汇总每个目录的变更，
+// 返回按时间排序的报告；since 非零时只统计之后的事件
+func BuildReports(r io.Reader, period string, since time.Time) ([]ChangeReport, error) {
+	if period != "day" && period != "week" {
+		return nil, fmt.Errorf("unknown report period %q (want day or week)", period)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	reports := make(map[time.Time]map[string]*DirChanges)
+	sizes := make(map[string]int64) // 路径最后已知的大小，跨周期保留
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec recordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		if rec.Time.IsZero() {
+			return nil, fmt.Errorf("journal line %d: no timestamp (recorded by an older version)", line)
+		}
+		op, err := ParseOps(rec.Op)
+		if err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+
+		// 字节变动按全部事件计算，保证 since 之后的第一次写入也能与之前的大小比较
+		var churn int64
+		prev, known := sizes[rec.Path]
+		switch {
+		case rec.Size != nil:
+			churn = *rec.Size - prev
+			if churn < 0 {
+				churn = -churn
+			}
+			sizes[rec.Path] = *rec.Size
+		case op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename):
+			if known {
+				churn = prev
+			}
+			delete(sizes, rec.Path)
+		}
+		if !since.IsZero() && rec.Time.Before(since) {
+			continue
+		}
+
+		start := periodStart(rec.Time, period)
+		dirs := reports[start]
+		if dirs == nil {
+			dirs = make(map[string]*DirChanges)
+			reports[start] = dirs
+		}
+		dir := filepath.Dir(rec.Path)
+		c := dirs[dir]
+		if c == nil {
+			c = &DirChanges{Dir: dir}
+			dirs[dir] = c
+		}
+		switch {
+		case op.Has(fsnotify.Remove) || op.Has(fsnotify.Rename):
+			c.Removed++
+		case op.Has(fsnotify.Create):
+			c.Added++
+		case op.Has(fsnotify.Write):
+			c.Modified++
+		}
+		c.Bytes += churn
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]ChangeReport, 0, len(reports))
+	for start, dirs := range reports {
+		rep := ChangeReport{Period: period, Start: start, Total: DirChanges{Dir: "total"}}
+		if period == "day" {
+			rep.End = start.AddDate(0, 0, 1)
+		} else {
+			rep.End = start.AddDate(0, 0, 7)
+		}
+		for _, c := range dirs {
+			rep.Dirs = append(rep.Dirs, *c)
+			rep.Total.Added += c.Added
+			rep.Total.Modified += c.Modified
+			rep.Total.Removed += c.Removed
+			rep.Total.Bytes += c.Bytes
+		}
+		sort.Slice(rep.Dirs, func(i, j int) bool { return rep.Dirs[i].Dir < rep.Dirs[j].Dir })
+		out = append(out, rep)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+// periodStart 返回时间所在周期的起点（本地时区的零点，周从周一开始）
+func periodStart(t time.Time, period string) time.Time {
+	y, m, d := t.Local().Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if period == "week" {
+		offset := (int(start.Weekday()) + 6) % 7 // 周一为 0
+		start = start.AddDate(0, 0, -offset)
+	}
+	return start
+}
+
+// title 报告标题，如 "Changes on 2025-01-06" 或 "Changes for week of 2025-01-06"
+func (rep ChangeReport) title() string {
+	if rep.Period == "week" {
+		return "Changes for week of " + rep.Start.Format("2006-01-02")
+	}
+	return "Changes on " + rep.Start.Format("2006-01-02")
+}
+
+// RenderReports 以 text、markdown 或 html 格式输出报告
+func RenderReports(w io.Writer, reports []ChangeReport, format string) error {
+	switch format {
+	case "text":
+		for i, rep := range reports {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, rep.title())
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(tw, "added\tmodified\tremoved\tbytes\t  directory")
+			for _, c := range append(rep.Dirs, rep.Total) {
+				fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t  %s\n", c.Added, c.Modified, c.Removed, formatBytes(c.Bytes), c.Dir)
+			}
+			tw.Flush()
+		}
+		return nil
+	case "markdown":
+		for i, rep := range reports {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "## %s\n\n", rep.title())
+			fmt.Fprintln(w, "| Directory | Added | Modified | Removed | Bytes |")
+			fmt.Fprintln(w, "|---|---:|---:|---:|---:|")
+			for _, c := range rep.Dirs {
+				fmt.Fprintf(w, "| `%s` | %d | %d | %d | %s |\n", strings.ReplaceAll(c.Dir, "|", `\|`), c.Added, c.Modified, c.Removed, formatBytes(c.Bytes))
+			}
+			t := rep.Total
+			fmt.Fprintf(w, "| **Total** | **%d** | **%d** | **%d** | **%s** |\n", t.Added, t.Modified, t.Removed, formatBytes(t.Bytes))
+		}
+		return nil
+	case "html":
+		return reportHTML.Execute(w, reports)
+	}
+	return fmt.Errorf("unknown report format %q (want text, markdown or html)", format)
+}
+
+// reportHTML HTML 报告模板
+var reportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>File change report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+td.n { text-align: right; }
+tr.total { font-weight: bold; }
+</style></head><body>
+{{range .}}<h2>{{.Title}}</h2>
+<table>
+<tr><th>Directory</th><th>Added</th><th>Modified</th><th>Removed</th><th>Bytes</th></tr>
+{{range .Dirs}}<tr><td><code>{{.Dir}}</code></td><td class="n">{{.Added}}</td><td class="n">{{.Modified}}</td><td class="n">{{.Removed}}</td><td class="n">{{bytes .Bytes}}</td></tr>
+{{end}}{{with .Total}}<tr class="total"><td>Total</td><td class="n">{{.Added}}</td><td class="n">{{.Modified}}</td><td class="n">{{.Removed}}</td><td class="n">{{bytes .Bytes}}</td></tr>{{end}}
+</table>
+{{else}}<p>No changes recorded.</p>
+{{end}}</body></html>
+`))
+
+// Title 供 HTML 模板使用
+func (rep ChangeReport) Title() string { return rep.title() }
+
+// formatBytes 以人类可读的单位格式化字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// runReport report 子命令：将事件日志汇总为每日/每周变更报告
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	period := fs.String("period", "day", "aggregate by day or week")
+	format := fs.String("format", "text", "output format: text, markdown or html")
+	sinceFlag := fs.String("since", "", "only include events on or after this date (YYYY-MM-DD) or this long ago (e.g. 7d)")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	keyFile := fs.String("encryption-key-file", os.Getenv(envName("encryption-key-file")), "decrypt journals recorded with --encryption-key-file using the key in this file; defaults to $WATCHDOG_ENCRYPTION_KEY_FILE or the key in $WATCHDOG_ENCRYPTION_KEY")
+	acceptPlaintext := fs.Bool("encryption-accept-plaintext", false, "with an encryption key, also accept unencrypted journal lines (rejected by default)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s report [flags] journal.jsonl...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitConfig
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		var ok bool
+		if since, ok = parseSince(*sinceFlag); !ok {
+			return exitWith(ExitConfig, "invalid --since %q: want YYYY-MM-DD or a duration like 7d", *sinceFlag)
+		}
+	}
+
+	sealer, err := loadSealer(*keyFile, *acceptPlaintext)
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	var readers []io.Reader
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return exitErr(err, "failed to open journal: %v", err)
+		}
+		defer f.Close()
+		readers = append(readers, sealer.NewReader(f))
+	}
+	reports, err := BuildReports(io.MultiReader(readers...), *period, since)
+	if err != nil {
+		return exitErr(err, "%v", err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return exitErr(err, "failed to create report: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := RenderReports(w, reports, *format); err != nil {
+		return exitErr(err, "%v", err)
+	}
+	return ExitOK
+}
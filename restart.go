@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// restartRetryInterval 重建后端失败时的重试间隔
+const restartRetryInterval = time.Second
+
+// restartConfig 后端自愈配置
+type restartConfig struct {
+	resync    bool                  // 重建后是否扫描补发间隙期间的变化
+	onRestart func(since time.Time) // 重建完成回调，since 为后端失效的时间
+}
+
+// WithAutoRestart 后端失效（事件通道关闭）时自动重建 fsnotify watcher 并重新注册所有路径
+// resync 为 true 时，重建后扫描监控路径，为间隙期间修改过的文件补发 WRITE 事件；
+// onRestart 可为 nil，用于通知调用方可能存在事件丢失的间隙
+func WithAutoRestart(resync bool, onRestart func(since time.Time)) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.restart = &restartConfig{
+			resync:    resync,
+			onRestart: onRestart,
+		}
+	}
+}
+
+// stopped 判断监控器是否已停止
+func (fw *FileWatcher) stopped() bool {
+	select {
+	case <-fw.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Failed 返回后端失效且无法恢复（未启用 WithAutoRestart）时关闭的通道，此后不会再收到事件
+func (fw *FileWatcher) Failed() <-chan struct{} {
+	return fw.failed
+}
+
+// recoverBackend 后端失效后尝试重建，返回 false 表示事件循环应退出
+func (fw *FileWatcher) recoverBackend() bool {
+	if fw.stopped() {
+		return false
+	}
+	if fw.restart == nil {
+		fw.reportError(fmt.Errorf("%w: events channel closed unexpectedly", ErrBackendFailed))
+		close(fw.failed)
+		return false
+	}
+
+	since := time.Now()
+	logf("fsnotify backend closed unexpectedly, restarting...")
+
+	// 共享后端失效后改用独占后端
+	var w watchBackend
+	for {
+		var err error
+		if w, err = newFsnotifyBackend(); err == nil {
+			break
+		}
+		fw.reportError(err)
+
+		select {
+		case <-fw.done:
+			return false
+		case <-time.After(restartRetryInterval):
+		}
+	}
+
+	fw.mu.Lock()
+	old := fw.watcher
+	fw.watcher = w
+	roots := append([]*watchRoot(nil), fw.roots...)
+	fw.mu.Unlock()
+	old.Close()
+
+	// 重新注册所有根路径
+	for _, root := range roots {
+		if err := fw.addRoot(root); err != nil {
+			fw.reportError(classifyWatchError(root.path, err))
+		}
+	}
+	logf("fsnotify backend restarted, %d root(s) re-registered", len(roots))
+
+	if fw.restart.resync {
+		for _, root := range roots {
+			fw.resync(root, since)
+		}
+	}
+	if fw.restart.onRestart != nil {
+		fw.restart.onRestart(since)
+	}
+	return true
+}
+
+// resync 扫描根路径，为 since 之后修改过的文件补发 WRITE 事件
+func (fw *FileWatcher) resync(root *watchRoot, since time.Time) {
+	err := filepath.Walk(root.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fw.ignored(root, path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if path != root.path && (!root.recursive || fw.tooDeep(root, path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.ModTime().Before(since) {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+		return nil
+	})
+	if err != nil {
+		fw.reportError(classifyWatchError(root.path, err))
+	}
+}
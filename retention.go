@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// retentionSettle 新文件到达后延迟清理的时间，合并一批文件只清理一次
+const retentionSettle = time.Second
+
+// RetentionRule 目录保留策略，只作用于目录下的直接文件（不含子目录）
+type RetentionRule struct {
+	Dir        string        // 要清理的目录
+	MaxAge     time.Duration // 修改时间早于该时长的文件被清理，0 表示不按时间清理
+	KeepNewest int           // 只保留最新的 K 个文件，0 表示不按数量清理
+	ArchiveDir string        // 非空时移动到该目录归档，而不是删除
+}
+
+// retention 保留策略执行器
+type retention struct {
+	interval  time.Duration
+	rules     []RetentionRule
+	debouncer *Debouncer
+	dryRun    bool
+}
+
+// WithRetention 按时间或数量清理（删除或归档）旧文件，适用于投递目录
+// 新文件到达时触发所在目录的清理，另外每隔 sweep 全量检查一次
+func WithRetention(sweep time.Duration, rules ...RetentionRule) WatcherOption {
+	return func(fw *FileWatcher) {
+		for i := range rules {
+			rules[i].Dir = filepath.Clean(rules[i].Dir)
+		}
+		fw.retention = &retention{
+			interval:  sweep,
+			rules:     rules,
+			debouncer: NewDebouncer(retentionSettle),
+		}
+	}
+}
+
+// observe 新文件到达时延迟清理所在目录
+func (r *retention) observe(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) {
+		return
+	}
+	dir := filepath.Dir(filepath.Clean(event.Name))
+	for _, rule := range r.rules {
+		if rule.Dir == dir {
+			rule := rule
+			r.debouncer.Debounce(rule.Dir, func() { r.apply(rule) })
+		}
+	}
+}
+
+// sweep 对所有规则执行一次清理
+func (r *retention) sweep() {
+	for _, rule := range r.rules {
+		r.apply(rule)
+	}
+}
+
+// apply 执行单条规则：先按数量，再按时间
+func (r *retention) apply(rule RetentionRule) {
+	entries, err := os.ReadDir(rule.Dir)
+	if err != nil {
+		logf("retention: read %s: %v", rule.Dir, err)
+		return
+	}
+
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{filepath.Join(rule.Dir, entry.Name()), info.ModTime()})
+	}
+	// 最新的排在前面
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	cutoff := time.Now().Add(-rule.MaxAge)
+	for i, f := range files {
+		tooMany := rule.KeepNewest > 0 && i >= rule.KeepNewest
+		tooOld := rule.MaxAge > 0 && f.modTime.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if r.dryRun {
+			if rule.ArchiveDir == "" {
+				wouldDo("remove %s (retention)", f.path)
+			} else {
+				wouldDo("archive %s -> %s (retention)", f.path, rule.ArchiveDir)
+			}
+			continue
+		}
+		if err := rule.expire(f.path); err != nil {
+			logf("retention: %v", err)
+		}
+	}
+}
+
+// expire 删除或归档单个文件
+func (rule RetentionRule) expire(path string) error {
+	if rule.ArchiveDir == "" {
+		logf("retention: removing %s", path)
+		return os.Remove(path)
+	}
+
+	if err := os.MkdirAll(rule.ArchiveDir, 0o755); err != nil {
+		return err
+	}
+	dest := filepath.Join(rule.ArchiveDir, filepath.Base(path))
+	logf("retention: archiving %s -> %s", path, dest)
+	if err := os.Rename(path, dest); err == nil {
+		return nil
+	}
+	// 跨设备时无法重命名，退化为复制后删除
+	if err := copyFile(path, dest); err != nil {
+		return fmt.Errorf("archive %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// copyFile 复制文件内容和权限
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runRetention 启动时清理一次，之后定期清理
+func (fw *FileWatcher) runRetention() {
+	fw.retention.sweep()
+	if fw.retention.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(fw.retention.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.done:
+			return
+		case <-ticker.C:
+			fw.retention.sweep()
+		}
+	}
+}
+
+// ParseDuration 在 time.ParseDuration 基础上支持天（d），如 "7d"、"36h"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
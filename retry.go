@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"watchdogdemo/handlers"
+)
+
+// RetryPolicy 处理器失败重试策略（指数退避 + 随机抖动）
+// 零值表示不重试，只调用一次
+type RetryPolicy struct {
+	MaxAttempts    int              // 最大尝试次数（含首次），<=1 表示不重试
+	InitialBackoff time.Duration    // 首次重试前的等待时间
+	MaxBackoff     time.Duration    // 退避时间上限，0 表示不限制
+	Multiplier     float64          // 每次重试的退避倍数，0 表示 2，1 表示固定间隔；不能小于 1
+	Jitter         float64          // 随机抖动比例（0~1），避免多个事件同时重试
+	Retryable      func(error) bool // 判断错误是否可重试，nil 表示除 Permanent 外都重试
+}
+
+// DefaultRetryPolicy 返回一个适用于大多数场景的默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Permanent 将错误包装为不可重试错误，分发器会立即放弃该事件；与 handlers.Permanent 是同一个标记，
+// 组合处理器 handlers.Retry 同样不重试
+func Permanent(err error) error {
+	return handlers.Permanent(err)
+}
+
+// IsPermanent 判断错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	return handlers.IsPermanent(err)
+}
+
+// retryable 判断错误是否应该重试
+func (p RetryPolicy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// backoff 计算第 attempt 次重试前的等待时间（attempt 从 1 开始）
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+			d = float64(p.MaxBackoff)
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		// 在 [1-jitter, 1+jitter] 范围内随机缩放
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// Do 按策略执行 fn，返回实际尝试次数和最后一次的错误；ctx 结束时不再等待退避，立即返回最后一次的错误
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+	attempt := 0
+	for {
+		attempt++
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= p.MaxAttempts || !p.retryable(err) {
+			return attempt, err
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffMultiplier(t *testing.T) {
+	tests := []struct {
+		multiplier float64
+		want       []time.Duration // 第 1、2、3 次重试前的等待时间
+	}{
+		{0, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		{1, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}},
+		{3, []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: tt.multiplier}
+		for i, want := range tt.want {
+			if got := p.backoff(i + 1); got != want {
+				t.Errorf("multiplier %g: backoff(%d) = %s, want %s", tt.multiplier, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestRetryBackoffCapped(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 10, Jitter: 0.5}
+	for attempt := 1; attempt <= 5; attempt++ {
+		if got := p.backoff(attempt); got > p.MaxBackoff {
+			t.Errorf("backoff(%d) = %s exceeds max %s", attempt, got, p.MaxBackoff)
+		}
+	}
+}
+
+func TestRetryMultiplierValidated(t *testing.T) {
+	for _, multiplier := range []float64{0.5, -1} {
+		policy := DefaultRetryPolicy()
+		policy.Multiplier = multiplier
+		_, err := NewFileWatcher(NopHandler{}, WithRetry(policy))
+		if err == nil || !strings.Contains(err.Error(), "retry multiplier") {
+			t.Errorf("multiplier %g: err = %v, want a retry multiplier error", multiplier, err)
+		}
+	}
+	for _, multiplier := range []float64{0, 1, 1.5} {
+		policy := DefaultRetryPolicy()
+		policy.Multiplier = multiplier
+		fw, err := NewFileWatcher(NopHandler{}, WithRetry(policy))
+		if err != nil {
+			t.Errorf("multiplier %g: %v", multiplier, err)
+			continue
+		}
+		fw.Stop()
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// watchRoot 通过 Watch 注册的根路径及其生效的选项
+type watchRoot struct {
+	path       string
+	recursive  bool
+	maxDepth   int      // 负数表示不限制
+	excludes   []string // 仅作用于该根路径的排除模式，与全局排除模式叠加
+	skipHidden bool
+	file       bool        // 单文件监控：实际监控父目录，只保留目标文件的事件
+	change     *fileChange // OnFileChange 注册的回调，受 FileWatcher.mu 保护
+
+	skipInaccessible bool
+	inaccessible     []*WatchError // 因无权访问而跳过的子树，受 FileWatcher.mu 保护
+	pollDepth        int           // 超出 watch 预算时从这一层开始改为轮询，-1 表示全部使用原生 watch
+	fsType           string        // 启用 WithAutoBackend 时检测到的文件系统类型
+	polled           bool          // 整个根路径以轮询代替原生 watch
+}
+
+// WatchOption 单次 Watch 调用的选项，覆盖监控器的全局配置
+type WatchOption func(*watchRoot)
+
+// Recursive 设置该根路径是否递归监控
+func Recursive(recursive bool) WatchOption {
+	return func(r *watchRoot) {
+		r.recursive = recursive
+	}
+}
+
+// Exclude 为该根路径追加排除模式（语法同 WithExclude）
+func Exclude(patterns ...string) WatchOption {
+	return func(r *watchRoot) {
+		r.excludes = append(r.excludes, patterns...)
+	}
+}
+
+// MaxDepth 设置该根路径的最大递归深度，负数表示不限制
+func MaxDepth(n int) WatchOption {
+	return func(r *watchRoot) {
+		r.maxDepth = n
+	}
+}
+
+// SkipHidden 设置该根路径是否忽略隐藏文件、垃圾文件和临时文件
+func SkipHidden(skip bool) WatchOption {
+	return func(r *watchRoot) {
+		r.skipHidden = skip
+	}
+}
+
+// newRoot 以全局配置为默认值创建根路径配置，再应用单次调用的选项
+func (fw *FileWatcher) newRoot(path string, opts []WatchOption) *watchRoot {
+	root := &watchRoot{
+		path:       fw.rootPath(path),
+		recursive:  fw.recursive,
+		maxDepth:   fw.maxDepth,
+		skipHidden: fw.hidden != nil && fw.hidden.appliesTo(path),
+
+		skipInaccessible: fw.skipInaccessible,
+		pollDepth:        -1,
+	}
+	for _, opt := range opts {
+		opt(root)
+	}
+	return root
+}
+
+// rootPath 返回根路径的规范写法，与已注册根路径的 path 字段可直接比较。
+// 经过 longPath 往返：Windows 上后端注册的是绝对路径，根路径也要是绝对路径，事件路径才能与它匹配
+func (fw *FileWatcher) rootPath(path string) string {
+	return filepath.Clean(fw.canonical(stripLongPath(longPath(path))))
+}
+
+// removeRoot 注销根路径，并移除只为它添加的后端 watch（包括递归添加的子目录和单文件监控的父目录），
+// 根路径已注销时返回 false
+func (fw *FileWatcher) removeRoot(root *watchRoot) bool {
+	fw.mu.Lock()
+	i := slices.Index(fw.roots, root)
+	if i < 0 {
+		fw.mu.Unlock()
+		return false
+	}
+	fw.roots = slices.Delete(fw.roots, i, i+1)
+	fw.mu.Unlock()
+	fw.releaseWatches(root)
+	return true
+}
+
+// releaseWatches 移除 root 需要、但已注册的根路径都不再需要的后端 watch
+func (fw *FileWatcher) releaseWatches(root *watchRoot) {
+	if fw.poller != nil {
+		fw.poller.drop(root)
+	}
+	fw.mu.Lock()
+	w := fw.watcher
+	var stale []string
+	for _, name := range w.WatchList() {
+		dir := stripLongPath(name)
+		if fw.needs(root, dir) && !fw.watchedByRoots(dir) {
+			stale = append(stale, name)
+		}
+	}
+	fw.mu.Unlock()
+
+	// 目录已删除时后端会自动移除 watch，这里的错误可以忽略
+	for _, name := range stale {
+		w.Remove(name)
+	}
+}
+
+// needs 判断后端 watch 的目录 dir 是否为根路径 r 所需；递归根路径不需要已排除或超出深度的子目录
+func (fw *FileWatcher) needs(r *watchRoot, dir string) bool {
+	switch {
+	case r.polled:
+		return false
+	case r.file:
+		return filepath.Dir(r.path) == dir
+	case r.recursive:
+		return dir == r.path || isUnder(r.path, dir) && !fw.ignored(r, dir) && !fw.tooDeep(r, dir) &&
+			(r.pollDepth < 0 || depth(r.path, dir) < r.pollDepth)
+	}
+	return r.path == dir
+}
+
+// watchedByRoots 判断 dir 是否仍被某个已注册的根路径需要，调用方需持有 fw.mu
+func (fw *FileWatcher) watchedByRoots(dir string) bool {
+	for _, r := range fw.roots {
+		if fw.needs(r, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupRoot 返回包含 path 的已注册根路径（最长匹配），找不到时返回 nil
+func (fw *FileWatcher) lookupRoot(path string) *watchRoot {
+	path = filepath.Clean(path)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	var best *watchRoot
+	for _, root := range fw.roots {
+		if isUnder(root.path, path) && (best == nil || len(root.path) > len(best.path)) {
+			best = root
+		}
+	}
+	return best
+}
+
+// rootFor 返回包含 path 的已注册根路径
+// 找不到时以 path 所在目录和全局配置构造一个临时根路径
+func (fw *FileWatcher) rootFor(path string) *watchRoot {
+	if root := fw.lookupRoot(path); root != nil {
+		return root
+	}
+	return fw.newRoot(filepath.Dir(path), nil)
+}
+
+// fileParentOnly 判断 path 所在目录是否只是为单文件监控而注册的父目录
+func (fw *FileWatcher) fileParentOnly(path string) bool {
+	dir := filepath.Dir(filepath.Clean(path))
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, root := range fw.roots {
+		if root.file && filepath.Dir(root.path) == dir {
+			return true
+		}
+	}
+	return false
+}
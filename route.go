@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/fsnotify/fsnotify"
+)
+
+// route 一个处理器及其过滤条件，由分发器逐个判断
+type route struct {
+	handler EventHandler
+	include []string    // 非空时只接收匹配的路径
+	exclude []string    // 匹配的路径不接收
+	ops     fsnotify.Op // 非 0 时只接收这些事件类型
+}
+
+// HandlerOption 处理器级过滤选项，用于 WithHandler 和 WithHandlerFilter
+type HandlerOption func(*route)
+
+// OnlyPaths 只接收匹配任一模式的路径（相对根路径，语法同 WithExclude）
+func OnlyPaths(patterns ...string) HandlerOption {
+	return func(r *route) {
+		r.include = append(r.include, patterns...)
+	}
+}
+
+// SkipPaths 不接收匹配任一模式的路径（相对根路径，语法同 WithExclude）
+func SkipPaths(patterns ...string) HandlerOption {
+	return func(r *route) {
+		r.exclude = append(r.exclude, patterns...)
+	}
+}
+
+// OnlyOps 只接收指定类型的事件，如 OnlyOps(fsnotify.Write)；
+// 同时包含多种操作的事件只保留掩码内的部分
+func OnlyOps(ops fsnotify.Op) HandlerOption {
+	return func(r *route) {
+		r.ops = ops
+	}
+}
+
+// WithHandler 注册额外的处理器，每个事件按各自的过滤条件分别判断，如
+// WithHandler(webhook, OnlyPaths("*.json"), OnlyOps(fsnotify.Write))；
+// 处理器按注册顺序在分发 goroutine 中依次调用，Initializer/Closer 等可选接口同样生效
+func WithHandler(h EventHandler, opts ...HandlerOption) WatcherOption {
+	return func(fw *FileWatcher) {
+		r := &route{handler: h}
+		for _, opt := range opts {
+			opt(r)
+		}
+		fw.routes = append(fw.routes, r)
+	}
+}
+
+// WithHandlerFilter 为 NewFileWatcher 传入的主处理器设置过滤条件
+func WithHandlerFilter(opts ...HandlerOption) WatcherOption {
+	return func(fw *FileWatcher) {
+		for _, opt := range opts {
+			opt(fw.routes[0])
+		}
+	}
+}
+
+// accept 按过滤条件裁剪事件，返回 false 表示该处理器不接收
+func (r *route) accept(fw *FileWatcher, ev Event) (Event, bool) {
+	if r.ops != 0 {
+		ev.Op &= r.ops
+		if ev.Op == 0 {
+			return ev, false
+		}
+	}
+	if len(r.include) > 0 && !fw.matchAnyPath(r.include, ev.Path) {
+		return ev, false
+	}
+	if fw.matchAnyPath(r.exclude, ev.Path) {
+		return ev, false
+	}
+	return ev, true
+}
+
+// matchAnyPath 判断路径是否匹配任一模式
+func (fw *FileWatcher) matchAnyPath(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if fw.matchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplePolicy 一条采样规则：匹配 Pattern 的路径只保留部分事件，其余略去，
+// 略去的数量记在下一个保留事件的 Event.Sampled 中
+type SamplePolicy struct {
+	Pattern   string // 模式语法同 WithPriority，以 / 开头的模式按绝对路径匹配
+	EveryN    int    // 每个路径每 N 个事件保留 1 个（第 1 个保留），0 表示不按比例采样
+	PerSecond int    // 每个路径每秒最多保留的事件数，0 表示不限
+}
+
+// String 返回 ParseSamplePolicies 可解析的形式，如 "*.log:1/100"
+func (p SamplePolicy) String() string {
+	if p.EveryN > 0 {
+		return fmt.Sprintf("%s:1/%d", p.Pattern, p.EveryN)
+	}
+	return fmt.Sprintf("%s:%d/s", p.Pattern, p.PerSecond)
+}
+
+// ParseSamplePolicies 解析逗号分隔的采样规则，如 "*.log:1/100,/var/tmp/**:5/s"：
+// 1/N 表示每 N 个事件保留 1 个，M/s 表示每个路径每秒最多保留 M 个
+func ParseSamplePolicies(s string) ([]SamplePolicy, error) {
+	var policies []SamplePolicy
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid sample policy %q: want pattern:1/N or pattern:M/s", spec)
+		}
+		p := SamplePolicy{Pattern: spec[:i]}
+		rate := spec[i+1:]
+		var err error
+		switch {
+		case strings.HasPrefix(rate, "1/"):
+			p.EveryN, err = strconv.Atoi(rate[2:])
+		case strings.HasSuffix(rate, "/s"):
+			p.PerSecond, err = strconv.Atoi(strings.TrimSuffix(rate, "/s"))
+		default:
+			err = fmt.Errorf("unknown rate %q", rate)
+		}
+		if err != nil || p.EveryN < 0 || p.PerSecond < 0 || p.EveryN == 0 && p.PerSecond == 0 {
+			return nil, fmt.Errorf("invalid sample policy %q: want pattern:1/N or pattern:M/s", spec)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// sampleState 一个路径的采样状态
+type sampleState struct {
+	seen    uint64 // 匹配规则的事件总数
+	second  int64  // 当前计数的 Unix 秒
+	kept    int    // 当前秒内保留的事件数
+	skipped int    // 自上一个保留事件以来略去的事件数
+}
+
+// sampler 按规则对热点路径的事件采样
+type sampler struct {
+	policies []SamplePolicy
+
+	mu    sync.Mutex
+	paths map[string]*sampleState
+}
+
+// WithSampling 为极热的路径启用采样：匹配规则（按顺序取第一条）的路径只保留部分事件分发，
+// 其余略去并计入统计的 sampled，数量记在该路径下一个保留事件的 Event.Sampled 中，
+// 让日志、指标等观测类处理器不被重复事件淹没
+func WithSampling(policies ...SamplePolicy) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.sampler = &sampler{policies: policies, paths: make(map[string]*sampleState)}
+	}
+}
+
+// sample 对事件采样，略去时返回命中的规则，保留时返回 nil 并把略去的数量写入 ev.Sampled；不匹配任何规则的事件都保留
+func (s *sampler) sample(fw *FileWatcher, ev *Event) *SamplePolicy {
+	var policy *SamplePolicy
+	for i := range s.policies {
+		if fw.matchPath(s.policies[i].Pattern, ev.Path) {
+			policy = &s.policies[i]
+			break
+		}
+	}
+	if policy == nil {
+		return nil
+	}
+	now := time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 顺带清理一秒以上没有事件、也没有待报告略去数量的路径
+	if len(s.paths) > 1024 {
+		for path, st := range s.paths {
+			if st.second < now-1 && st.skipped == 0 {
+				delete(s.paths, path)
+			}
+		}
+	}
+	st := s.paths[ev.Path]
+	if st == nil {
+		st = &sampleState{}
+		s.paths[ev.Path] = st
+	}
+	st.seen++
+	if st.second != now {
+		st.second, st.kept = now, 0
+	}
+	keep := true
+	if policy.EveryN > 0 && (st.seen-1)%uint64(policy.EveryN) != 0 {
+		keep = false
+	}
+	if policy.PerSecond > 0 && st.kept >= policy.PerSecond {
+		keep = false
+	}
+	if !keep {
+		st.skipped++
+		return policy
+	}
+	st.kept++
+	ev.Sampled, st.skipped = st.skipped, 0
+	return nil
+}
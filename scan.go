@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanResult 病毒扫描结果
+type ScanResult struct {
+	Infected  bool
+	Signature string // 感染时命中的特征名，扫描器未提供时为空
+}
+
+// Scanner 病毒扫描器
+type Scanner interface {
+	Scan(ctx context.Context, path string) (ScanResult, error)
+}
+
+// clamdChunkSize INSTREAM 每次发送的数据块大小
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner 通过 clamd 的 INSTREAM 命令扫描：文件内容经套接字发送，clamd 不需要能访问文件路径
+type ClamdScanner struct {
+	Network string        // "unix" 或 "tcp"
+	Address string        // 如 /var/run/clamav/clamd.ctl 或 127.0.0.1:3310
+	Timeout time.Duration // 单个文件的扫描超时，0 表示不限
+}
+
+// ParseClamdAddress 解析 clamd 地址：unix:PATH、tcp:HOST:PORT，或以 / 开头的套接字路径、HOST:PORT
+func ParseClamdAddress(addr string) ClamdScanner {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return ClamdScanner{Network: "unix", Address: strings.TrimPrefix(addr, "unix:")}
+	case strings.HasPrefix(addr, "tcp:"):
+		return ClamdScanner{Network: "tcp", Address: strings.TrimPrefix(addr, "tcp:")}
+	case strings.HasPrefix(addr, "/"):
+		return ClamdScanner{Network: "unix", Address: addr}
+	}
+	return ClamdScanner{Network: "tcp", Address: addr}
+}
+
+// Scan 发送文件内容并解析 clamd 的回复，如 "stream: OK"、"stream: Eicar-Signature FOUND"
+func (s ClamdScanner) Scan(ctx context.Context, path string) (ScanResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer f.Close()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if s.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, rerr := f.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return ScanResult{}, fmt.Errorf("clamd: %w", err)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return ScanResult{}, rerr
+		}
+	}
+	// 长度为 0 的块表示数据结束
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+}
+
+// CommandScanner 用外部命令扫描，约定同 clamscan：退出码 0 为干净，1 为感染，其他为扫描失败
+type CommandScanner struct {
+	Command Command
+}
+
+// Scan 执行扫描命令，感染时以输出的最后一行作为特征名
+func (s CommandScanner) Scan(ctx context.Context, path string) (ScanResult, error) {
+	out, err := s.Command.output(ctx, path)
+	if err == nil {
+		return ScanResult{}, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n") // 没有输出时特征名为空
+		return ScanResult{Infected: true, Signature: strings.TrimSpace(lines[len(lines)-1])}, nil
+	}
+	return ScanResult{}, fmt.Errorf("scan command %q on %s: %w: %s", s.Command.Line, path, err, out)
+}
+
+// ScanHandler 病毒扫描处理器：新建或写入的文件稳定 settle 时间后提交给扫描器，
+// 干净的文件移到 Clean 目录并作为 CREATE 交给下一个处理器，感染的文件移到 Infected 目录；
+// 扫描失败的文件留在原处，下次变化时重新扫描。同时进行的扫描数不超过 workers，
+// 大批文件到达时其余文件排队等待。删除、重命名和权限变化照常转发
+type ScanHandler struct {
+	next     EventHandler
+	scanner  Scanner
+	clean    string
+	infected string
+	settle   *Debouncer
+	slots    chan struct{} // 限制同时进行的扫描数
+
+	// DrainTimeout Close 扫描仍在等待稳定的文件时最多用多久，
+	// 这些扫描使用独立的上下文，监控停止时的取消不会让它们失败
+	DrainTimeout time.Duration
+
+	ctx     context.Context
+	drain   context.Context // Close 期间新开始的扫描使用的上下文，由 mu 保护
+	mu      sync.Mutex
+	dryRun  bool
+	running sync.WaitGroup
+}
+
+// scanDrainTimeout DrainTimeout 的默认值
+const scanDrainTimeout = 30 * time.Second
+
+// NewScanHandler 创建病毒扫描处理器，clean 和 infected 目录不存在时创建
+func NewScanHandler(next EventHandler, scanner Scanner, clean, infected string, settle time.Duration, workers int) (*ScanHandler, error) {
+	var dirs [2]string
+	for i, dir := range []string{clean, infected} {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs[i] = abs
+	}
+	return &ScanHandler{
+		next:     next,
+		scanner:  scanner,
+		clean:    dirs[0],
+		infected: dirs[1],
+		settle:   NewDebouncer(settle),
+		slots:    make(chan struct{}, max(workers, 1)),
+		ctx:      context.Background(),
+
+		DrainTimeout: scanDrainTimeout,
+	}, nil
+}
+
+func (h *ScanHandler) OnCreate(path string) error { h.schedule(path); return nil }
+func (h *ScanHandler) OnWrite(path string) error  { h.schedule(path); return nil }
+func (h *ScanHandler) OnRemove(path string) error { return h.next.OnRemove(path) }
+func (h *ScanHandler) OnRename(path string) error { return h.next.OnRename(path) }
+func (h *ScanHandler) OnChmod(path string) error  { return h.next.OnChmod(path) }
+
+// Init 创建目标目录，记录上下文并转发给下一个处理器的 Initializer
+func (h *ScanHandler) Init(ctx context.Context) error {
+	h.ctx = ctx
+	h.dryRun = IsDryRun(ctx)
+	if !h.dryRun {
+		for _, dir := range []string{h.clean, h.infected} {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+	}
+	if initializer, ok := h.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 扫描仍在等待稳定的文件并等待进行中的扫描结束，再转发给下一个处理器的 Closer
+func (h *ScanHandler) Close() error {
+	drain, cancel := context.WithTimeout(context.WithoutCancel(h.ctx), h.DrainTimeout)
+	defer cancel()
+	h.mu.Lock()
+	h.drain = drain
+	h.mu.Unlock()
+	h.settle.Flush()
+	h.running.Wait()
+	if closer, ok := h.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// schedule 文件每次变化后重新计时，稳定后排队扫描；忽略目标目录中的文件
+func (h *ScanHandler) schedule(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil || isUnder(h.clean, abs) || isUnder(h.infected, abs) {
+		return
+	}
+	h.settle.Debounce(path, func() {
+		h.running.Add(1)
+		go func() {
+			defer h.running.Done()
+			h.slots <- struct{}{}
+			defer func() { <-h.slots }()
+			h.scan(h.context(), path)
+		}()
+	})
+}
+
+// context 返回新开始的扫描使用的上下文
+func (h *ScanHandler) context() context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.drain != nil {
+		return h.drain
+	}
+	return h.ctx
+}
+
+// scan 扫描文件并按结果移到干净或感染目录
+func (h *ScanHandler) scan(ctx context.Context, path string) {
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return // 稳定前已被删除或移走
+	}
+	result, err := h.scanner.Scan(ctx, path)
+	if err != nil {
+		logf("scan: %s: %v", path, err)
+		return
+	}
+	dir := h.clean
+	if result.Infected {
+		dir = h.infected
+	}
+	if h.dryRun {
+		wouldDo("move %s to %s/", path, dir)
+		return
+	}
+	// 不同目录中的同名文件不能互相覆盖，同时扫描完的同名文件也不能选中同一个目标
+	dest, err := reservePath(filepath.Join(dir, filepath.Base(path)))
+	if err != nil {
+		logf("scan: move %s: %v", path, err)
+		return
+	}
+	if err := SelfWriteContext(ctx, func() error { return os.Rename(path, dest) }, path, dest); err != nil {
+		os.Remove(dest)
+		logf("scan: move %s: %v", path, err)
+		return
+	}
+	if result.Infected && result.Signature == "" {
+		logf("scan: %s is infected, moved to %s", path, dest)
+		return
+	}
+	if result.Infected {
+		logf("scan: %s is infected (%s), moved to %s", path, result.Signature, dest)
+		return
+	}
+	if err := h.next.OnCreate(dest); err != nil {
+		logf("scan: handler failed for %s: %v", dest, err)
+	}
+}
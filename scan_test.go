@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScanner 文件名含 "eicar" 视为感染；上下文取消后扫描失败
+type fakeScanner struct{}
+
+func (fakeScanner) Scan(ctx context.Context, path string) (ScanResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ScanResult{}, err
+	}
+	if strings.Contains(filepath.Base(path), "eicar") {
+		return ScanResult{Infected: true, Signature: "Eicar-Signature"}, nil
+	}
+	return ScanResult{}, nil
+}
+
+// createRecorder 记录转发给下一个处理器的 CREATE
+type createRecorder struct {
+	NopHandler
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *createRecorder) OnCreate(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, path)
+	return nil
+}
+
+func newTestScanHandler(t *testing.T, ctx context.Context, settle time.Duration) (*ScanHandler, *createRecorder, string) {
+	t.Helper()
+	root := t.TempDir()
+	next := &createRecorder{}
+	h, err := NewScanHandler(next, fakeScanner{}, filepath.Join(root, "clean"), filepath.Join(root, "infected"), settle, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	incoming := filepath.Join(root, "incoming")
+	mkdir(t, incoming)
+	return h, next, incoming
+}
+
+func TestScanHandlerMovesByResult(t *testing.T) {
+	h, next, incoming := newTestScanHandler(t, context.Background(), time.Millisecond)
+	for _, name := range []string{"report.pdf", "eicar.com"} {
+		path := filepath.Join(incoming, name)
+		writeFile(t, path)
+		h.OnCreate(path)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, h.clean); len(got) != 1 || got[0] != "report.pdf" {
+		t.Errorf("clean = %v, want [report.pdf]", got)
+	}
+	if got := listDir(t, h.infected); len(got) != 1 || got[0] != "eicar.com" {
+		t.Errorf("infected = %v, want [eicar.com]", got)
+	}
+	if len(next.paths) != 1 || next.paths[0] != filepath.Join(h.clean, "report.pdf") {
+		t.Errorf("forwarded CREATE = %v, want the clean copy only", next.paths)
+	}
+}
+
+func TestScanHandlerSameNameDoesNotOverwrite(t *testing.T) {
+	h, next, incoming := newTestScanHandler(t, context.Background(), time.Hour)
+	const n = 8
+	for i := 0; i < n; i++ {
+		dir := filepath.Join(incoming, string(rune('a'+i)))
+		mkdir(t, dir)
+		path := filepath.Join(dir, "same.txt")
+		if err := os.WriteFile(path, []byte(dir), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		h.OnCreate(path)
+	}
+	// Close 一次放出全部挂起的文件，由多个 worker 同时移动
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := listDir(t, h.clean)
+	if len(got) != n {
+		t.Fatalf("clean has %d file(s), want %d: %v", len(got), n, got)
+	}
+	seen := map[string]bool{}
+	for _, name := range got {
+		data, err := os.ReadFile(filepath.Join(h.clean, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[string(data)] = true
+	}
+	if len(seen) != n {
+		t.Errorf("clean holds %d distinct file(s), want %d", len(seen), n)
+	}
+	if len(next.paths) != n {
+		t.Errorf("forwarded %d CREATE(s), want %d", len(next.paths), n)
+	}
+}
+
+func TestScanHandlerCloseScansPendingAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h, _, incoming := newTestScanHandler(t, ctx, time.Hour)
+	path := filepath.Join(incoming, "late.txt")
+	writeFile(t, path)
+	h.OnCreate(path)
+
+	// Shutdown 超时时先取消上下文，挂起的文件仍应在 Close 中扫描完
+	cancel()
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, h.clean); len(got) != 1 || got[0] != "late.txt" {
+		t.Errorf("clean = %v, want [late.txt]", got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// selfWriteGrace 自身写入完成后继续识别回声事件的时间，覆盖后端延迟和去抖动
+const selfWriteGrace = 2 * time.Second
+
+// watcherKey 上下文中保存监控器的键，处理器通过 Init 收到的 ctx 使用 SelfWriteContext
+type watcherKey struct{}
+
+// selfMark 一个被标记为自身写入的路径
+type selfMark struct {
+	active int       // 正在进行的写入数
+	done   time.Time // 最近一次写入完成的时间
+}
+
+// selfWrites SelfWrite 标记的路径
+type selfWrites struct {
+	mu    sync.Mutex
+	marks map[string]*selfMark
+}
+
+// SelfWrite 将 fn 对 paths（目录包括其下所有文件）的修改标记为监控器自身的写入：fn 执行期间这些路径上的事件被丢弃，
+// 完成后 selfWriteGrace 内，修改时间不晚于写入完成时间的事件（以及删除事件）同样丢弃，其他进程随后的修改照常上报；
+// 同步、镜像等写入被监控目录的处理器用它避免处理自己的输出，如
+// fw.SelfWrite(func() error { return os.WriteFile(dst, data, 0o644) }, dst)
+// 处理器可以调用自己启动的子进程并把它包在 fn 中；按 PID 识别任意进程的写入需要 fanotify，未实现
+func (fw *FileWatcher) SelfWrite(fn func() error, paths ...string) error {
+	s := &fw.self
+	keys := make([]string, len(paths))
+	s.mu.Lock()
+	if s.marks == nil {
+		s.marks = make(map[string]*selfMark)
+	}
+	for i, path := range paths {
+		keys[i] = absPath(fw.rootPath(path))
+		m := s.marks[keys[i]]
+		if m == nil {
+			m = &selfMark{}
+			s.marks[keys[i]] = m
+		}
+		m.active++
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		now := time.Now()
+		s.mu.Lock()
+		for _, key := range keys {
+			m := s.marks[key]
+			m.active--
+			m.done = now
+		}
+		s.mu.Unlock()
+	}()
+	return fn()
+}
+
+// SelfWriteContext 在处理器中调用 SelfWrite，ctx 为 Init 收到的上下文；ctx 不属于监控器时直接执行 fn
+func SelfWriteContext(ctx context.Context, fn func() error, paths ...string) error {
+	if fw, ok := ctx.Value(watcherKey{}).(*FileWatcher); ok {
+		return fw.SelfWrite(fn, paths...)
+	}
+	return fn()
+}
+
+// selfWritten 判断事件是否由 SelfWrite 标记的写入产生，顺带清理过期标记
+func (fw *FileWatcher) selfWritten(path string) bool {
+	s := &fw.self
+	s.mu.Lock()
+	if len(s.marks) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	abs := absPath(path) // 标记按绝对路径记录，事件路径可能是相对的
+	var done time.Time
+	matched := false
+	for key, m := range s.marks {
+		if m.active == 0 && now.Sub(m.done) > selfWriteGrace {
+			delete(s.marks, key)
+			continue
+		}
+		if !isUnder(key, abs) {
+			continue
+		}
+		if m.active > 0 {
+			s.mu.Unlock()
+			return true
+		}
+		matched = true
+		if m.done.After(done) {
+			done = m.done
+		}
+	}
+	s.mu.Unlock()
+	if !matched {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return true // 自身写入中删除或重命名的路径
+	}
+	return !info.ModTime().After(done)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// ctxHandler 记录 Close 时 Init 收到的上下文是否已被取消
+type ctxHandler struct {
+	NopHandler
+	ctx        context.Context
+	errAtClose error
+}
+
+func (h *ctxHandler) Init(ctx context.Context) error {
+	h.ctx = ctx
+	return nil
+}
+
+func (h *ctxHandler) Close() error {
+	h.errAtClose = h.ctx.Err()
+	return nil
+}
+
+func TestShutdownClosesHandlersBeforeCancel(t *testing.T) {
+	h := &ctxHandler{}
+	fw, err := NewFileWatcher(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Watch(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if h.ctx == nil {
+		t.Fatal("Init was not called")
+	}
+	if h.errAtClose != nil {
+		t.Errorf("context already cancelled when Close ran: %v", h.errAtClose)
+	}
+	if h.ctx.Err() == nil {
+		t.Error("context not cancelled after Shutdown returned")
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"watchdogdemo/handlers"
+)
+
+// Snapshot 目录树在某一时刻的状态：路径、大小、权限和内容哈希
+type Snapshot struct {
+	Root    string          `json:"root"`
+	Taken   time.Time       `json:"taken"`
+	Entries []SnapshotEntry `json:"entries"` // 按路径排序
+}
+
+// SnapshotEntry 快照中的一个文件或目录
+type SnapshotEntry struct {
+	Path    string      `json:"path"` // 相对根目录，使用 / 分隔
+	Dir     bool        `json:"dir,omitempty"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	SHA256  string      `json:"sha256,omitempty"` // 普通文件的内容哈希，不计算哈希时为空
+	Link    string      `json:"link,omitempty"`   // 符号链接的目标
+}
+
+// SnapshotOptions 生成快照的选项
+type SnapshotOptions struct {
+	Exclude []string // 排除模式，语法同 WithExclude
+	NoHash  bool     // 不计算内容哈希，只比较大小和修改时间
+}
+
+// TakeSnapshot 遍历 root 生成快照
+func TakeSnapshot(root string, opts SnapshotOptions) (*Snapshot, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Root: abs, Taken: time.Now()}
+	err = filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == abs {
+			return nil
+		}
+		if excluded(opts.Exclude, abs, path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(abs, path)
+		e := SnapshotEntry{
+			Path:    filepath.ToSlash(rel),
+			Dir:     d.IsDir(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			e.Link, _ = os.Readlink(path)
+		case info.Mode().IsRegular():
+			e.Size = info.Size()
+			if !opts.NoHash {
+				if e.SHA256, err = hashFile(path); err != nil {
+					return err
+				}
+			}
+		}
+		snap.Entries = append(snap.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(snap.Entries, func(i, j int) bool { return snap.Entries[i].Path < snap.Entries[j].Path })
+	return snap, nil
+}
+
+// hashFile 计算文件内容的 SHA-256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadSnapshot 读取 WriteTo 写入的快照
+func LoadSnapshot(path string) (*Snapshot, error) {
+	return loadSnapshot(path, nil)
+}
+
+// loadSnapshot 读取快照，加密写入的快照用 sealer 解密；明文快照（以 { 开头）直接解析，
+// 但设置了密钥时只在 sealer.AcceptPlaintext 开启时接受
+func loadSnapshot(path string, sealer *handlers.Sealer) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if handlers.Plaintext(data) {
+		if sealer != nil && !sealer.AcceptPlaintext {
+			return nil, fmt.Errorf("read snapshot %s: %w", path, handlers.ErrPlaintext)
+		}
+	} else if len(bytes.TrimSpace(data)) > 0 {
+		if data, err = io.ReadAll(sealer.NewReader(bytes.NewReader(data))); err != nil {
+			return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+		}
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// WriteTo 以 JSON 格式写出快照
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// writeSealed 以一行 JSON 写出快照并用 sealer 加密
+func (s *Snapshot) writeSealed(w io.Writer, sealer *handlers.Sealer) error {
+	return json.NewEncoder(sealer.NewWriter(w)).Encode(s)
+}
+
+// hashed 判断快照是否包含内容哈希
+func (s *Snapshot) hashed() bool {
+	for _, e := range s.Entries {
+		if e.SHA256 != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapshotChange 两个快照之间一个路径的变化
+type SnapshotChange struct {
+	Kind string         `json:"kind"` // added、removed、modified（内容或类型变化）、mode（只有权限变化）
+	Path string         `json:"path"`
+	Old  *SnapshotEntry `json:"old,omitempty"`
+	New  *SnapshotEntry `json:"new,omitempty"`
+}
+
+// CompareSnapshots 比较两个快照，返回按路径排序的变化；
+// 两边都有哈希时按内容比较，否则按大小和修改时间比较
+func CompareSnapshots(old, cur *Snapshot) []SnapshotChange {
+	byHash := old.hashed() && cur.hashed()
+	oldIdx := make(map[string]*SnapshotEntry, len(old.Entries))
+	for i := range old.Entries {
+		oldIdx[old.Entries[i].Path] = &old.Entries[i]
+	}
+
+	var changes []SnapshotChange
+	for i := range cur.Entries {
+		n := &cur.Entries[i]
+		o, ok := oldIdx[n.Path]
+		if !ok {
+			changes = append(changes, SnapshotChange{Kind: "added", Path: n.Path, New: n})
+			continue
+		}
+		delete(oldIdx, n.Path)
+		if kind := compareEntry(o, n, byHash); kind != "" {
+			changes = append(changes, SnapshotChange{Kind: kind, Path: n.Path, Old: o, New: n})
+		}
+	}
+	for _, o := range oldIdx {
+		changes = append(changes, SnapshotChange{Kind: "removed", Path: o.Path, Old: o})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// compareEntry 比较同一路径的两个条目，相同时返回空
+func compareEntry(o, n *SnapshotEntry, byHash bool) string {
+	if o.Dir != n.Dir || o.Mode.Type() != n.Mode.Type() || o.Link != n.Link {
+		return "modified"
+	}
+	if !n.Dir {
+		if o.Size != n.Size {
+			return "modified"
+		}
+		if byHash {
+			if o.SHA256 != n.SHA256 {
+				return "modified"
+			}
+		} else if !o.ModTime.Equal(n.ModTime) {
+			return "modified"
+		}
+	}
+	if o.Mode.Perm() != n.Mode.Perm() {
+		return "mode"
+	}
+	return ""
+}
+
+// formatChange 以一行文本描述变化，如 "M  config/app.yaml (120 B -> 98 B)"
+func formatChange(c SnapshotChange) string {
+	switch c.Kind {
+	case "added":
+		return "A  " + c.Path
+	case "removed":
+		return "D  " + c.Path
+	case "mode":
+		return fmt.Sprintf("P  %s (%s -> %s)", c.Path, c.Old.Mode.Perm(), c.New.Mode.Perm())
+	}
+	if c.Old.Size != c.New.Size {
+		return fmt.Sprintf("M  %s (%s -> %s)", c.Path, formatBytes(c.Old.Size), formatBytes(c.New.Size))
+	}
+	return "M  " + c.Path
+}
+
+// runSnapshot snapshot 子命令：记录目录树的路径、大小和哈希
+func runSnapshot(args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	output := fs.String("o", "", "write the snapshot to this file instead of stdout")
+	exclude := fs.String("exclude", "", "comma-separated glob patterns to skip, e.g. node_modules,.git")
+	noHash := fs.Bool("no-hash", false, "skip content hashes and compare by size and modification time only")
+	keyFile := fs.String("encryption-key-file", os.Getenv(envName("encryption-key-file")), "encrypt the snapshot with AES-GCM using the key in this file (16, 24 or 32 bytes, hex or base64); defaults to $WATCHDOG_ENCRYPTION_KEY_FILE or the key in $WATCHDOG_ENCRYPTION_KEY")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s snapshot [flags] [dir]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	root := "."
+	if fs.NArg() > 0 {
+		root = fs.Arg(0)
+	}
+	opts := SnapshotOptions{NoHash: *noHash}
+	if *exclude != "" {
+		opts.Exclude = strings.Split(*exclude, ",")
+	}
+	sealer, err := loadSealer(*keyFile, false)
+	if err != nil {
+		return exitWith(ExitConfig, "%v", err)
+	}
+	snap, err := TakeSnapshot(root, opts)
+	if err != nil {
+		return exitErr(err, "snapshot %s: %v", root, err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return exitErr(err, "failed to create snapshot: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if sealer != nil {
+		err = snap.writeSealed(w, sealer)
+	} else {
+		_, err = snap.WriteTo(w)
+	}
+	if err != nil {
+		return exitErr(err, "write snapshot: %v", err)
+	}
+	if *output != "" {
+		logf("Snapshot of %s: %d entries written to %s", snap.Root, len(snap.Entries), *output)
+	}
+	return ExitOK
+}
+
+// runDiff diff 子命令：比较两个快照，或快照与当前目录树；有差异时退出码为 1，出错时为 2
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	exclude := fs.String("exclude", "", "comma-separated glob patterns to skip when reading a live tree")
+	keyFile := fs.String("encryption-key-file", os.Getenv(envName("encryption-key-file")), "decrypt snapshots written with --encryption-key-file using the key in this file; defaults to $WATCHDOG_ENCRYPTION_KEY_FILE or the key in $WATCHDOG_ENCRYPTION_KEY")
+	acceptPlaintext := fs.Bool("encryption-accept-plaintext", false, "with an encryption key, also accept unencrypted snapshots (rejected by default)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff [flags] old.json (new.json | dir)\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// 与 diff(1) 一致：0 无差异，1 有差异，2 出错
+	fail := func(format string, args ...any) int {
+		return exitWith(2, format, args...)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	sealer, err := loadSealer(*keyFile, *acceptPlaintext)
+	if err != nil {
+		return fail("%v", err)
+	}
+	old, err := loadSnapshot(fs.Arg(0), sealer)
+	if err != nil {
+		return fail("%v", err)
+	}
+	var cur *Snapshot
+	if info, err := os.Stat(fs.Arg(1)); err == nil && info.IsDir() {
+		// 与当前目录树比较，旧快照没有哈希时也不计算哈希
+		opts := SnapshotOptions{NoHash: !old.hashed()}
+		if *exclude != "" {
+			opts.Exclude = strings.Split(*exclude, ",")
+		}
+		if cur, err = TakeSnapshot(fs.Arg(1), opts); err != nil {
+			return fail("snapshot %s: %v", fs.Arg(1), err)
+		}
+	} else if cur, err = loadSnapshot(fs.Arg(1), sealer); err != nil {
+		return fail("%v", err)
+	}
+
+	changes := CompareSnapshots(old, cur)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if changes == nil {
+			changes = []SnapshotChange{}
+		}
+		enc.Encode(changes)
+	} else {
+		for _, c := range changes {
+			fmt.Println(formatChange(c))
+		}
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return ExitOK
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SpecialAction 遇到特殊文件时的处理方式
+type SpecialAction int
+
+const (
+	SpecialReport SpecialAction = iota // 照常分发，Event.Special 注明类型
+	SpecialSkip                        // 忽略，计入统计的 ignored
+	SpecialError                       // 忽略并以 ErrSpecialFile 上报错误
+)
+
+// 特殊文件类型，见 Event.Special
+const (
+	SpecialFIFO   = "fifo"
+	SpecialSocket = "socket"
+	SpecialDevice = "device"
+	SpecialSparse = "sparse"
+)
+
+// SpecialFilePolicy 各类特殊文件的处理方式，零值表示全部照常分发
+type SpecialFilePolicy struct {
+	FIFO   SpecialAction // 命名管道：读取会阻塞到有写入方为止
+	Socket SpecialAction // Unix 域套接字：不能作为文件读取
+	Device SpecialAction // 字符和块设备
+	Sparse SpecialAction // 稀疏文件：占用的磁盘空间小于大小（仅 Unix），复制和哈希会读出大量空洞
+}
+
+// WithSpecialFiles 设置遍历和事件中遇到命名管道、套接字、设备文件和稀疏文件时的处理方式，
+// 避免处理器对非普通文件做读取、哈希等操作而阻塞或出错；启用后 Event.Special 注明事件路径的特殊类型
+func WithSpecialFiles(policy SpecialFilePolicy) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.special = &policy
+	}
+}
+
+// ParseSpecialFilePolicy 解析 "skip" 这样对所有类型生效的处理方式，或逗号分隔的 类型=处理方式，
+// 如 "fifo=skip,socket=skip,device=error,sparse=report"；处理方式为 report、skip 或 error
+func ParseSpecialFilePolicy(s string) (SpecialFilePolicy, error) {
+	var p SpecialFilePolicy
+	if !strings.Contains(s, "=") {
+		action, err := parseSpecialAction(s)
+		if err != nil {
+			return p, err
+		}
+		return SpecialFilePolicy{FIFO: action, Socket: action, Device: action, Sparse: action}, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		kind, name, _ := strings.Cut(strings.TrimSpace(item), "=")
+		action, err := parseSpecialAction(name)
+		if err != nil {
+			return p, err
+		}
+		switch kind {
+		case SpecialFIFO:
+			p.FIFO = action
+		case SpecialSocket:
+			p.Socket = action
+		case SpecialDevice:
+			p.Device = action
+		case SpecialSparse:
+			p.Sparse = action
+		default:
+			return p, fmt.Errorf("unknown special file type %q: want fifo, socket, device or sparse", kind)
+		}
+	}
+	return p, nil
+}
+
+// parseSpecialAction 解析 report、skip 或 error
+func parseSpecialAction(s string) (SpecialAction, error) {
+	switch s {
+	case "report":
+		return SpecialReport, nil
+	case "skip":
+		return SpecialSkip, nil
+	case "error":
+		return SpecialError, nil
+	}
+	return 0, fmt.Errorf("unknown special file action %q: want report, skip or error", s)
+}
+
+// action 返回某类特殊文件的处理方式
+func (p *SpecialFilePolicy) action(kind string) SpecialAction {
+	switch kind {
+	case SpecialFIFO:
+		return p.FIFO
+	case SpecialSocket:
+		return p.Socket
+	case SpecialDevice:
+		return p.Device
+	case SpecialSparse:
+		return p.Sparse
+	}
+	return SpecialReport
+}
+
+// specialKind 返回文件的特殊类型，普通文件、目录和符号链接返回空字符串
+func specialKind(info os.FileInfo) string {
+	mode := info.Mode()
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return SpecialFIFO
+	case mode&os.ModeSocket != 0:
+		return SpecialSocket
+	case mode&os.ModeDevice != 0:
+		return SpecialDevice
+	case mode.IsRegular() && sparse(info):
+		return SpecialSparse
+	}
+	return ""
+}
+
+// allowSpecial 按策略判断路径上的事件或遍历到的文件是否继续处理，info 为 nil 时自行获取；
+// 文件已不存在时返回 true
+func (fw *FileWatcher) allowSpecial(path string, info os.FileInfo) bool {
+	if info == nil {
+		var err error
+		if info, err = os.Lstat(path); err != nil {
+			return true
+		}
+	}
+	kind := specialKind(info)
+	switch fw.special.action(kind) {
+	case SpecialSkip:
+		fw.explainf(path, "ignored: %s file", kind)
+		fw.stats.add(&fw.stats.ignored)
+		return false
+	case SpecialError:
+		fw.explainf(path, "ignored: %s file", kind)
+		fw.stats.add(&fw.stats.ignored)
+		fw.reportError(&WatchError{Path: path, Err: fmt.Errorf("%w: %s", ErrSpecialFile, kind)})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// sparse 当前平台的 FileInfo 不提供分配的块数，不识别稀疏文件
+func sparse(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// sparse 判断文件实际分配的块是否少于大小（有空洞）
+func sparse(info os.FileInfo) bool {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks)*512 < info.Size()
+	}
+	return false
+}
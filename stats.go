@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxStatsPaths 按路径计数的上限，超出后新路径只计入 OtherPaths，避免长期运行时内存无限增长
+const maxStatsPaths = 10000
+
+// Stats 运行以来的事件统计，用于调整去抖动和过滤配置
+type Stats struct {
+	Since         time.Time         `json:"since"`
+	Received      map[string]uint64 `json:"received"`                // 按事件类型统计的原始事件
+	Dispatched    map[string]uint64 `json:"dispatched"`              // 按事件类型统计的分发事件
+	Ignored       uint64            `json:"ignored"`                 // 命中排除模式、隐藏文件等被忽略的原始事件
+	Filtered      uint64            `json:"filtered"`                // 被过滤器、MIME 或去重丢弃的事件
+	Coalesced     uint64            `json:"coalesced"`               // 并入同一路径挂起事件的原始事件
+	Suppressed    uint64            `json:"suppressed"`              // 去抖动窗口内相互抵消的事件（如临时文件）
+	Bulk          uint64            `json:"bulk"`                    // 批量模式期间被汇总的原始事件
+	Muted         uint64            `json:"muted"`                   // 被 Mute 静音丢弃的原始事件
+	Self          uint64            `json:"self"`                    // 识别为 SelfWrite 自身写入而丢弃的原始事件
+	Sampled       uint64            `json:"sampled"`                 // 被采样规则略去的事件
+	BytesWritten  uint64            `json:"bytes_written,omitempty"` // 启用字节统计时写入的字节数
+	BytesByDir    []DirBytes        `json:"bytes_by_dir,omitempty"`  // 启用字节统计时最近一小时写入最多的 10 个目录
+	BytesByFile   []DirBytes        `json:"bytes_by_file,omitempty"` // 启用字节统计时最近一小时写入最多的 10 个文件
+	Forced        uint64            `json:"forced"`                  // 因去抖动定时器超限被提前分发的次数
+	HandlerErrors map[string]uint64 `json:"handler_errors"`          // 按调用类型统计的处理器最终失败次数
+	TopPaths      []PathCount       `json:"top_paths"`               // 原始事件最多的 10 个路径
+	Hot           []HotWindow       `json:"hot"`                     // 最近 1、5、15 分钟内原始事件最多的路径和目录
+	OtherPaths    uint64            `json:"other_paths"`             // 超出计数上限的路径上的原始事件
+}
+
+// PathCount 一个路径的原始事件数
+type PathCount struct {
+	Path   string `json:"path"`
+	Events uint64 `json:"events"`
+}
+
+// statsCollector 事件统计
+type statsCollector struct {
+	mu         sync.Mutex
+	since      time.Time
+	received   map[string]uint64
+	dispatched map[string]uint64
+	errors     map[string]uint64
+	paths      map[string]uint64
+	other      uint64
+	hot        *hotTracker
+	bytes      uint64
+	fileBytes  map[string]*byteCounter
+	dirBytes   map[string]*byteCounter
+
+	ignored, filtered, coalesced, suppressed, bulk, muted, self, sampled uint64
+}
+
+// newStatsCollector 创建事件统计
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		since:      time.Now(),
+		received:   make(map[string]uint64),
+		dispatched: make(map[string]uint64),
+		errors:     make(map[string]uint64),
+		paths:      make(map[string]uint64),
+		hot:        newHotTracker(),
+		fileBytes:  make(map[string]*byteCounter),
+		dirBytes:   make(map[string]*byteCounter),
+	}
+}
+
+// receive 统计一个原始事件
+func (s *statsCollector) receive(event fsnotify.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	countOps(s.received, event.Op)
+	s.hot.observe(event.Name, time.Now())
+	if _, ok := s.paths[event.Name]; ok || len(s.paths) < maxStatsPaths {
+		s.paths[event.Name]++
+	} else {
+		s.other++
+	}
+}
+
+// dispatch 统计一个分发的事件
+func (s *statsCollector) dispatch(op fsnotify.Op) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	countOps(s.dispatched, op)
+}
+
+// handlerError 统计一次处理器最终失败
+func (s *statsCollector) handlerError(op string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[op]++
+}
+
+// add 计数器加一
+func (s *statsCollector) add(counter *uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*counter++
+}
+
+// countOps 按事件类型分别计数
+func countOps(m map[string]uint64, op fsnotify.Op) {
+	for _, o := range knownOps {
+		if op.Has(o) {
+			m[o.String()]++
+		}
+	}
+}
+
+// Stats 返回运行以来的事件统计
+func (fw *FileWatcher) Stats() Stats {
+	s := fw.stats
+	s.mu.Lock()
+	st := Stats{
+		Since:         s.since,
+		Received:      copyCounts(s.received),
+		Dispatched:    copyCounts(s.dispatched),
+		Ignored:       s.ignored,
+		Filtered:      s.filtered,
+		Coalesced:     s.coalesced,
+		Suppressed:    s.suppressed,
+		Bulk:          s.bulk,
+		Muted:         s.muted,
+		Self:          s.self,
+		Sampled:       s.sampled,
+		HandlerErrors: copyCounts(s.errors),
+		OtherPaths:    s.other,
+		BytesWritten:  s.bytes,
+	}
+	if s.bytes > 0 {
+		st.BytesByDir = topBytes(s.dirBytes, byteTopN, time.Now())
+		st.BytesByFile = topBytes(s.fileBytes, byteTopN, time.Now())
+	}
+	st.TopPaths = topCounts(s.paths, 10)
+	st.Hot = s.hot.top(time.Now())
+	s.mu.Unlock()
+
+	if fw.debouncer != nil {
+		st.Forced = fw.debouncer.Forced()
+	}
+	return st
+}
+
+// copyCounts 复制计数表
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// WriteSummary 以文本形式输出统计摘要
+func (s Stats) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "Event summary (%v):\n", time.Since(s.Since).Round(time.Second))
+	fmt.Fprintf(w, "  received:    %s\n", formatCounts(s.Received))
+	fmt.Fprintf(w, "  dispatched:  %s\n", formatCounts(s.Dispatched))
+	fmt.Fprintf(w, "  ignored:     %d\n", s.Ignored)
+	fmt.Fprintf(w, "  filtered:    %d\n", s.Filtered)
+	fmt.Fprintf(w, "  coalesced:   %d\n", s.Coalesced)
+	fmt.Fprintf(w, "  suppressed:  %d\n", s.Suppressed)
+	if s.Bulk > 0 {
+		fmt.Fprintf(w, "  bulk:        %d\n", s.Bulk)
+	}
+	if s.Muted > 0 {
+		fmt.Fprintf(w, "  muted:       %d\n", s.Muted)
+	}
+	if s.Self > 0 {
+		fmt.Fprintf(w, "  self:        %d\n", s.Self)
+	}
+	if s.Sampled > 0 {
+		fmt.Fprintf(w, "  sampled:     %d\n", s.Sampled)
+	}
+	if s.BytesWritten > 0 {
+		fmt.Fprintf(w, "  written:     %s\n", formatBytes(int64(s.BytesWritten)))
+	}
+	if s.Forced > 0 {
+		fmt.Fprintf(w, "  forced:      %d\n", s.Forced)
+	}
+	fmt.Fprintf(w, "  errors:      %s\n", formatCounts(s.HandlerErrors))
+	if len(s.TopPaths) > 0 {
+		fmt.Fprintln(w, "  most active paths:")
+		for _, p := range s.TopPaths {
+			fmt.Fprintf(w, "    %8d  %s\n", p.Events, p.Path)
+		}
+	}
+	if s.OtherPaths > 0 {
+		fmt.Fprintf(w, "  (%d event(s) on paths beyond the tracking limit)\n", s.OtherPaths)
+	}
+	if len(s.Hot) > 0 && len(s.Hot[0].Dirs) > 0 {
+		fmt.Fprintf(w, "  most active directories (last %s):\n", s.Hot[0].Window)
+		for _, p := range s.Hot[0].Dirs {
+			fmt.Fprintf(w, "    %8d  %s\n", p.Events, p.Path)
+		}
+	}
+	if len(s.BytesByDir) > 0 {
+		fmt.Fprintln(w, "  bytes written by directory (last hour / total):")
+		for _, d := range s.BytesByDir {
+			fmt.Fprintf(w, "    %10s / %-10s  %s\n", formatBytes(int64(d.LastHour)), formatBytes(int64(d.Total)), d.Path)
+		}
+	}
+}
+
+// formatCounts 格式化计数表，如 "CREATE 12, WRITE 40"
+func formatCounts(m map[string]uint64) string {
+	if len(m) == 0 {
+		return "0"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, m[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// registerStats 注册 /stats 端点，返回 JSON 格式的事件统计
+func registerStats(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, fw.Stats())
+	})
+}
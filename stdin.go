@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// commandReply --stdin-commands 对一条命令的应答（--pipe 时以 JSON 行写出）
+type commandReply struct {
+	Reply   string `json:"reply"` // ok 或 error
+	Command string `json:"command"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// String 返回文本应答："OK [detail]" 或 "ERR detail"
+func (r commandReply) String() string {
+	if r.Reply == "ok" {
+		return strings.TrimSpace("OK " + r.Detail)
+	}
+	return "ERR " + r.Detail
+}
+
+// serveCommands 从 r 逐行读取命令并执行，每条命令写一行应答，直到 QUIT 或输入结束（父进程退出）后返回：
+//
+//	ADD <path>        监控路径（路径取行内其余部分，可含空格）
+//	REMOVE <path>     注销以 ADD 或命令行参数注册的根路径
+//	PAUSE [duration]  暂停分发，事件保留到 RESUME（或 duration 后自动恢复）
+//	RESUME            恢复分发并补发保留的事件
+//	QUIT              正常关闭
+//
+// 命令不区分大小写，空行和 # 开头的行被忽略；PAUSE/RESUME 作用于所有监控组，ADD/REMOVE 只在没有监控组时可用
+func serveCommands(r io.Reader, reply func(commandReply), watchers []*FileWatcher) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, arg, _ := strings.Cut(line, " ")
+		name, arg = strings.ToUpper(name), strings.TrimSpace(arg)
+		if name == "QUIT" {
+			reply(commandReply{Reply: "ok", Command: name})
+			logf("QUIT received on stdin, stopping")
+			return
+		}
+		detail, err := runCommand(name, arg, watchers)
+		if err != nil {
+			reply(commandReply{Reply: "error", Command: name, Detail: err.Error()})
+			continue
+		}
+		reply(commandReply{Reply: "ok", Command: name, Detail: detail})
+	}
+	logf("stdin closed, stopping")
+}
+
+// runCommand 执行一条命令，返回应答中的说明
+func runCommand(name, arg string, watchers []*FileWatcher) (string, error) {
+	switch name {
+	case "ADD", "REMOVE":
+		if arg == "" {
+			return "", fmt.Errorf("%s requires a path", name)
+		}
+		if len(watchers) != 1 {
+			return "", fmt.Errorf("%s is not available with watch groups", name)
+		}
+		fw := watchers[0]
+		if name == "REMOVE" {
+			if err := fw.Unwatch(arg); err != nil {
+				return "", err
+			}
+			logf("Stopped watching %s", arg)
+			return "", nil
+		}
+		h, err := fw.Watch(arg)
+		if h == nil {
+			return "", err
+		}
+		logf("Watching: %s (recursive: %v)", h.Path(), h.root.recursive)
+		if err != nil {
+			return fmt.Sprintf("%s (partially watched: %v)", h.Path(), err), nil
+		}
+		return h.Path(), nil
+	case "PAUSE":
+		var duration time.Duration
+		if arg != "" {
+			var err error
+			if duration, err = time.ParseDuration(arg); err != nil || duration <= 0 {
+				return "", fmt.Errorf("invalid duration %q", arg)
+			}
+		}
+		for _, fw := range watchers {
+			fw.Pause(duration)
+		}
+		return "", nil
+	case "RESUME":
+		released := 0
+		for _, fw := range watchers {
+			released += fw.Resume()
+		}
+		return fmt.Sprint(released), nil
+	}
+	return "", fmt.Errorf("unknown command %q (want ADD, REMOVE, PAUSE, RESUME or QUIT)", name)
+}
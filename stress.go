@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// stressHandler 统计端到端延迟：从写入文件到处理器收到事件
+type stressHandler struct {
+	mu        sync.Mutex
+	pending   map[string]time.Time // 尚未收到事件的路径 → 最早一次写入时间
+	latencies []time.Duration
+	writes    int // 发出的写入次数
+	coalesced int // 写入时该路径已有未送达的写入，会被合并为一个事件
+	events    int // 收到的事件数
+	stray     int // 没有对应写入的事件（如目录事件）
+}
+
+// wrote 在写入前登记，保证事件不会早于登记到达
+func (h *stressHandler) wrote(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writes++
+	if _, ok := h.pending[path]; ok {
+		h.coalesced++
+		return
+	}
+	h.pending[path] = time.Now()
+}
+
+// OnEvent 实现 EventAwareHandler 接口
+func (h *stressHandler) OnEvent(ev Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events++
+	if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+		return nil
+	}
+	t, ok := h.pending[ev.Path]
+	if !ok {
+		h.stray++
+		return nil
+	}
+	h.latencies = append(h.latencies, time.Since(t))
+	delete(h.pending, ev.Path)
+	return nil
+}
+
+// OnCreate 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *stressHandler) OnCreate(string) error { return nil }
+
+// OnWrite 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *stressHandler) OnWrite(string) error { return nil }
+
+// OnRemove 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *stressHandler) OnRemove(string) error { return nil }
+
+// OnRename 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *stressHandler) OnRename(string) error { return nil }
+
+// OnChmod 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *stressHandler) OnChmod(string) error { return nil }
+
+// runStress stress 子命令：在临时目录中制造文件变化，报告端到端延迟和丢失率
+func runStress(args []string) int {
+	fs := flag.NewFlagSet("stress", flag.ExitOnError)
+	files := fs.Int("files", 100, "number of files to churn")
+	dirs := fs.Int("dirs", 4, "spread files over this many subdirectories")
+	rate := fs.Int("rate", 200, "steady writes per second")
+	burst := fs.Int("burst", 0, "extra writes issued at once every --burst-every")
+	burstEvery := fs.Duration("burst-every", time.Second, "interval between bursts")
+	duration := fs.Duration("duration", 10*time.Second, "how long to generate load")
+	drain := fs.Duration("drain", 2*time.Second, "how long to wait for outstanding events after the load stops")
+	debounce := fs.Duration("debounce", 100*time.Millisecond, "watcher debounce window (0 = disabled)")
+	limit := fs.Int("debounce-limit", DefaultDebounceLimit, "max pending debounce timers (0 = unlimited)")
+	dir := fs.String("dir", "", "directory to churn (default: a new temp directory, removed afterwards)")
+	fs.Parse(args)
+
+	if *files <= 0 || *dirs <= 0 || *rate <= 0 {
+		return exitWith(ExitConfig, "--files, --dirs and --rate must be positive")
+	}
+
+	root := *dir
+	if root == "" {
+		tmp, err := os.MkdirTemp("", "watchdog-stress-")
+		if err != nil {
+			return exitErr(err, "failed to create temp directory: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		root = tmp
+	}
+
+	// 预先创建文件，避免初始创建事件计入统计
+	paths := make([]string, *files)
+	for i := range paths {
+		sub := filepath.Join(root, "d"+strconv.Itoa(i%*dirs))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			return exitErr(err, "failed to create %s: %v", sub, err)
+		}
+		paths[i] = filepath.Join(sub, fmt.Sprintf("f%05d.dat", i))
+		if err := os.WriteFile(paths[i], nil, 0o644); err != nil {
+			return exitErr(err, "failed to create %s: %v", paths[i], err)
+		}
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+
+	h := &stressHandler{pending: make(map[string]time.Time)}
+	watcher, err := NewFileWatcher(h, WithRecursive(true), WithDebounce(*debounce), WithDebounceLimit(*limit))
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+	if _, err := watcher.Watch(root); err != nil {
+		return exitErr(err, "failed to watch path %s: %v", root, err)
+	}
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+
+	logf("stress: %d files in %d dirs, %d writes/s, burst %d every %v, for %v",
+		*files, *dirs, *rate, *burst, *burstEvery, *duration)
+
+	write := func(seq int) {
+		path := paths[rand.Intn(len(paths))]
+		h.wrote(path)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			logf("stress: write %s: %v", path, err)
+			return
+		}
+		fmt.Fprintf(f, "%d\n", seq)
+		f.Close()
+	}
+
+	tick := time.NewTicker(time.Second / time.Duration(*rate))
+	defer tick.Stop()
+	var bursts <-chan time.Time
+	if *burst > 0 && *burstEvery > 0 {
+		bt := time.NewTicker(*burstEvery)
+		defer bt.Stop()
+		bursts = bt.C
+	}
+	deadline := time.After(*duration)
+	start := time.Now()
+	seq := 0
+loop:
+	for {
+		select {
+		case <-tick.C:
+			seq++
+			write(seq)
+		case <-bursts:
+			for i := 0; i < *burst; i++ {
+				seq++
+				write(seq)
+			}
+		case <-deadline:
+			break loop
+		}
+	}
+	elapsed := time.Since(start)
+	time.Sleep(*drain)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	printStressReport(h, elapsed)
+	if watcher.debouncer != nil {
+		fmt.Printf("forced:      %d early dispatches (debounce limit %d)\n", watcher.debouncer.Forced(), *limit)
+	}
+	return ExitOK
+}
+
+// printStressReport 输出压测结果
+func printStressReport(h *stressHandler, elapsed time.Duration) {
+	delivered := len(h.latencies)
+	dropped := len(h.pending)
+	expected := delivered + dropped
+
+	fmt.Printf("writes:      %d (%.0f/s over %v)\n", h.writes, float64(h.writes)/elapsed.Seconds(), elapsed.Round(time.Millisecond))
+	fmt.Printf("coalesced:   %d writes merged into pending events\n", h.coalesced)
+	fmt.Printf("events:      %d received, %d unmatched\n", h.events, h.stray)
+	fmt.Printf("delivered:   %d\n", delivered)
+	if expected > 0 {
+		fmt.Printf("dropped:     %d (%.2f%%)\n", dropped, 100*float64(dropped)/float64(expected))
+	}
+	if delivered == 0 {
+		return
+	}
+
+	sort.Slice(h.latencies, func(i, j int) bool { return h.latencies[i] < h.latencies[j] })
+	pct := func(p float64) time.Duration {
+		return h.latencies[int(p*float64(delivered-1))].Round(10 * time.Microsecond)
+	}
+	fmt.Printf("latency:     p50 %v  p90 %v  p99 %v  max %v\n", pct(0.50), pct(0.90), pct(0.99), pct(1))
+}
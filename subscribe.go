@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventKind 事件类型标记，用作 Subscribe 的类型参数
+type EventKind interface {
+	Created | Written | Removed | Renamed | Chmodded
+	op() fsnotify.Op
+}
+
+// Created 文件或目录创建
+type Created struct{}
+
+// Written 文件写入
+type Written struct{}
+
+// Removed 文件或目录删除
+type Removed struct{}
+
+// Renamed 文件或目录重命名（旧路径）
+type Renamed struct{}
+
+// Chmodded 权限或属性变化
+type Chmodded struct{}
+
+func (Created) op() fsnotify.Op  { return fsnotify.Create }
+func (Written) op() fsnotify.Op  { return fsnotify.Write }
+func (Removed) op() fsnotify.Op  { return fsnotify.Remove }
+func (Renamed) op() fsnotify.Op  { return fsnotify.Rename }
+func (Chmodded) op() fsnotify.Op { return fsnotify.Chmod }
+
+// binding 一个函数订阅
+type binding struct {
+	op      fsnotify.Op
+	pattern string
+	fn      func(Event)
+}
+
+// bindings 按注册顺序保存的函数订阅
+type bindings struct {
+	mu   sync.RWMutex
+	list []*binding
+}
+
+// Subscribe 为 K 类型的事件注册回调，pattern 为空时匹配所有路径，否则按 WithExclude 的语法匹配相对根路径的路径；
+// 回调在分发 goroutine 中同步执行，与处理器收到相同的事件；返回的函数用于取消订阅
+//
+//	cancel := Subscribe[Written](fw, "*.json", func(ev Event) { ... })
+func Subscribe[K EventKind](fw *FileWatcher, pattern string, fn func(Event)) (cancel func()) {
+	var kind K
+	return fw.bind(kind.op(), pattern, fn)
+}
+
+// OnCreate 为匹配 pattern 的创建事件注册回调，见 Subscribe
+func (fw *FileWatcher) OnCreate(pattern string, fn func(Event)) (cancel func()) {
+	return Subscribe[Created](fw, pattern, fn)
+}
+
+// OnWrite 为匹配 pattern 的写入事件注册回调，见 Subscribe
+func (fw *FileWatcher) OnWrite(pattern string, fn func(Event)) (cancel func()) {
+	return Subscribe[Written](fw, pattern, fn)
+}
+
+// OnRemove 为匹配 pattern 的删除事件注册回调，见 Subscribe
+func (fw *FileWatcher) OnRemove(pattern string, fn func(Event)) (cancel func()) {
+	return Subscribe[Removed](fw, pattern, fn)
+}
+
+// OnRename 为匹配 pattern 的重命名事件注册回调，见 Subscribe
+func (fw *FileWatcher) OnRename(pattern string, fn func(Event)) (cancel func()) {
+	return Subscribe[Renamed](fw, pattern, fn)
+}
+
+// OnChmod 为匹配 pattern 的属性变化事件注册回调，见 Subscribe
+func (fw *FileWatcher) OnChmod(pattern string, fn func(Event)) (cancel func()) {
+	return Subscribe[Chmodded](fw, pattern, fn)
+}
+
+// bind 注册函数订阅
+func (fw *FileWatcher) bind(op fsnotify.Op, pattern string, fn func(Event)) func() {
+	b := &binding{op: op, pattern: pattern, fn: fn}
+	fw.bindings.mu.Lock()
+	fw.bindings.list = append(fw.bindings.list, b)
+	fw.bindings.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			fw.bindings.mu.Lock()
+			defer fw.bindings.mu.Unlock()
+			for i, existing := range fw.bindings.list {
+				if existing == b {
+					fw.bindings.list = append(fw.bindings.list[:i:i], fw.bindings.list[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// callBindings 调用与事件匹配的函数订阅
+func (fw *FileWatcher) callBindings(ev Event) {
+	fw.bindings.mu.RLock()
+	list := fw.bindings.list
+	fw.bindings.mu.RUnlock()
+
+	for _, b := range list {
+		if ev.Has(b.op) && fw.matchPath(b.pattern, ev.Path) {
+			fw.explainf(ev.Path, "fires %s binding %q", b.op, b.pattern)
+			b.fn(ev)
+		}
+	}
+}
+
+// matchPath 判断路径是否匹配模式（相对所属根路径，语法同 WithExclude；绝对路径模式按绝对路径匹配），空模式匹配所有路径
+func (fw *FileWatcher) matchPath(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+	target := path
+	if !filepath.IsAbs(pattern) {
+		rel, err := filepath.Rel(fw.rootFor(path).path, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		target = rel
+	}
+	return matchGlob(fw.fold(pattern), fw.fold(target))
+}
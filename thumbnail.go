@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"watchdogdemo/handlers"
+)
+
+// imageSuffixes 生成缩略图的源图片扩展名
+var imageSuffixes = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// ThumbnailSpec 一种输出：长边缩放到 MaxSize（0 表示保持原尺寸，只转换格式），以 Format 编码
+type ThumbnailSpec struct {
+	MaxSize int
+	Format  string // "jpeg" 或 "png"
+}
+
+// String 返回 ParseThumbnailSpecs 可解析的形式，如 "256:jpeg"
+func (s ThumbnailSpec) String() string {
+	return fmt.Sprintf("%d:%s", s.MaxSize, s.Format)
+}
+
+// suffix 输出文件名的后缀，如 "_256.jpg"、"_full.png"
+func (s ThumbnailSpec) suffix() string {
+	size := "full"
+	if s.MaxSize > 0 {
+		size = strconv.Itoa(s.MaxSize)
+	}
+	ext := ".jpg"
+	if s.Format == "png" {
+		ext = ".png"
+	}
+	return "_" + size + ext
+}
+
+// ParseThumbnailSpecs 解析逗号分隔的输出规格 SIZE[:FORMAT]，如 "256,1024:jpeg,0:png"，格式默认 jpeg
+func ParseThumbnailSpecs(s string) ([]ThumbnailSpec, error) {
+	var specs []ThumbnailSpec
+	for _, item := range strings.Split(s, ",") {
+		size, format, _ := strings.Cut(strings.TrimSpace(item), ":")
+		if format == "" {
+			format = "jpeg"
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 || format != "jpeg" && format != "png" {
+			return nil, fmt.Errorf("invalid thumbnail spec %q: want SIZE[:jpeg|png]", item)
+		}
+		specs = append(specs, ThumbnailSpec{MaxSize: n, Format: format})
+	}
+	return specs, nil
+}
+
+// ThumbnailHandler 为 Source 下新建或修改的图片在 Output 下按相同的目录结构生成缩略图和转换格式的副本，
+// 源图片被删除或移走时删除对应的输出；一般通过 NewImagePipeline 与稳定等待、worker 池和账本组合使用
+type ThumbnailHandler struct {
+	Source string
+	Output string
+	Specs  []ThumbnailSpec
+
+	ctx    context.Context
+	dryRun bool
+}
+
+// NewThumbnailHandler 创建缩略图处理器
+func NewThumbnailHandler(source, output string, specs ...ThumbnailSpec) (*ThumbnailHandler, error) {
+	var dirs [2]string
+	for i, dir := range []string{source, output} {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs[i] = abs
+	}
+	if isUnder(dirs[0], dirs[1]) {
+		return nil, fmt.Errorf("thumbnail output %s must not be inside the source %s", output, source)
+	}
+	return &ThumbnailHandler{Source: dirs[0], Output: dirs[1], Specs: specs, ctx: context.Background()}, nil
+}
+
+func (h *ThumbnailHandler) OnCreate(path string) error { return h.generate(path) }
+func (h *ThumbnailHandler) OnWrite(path string) error  { return h.generate(path) }
+func (h *ThumbnailHandler) OnRemove(path string) error { return h.remove(path) }
+func (h *ThumbnailHandler) OnRename(path string) error { return h.remove(path) }
+func (h *ThumbnailHandler) OnChmod(path string) error  { return nil }
+
+// Init 记录上下文，用于演练模式和 SelfWrite
+func (h *ThumbnailHandler) Init(ctx context.Context) error {
+	h.ctx = ctx
+	h.dryRun = IsDryRun(ctx)
+	return nil
+}
+
+// outputs 返回源图片对应的输出路径（与 Specs 一一对应），不是 Source 下的图片时返回 nil
+func (h *ThumbnailHandler) outputs(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil || !isUnder(h.Source, abs) || !isImage(abs) {
+		return nil
+	}
+	rel, err := filepath.Rel(h.Source, abs)
+	if err != nil {
+		return nil
+	}
+	base := filepath.Join(h.Output, strings.TrimSuffix(rel, filepath.Ext(rel)))
+	outs := make([]string, len(h.Specs))
+	for i, spec := range h.Specs {
+		outs[i] = base + spec.suffix()
+	}
+	return outs
+}
+
+// isImage 判断是否为支持的图片扩展名
+func isImage(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, s := range imageSuffixes {
+		if ext == s {
+			return true
+		}
+	}
+	return false
+}
+
+// generate 解码图片并生成每种输出；写入临时文件后改名，读到的始终是完整的输出
+func (h *ThumbnailHandler) generate(path string) error {
+	outs := h.outputs(path)
+	if outs == nil {
+		return nil
+	}
+	if h.dryRun {
+		wouldDo("generate %d thumbnail(s) for %s", len(outs), path)
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	for i, spec := range h.Specs {
+		out := outs[i]
+		img := scaleImage(src, spec.MaxSize)
+		err := SelfWriteContext(h.ctx, func() error {
+			if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+				return err
+			}
+			tmp := out + ".tmp"
+			w, err := os.Create(tmp)
+			if err != nil {
+				return err
+			}
+			if spec.Format == "png" {
+				err = png.Encode(w, img)
+			} else {
+				err = jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+			}
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+			if err == nil {
+				err = os.Rename(tmp, out)
+			}
+			if err != nil {
+				os.Remove(tmp)
+			}
+			return err
+		}, out, out+".tmp")
+		if err != nil {
+			return fmt.Errorf("write %s: %w", out, err)
+		}
+	}
+	logf("thumbnail: %s -> %d file(s) under %s", path, len(outs), h.Output)
+	return nil
+}
+
+// remove 删除源图片对应的输出
+func (h *ThumbnailHandler) remove(path string) error {
+	for _, out := range h.outputs(path) {
+		if h.dryRun {
+			wouldDo("remove %s", out)
+			continue
+		}
+		if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleImage 按区域平均把长边缩小到 maxSize，不放大；maxSize 为 0 时原样返回
+func scaleImage(src image.Image, maxSize int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxSize <= 0 || w <= maxSize && h <= maxSize {
+		return src
+	}
+	dw, dh := maxSize, h*maxSize/w
+	if h > w {
+		dw, dh = w*maxSize/h, maxSize
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// settleHandler 创建/写入事件在同一路径 settle 时间内没有新事件后才转发，删除和重命名立即转发；
+// accept 不为 nil 时只转发它接受的路径
+type settleHandler struct {
+	next      EventHandler
+	debouncer *Debouncer
+	accept    func(path string) bool
+	label     string // 转发失败时日志的前缀
+
+	mu      sync.Mutex
+	created map[string]bool // 等待期间收到过创建事件的路径
+}
+
+func (s *settleHandler) OnCreate(path string) error { s.wait(path, true); return nil }
+func (s *settleHandler) OnWrite(path string) error  { s.wait(path, false); return nil }
+func (s *settleHandler) OnRemove(path string) error { return s.forward(path, s.next.OnRemove) }
+func (s *settleHandler) OnRename(path string) error { return s.forward(path, s.next.OnRename) }
+func (s *settleHandler) OnChmod(path string) error  { return s.forward(path, s.next.OnChmod) }
+
+// forward 立即转发接受的路径
+func (s *settleHandler) forward(path string, fn func(string) error) error {
+	if s.accept != nil && !s.accept(path) {
+		return nil
+	}
+	return fn(path)
+}
+
+// wait 重新计时，稳定后按是否创建过转发为 CREATE 或 WRITE
+func (s *settleHandler) wait(path string, create bool) {
+	if s.accept != nil && !s.accept(path) {
+		return
+	}
+	s.mu.Lock()
+	s.created[path] = s.created[path] || create
+	s.mu.Unlock()
+	s.debouncer.Debounce(path, func() {
+		s.mu.Lock()
+		created := s.created[path]
+		delete(s.created, path)
+		s.mu.Unlock()
+		fn := s.next.OnWrite
+		if created {
+			fn = s.next.OnCreate
+		}
+		if err := fn(path); err != nil {
+			logf("%s: %s: %v", s.label, path, err)
+		}
+	})
+}
+
+// NewSettleHandler 创建/写入事件在同一路径 settle 时间内没有新事件后才转发给 next（等待期间创建过的转发为 CREATE），
+// 删除、重命名和属性变化立即转发；转发返回的错误记录在以 label 开头的日志中
+func NewSettleHandler(next EventHandler, settle time.Duration, label string) EventHandler {
+	return &settleHandler{next: next, debouncer: NewDebouncer(settle), label: label, created: make(map[string]bool)}
+}
+
+// Init 转发给下一个处理器的 Initializer
+func (s *settleHandler) Init(ctx context.Context) error {
+	if initializer, ok := s.next.(Initializer); ok {
+		return initializer.Init(ctx)
+	}
+	return nil
+}
+
+// Close 转发仍在等待的事件，再转发给下一个处理器的 Closer
+func (s *settleHandler) Close() error {
+	s.debouncer.Flush()
+	if closer, ok := s.next.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewImagePipeline 组装完整的图片处理流水线：图片稳定 settle 时间后（settleHandler）交给 workers 个后台 worker
+// （handlers.Async），ledger 非空时跳过内容未变的已处理图片（LedgerHandler），最后由 ThumbnailHandler 生成输出；
+// 同时也是组合这些子系统的示例
+func NewImagePipeline(source, output string, specs []ThumbnailSpec, settle time.Duration, workers int, ledger *Ledger) (EventHandler, error) {
+	thumbs, err := NewThumbnailHandler(source, output, specs...)
+	if err != nil {
+		return nil, err
+	}
+	var h EventHandler = thumbs
+	if ledger != nil {
+		h = NewLedgerHandler(h, ledger)
+	}
+	pool := handlers.Async(h, workers)
+	pool.OnError = func(op fsnotify.Op, path string, err error) {
+		logf("thumbnail: %s: %v", path, err)
+	}
+	return &settleHandler{
+		next:      pool,
+		debouncer: NewDebouncer(settle),
+		accept:    func(path string) bool { return thumbs.outputs(path) != nil },
+		label:     "thumbnail",
+		created:   make(map[string]bool),
+	}, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// 目录树视图中路径的监控状态
+const (
+	TreeWatched   = "watched"   // 目录有原生 watch，或文件所在目录有原生 watch
+	TreePolled    = "polled"    // 由轮询覆盖（watch 预算、网络文件系统）
+	TreeIgnored   = "ignored"   // 被排除模式、隐藏文件等规则忽略
+	TreeUnwatched = "unwatched" // 在根路径下但没有被监控（超出深度、非递归根路径的子目录、添加 watch 失败等）
+)
+
+// treeDefaultLimit /tree 默认最多返回的条目数
+const treeDefaultLimit = 10000
+
+// TreeView 监控器眼中某个路径下的目录树
+type TreeView struct {
+	Root      string      `json:"root"` // 包含该路径的根路径
+	Path      string      `json:"path"`
+	Entries   []TreeEntry `json:"entries"`             // 按遍历顺序（路径字典序）
+	Truncated bool        `json:"truncated,omitempty"` // 达到 limit，后面的条目未列出
+}
+
+// TreeEntry 目录树中的一个路径
+type TreeEntry struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Status  string    `json:"status"` // TreeWatched、TreePolled、TreeIgnored 或 TreeUnwatched
+}
+
+// Tree 列出 path 下的文件和目录及其监控状态，供外部工具核对覆盖范围和同步状态；
+// maxDepth 为相对 path 的最大深度（负数不限），最多返回 limit 条（不为正时不限）。
+// path 不在任何已注册的根路径下时返回 ErrNotWatching
+func (fw *FileWatcher) Tree(path string, maxDepth, limit int) (*TreeView, error) {
+	path = fw.rootPath(path)
+	root := fw.lookupRoot(path)
+	if root == nil {
+		return nil, &WatchError{Path: path, Err: ErrNotWatching}
+	}
+	watched := make(map[string]bool)
+	for _, name := range fw.backend().WatchList() {
+		watched[stripLongPath(name)] = true
+	}
+	view := &TreeView{Root: root.path, Path: path, Entries: []TreeEntry{}}
+	// 目录的状态，文件沿用所在目录的状态
+	dirStatus := func(dir string) string {
+		switch {
+		case watched[dir]:
+			return TreeWatched
+		case fw.poller != nil && fw.poller.covered(root, dir):
+			return TreePolled
+		}
+		return TreeUnwatched
+	}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == path {
+				return err
+			}
+			return nil
+		}
+		if maxDepth >= 0 && depth(path, p) > maxDepth {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if limit > 0 && len(view.Entries) >= limit {
+			view.Truncated = true
+			return filepath.SkipAll
+		}
+		entry := TreeEntry{Path: p, Dir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+		switch {
+		case p != root.path && fw.ignored(root, p):
+			entry.Status = TreeIgnored
+		case info.IsDir():
+			entry.Status = dirStatus(p)
+		default:
+			entry.Status = dirStatus(filepath.Dir(p))
+			if root.file && p != root.path {
+				entry.Status = TreeUnwatched
+			}
+		}
+		view.Entries = append(view.Entries, entry)
+		if info.IsDir() && entry.Status == TreeIgnored {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, classifyWatchError(path, err)
+	}
+	return view, nil
+}
+
+// registerTree 注册 /tree 端点：?path= 指定路径（只有一个根路径时可省略），
+// ?depth= 限制深度，?limit= 限制条目数（默认 10000）；会列出文件，只注册在 --control-socket 的控制接口上
+func registerTree(mux *http.ServeMux, fw *FileWatcher) {
+	mux.HandleFunc("/tree", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		path := q.Get("path")
+		if path == "" {
+			fw.mu.Lock()
+			if len(fw.roots) == 1 {
+				path = fw.roots[0].path
+			}
+			fw.mu.Unlock()
+			if path == "" {
+				http.Error(w, "path is required when watching several roots", http.StatusBadRequest)
+				return
+			}
+		}
+		maxDepth, limit := -1, treeDefaultLimit
+		var err error
+		if s := q.Get("depth"); s != "" {
+			if maxDepth, err = strconv.Atoi(s); err != nil || maxDepth < 0 {
+				http.Error(w, "invalid depth: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		if s := q.Get("limit"); s != "" {
+			if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+				http.Error(w, "invalid limit: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		view, err := fw.Tree(path, maxDepth, limit)
+		switch {
+		case errors.Is(err, ErrNotWatching), errors.Is(err, ErrPathNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, view)
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TruncateHandler 可选接口：区分截断和普通写入的处理器实现此接口
+// 启用截断检测后，文件变小（包括截断为 0）时调用 OnTruncate 而不是 OnWrite
+type TruncateHandler interface {
+	OnTruncate(path string) error
+}
+
+// sizeTracker 记录文件大小，用于识别截断和统计写入的字节数
+type sizeTracker struct {
+	mu         sync.Mutex
+	sizes      map[string]int64
+	truncation bool             // WithTruncateDetection 启用截断检测
+	truncated  map[string]bool  // 已检测到截断、尚未分发的路径
+	accounting bool             // WithByteAccounting 启用字节统计
+	written    map[string]int64 // 已统计、尚未随事件分发的写入字节数
+}
+
+// WithTruncateDetection 跟踪文件大小，文件变小时将写事件标记为截断
+// fsnotify 只会把截断报告为普通 WRITE，日志跟踪等场景需要区别对待
+func WithTruncateDetection() WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.sizeTracker().truncation = true
+	}
+}
+
+// sizeTracker 返回大小跟踪器，没有时创建
+func (fw *FileWatcher) sizeTracker() *sizeTracker {
+	if fw.sizes == nil {
+		fw.sizes = &sizeTracker{
+			sizes:     make(map[string]int64),
+			truncated: make(map[string]bool),
+			written:   make(map[string]int64),
+		}
+	}
+	return fw.sizes
+}
+
+// record 记录文件当前大小（初始遍历时调用）
+func (t *sizeTracker) record(path string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sizes[filepath.Clean(path)] = size
+}
+
+// observe 在原始事件到达时更新大小，在去抖动之前调用以免错过"截断后立即写入"；
+// 启用字节统计时返回本次新统计的写入字节数
+func (t *sizeTracker) observe(event fsnotify.Event) int64 {
+	path := filepath.Clean(event.Name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		delete(t.sizes, path)
+		delete(t.truncated, path)
+		return 0
+	}
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return 0
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	prev, known := t.sizes[path]
+	if t.truncation && known && event.Has(fsnotify.Write) && info.Size() < prev {
+		t.truncated[path] = true
+	}
+	t.sizes[path] = info.Size()
+	return t.account(path, prev, known, info.Size())
+}
+
+// takeTruncated 返回并清除路径的截断标记
+func (t *sizeTracker) takeTruncated(path string) bool {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	truncated := t.truncated[path]
+	delete(t.truncated, path)
+	return truncated
+}
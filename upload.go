@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// uploadPlaceholderWait 零字节文件视为占位文件，需稳定这么多个 settle 时间才当作空文件上传完成
+const uploadPlaceholderWait = 3
+
+// DefaultUploadTempPatterns 常见 FTP/SFTP 服务器和客户端上传时使用的临时文件名：
+// WinSCP、FileZilla 等客户端的 .filepart/.part，Pure-FTPd 的 .pureftpd-upload.*，ProFTPD HiddenStores 的 .in.*
+var DefaultUploadTempPatterns = []string{"*.filepart", "*.part", "*.partial", "*.tmp", ".pureftpd-upload.*", ".in.*"}
+
+// pendingUpload 一个尚未完成的上传
+type pendingUpload struct {
+	ev      Event     // 上传期间合并的事件
+	size    int64     // 上次检查时的大小
+	modTime time.Time // 上次检查时的修改时间
+	stable  int       // 大小和修改时间连续未变化的检查次数
+	timer   *time.Timer
+}
+
+// uploadDetector 识别 FTP/SFTP 上传完成，每个文件只在传输真正结束时分发一次
+type uploadDetector struct {
+	settle   time.Duration
+	patterns []string
+
+	mu      sync.Mutex
+	uploads map[string]*pendingUpload
+}
+
+// WithUploadDetection 为 FTP/SFTP 投递目录识别上传完成：匹配 patterns（为空时使用 DefaultUploadTempPatterns）的
+// 临时文件不分发，改名为正式文件名后按新文件处理；新建或写入的文件暂不分发，直到大小和修改时间 settle 时间内不再变化，
+// 零字节的占位文件要等到开始增长或稳定 3 个 settle 时间。期间的事件合并为一个，上传完成时只分发一次；
+// 上传中途文件被删除或移走时整个上传不产生事件
+func WithUploadDetection(settle time.Duration, patterns ...string) WatcherOption {
+	return func(fw *FileWatcher) {
+		if len(patterns) == 0 {
+			patterns = DefaultUploadTempPatterns
+		}
+		fw.uploads = &uploadDetector{settle: settle, patterns: patterns, uploads: make(map[string]*pendingUpload)}
+	}
+}
+
+// temporary 返回路径匹配的临时文件模式，不匹配时返回空字符串
+func (u *uploadDetector) temporary(fw *FileWatcher, path string) string {
+	for _, p := range u.patterns {
+		if fw.matchPath(p, path) {
+			return p
+		}
+	}
+	return ""
+}
+
+// hold 把创建、写入事件留到上传完成时再分发；文件在上传中途消失时连同之前的事件一起丢弃。
+// 返回 true 表示事件已被接管
+func (u *uploadDetector) hold(fw *FileWatcher, ev Event) bool {
+	path := filepath.Clean(ev.Path)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	p := u.uploads[path]
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		if p == nil {
+			return false
+		}
+		p.timer.Stop()
+		delete(u.uploads, path)
+		fw.explainf(ev.Path, "upload abandoned before completion, dropping its events")
+		return true
+	}
+	if p != nil {
+		p.ev.Op |= ev.Op
+		p.ev.LastSeen = ev.LastSeen
+		p.stable = 0
+		return true
+	}
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	p = &pendingUpload{ev: ev, size: info.Size(), modTime: info.ModTime()}
+	p.timer = time.AfterFunc(u.settle, func() { u.check(fw, path, p) })
+	u.uploads[path] = p
+	fw.explainf(ev.Path, "waiting for the upload to settle for %v", u.settle)
+	return true
+}
+
+// check 检查上传是否完成，完成时分发合并后的事件
+func (u *uploadDetector) check(fw *FileWatcher, path string, p *pendingUpload) {
+	info, err := os.Stat(path)
+
+	u.mu.Lock()
+	if u.uploads[path] != p {
+		u.mu.Unlock()
+		return
+	}
+	if err != nil || !info.Mode().IsRegular() {
+		// 消失但还没收到删除事件，等删除事件丢弃
+		p.timer.Reset(u.settle)
+		u.mu.Unlock()
+		return
+	}
+	if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+		p.size, p.modTime, p.stable = info.Size(), info.ModTime(), 0
+	} else {
+		p.stable++
+	}
+	need := 1
+	if p.size == 0 {
+		need = uploadPlaceholderWait
+	}
+	if p.stable < need {
+		p.timer.Reset(u.settle)
+		u.mu.Unlock()
+		return
+	}
+	delete(u.uploads, path)
+	u.mu.Unlock()
+
+	fw.explainf(path, "upload complete at %s", formatBytes(p.size))
+	fw.dispatchReady(p.ev)
+}
+
+// drain 停止并移除所有未完成的上传
+func (u *uploadDetector) drain() []Event {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	events := make([]Event, 0, len(u.uploads))
+	for path, p := range u.uploads {
+		p.timer.Stop()
+		events = append(events, p.ev)
+		delete(u.uploads, path)
+	}
+	return events
+}
+
+// drainUploads 停止时按 WithFlushOnStop 分发或丢弃尚未完成的上传
+func (fw *FileWatcher) drainUploads() {
+	if fw.uploads == nil {
+		return
+	}
+	events := fw.uploads.drain()
+	if len(events) == 0 {
+		return
+	}
+	if !fw.flushOnStop {
+		logf("Discarded %d unfinished upload(s)", len(events))
+		return
+	}
+	for _, ev := range events {
+		fw.dispatchReady(ev)
+	}
+	logf("Flushed %d unfinished upload(s)", len(events))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ConfigError 监控器配置非法
+type ConfigError struct {
+	Problems []string // 所有发现的配置问题
+}
+
+func (e *ConfigError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid watcher config: " + e.Problems[0]
+	}
+	return fmt.Sprintf("invalid watcher config: %d problems: %v", len(e.Problems), e.Problems)
+}
+
+// validate 检查应用选项后的配置，一次性报告所有问题
+func (fw *FileWatcher) validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if fw.handler == nil {
+		addf("handler must not be nil")
+	}
+	for i, r := range fw.routes[1:] {
+		if r.handler == nil {
+			addf("handler #%d registered with WithHandler must not be nil", i+1)
+		}
+		if r.ops != 0 && r.ops&allOps == 0 {
+			addf("op filter %s of handler #%d matches no known event type", r.ops, i+1)
+		}
+	}
+	for i, r := range fw.routes {
+		for _, pattern := range append(r.include[:len(r.include):len(r.include)], r.exclude...) {
+			if pattern == "" || !validGlob(pattern) {
+				addf("invalid path pattern %q for handler #%d", pattern, i)
+			}
+		}
+	}
+	if fw.debouncer != nil && fw.debouncer.duration < 0 {
+		addf("debounce duration must not be negative, got %s", fw.debouncer.duration)
+	}
+	if d := fw.debouncer; d != nil && d.adaptive != nil {
+		if d.adaptive.min <= 0 {
+			addf("adaptive debounce min must be positive, got %s", d.adaptive.min)
+		}
+		if d.adaptive.max <= d.adaptive.min {
+			addf("adaptive debounce max %s must exceed min %s", d.adaptive.max, d.adaptive.min)
+		}
+	}
+	if fw.dirs != nil {
+		if fw.debouncer == nil {
+			addf("directory coalescing requires debounce")
+		}
+		if fw.dirs.window <= 0 {
+			addf("directory window must be positive, got %s", fw.dirs.window)
+		}
+		if fw.dirs.threshold < 1 {
+			addf("directory coalescing threshold must be at least 1, got %d", fw.dirs.threshold)
+		}
+	}
+	if fw.sampler != nil {
+		for _, p := range fw.sampler.policies {
+			if p.Pattern == "" || !validGlob(p.Pattern) {
+				addf("invalid sample pattern %q", p.Pattern)
+			}
+			if p.EveryN < 0 || p.PerSecond < 0 || p.EveryN == 0 && p.PerSecond == 0 {
+				addf("sample policy for %q must keep 1 in N or at most M per second", p.Pattern)
+			}
+		}
+	}
+	if fw.progress != nil {
+		if fw.progress.interval <= 0 {
+			addf("progress interval must be positive, got %s", fw.progress.interval)
+		}
+		if fw.progress.settle < fw.progress.interval {
+			addf("progress settle time %s must not be shorter than the interval %s", fw.progress.settle, fw.progress.interval)
+		}
+		if fw.progress.minSize < 0 {
+			addf("progress minimum size must not be negative, got %d", fw.progress.minSize)
+		}
+	}
+	if fw.uploads != nil {
+		if fw.uploads.settle <= 0 {
+			addf("upload settle time must be positive, got %s", fw.uploads.settle)
+		}
+		for _, p := range fw.uploads.patterns {
+			if p == "" || !validGlob(p) {
+				addf("invalid upload temp pattern %q", p)
+			}
+		}
+	}
+	if fw.latency.budget < 0 {
+		addf("latency budget must not be negative, got %s", fw.latency.budget)
+	}
+	if fw.observer != nil && fw.observer.window < time.Second {
+		addf("observe window must be at least 1s, got %s", fw.observer.window)
+	}
+	if fw.debounceLimit < 0 {
+		addf("debounce limit must not be negative, got %d", fw.debounceLimit)
+	}
+
+	r := fw.retry
+	if r.MaxAttempts < 0 {
+		addf("retry max attempts must not be negative, got %d", r.MaxAttempts)
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		addf("retry backoff must not be negative")
+	}
+	if r.MaxBackoff > 0 && r.InitialBackoff > r.MaxBackoff {
+		addf("retry initial backoff %s exceeds max backoff %s", r.InitialBackoff, r.MaxBackoff)
+	}
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		addf("retry multiplier must be 0 (default 2) or at least 1, got %g", r.Multiplier)
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		addf("retry jitter must be within [0, 1], got %g", r.Jitter)
+	}
+
+	if fw.opMask != nil && *fw.opMask == 0 {
+		addf("op filter is empty and would drop every event")
+	} else if fw.opMask != nil && *fw.opMask&allOps == 0 {
+		addf("op filter %s matches no known event type", *fw.opMask)
+	}
+	if fw.extensions != nil && len(fw.extensions) == 0 {
+		addf("extension filter is empty and would drop every event")
+	}
+	if fw.minSize < 0 || fw.maxSize < 0 {
+		addf("size limits must not be negative")
+	}
+	if fw.maxSize > 0 && fw.minSize > fw.maxSize {
+		addf("min size %d exceeds max size %d", fw.minSize, fw.maxSize)
+	}
+
+	for _, pattern := range fw.excludes {
+		if pattern == "" || !validGlob(pattern) {
+			addf("invalid exclude pattern %q", pattern)
+		}
+	}
+
+	for _, pattern := range fw.priority {
+		if pattern == "" || !validGlob(pattern) {
+			addf("invalid priority pattern %q", pattern)
+		}
+	}
+
+	if fw.quota != nil {
+		for _, st := range fw.quota.states {
+			if st.rule.Limit <= 0 {
+				addf("quota limit for %s must be positive", st.rule.Dir)
+			}
+		}
+	}
+
+	if fw.budget != nil && fw.budget.max <= 0 {
+		addf("watch budget must be positive")
+	}
+	if fw.container < 0 {
+		addf("container reconcile interval must not be negative")
+	}
+	if fw.poller != nil && fw.poller.interval <= 0 {
+		addf("poll interval must be positive")
+	}
+	if fw.retention != nil {
+		for _, rule := range fw.retention.rules {
+			if rule.MaxAge < 0 || rule.KeepNewest < 0 {
+				addf("retention limits for %s must not be negative", rule.Dir)
+			}
+			if rule.MaxAge == 0 && rule.KeepNewest == 0 {
+				addf("retention rule for %s needs a max age or a keep count", rule.Dir)
+			}
+			if rule.ArchiveDir != "" && filepath.Clean(rule.ArchiveDir) == rule.Dir {
+				addf("retention archive dir must differ from %s", rule.Dir)
+			}
+		}
+	}
+
+	if fw.dedupe != nil && fw.dedupe.window <= 0 {
+		addf("dedupe window must be positive, got %s", fw.dedupe.window)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: problems}
+}
+
+// IsConfigError 判断错误是否由非法配置引起
+func IsConfigError(err error) bool {
+	var ce *ConfigError
+	return errors.As(err, &ce)
+}
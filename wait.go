@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// waitHandler 等待第一个匹配的事件
+type waitHandler struct {
+	patterns []string // 绝对路径模式
+	ops      fsnotify.Op
+	matched  chan Event
+}
+
+// OnEvent 实现 EventAwareHandler 接口
+func (h *waitHandler) OnEvent(ev Event) error {
+	if ev.Op&h.ops == 0 {
+		return nil
+	}
+	for _, pattern := range h.patterns {
+		if matchGlob(pattern, ev.Path) {
+			select {
+			case h.matched <- ev:
+			default: // 已有匹配，等待退出
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// OnCreate 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *waitHandler) OnCreate(string) error { return nil }
+
+// OnWrite 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *waitHandler) OnWrite(string) error { return nil }
+
+// OnRemove 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *waitHandler) OnRemove(string) error { return nil }
+
+// OnRename 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *waitHandler) OnRename(string) error { return nil }
+
+// OnChmod 实现 EventHandler 接口（由 OnEvent 处理）
+func (h *waitHandler) OnChmod(string) error { return nil }
+
+// waitBase 返回需要监控的目录：模式中不含通配符的前缀目录，不存在时取最近的已存在的上级目录
+func waitBase(pattern string) string {
+	var literal []string
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if hasGlobMeta(segment) {
+			break
+		}
+		literal = append(literal, segment)
+	}
+	if len(literal) == len(strings.Split(filepath.ToSlash(pattern), "/")) {
+		literal = literal[:len(literal)-1] // 完整路径：监控所在目录，等待文件出现
+	}
+	dir := filepath.FromSlash(strings.Join(literal, "/"))
+	if dir == "" {
+		dir = string(filepath.Separator)
+	}
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// runWait wait 子命令：阻塞直到出现匹配的事件后退出（退出码 0），超时退出码为 ExitTimeout，
+// 取代 shell 脚本和 CI 中轮询文件是否出现的 sleep 循环
+func runWait(args []string) int {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	forOps := fs.String("for", "create,write", "comma-separated ops to wait for (create, write, remove, rename, chmod)")
+	timeout := fs.Duration("timeout", 0, "give up after this long (0 = wait forever)")
+	debounce := fs.Duration("debounce", 100*time.Millisecond, "coalesce events on the same path within this window, so a file written in chunks matches once it settles (0 = disabled)")
+	quiet := fs.Bool("quiet", false, "do not print the matched event")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s wait [flags] pattern...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(fs.Output(), "Patterns are paths that may contain *, ? and ** (e.g. 'build/output.bin', 'dist/**/*.js').\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitConfig
+	}
+	ops, err := ParseOps(*forOps)
+	if err != nil || ops == 0 {
+		return exitWith(ExitConfig, "invalid --for %q: %v", *forOps, err)
+	}
+	if *timeout < 0 || *debounce < 0 {
+		return exitWith(ExitConfig, "--timeout and --debounce must not be negative")
+	}
+
+	h := &waitHandler{ops: ops, matched: make(chan Event, 1)}
+	var bases []string
+	for _, pattern := range fs.Args() {
+		if !validGlob(pattern) {
+			return exitWith(ExitConfig, "invalid pattern %q", pattern)
+		}
+		abs, err := filepath.Abs(pattern)
+		if err != nil {
+			return exitErr(err, "invalid pattern %q: %v", pattern, err)
+		}
+		h.patterns = append(h.patterns, abs)
+		bases = append(bases, waitBase(abs))
+	}
+	// 只监控最外层的目录，内层目录已被递归覆盖
+	sort.Strings(bases)
+	var roots []string
+	for _, base := range bases {
+		if len(roots) > 0 && isUnder(roots[len(roots)-1], base) {
+			continue
+		}
+		roots = append(roots, base)
+	}
+
+	watcher, err := NewFileWatcher(h, WithRecursive(true), WithDebounce(*debounce))
+	if err != nil {
+		return exitErr(err, "failed to create watcher: %v", err)
+	}
+	defer watcher.Stop()
+	for _, root := range roots {
+		if _, err := watcher.Watch(root); err != nil {
+			return exitErr(err, "failed to watch path %s: %v", root, err)
+		}
+	}
+	if err := watcher.Start(); err != nil {
+		return exitErr(err, "failed to start watcher: %v", err)
+	}
+
+	var expired <-chan time.Time
+	if *timeout > 0 {
+		timer := time.NewTimer(*timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	select {
+	case ev := <-h.matched:
+		if !*quiet {
+			fmt.Printf("%s %s\n", formatOps(ev.Op), displayPath(ev.Path))
+		}
+		return ExitOK
+	case <-expired:
+		return exitWith(ExitTimeout, "timed out after %s waiting for %s", *timeout, strings.Join(fs.Args(), ", "))
+	case <-watcher.Failed():
+		return exitWith(ExitBackend, "%v", ErrBackendFailed)
+	case <-sigChan:
+		return exitWith(ExitFailure, "interrupted before a matching event")
+	}
+}
+
+// displayPath 返回相对当前目录的路径，不在当前目录下时返回原路径
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,13 @@
+package main
+
+// WalkProgressFunc 递归注册的进度回调：dirsAdded 为已添加 watch 的目录数，filesSeen 为遍历到的文件数，
+// current 为刚添加的目录，遍历结束时以空字符串再调用一次
+type WalkProgressFunc func(dirsAdded, filesSeen int, current string)
+
+// WithWalkProgress 在递归注册（Watch、Update 和后端重启后的重新注册）期间每添加一个目录调用一次 fn，
+// 供命令行或界面在注册几十万个目录时显示进度，而不是看起来像卡住；fn 在遍历的 goroutine 中同步调用，应尽快返回
+func WithWalkProgress(fn WalkProgressFunc) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.walkProgress = fn
+	}
+}
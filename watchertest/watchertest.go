@@ -0,0 +1,193 @@
+// Package watchertest 提供内存中的假监控器，用于在单元测试中驱动事件处理器，
+// 无需创建临时目录，也无需 sleep 等待文件系统事件
+//
+// Handler 与 watchdogdemo 的 EventHandler 方法集相同，任何 EventHandler 实现都可以直接传入：
+//
+//	w := watchertest.New(myHandler)
+//	w.Create("/data/in.csv")
+//	w.Write("/data/in.csv")
+//	if err := w.Err(); err != nil { t.Fatal(err) }
+//
+// 只关心处理器被如何调用时，可以用 Recorder 作为处理器并断言调用序列
+//
+// 假监控器只验证事件类型到处理方法的路由：它不经过 watchdogdemo 的真实分发器（watchdogdemo 是 main 包，无法被导入），
+// 因此不会去抖动、合并、规范化或过滤事件，不会按 EventAwareHandler、TruncateHandler 等扩展接口分发，
+// 也不会重试或调用 Init/Close。这些行为需要在真实目录上运行 FileWatcher 来测试
+package watchertest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Handler 与 watchdogdemo 的 EventHandler 方法集一致
+type Handler interface {
+	OnCreate(path string) error
+	OnWrite(path string) error
+	OnRemove(path string) error
+	OnRename(path string) error
+	OnChmod(path string) error
+}
+
+// Call 一次处理器调用
+type Call struct {
+	Op   string // CREATE、WRITE、REMOVE、RENAME、CHMOD
+	Path string
+}
+
+func (c Call) String() string {
+	return c.Op + " " + c.Path
+}
+
+// Watcher 内存中的假监控器，注入的事件原样、同步地交给处理器的 OnXxx 方法（不经过真实分发器）
+type Watcher struct {
+	handler Handler
+
+	mu     sync.Mutex
+	calls  []Call
+	errors []error
+}
+
+// New 创建假监控器
+func New(handler Handler) *Watcher {
+	return &Watcher{handler: handler}
+}
+
+// Emit 注入一个事件，按真实分发器调用处理方法的顺序（CREATE、WRITE、REMOVE、RENAME、CHMOD）
+// 对 op 中的每一位调用一次，不做任何去抖动或过滤；处理方法返回的错误会被记录，可通过 Errors/Err 读取
+func (w *Watcher) Emit(path string, op fsnotify.Op) {
+	dispatch := []struct {
+		op   fsnotify.Op
+		name string
+		fn   func(string) error
+	}{
+		{fsnotify.Create, "CREATE", w.handler.OnCreate},
+		{fsnotify.Write, "WRITE", w.handler.OnWrite},
+		{fsnotify.Remove, "REMOVE", w.handler.OnRemove},
+		{fsnotify.Rename, "RENAME", w.handler.OnRename},
+		{fsnotify.Chmod, "CHMOD", w.handler.OnChmod},
+	}
+	for _, d := range dispatch {
+		if !op.Has(d.op) {
+			continue
+		}
+		err := d.fn(path)
+
+		w.mu.Lock()
+		w.calls = append(w.calls, Call{Op: d.name, Path: path})
+		if err != nil {
+			w.errors = append(w.errors, fmt.Errorf("handler %s failed for %s: %w", d.name, path, err))
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Create 注入 CREATE 事件
+func (w *Watcher) Create(path string) { w.Emit(path, fsnotify.Create) }
+
+// Write 注入 WRITE 事件
+func (w *Watcher) Write(path string) { w.Emit(path, fsnotify.Write) }
+
+// Remove 注入 REMOVE 事件
+func (w *Watcher) Remove(path string) { w.Emit(path, fsnotify.Remove) }
+
+// Rename 注入 RENAME 事件
+func (w *Watcher) Rename(path string) { w.Emit(path, fsnotify.Rename) }
+
+// Chmod 注入 CHMOD 事件
+func (w *Watcher) Chmod(path string) { w.Emit(path, fsnotify.Chmod) }
+
+// Calls 返回已分发的处理器调用
+func (w *Watcher) Calls() []Call {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Call(nil), w.calls...)
+}
+
+// Errors 返回处理方法返回的所有错误
+func (w *Watcher) Errors() []error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]error(nil), w.errors...)
+}
+
+// Err 将所有处理错误合并为一个，没有错误时返回 nil
+func (w *Watcher) Err() error {
+	return errors.Join(w.Errors()...)
+}
+
+// Recorder 记录所有调用的处理器，可选地为指定调用返回错误
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+	fail  map[Call]error
+}
+
+// FailOn 让 op（如 "WRITE"）作用于 path 的调用返回 err
+func (r *Recorder) FailOn(op, path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail == nil {
+		r.fail = make(map[Call]error)
+	}
+	r.fail[Call{Op: op, Path: path}] = err
+}
+
+func (r *Recorder) record(op, path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := Call{Op: op, Path: path}
+	r.calls = append(r.calls, c)
+	return r.fail[c]
+}
+
+// OnCreate 实现 Handler 接口
+func (r *Recorder) OnCreate(path string) error { return r.record("CREATE", path) }
+
+// OnWrite 实现 Handler 接口
+func (r *Recorder) OnWrite(path string) error { return r.record("WRITE", path) }
+
+// OnRemove 实现 Handler 接口
+func (r *Recorder) OnRemove(path string) error { return r.record("REMOVE", path) }
+
+// OnRename 实现 Handler 接口
+func (r *Recorder) OnRename(path string) error { return r.record("RENAME", path) }
+
+// OnChmod 实现 Handler 接口
+func (r *Recorder) OnChmod(path string) error { return r.record("CHMOD", path) }
+
+// Calls 返回记录的调用
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Reset 清空记录的调用
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// AssertCalls 断言记录的调用与 want 完全一致（顺序相关），want 的格式为 "WRITE /path"
+func (r *Recorder) AssertCalls(t testing.TB, want ...string) {
+	t.Helper()
+	calls := r.Calls()
+	got := make([]string, len(calls))
+	for i, c := range calls {
+		got[i] = c.String()
+	}
+	if len(want) == 0 {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler calls mismatch\n got: %s\nwant: %s", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+}
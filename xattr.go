@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// errXattrUnsupported 当前平台不支持读取扩展属性
+var errXattrUnsupported = errors.New("extended attributes not supported on this platform")
+
+// XattrChange 扩展属性的变化，Old/New 为变化前后的完整属性集
+type XattrChange struct {
+	Old map[string][]byte
+	New map[string][]byte
+}
+
+// Keys 返回新增、删除或值发生变化的属性名（已排序）
+func (c XattrChange) Keys() []string {
+	var keys []string
+	for k, v := range c.New {
+		if old, ok := c.Old[k]; !ok || !bytes.Equal(old, v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range c.Old {
+		if _, ok := c.New[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AttribHandler 可选接口：关心扩展属性（xattr）变化的处理器实现此接口
+// 启用 xattr 监控后，xattr 变化时调用 OnAttrib 而不是 OnChmod
+type AttribHandler interface {
+	OnAttrib(path string, change XattrChange) error
+}
+
+// xattrTracker 缓存文件的扩展属性
+type xattrTracker struct {
+	mu       sync.Mutex
+	interval time.Duration // 周期性复查间隔，0 表示只依赖后端事件
+	values   map[string]map[string][]byte
+	pending  map[string]*XattrChange
+}
+
+// WithXattrMonitoring 监控文件扩展属性（Linux/macOS）的变化并通过 OnAttrib 上报
+// inotify/kqueue 会把 xattr 修改报告为 CHMOD，此时读取并对比属性；
+// interval > 0 时还会周期性复查已跟踪的文件，弥补不报告 xattr 变化的后端
+func WithXattrMonitoring(interval time.Duration) WatcherOption {
+	return func(fw *FileWatcher) {
+		fw.xattrs = &xattrTracker{
+			interval: interval,
+			values:   make(map[string]map[string][]byte),
+			pending:  make(map[string]*XattrChange),
+		}
+	}
+}
+
+// record 记录文件当前的扩展属性（初始遍历时调用）
+func (t *xattrTracker) record(path string) {
+	values, err := readXattrs(path)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.values[filepath.Clean(path)] = values
+}
+
+// observe 在原始事件到达时对比扩展属性，发现变化时记录待分发的变化
+func (t *xattrTracker) observe(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		t.mu.Lock()
+		delete(t.values, path)
+		delete(t.pending, path)
+		t.mu.Unlock()
+		return
+	}
+	if !event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Create) {
+		return
+	}
+
+	values, err := readXattrs(path)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, known := t.values[path]
+	t.values[path] = values
+	if !known || xattrsEqual(prev, values) {
+		return
+	}
+	if change, ok := t.pending[path]; ok {
+		change.New = values
+		return
+	}
+	t.pending[path] = &XattrChange{Old: prev, New: values}
+}
+
+// take 返回并清除路径待分发的扩展属性变化
+func (t *xattrTracker) take(path string) *XattrChange {
+	path = filepath.Clean(path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	change := t.pending[path]
+	delete(t.pending, path)
+	return change
+}
+
+// changed 返回扩展属性与缓存不一致的已跟踪路径
+func (t *xattrTracker) changed() []string {
+	t.mu.Lock()
+	paths := make([]string, 0, len(t.values))
+	for path := range t.values {
+		paths = append(paths, path)
+	}
+	t.mu.Unlock()
+
+	var result []string
+	for _, path := range paths {
+		values, err := readXattrs(path)
+		if err != nil {
+			continue
+		}
+		t.mu.Lock()
+		prev := t.values[path]
+		t.mu.Unlock()
+		if !xattrsEqual(prev, values) {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// pollXattrs 周期性复查扩展属性，发现变化时注入合成的 CHMOD 事件
+func (fw *FileWatcher) pollXattrs() {
+	ticker := time.NewTicker(fw.xattrs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fw.done:
+			return
+		case <-ticker.C:
+			for _, path := range fw.xattrs.changed() {
+				fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+			}
+		}
+	}
+}
+
+// xattrsEqual 比较两组扩展属性
+func xattrsEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// warnXattrUnsupported 在不支持的平台上提示一次
+func warnXattrUnsupported() {
+	if _, err := readXattrs(os.TempDir()); errors.Is(err, errXattrUnsupported) {
+		logf("xattr monitoring requested but %v", err)
+	}
+}
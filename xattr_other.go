@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// readXattrs 当前平台不支持扩展属性
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, errXattrUnsupported
+}
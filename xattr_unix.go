@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs 读取文件的全部扩展属性
+func readXattrs(path string) (map[string][]byte, error) {
+	size, err := unix.Listxattr(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte)
+	if size == 0 {
+		return values, nil
+	}
+
+	buf := make([]byte, size)
+	if size, err = unix.Listxattr(path, buf); err != nil {
+		return nil, err
+	}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		key := string(name)
+		n, err := unix.Getxattr(path, key, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n, err = unix.Getxattr(path, key, value); err != nil {
+			continue
+		}
+		values[key] = value[:n]
+	}
+	return values, nil
+}